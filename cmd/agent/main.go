@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/benlocal/lai-panel/pkg/api"
 	"github.com/benlocal/lai-panel/pkg/handler"
@@ -39,8 +40,13 @@ var (
 
 	masterHost string
 	masterPort int
+	masterTLS  bool
 	name       string
 	address    string
+	port       int
+	tunnel     bool
+
+	metricsInterval time.Duration
 )
 
 func main() {
@@ -53,7 +59,12 @@ func main() {
 func init() {
 	api.DefaultRegistry.Add(func(h *handler.BaseHandler, router *route.Engine) {
 		router.Handle("GET", "/healthz", h.HandleHealthz)
-		router.Any("/docker.proxy/*path", h.HandleDockerProxy)
+		// only the master may drive docker and the node
+		router.Any("/docker.proxy/*path", h.AgentAuth, h.HandleDockerProxy)
+		exec := router.Group("/node.exec", h.AgentAuth)
+		exec.GET("/file", h.HandleNodeExecReadFile)
+		exec.POST("/file", h.HandleNodeExecWriteFile)
+		exec.POST("/command", h.HandleNodeExecCommand)
 
 		api := router.Group("/open")
 		// static files
@@ -70,16 +81,24 @@ func init() {
 
 	runCmd.Flags().StringVar(&masterHost, "master-host", "localhost", "master host")
 	runCmd.Flags().IntVar(&masterPort, "master-port", 8080, "master port")
+	runCmd.Flags().BoolVar(&masterTLS, "master-tls", false, "reach the master over https and wss, the agent token is sent in cleartext otherwise")
 	runCmd.Flags().StringVar(&name, "name", "", "name")
 	runCmd.Flags().StringVar(&address, "address", "", "address")
+	runCmd.Flags().IntVar(&port, "port", 8081, "agent port")
+	runCmd.Flags().BoolVar(&tunnel, "tunnel", false, "dial the master and serve the agent through that connection, for nodes behind a nat")
+	runCmd.Flags().DurationVar(&metricsInterval, "metrics-interval", options.DefaultMetricsInterval, "interval of the node metrics, 0 disables them")
 }
 
 func runAgent(_ *cobra.Command) error {
 	op := options.NewAgentOptions(
 		options.WithMasterHost(masterHost),
 		options.WithMasterPort(masterPort),
+		options.WithMasterTLS(masterTLS),
 		options.WithName(name),
 		options.WithAddress(address),
+		options.WithAgentPort(port),
+		options.WithMetricsInterval(metricsInterval),
+		options.WithTunnel(tunnel),
 	)
 
 	runtime := NewAgentRuntime(op)
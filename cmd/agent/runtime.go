@@ -10,6 +10,7 @@ import (
 	"github.com/benlocal/lai-panel/pkg/docker"
 	"github.com/benlocal/lai-panel/pkg/gracefulshutdown"
 	"github.com/benlocal/lai-panel/pkg/handler"
+	"github.com/benlocal/lai-panel/pkg/metrics"
 	"github.com/benlocal/lai-panel/pkg/options"
 	"github.com/benlocal/lai-panel/pkg/service"
 	"github.com/docker/docker/client"
@@ -39,7 +40,7 @@ func (r *AgentRuntime) Start() error {
 		return err
 	}
 	dp, _ := docker.NewDockerProxy(dh, "/docker.proxy")
-	baseClient := myClient.NewBaseClient()
+	baseClient := myClient.NewBaseClient(r.op.MasterTLS())
 
 	appCtx, err := ctx.NewAppCtx(r.op, dp)
 	if err != nil {
@@ -62,6 +63,15 @@ func (r *AgentRuntime) Start() error {
 	registryService := service.NewRemoteRegistryService(baseClient)
 	g.Add(registryService)
 
+	if r.op.Tunnel {
+		tunnelService := service.NewTunnelService()
+		g.Add(tunnelService)
+	}
+
+	collector := metrics.NewCollector(r.op.DataPath(), localDockerClient)
+	metricsService := service.NewRemoteMetricsService(baseClient, collector, r.op.MetricsInterval)
+	g.Add(metricsService)
+
 	log.Println("start agent server on port", r.op.Port, "with name", r.op.Name)
 
 	ctx := context.Background()
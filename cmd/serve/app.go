@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/bundle"
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/spf13/cobra"
+)
+
+var (
+	appCmd = &cobra.Command{
+		Use:   "app",
+		Short: "Manage applications",
+	}
+
+	appExportCmd = &cobra.Command{
+		Use:          "export <id|name>",
+		Short:        "Export an application as a bundle",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runAppExport,
+	}
+
+	appImportCmd = &cobra.Command{
+		Use:          "import <file>",
+		Short:        "Import an application bundle",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runAppImport,
+	}
+)
+
+func init() {
+	appExportCmd.Flags().StringP("output", "o", "", "output file, defaults to <name>-<version>.tar.gz, - for stdout")
+	appImportCmd.Flags().String("name", "", "import the application under another name")
+	appImportCmd.Flags().String("conflict", bundle.ConflictFail, "what to do when the application exists: fail, rename or overwrite")
+
+	appCmd.AddCommand(appExportCmd)
+	appCmd.AddCommand(appImportCmd)
+}
+
+func openBundleService() (*bundle.Service, *repository.AppRepository, error) {
+	op := options.NewServeOptions()
+	if err := database.InitDB(op.DBPath); err != nil {
+		return nil, nil, err
+	}
+	if err := options.InitOptions(op); err != nil {
+		return nil, nil, err
+	}
+	apps := repository.NewAppRepository()
+	versions := appversion.NewStore(repository.NewAppVersionRepository(), op.DataPath())
+	return bundle.NewService(apps, versions, op.DataPath()), apps, nil
+}
+
+func runAppExport(cmd *cobra.Command, args []string) error {
+	service, apps, err := openBundleService()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	var application *model.App
+	if id, err := strconv.ParseInt(args[0], 10, 64); err == nil {
+		application, err = apps.GetByID(id)
+		if err != nil {
+			return fmt.Errorf("application %d: %w", id, err)
+		}
+	} else {
+		application, err = apps.GetByName(args[0])
+		if err != nil {
+			return err
+		}
+		if application == nil {
+			return fmt.Errorf("application %s not found", args[0])
+		}
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		output = fmt.Sprintf("%s-%s.tar.gz", application.Name, application.Version)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := service.Export(w, application); err != nil {
+		if output != "-" {
+			os.Remove(output)
+		}
+		return err
+	}
+	if output != "-" {
+		fmt.Fprintf(os.Stderr, "exported %s to %s\n", application.Name, output)
+	}
+	return nil
+}
+
+func runAppImport(cmd *cobra.Command, args []string) error {
+	service, _, err := openBundleService()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name, _ := cmd.Flags().GetString("name")
+	conflict, _ := cmd.Flags().GetString("conflict")
+	application, err := service.Import(f, bundle.ImportOptions{Name: name, Conflict: conflict})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %s (id %d, version %s)\n", application.Name, application.ID, application.Version)
+	return nil
+}
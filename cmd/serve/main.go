@@ -15,6 +15,7 @@ import (
 	"github.com/benlocal/lai-panel/pkg/api"
 	"github.com/benlocal/lai-panel/pkg/client"
 	"github.com/benlocal/lai-panel/pkg/handler"
+	"github.com/benlocal/lai-panel/pkg/tunnel"
 	"github.com/benlocal/lai-panel/pkg/version"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/common/adaptor"
@@ -64,6 +65,12 @@ func init() {
 		router.GET("/healthz", h.HandleHealthz)
 		router.POST(client.RegistryPath, h.GetRegistryHandler)
 		router.POST(client.DockerEventPath, h.GetDockerEventHandler)
+		router.POST(client.NodeMetricsPath, h.ReportNodeMetricsHandler)
+		// agents behind a nat serve the master through their tunnel
+		router.GET(tunnel.Path, adaptor.HertzHandler(h.NodeManager().Tunnels()))
+
+		// embedded image registry
+		router.Any("/v2/*path", h.OCIRegistryHandler)
 
 		openApi := router.Group("/open")
 		// static files
@@ -120,6 +127,16 @@ func init() {
 		api.POST("/application/delete", h.DeleteApplicationHandler)
 		api.POST("/application/get", h.GetApplicationHandler)
 		api.POST("/application/page", h.GetApplicationPageHandler)
+		api.POST("/application/export", h.ExportApplicationHandler)
+		api.POST("/application/import", h.ImportApplicationHandler)
+		api.POST("/application/convert", h.ConvertApplicationHandler)
+		api.POST("/application/version/list", h.GetAppVersionListHandler)
+		api.POST("/application/version/delete", h.DeleteAppVersionHandler)
+		api.POST("/catalog/source/list", h.GetCatalogSourceListHandler)
+		api.POST("/catalog/source/save", h.SaveCatalogSourceHandler)
+		api.POST("/catalog/source/delete", h.DeleteCatalogSourceHandler)
+		api.POST("/catalog/source/sync", h.SyncCatalogSourceHandler)
+		api.POST("/catalog/outdated", h.GetOutdatedServicesHandler)
 		api.POST("/docker/info", h.DockerInfo)
 		api.POST("/docker/containers", h.DockerContainers)
 		api.POST("/docker/container/start", h.DockerContainerStart)
@@ -128,6 +145,7 @@ func init() {
 		api.POST("/docker/container/remove", h.DockerContainerRemove)
 		api.POST("/docker/container/log", h.DockerContainerLog)
 		api.POST("/docker/container/inspect", h.DockerContainerInspect)
+		api.POST("/docker/container/stats", h.DockerContainerStats)
 		api.POST("/docker/images", h.DockerImages)
 		api.POST("/docker/image/inspect", h.DockerImageInspect)
 		api.POST("/docker/image/pushTo", h.DockerImagePushTo)
@@ -136,15 +154,31 @@ func init() {
 		api.POST("/docker/compose/config", h.HandleDockerComposeConfig)
 		api.POST("/docker/compose/deploy", h.HandleDockerComposeDeploy)
 		api.POST("/docker/compose/undeploy", h.HandleDockerComposeUndeploy)
+		api.POST("/docker/compose/restart", h.HandleDockerComposeRestart)
+		api.POST("/docker/compose/logs", h.HandleDockerComposeLogs)
 		api.POST("/node/add", h.AddNodeHandler)
 		api.POST("/node/get", h.GetNodeHandler)
 		api.POST("/node/update", h.UpdateNodeHandler)
 		api.POST("/node/delete", h.DeleteNodeHandler)
 		api.POST("/node/list", h.GetNodeListHandler)
 		api.POST("/node/page", h.GetNodePageHandler)
+		api.POST("/node/metrics", h.GetNodeMetricsHandler)
+		api.POST("/node/preflight", h.NodePreflightHandler)
+		api.POST("/node/agent", h.NodeAgentHandler)
+		api.POST("/node/agent/upgrade", h.NodeAgentUpgradeHandler)
+		api.POST("/node/jobs", h.GetNodeJobsHandler)
+		api.POST("/node/job/get", h.GetNodeJobHandler)
 		api.POST("/service/page", h.GetServicePageHandler)
 		api.POST("/service/save", h.SaveServiceHandler)
 		api.POST("/service/delete", h.DeleteServiceHandler)
+		api.POST("/service/upgrade/preview", h.PreviewServiceUpgradeHandler)
+		api.POST("/service/upgrade", h.HandleServiceUpgrade)
+		api.POST("/service/placement", h.GetServicePlacementHandler)
+		api.POST("/service/discover", h.DiscoverComposeProjectsHandler)
+		api.POST("/service/adopt", h.AdoptComposeProjectHandler)
+		api.POST("/service/secret/list", h.GetServiceSecretListHandler)
+		api.POST("/service/secret/rotate", h.RotateServiceSecretHandler)
+		api.POST("/service/port/list", h.GetServicePortListHandler)
 		api.POST("/dashboard/stats", h.DashboardStatsHandler)
 		api.Static("/workspace", h.WorkSpaceDataPath())
 		api.POST("/workspace/upload", h.HandleWorkspaceUpload)
@@ -157,6 +191,16 @@ func init() {
 		api.POST("/env/scopes", h.GetEnvScopes)
 		api.POST("/env/addOrUpdate", h.AddOrUpdateEnv)
 		api.POST("/env/delete", h.DeleteEnv)
+		api.POST("/env/effective", h.GetEffectiveEnv)
+		api.POST("/env/import", h.ImportEnv)
+		api.POST("/env/export", h.ExportEnv)
+		api.POST("/image_registry/list", h.GetImageRegistryListHandler)
+		api.POST("/image_registry/save", h.SaveImageRegistryHandler)
+		api.POST("/image_registry/delete", h.DeleteImageRegistryHandler)
+		api.POST("/oci/repository/list", h.GetOCIRepositoryListHandler)
+		api.POST("/oci/image/delete", h.DeleteOCIImageHandler)
+		api.POST("/oci/image/push", h.PushImageToOCIRegistryHandler)
+		api.POST("/oci/gc", h.OCIGarbageCollectHandler)
 
 		// hub
 		sp := "/api/signalr"
@@ -175,6 +219,7 @@ func init() {
 	// CLI 命令
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(appCmd)
 }
 
 func runServe(_ *cobra.Command) error {
@@ -10,6 +10,7 @@ import (
 	"github.com/benlocal/lai-panel/pkg/docker"
 	"github.com/benlocal/lai-panel/pkg/gracefulshutdown"
 	"github.com/benlocal/lai-panel/pkg/handler"
+	"github.com/benlocal/lai-panel/pkg/metrics"
 	"github.com/benlocal/lai-panel/pkg/options"
 	"github.com/benlocal/lai-panel/pkg/service"
 
@@ -44,12 +45,15 @@ func (r *ServeRuntime) Start() error {
 	if err != nil {
 		return err
 	}
+	if err := appCtx.NodeJobRepository().FailRunning("interrupted by a server restart"); err != nil {
+		return err
+	}
 
 	g := gracefulshutdown.New()
 	g.CatchSignals()
 
 	baseHandler := handler.NewBaseHandler(appCtx)
-	baseClient := myClient.NewBaseClient()
+	baseClient := myClient.NewBaseClient(false)
 
 	apiServer := api.NewApiServer(fmt.Sprintf(":%d", op.Port), baseHandler)
 	g.Add(apiServer)
@@ -66,6 +70,16 @@ func (r *ServeRuntime) Start() error {
 	servicesStateUpdater := service.NewServicesStateService(baseHandler)
 	g.Add(servicesStateUpdater)
 
+	catalogSyncService := service.NewCatalogSyncService(baseHandler, op.CatalogSyncInterval)
+	g.Add(catalogSyncService)
+
+	collector := metrics.NewCollector(op.DataPath(), localDockerClient)
+	metricsService := service.NewLocalMetricsService(baseHandler, collector, op.MetricsInterval)
+	g.Add(metricsService)
+
+	metricsDownsampleService := service.NewMetricsDownsampleService(baseHandler, metrics.Tiers(op.MetricsRetention))
+	g.Add(metricsDownsampleService)
+
 	ctx := context.Background()
 	return g.Start(ctx)
 }
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS image_registries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    host TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL, -- encrypted password or token
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    metadata TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_image_registries_host ON image_registries (host);
\ No newline at end of file
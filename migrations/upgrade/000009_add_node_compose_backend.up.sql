@@ -0,0 +1 @@
+ALTER TABLE nodes ADD COLUMN compose_backend TEXT NOT NULL DEFAULT 'cli';
\ No newline at end of file
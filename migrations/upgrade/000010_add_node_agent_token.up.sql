@@ -0,0 +1 @@
+ALTER TABLE nodes ADD COLUMN agent_token TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS port_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL,
+    service_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_port_allocations_node_port ON port_allocations (node_id, port);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_port_allocations_service_name ON port_allocations (service_id, name);
\ No newline at end of file
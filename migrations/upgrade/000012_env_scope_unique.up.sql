@@ -0,0 +1,4 @@
+UPDATE env SET scope = '' WHERE scope IS NULL;
+
+DROP INDEX IF EXISTS idx_env_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_env_key_scope ON env (key, scope);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE env ADD COLUMN is_secret BOOLEAN NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,24 @@
+UPDATE env SET scope = '' WHERE scope IS NULL;
+UPDATE env SET description = '' WHERE description IS NULL;
+UPDATE env SET metadata = '' WHERE metadata IS NULL;
+
+CREATE TABLE env_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    is_secret BOOLEAN NOT NULL DEFAULT 0
+);
+
+INSERT INTO env_new (id, key, value, scope, description, metadata, created_at, updated_at, is_secret)
+SELECT id, key, value, scope, description, metadata, created_at, updated_at, is_secret FROM env;
+
+DROP TABLE env;
+ALTER TABLE env_new RENAME TO env;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_env_key_scope ON env (key, scope);
+CREATE INDEX IF NOT EXISTS idx_env_key_value ON env (key, value);
\ No newline at end of file
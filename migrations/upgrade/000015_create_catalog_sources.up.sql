@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS catalog_sources (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL, -- dir or git
+    url TEXT NOT NULL,
+    branch TEXT NOT NULL DEFAULT '',
+    last_commit TEXT NOT NULL DEFAULT '',
+    last_synced_at DATETIME,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_catalog_sources_name ON catalog_sources (name);
+
+ALTER TABLE apps ADD COLUMN catalog_source_id INTEGER;
+ALTER TABLE apps ADD COLUMN catalog_commit TEXT;
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS app_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    app_id INTEGER NOT NULL,
+    version TEXT NOT NULL,
+    docker_compose TEXT,
+    qa TEXT,
+    metadata TEXT,
+    static_path TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_app_versions_app_version ON app_versions (app_id, version);
+
+INSERT INTO app_versions (app_id, version, docker_compose, qa, metadata, static_path)
+SELECT id, version, docker_compose, qa, metadata, static_path FROM apps;
+
+ALTER TABLE services ADD COLUMN app_version_id INTEGER;
+
+UPDATE services SET app_version_id = (
+    SELECT app_versions.id FROM app_versions
+    JOIN apps ON apps.id = app_versions.app_id AND apps.version = app_versions.version
+    WHERE app_versions.app_id = services.app_id
+);
\ No newline at end of file
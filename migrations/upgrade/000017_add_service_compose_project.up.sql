@@ -0,0 +1 @@
+ALTER TABLE services ADD COLUMN compose_project TEXT;
\ No newline at end of file
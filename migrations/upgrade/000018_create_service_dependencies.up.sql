@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS service_dependencies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    depends_on_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_dependencies_service_depends_on ON service_dependencies (service_id, depends_on_id);
+CREATE INDEX IF NOT EXISTS idx_service_dependencies_depends_on ON service_dependencies (depends_on_id);
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS node_metrics (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL,
+    -- seconds covered by the sample, 0 for raw samples
+    resolution INTEGER NOT NULL DEFAULT 0,
+    -- unix seconds
+    ts INTEGER NOT NULL,
+    cpus INTEGER NOT NULL DEFAULT 0,
+    cpu_percent REAL NOT NULL DEFAULT 0,
+    load1 REAL NOT NULL DEFAULT 0,
+    load5 REAL NOT NULL DEFAULT 0,
+    load15 REAL NOT NULL DEFAULT 0,
+    memory_total INTEGER NOT NULL DEFAULT 0,
+    memory_used INTEGER NOT NULL DEFAULT 0,
+    disk_total INTEGER NOT NULL DEFAULT 0,
+    disk_used INTEGER NOT NULL DEFAULT 0,
+    net_rx_rate REAL NOT NULL DEFAULT 0,
+    net_tx_rate REAL NOT NULL DEFAULT 0,
+    containers INTEGER NOT NULL DEFAULT 0,
+    containers_running INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_node_metrics_node_resolution_ts ON node_metrics (node_id, resolution, ts);
\ No newline at end of file
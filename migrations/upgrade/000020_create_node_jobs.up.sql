@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS node_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL,
+    -- install, reinstall or uninstall
+    action TEXT NOT NULL,
+    -- manual or auto
+    triggered_by TEXT NOT NULL DEFAULT 'manual',
+    -- running, succeeded or failed
+    status TEXT NOT NULL,
+    output TEXT NOT NULL DEFAULT '',
+    error TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_jobs_node_id ON node_jobs (node_id, id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE nodes ADD COLUMN agent_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE nodes ADD COLUMN agent_capabilities TEXT NOT NULL DEFAULT '';
\ No newline at end of file
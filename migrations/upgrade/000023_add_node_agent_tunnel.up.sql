@@ -0,0 +1 @@
+ALTER TABLE nodes ADD COLUMN agent_tunnel INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -37,6 +37,9 @@ func (h *ApiServer) Start(ctx context.Context) error {
 	h.server = hertzServer.Default(
 		hertzServer.WithHostPorts(h.listenAddr),
 		hertzServer.WithMaxRequestBodySize(1*1024*1024*1024), // 1GB
+		// large bodies, registry layers or image archives, are read as streams
+		// instead of being buffered
+		hertzServer.WithStreamBody(true),
 	)
 
 	// 配置 CORS 中间件
@@ -0,0 +1,107 @@
+package appversion
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/benlocal/lai-panel/pkg/fsutil"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
+)
+
+// ErrImmutable is returned when an app changes without a new version.
+var ErrImmutable = errors.New("app version is immutable")
+
+// Store records app versions and keeps a copy of the workspace of each
+// version under <data>/versions/<app id>/<version id>.
+type Store struct {
+	versions *repository.AppVersionRepository
+	dataPath string
+}
+
+func NewStore(versions *repository.AppVersionRepository, dataPath string) *Store {
+	return &Store{versions: versions, dataPath: dataPath}
+}
+
+// Publish records the definition of the app as its version and copies the
+// workspace. An unchanged definition returns the existing version, a
+// changed definition under a recorded version fails with ErrImmutable.
+// Later workspace edits only reach the next version.
+func (s *Store) Publish(app *model.App) (*model.AppVersion, error) {
+	existing, err := s.versions.GetByAppAndVersion(app.ID, app.Version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if !existing.Matches(app) {
+			return nil, immutableError(app)
+		}
+		return existing, nil
+	}
+
+	version := model.NewAppVersion(app)
+	if err := s.versions.Create(version); err != nil {
+		return nil, err
+	}
+
+	workspace := filepath.Join(s.dataPath, options.WORK_SPACE_BASE_PATH, app.Name)
+	if _, err := os.Stat(workspace); err == nil {
+		if err := fsutil.CopyDir(workspace, s.workspacePath(version)); err != nil {
+			s.versions.Delete(version.ID)
+			os.RemoveAll(s.workspacePath(version))
+			return nil, fmt.Errorf("failed to snapshot the workspace: %w", err)
+		}
+	}
+	return version, nil
+}
+
+// Check fails with ErrImmutable when the app changes a recorded version.
+func (s *Store) Check(app *model.App) error {
+	existing, err := s.versions.GetByAppAndVersion(app.ID, app.Version)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !existing.Matches(app) {
+		return immutableError(app)
+	}
+	return nil
+}
+
+func immutableError(app *model.App) error {
+	return fmt.Errorf("%w: %s %s already exists with another definition, use a new version", ErrImmutable, app.Name, app.Version)
+}
+
+func (s *Store) workspacePath(version *model.AppVersion) string {
+	return filepath.Join(s.dataPath, options.APP_VERSION_BASE_PATH,
+		strconv.FormatInt(version.AppID, 10), strconv.FormatInt(version.ID, 10))
+}
+
+// Workspace returns the workspace copy of the version, empty for versions
+// recorded before workspaces were copied.
+func (s *Store) Workspace(version *model.AppVersion) string {
+	p := s.workspacePath(version)
+	if _, err := os.Stat(p); err != nil {
+		return ""
+	}
+	return p
+}
+
+// Delete removes a version and its workspace copy.
+func (s *Store) Delete(version *model.AppVersion) error {
+	if err := s.versions.Delete(version.ID); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.workspacePath(version))
+}
+
+// DeleteApp removes every version of an app.
+func (s *Store) DeleteApp(appID int64) error {
+	if err := s.versions.DeleteByApp(appID); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.dataPath, options.APP_VERSION_BASE_PATH, strconv.FormatInt(appID, 10)))
+}
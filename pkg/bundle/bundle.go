@@ -0,0 +1,295 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/qa"
+)
+
+// layout of a bundle archive
+const (
+	ManifestFile  = "manifest.json"
+	WorkspaceDir  = "workspace"
+	InstallerDir  = "installer"
+	FormatVersion = 1
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Manifest describes the app packed in a bundle.
+type Manifest struct {
+	FormatVersion int                `json:"format_version"`
+	ExportedAt    time.Time          `json:"exported_at"`
+	Name          string             `json:"name"`
+	Display       *string            `json:"display,omitempty"`
+	Description   *string            `json:"description,omitempty"`
+	Version       string             `json:"version"`
+	Icon          string             `json:"icon,omitempty"`
+	DockerCompose string             `json:"docker_compose"`
+	QA            []*model.AppQAItem `json:"qa"`
+	Metadata      []*model.Metadata  `json:"metadata"`
+	// Installer is the file name of the installer packed under installer/
+	Installer string `json:"installer,omitempty"`
+	// InstallerURL is kept when the installer of the app is a remote url
+	InstallerURL string `json:"installer_url,omitempty"`
+}
+
+// NewManifest builds the manifest of an app, the installer is not set.
+func NewManifest(app *model.App) *Manifest {
+	view := app.ToView()
+	m := &Manifest{
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Name:          view.Name,
+		Display:       view.Display,
+		Description:   view.Description,
+		Version:       view.Version,
+		Icon:          view.Icon,
+		QA:            view.QA,
+		Metadata:      view.Metadata,
+	}
+	if view.DockerCompose != nil {
+		m.DockerCompose = *view.DockerCompose
+	}
+	return m
+}
+
+// Validate checks the manifest can be imported.
+func (m *Manifest) Validate() error {
+	if m.FormatVersion < 1 || m.FormatVersion > FormatVersion {
+		return fmt.Errorf("unsupported bundle format version %d", m.FormatVersion)
+	}
+	if err := ValidateName(m.Name); err != nil {
+		return err
+	}
+	if strings.TrimSpace(m.DockerCompose) == "" {
+		return errors.New("manifest has no docker compose template")
+	}
+	if m.Installer != "" && (m.Installer != path.Base(m.Installer) || m.Installer == "." || m.Installer == "..") {
+		return fmt.Errorf("invalid installer name %q", m.Installer)
+	}
+	return qa.ValidateSchema(m.QA)
+}
+
+// ToApp converts the manifest to an app row, the installer is not set.
+func (m *Manifest) ToApp() *model.App {
+	compose := m.DockerCompose
+	view := &model.AppView{
+		Name:          m.Name,
+		Display:       m.Display,
+		Description:   m.Description,
+		DockerCompose: &compose,
+		Version:       m.Version,
+		Icon:          m.Icon,
+		QA:            m.QA,
+		Metadata:      m.Metadata,
+	}
+	if view.QA == nil {
+		view.QA = []*model.AppQAItem{}
+	}
+	if view.Metadata == nil {
+		view.Metadata = []*model.Metadata{}
+	}
+	return view.ToModel()
+}
+
+// ValidateName checks an app name is usable as a workspace directory.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid app name %q", name)
+	}
+	return nil
+}
+
+// Write packs the manifest, the workspace directory and the installer file
+// into a tar.gz archive. workspace and installer may be empty.
+func Write(w io.Writer, manifest *Manifest, workspace string, installer string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if installer != "" {
+		manifest.Installer = filepath.Base(installer)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    ManifestFile,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: manifest.ExportedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if workspace != "" {
+		if err := writeDir(tw, workspace); err != nil {
+			return err
+		}
+	}
+
+	if installer != "" {
+		if err := writeFile(tw, path.Join(InstallerDir, manifest.Installer), installer); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeDir(tw *tar.Writer, root string) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(root, func(file string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := path.Join(WorkspaceDir, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		}
+		if !info.Mode().IsRegular() {
+			// links and devices are not portable
+			return nil
+		}
+		return writeFile(tw, name, file)
+	})
+}
+
+func writeFile(tw *tar.Writer, name string, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Extract unpacks a bundle into dir and returns its validated manifest. The
+// workspace ends up in dir/workspace and the installer in dir/installer.
+func Extract(r io.Reader, dir string) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle is not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == ManifestFile {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			continue
+		}
+
+		top, _, _ := strings.Cut(name, "/")
+		if top != WorkspaceDir && top != InstallerDir {
+			return nil, fmt.Errorf("unexpected entry %q in bundle", header.Name)
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("unsafe path %q in bundle", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return nil, err
+			}
+			if err := extractFile(tr, target, os.FileMode(header.Mode).Perm()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported entry %q in bundle", header.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New("bundle has no " + ManifestFile)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	if manifest.Installer != "" {
+		if _, err := os.Stat(filepath.Join(dir, InstallerDir, manifest.Installer)); err != nil {
+			return nil, fmt.Errorf("installer %s is missing from the bundle", manifest.Installer)
+		}
+	}
+	return manifest, nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if mode == 0 {
+		mode = 0o644
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
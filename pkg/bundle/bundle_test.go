@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func testApp() *model.App {
+	compose := "services:\n  web:\n    image: nginx:{{ .version }}\n"
+	qa := `[{"name":"version","type":"string","default_value":"latest"}]`
+	return &model.App{
+		ID:            7,
+		Name:          "nginx",
+		Version:       "1.0.0",
+		Icon:          "https://example.com/nginx.png",
+		DockerCompose: &compose,
+		QA:            &qa,
+	}
+}
+
+func TestWriteExtract(t *testing.T) {
+	src := t.TempDir()
+	workspace := filepath.Join(src, "workspace")
+	assert.NoError(t, os.MkdirAll(filepath.Join(workspace, "conf"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(workspace, "conf", "nginx.conf"), []byte("worker_processes 1;"), 0o600))
+	installer := filepath.Join(src, "setup.tar.gz")
+	assert.NoError(t, os.WriteFile(installer, []byte("installer"), 0o644))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, NewManifest(testApp()), workspace, installer))
+
+	dst := t.TempDir()
+	manifest, err := Extract(&buf, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx", manifest.Name)
+	assert.Equal(t, "1.0.0", manifest.Version)
+	assert.Equal(t, "setup.tar.gz", manifest.Installer)
+	assert.Len(t, manifest.QA, 1)
+
+	data, err := os.ReadFile(filepath.Join(dst, WorkspaceDir, "conf", "nginx.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "worker_processes 1;", string(data))
+	info, err := os.Stat(filepath.Join(dst, WorkspaceDir, "conf", "nginx.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	data, err = os.ReadFile(filepath.Join(dst, InstallerDir, "setup.tar.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, "installer", string(data))
+
+	application := manifest.ToApp()
+	assert.Zero(t, application.ID)
+	assert.Equal(t, *testApp().DockerCompose, *application.DockerCompose)
+	assert.Equal(t, "latest", application.GetQA()[0].DefaultValue)
+}
+
+func TestExtractRejectsInvalidBundles(t *testing.T) {
+	archive := func(entries map[string]string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, body := range entries {
+			assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body))}))
+			_, err := tw.Write([]byte(body))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, tw.Close())
+		assert.NoError(t, gz.Close())
+		return &buf
+	}
+	manifest := `{"format_version":1,"name":"web","docker_compose":"services: {}"}`
+
+	_, err := Extract(archive(map[string]string{"workspace/a": "a"}), t.TempDir())
+	assert.ErrorContains(t, err, "no manifest.json")
+
+	_, err = Extract(archive(map[string]string{ManifestFile: manifest, "workspace/../../evil": "x"}), t.TempDir())
+	assert.Error(t, err)
+
+	_, err = Extract(archive(map[string]string{ManifestFile: manifest, "other/file": "x"}), t.TempDir())
+	assert.ErrorContains(t, err, "unexpected entry")
+
+	_, err = Extract(archive(map[string]string{ManifestFile: `{"format_version":9,"name":"web","docker_compose":"x"}`}), t.TempDir())
+	assert.ErrorContains(t, err, "format version")
+
+	_, err = Extract(archive(map[string]string{ManifestFile: `{"format_version":1,"name":"../web","docker_compose":"x"}`}), t.TempDir())
+	assert.ErrorContains(t, err, "invalid app name")
+
+	_, err = Extract(archive(map[string]string{ManifestFile: `{"format_version":1,"name":"web","docker_compose":"x","installer":"setup.sh"}`}), t.TempDir())
+	assert.ErrorContains(t, err, "installer setup.sh is missing")
+
+	_, err = Extract(archive(map[string]string{ManifestFile: manifest}), t.TempDir())
+	assert.NoError(t, err)
+}
+
+func TestUniqueName(t *testing.T) {
+	taken := map[string]bool{"web-2": true, "web-3": true}
+	name, err := uniqueName("web", func(candidate string) (bool, error) {
+		return taken[candidate], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "web-4", name)
+}
@@ -0,0 +1,225 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/fsutil"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
+)
+
+// how an import treats an app with the same name
+const (
+	ConflictFail      = "fail"
+	ConflictRename    = "rename"
+	ConflictOverwrite = "overwrite"
+)
+
+// ErrConflict is returned when the app already exists and the conflict
+// strategy is fail.
+var ErrConflict = errors.New("app already exists")
+
+// ImportOptions controls how a bundle is imported.
+type ImportOptions struct {
+	// Name replaces the app name of the manifest
+	Name string
+	// Conflict is one of ConflictFail, ConflictRename or ConflictOverwrite
+	Conflict string
+}
+
+// Service exports apps to bundles and imports them back, the app row, the
+// workspace directory and the installer under static move together.
+type Service struct {
+	apps     *repository.AppRepository
+	versions *appversion.Store
+	dataPath string
+}
+
+func NewService(apps *repository.AppRepository, versions *appversion.Store, dataPath string) *Service {
+	return &Service{apps: apps, versions: versions, dataPath: dataPath}
+}
+
+func (s *Service) workspacePath(name string) string {
+	return filepath.Join(s.dataPath, options.WORK_SPACE_BASE_PATH, name)
+}
+
+func (s *Service) staticPath() string {
+	return filepath.Join(s.dataPath, options.STATIC_BASE_PATH)
+}
+
+// Export writes the bundle of the app to w.
+func (s *Service) Export(w io.Writer, app *model.App) error {
+	manifest := NewManifest(app)
+
+	installer := ""
+	if app.StaticPath != nil && *app.StaticPath != "" {
+		p := *app.StaticPath
+		if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+			manifest.InstallerURL = p
+		} else {
+			if _, err := os.Stat(p); err != nil {
+				return fmt.Errorf("installer of app %s: %w", app.Name, err)
+			}
+			installer = p
+		}
+	}
+
+	return Write(w, manifest, s.workspacePath(app.Name), installer)
+}
+
+// Import reads a bundle from r and creates the app, its workspace and its
+// installer. With ConflictOverwrite an existing app keeps its id so services
+// stay attached to it.
+func (s *Service) Import(r io.Reader, opts ImportOptions) (*model.App, error) {
+	if opts.Conflict == "" {
+		opts.Conflict = ConflictFail
+	}
+	switch opts.Conflict {
+	case ConflictFail, ConflictRename, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("unknown conflict strategy %q", opts.Conflict)
+	}
+
+	// stage next to the workspaces so moving the directory is a rename
+	staging, err := os.MkdirTemp(filepath.Join(s.dataPath, options.WORK_SPACE_BASE_PATH), ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := Extract(r, staging)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name != "" {
+		if err := ValidateName(opts.Name); err != nil {
+			return nil, err
+		}
+		manifest.Name = opts.Name
+	}
+
+	name, existing, err := s.resolveName(manifest.Name, opts.Conflict)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Name = name
+
+	app := manifest.ToApp()
+	if manifest.InstallerURL != "" {
+		app.StaticPath = &manifest.InstallerURL
+	}
+	if manifest.Installer != "" {
+		target := filepath.Join(s.staticPath(), name, manifest.Installer)
+		app.StaticPath = &target
+	}
+	if existing != nil {
+		app.ID = existing.ID
+		if err := s.versions.Check(app); err != nil {
+			return nil, err
+		}
+	}
+
+	// the new files replace the old ones only once the app row is written,
+	// a failure puts the old ones back
+	var swaps []*fsutil.Swap
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(swaps) - 1; i >= 0; i-- {
+			swaps[i].Rollback()
+		}
+	}()
+
+	src := filepath.Join(staging, WorkspaceDir)
+	if !exists(src) {
+		if err := os.MkdirAll(src, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	swap, err := fsutil.Replace(src, s.workspacePath(name))
+	if err != nil {
+		return nil, err
+	}
+	swaps = append(swaps, swap)
+
+	if manifest.Installer != "" {
+		swap, err := fsutil.Replace(filepath.Join(staging, InstallerDir, manifest.Installer), *app.StaticPath)
+		if err != nil {
+			return nil, err
+		}
+		swaps = append(swaps, swap)
+	}
+
+	if existing != nil {
+		err = s.apps.Update(app)
+	} else {
+		err = s.apps.Create(app)
+	}
+	if err != nil {
+		return nil, err
+	}
+	committed = true
+	for _, swap := range swaps {
+		swap.Commit()
+	}
+	if _, err := s.versions.Publish(app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// resolveName applies the conflict strategy to the app name, existing is
+// the app to overwrite.
+func (s *Service) resolveName(name string, conflict string) (string, *model.App, error) {
+	existing, err := s.apps.GetByName(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if existing == nil && !exists(s.workspacePath(name)) {
+		return name, nil, nil
+	}
+
+	switch conflict {
+	case ConflictOverwrite:
+		return name, existing, nil
+	case ConflictRename:
+		unique, err := uniqueName(name, func(candidate string) (bool, error) {
+			app, err := s.apps.GetByName(candidate)
+			if err != nil {
+				return false, err
+			}
+			return app != nil || exists(s.workspacePath(candidate)), nil
+		})
+		return unique, nil, err
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrConflict, name)
+}
+
+// uniqueName returns the first of name-2, name-3, ... that is not taken.
+func uniqueName(name string, taken func(string) (bool, error)) (string, error) {
+	for i := 2; i < 1000; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		used, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for app %s", name)
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
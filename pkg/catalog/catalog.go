@@ -0,0 +1,165 @@
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/bundle"
+)
+
+// a catalog holds one folder per app:
+//
+//	<app>/manifest.json       the bundle manifest, docker_compose may be left out
+//	<app>/docker-compose.yml  the compose template when not in the manifest
+//	<app>/workspace/          files copied to the workspace of the app
+//	<app>/installer/<file>    the installer named in the manifest
+var composeFiles = []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}
+
+// Entry is an app folder of a catalog.
+type Entry struct {
+	// Folder is the path of the folder relative to the catalog root
+	Folder   string
+	Dir      string
+	Manifest *bundle.Manifest
+}
+
+// Workspace returns the workspace folder of the entry, empty when missing.
+func (e *Entry) Workspace() string {
+	p := filepath.Join(e.Dir, bundle.WorkspaceDir)
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return p
+	}
+	return ""
+}
+
+// Installer returns the installer file of the entry, empty when none.
+func (e *Entry) Installer() string {
+	if e.Manifest.Installer == "" {
+		return ""
+	}
+	return filepath.Join(e.Dir, bundle.InstallerDir, e.Manifest.Installer)
+}
+
+// Load reads the app folders of a catalog. Folders without a manifest are
+// ignored, invalid apps are returned as errors next to the valid entries.
+func Load(root string) ([]*Entry, []error) {
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var entries []*Entry
+	var errs []error
+	names := map[string]string{}
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(root, d.Name())
+		if _, err := os.Stat(filepath.Join(dir, bundle.ManifestFile)); os.IsNotExist(err) {
+			continue
+		}
+		entry, err := loadEntry(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Name(), err))
+			continue
+		}
+		entry.Folder = d.Name()
+		if other, ok := names[entry.Manifest.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: app %s is already defined in %s", d.Name(), entry.Manifest.Name, other))
+			continue
+		}
+		names[entry.Manifest.Name] = d.Name()
+		entries = append(entries, entry)
+	}
+	return entries, errs
+}
+
+func loadEntry(dir string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, bundle.ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &bundle.Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.FormatVersion == 0 {
+		manifest.FormatVersion = bundle.FormatVersion
+	}
+	if manifest.Name == "" {
+		manifest.Name = filepath.Base(dir)
+	}
+	if manifest.DockerCompose == "" {
+		for _, name := range composeFiles {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				manifest.DockerCompose = string(data)
+				break
+			}
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{Dir: dir, Manifest: manifest}
+	if installer := entry.Installer(); installer != "" {
+		if _, err := os.Stat(installer); err != nil {
+			return nil, fmt.Errorf("installer %s: %w", manifest.Installer, err)
+		}
+	}
+	return entry, nil
+}
+
+// HashDir returns a digest of the files under dir, it stands in for the
+// commit of catalogs which are not git repositories.
+func HashDir(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16], nil
+}
@@ -0,0 +1,144 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("1.2.0", "v1.2.0"))
+	assert.Equal(t, 0, CompareVersions("1.2", "1.2.0"))
+	assert.Equal(t, 1, CompareVersions("1.10.0", "1.9.3"))
+	assert.Equal(t, -1, CompareVersions("1.2.0-beta", "1.2.0"))
+	assert.Equal(t, 1, CompareVersions("1.2.0-rc2", "1.2.0-rc1"))
+	assert.Equal(t, 1, CompareVersions("2024.10.1", "2024.9.30"))
+	assert.Equal(t, 1, CompareVersions("latest-b", "latest-a"))
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"nginx/manifest.json":           `{"version":"1.0.0","qa":[{"name":"port","type":"port","default_value":"80"}]}`,
+		"nginx/docker-compose.yml":      "services:\n  web:\n    image: nginx\n",
+		"nginx/workspace/conf/app.conf": "server {}",
+		"redis/manifest.json":           `{"name":"cache","version":"7","docker_compose":"services: {}"}`,
+		"broken/manifest.json":          `{"version":"1"}`,
+		"redis-copy/manifest.json":      `{"name":"cache","version":"1","docker_compose":"services: {}"}`,
+		"docs/README.md":                "not an app",
+	})
+
+	entries, errs := Load(root)
+	assert.Len(t, errs, 2)
+	assert.Len(t, entries, 2)
+
+	byName := map[string]*Entry{}
+	for _, e := range entries {
+		byName[e.Manifest.Name] = e
+	}
+	assert.Equal(t, "nginx", byName["nginx"].Folder)
+	assert.Contains(t, byName["nginx"].Manifest.DockerCompose, "image: nginx")
+	assert.Equal(t, filepath.Join(root, "nginx", "workspace"), byName["nginx"].Workspace())
+	assert.Equal(t, "redis", byName["cache"].Folder)
+	assert.Empty(t, byName["cache"].Workspace())
+
+	before, err := HashDir(root)
+	assert.NoError(t, err)
+	writeFiles(t, root, map[string]string{"nginx/workspace/conf/app.conf": "server { listen 80; }"})
+	after, err := HashDir(root)
+	assert.NoError(t, err)
+	assert.NotEqual(t, before, after)
+}
+
+func TestCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	run := func(dir string, args ...string) string {
+		out, err := git(ctx, dir, args...)
+		assert.NoError(t, err, args)
+		return out
+	}
+
+	base := t.TempDir()
+	bare := filepath.Join(base, "catalog.git")
+	work := filepath.Join(base, "work")
+	run(base, "init", "--quiet", "--bare", "--initial-branch=main", bare)
+	run(base, "clone", "--quiet", bare, work)
+	run(work, "config", "user.email", "test@example.com")
+	run(work, "config", "user.name", "test")
+	run(work, "checkout", "--quiet", "-b", "main")
+
+	writeFiles(t, work, map[string]string{
+		"web/manifest.json":    `{"version":"1.0.0","docker_compose":"services: {}"}`,
+		"worker/manifest.json": `{"version":"1.0.0","docker_compose":"services: {}"}`,
+	})
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "add apps")
+	first := run(work, "rev-parse", "HEAD")
+	run(work, "push", "--quiet", "origin", "main")
+
+	clone := filepath.Join(base, "clone")
+	commit, err := checkout(ctx, bare, "main", clone)
+	assert.NoError(t, err)
+	assert.Equal(t, first, commit)
+
+	writeFiles(t, work, map[string]string{"web/manifest.json": `{"version":"1.1.0","docker_compose":"services: {}"}`})
+	run(work, "commit", "--quiet", "-am", "bump web")
+	second := run(work, "rev-parse", "HEAD")
+	run(work, "push", "--quiet", "origin", "main")
+
+	commit, err = checkout(ctx, bare, "main", clone)
+	assert.NoError(t, err)
+	assert.Equal(t, second, commit)
+
+	web, err := folderCommit(ctx, clone, "web")
+	assert.NoError(t, err)
+	assert.Equal(t, second, web)
+	worker, err := folderCommit(ctx, clone, "worker")
+	assert.NoError(t, err)
+	assert.Equal(t, first, worker)
+}
+
+func TestFindOutdated(t *testing.T) {
+	sourceID := int64(1)
+	commit := "abc"
+	apps := []model.App{
+		{ID: 1, Name: "web", Version: "1.2.0", CatalogSourceID: &sourceID, CatalogCommit: &commit},
+		{ID: 2, Name: "manual", Version: "9.0.0"},
+	}
+	info := func(s string) *string { return &s }
+	services := []*model.Service{
+		{ID: 10, Name: "web-old", AppID: 1, DeployInfo: info(`{"app_version":"1.1.0","app_commit":"old"}`)},
+		{ID: 11, Name: "web-new", AppID: 1, DeployInfo: info(`{"app_version":"1.2.0"}`)},
+		{ID: 12, Name: "web-unknown", AppID: 1, DeployInfo: info(`{}`)},
+		{ID: 13, Name: "web-never", AppID: 1},
+		{ID: 14, Name: "manual", AppID: 2, DeployInfo: info(`{"app_version":"1.0.0"}`)},
+	}
+
+	outdated := FindOutdated(apps, services)
+	assert.Equal(t, []OutdatedService{{
+		ServiceID:      10,
+		ServiceName:    "web-old",
+		AppID:          1,
+		AppName:        "web",
+		RunningVersion: "1.1.0",
+		CatalogVersion: "1.2.0",
+		RunningCommit:  "old",
+		CatalogCommit:  "abc",
+	}}, outdated)
+}
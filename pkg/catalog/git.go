@@ -0,0 +1,89 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git runs a git command in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// checkout clones url into dir or brings an existing clone up to date with
+// the branch, the default branch when empty. It returns the head commit.
+func checkout(ctx context.Context, url string, branch string, dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+			return "", err
+		}
+		args := []string{"clone", "--quiet"}
+		if branch != "" {
+			args = append(args, "--branch", branch, "--single-branch")
+		}
+		args = append(args, "--", url, dir)
+		if _, err := git(ctx, "", args...); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		return headCommit(ctx, dir)
+	}
+
+	if _, err := git(ctx, dir, "remote", "set-url", "origin", url); err != nil {
+		return "", err
+	}
+	ref := branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := git(ctx, dir, "fetch", "--quiet", "origin", ref); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, dir, "reset", "--hard", "--quiet", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, dir, "clean", "-fdxq"); err != nil {
+		return "", err
+	}
+	return headCommit(ctx, dir)
+}
+
+func headCommit(ctx context.Context, dir string) (string, error) {
+	return git(ctx, dir, "rev-parse", "HEAD")
+}
+
+// isGitWorkTree reports whether dir is inside a git work tree.
+func isGitWorkTree(ctx context.Context, dir string) bool {
+	out, err := git(ctx, dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// folderCommit returns the last commit which touched the folder.
+func folderCommit(ctx context.Context, dir string, folder string) (string, error) {
+	return git(ctx, dir, "log", "-1", "--format=%H", "--", folder)
+}
+
+// folderDirty reports whether the folder has uncommitted changes.
+func folderDirty(ctx context.Context, dir string, folder string) (bool, error) {
+	out, err := git(ctx, dir, "status", "--porcelain", "--", folder)
+	return out != "", err
+}
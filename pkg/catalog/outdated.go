@@ -0,0 +1,62 @@
+package catalog
+
+import (
+	"encoding/json"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// OutdatedService is a service running an older version of a catalog app.
+type OutdatedService struct {
+	ServiceID      int64  `json:"service_id"`
+	ServiceName    string `json:"service_name"`
+	AppID          int64  `json:"app_id"`
+	AppName        string `json:"app_name"`
+	RunningVersion string `json:"running_version"`
+	CatalogVersion string `json:"catalog_version"`
+	RunningCommit  string `json:"running_commit,omitempty"`
+	CatalogCommit  string `json:"catalog_commit,omitempty"`
+}
+
+// FindOutdated returns the deployed services whose catalog app has a newer
+// version than the one they were deployed with. Apps which are not from a
+// catalog and services deployed before versions were recorded are ignored.
+func FindOutdated(apps []model.App, services []*model.Service) []OutdatedService {
+	byID := map[int64]*model.App{}
+	for i := range apps {
+		if apps[i].CatalogSourceID != nil {
+			byID[apps[i].ID] = &apps[i]
+		}
+	}
+
+	outdated := []OutdatedService{}
+	for _, service := range services {
+		app, ok := byID[service.AppID]
+		if !ok || service.DeployInfo == nil {
+			continue
+		}
+		info := map[string]string{}
+		if err := json.Unmarshal([]byte(*service.DeployInfo), &info); err != nil {
+			continue
+		}
+		running, ok := info[model.DeployInfoAppVersion]
+		if !ok || CompareVersions(app.Version, running) <= 0 {
+			continue
+		}
+
+		item := OutdatedService{
+			ServiceID:      service.ID,
+			ServiceName:    service.Name,
+			AppID:          app.ID,
+			AppName:        app.Name,
+			RunningVersion: running,
+			CatalogVersion: app.Version,
+			RunningCommit:  info[model.DeployInfoAppCommit],
+		}
+		if app.CatalogCommit != nil {
+			item.CatalogCommit = *app.CatalogCommit
+		}
+		outdated = append(outdated, item)
+	}
+	return outdated
+}
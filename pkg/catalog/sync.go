@@ -0,0 +1,274 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/fsutil"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
+)
+
+// SyncResult lists what a sync did with each app of the catalog.
+type SyncResult struct {
+	Commit    string   `json:"commit"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	// Skipped apps exist in the panel without being managed by the source
+	Skipped []string `json:"skipped"`
+	// Missing apps were synchronized from the source but left the catalog
+	Missing []string `json:"missing"`
+	Errors  []string `json:"errors"`
+}
+
+// Syncer creates and updates apps from catalog sources. Syncs are
+// serialized, a git clone is never used by two syncs at once.
+type Syncer struct {
+	mu       sync.Mutex
+	sources  *repository.CatalogSourceRepository
+	apps     *repository.AppRepository
+	versions *appversion.Store
+	dataPath string
+}
+
+func NewSyncer(sources *repository.CatalogSourceRepository, apps *repository.AppRepository, versions *appversion.Store, dataPath string) *Syncer {
+	return &Syncer{sources: sources, apps: apps, versions: versions, dataPath: dataPath}
+}
+
+// SyncAll syncs every source, failures are recorded on the sources.
+func (s *Syncer) SyncAll(ctx context.Context) error {
+	sources, err := s.sources.List()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range sources {
+		if _, err := s.Sync(ctx, &sources[i]); err != nil {
+			errs = append(errs, fmt.Errorf("catalog %s: %w", sources[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sync brings the apps of the source up to date and records the outcome on
+// the source.
+func (s *Syncer) Sync(ctx context.Context, source *model.CatalogSource) (*SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.sync(ctx, source)
+	commit := ""
+	if result != nil {
+		commit = result.Commit
+	}
+	if stateErr := s.sources.UpdateSyncState(source.ID, commit, err); stateErr != nil && err == nil {
+		err = stateErr
+	}
+	return result, err
+}
+
+// ClonePath returns where a git source is cloned.
+func (s *Syncer) ClonePath(source *model.CatalogSource) string {
+	return filepath.Join(s.dataPath, options.CATALOG_BASE_PATH, strconv.FormatInt(source.ID, 10))
+}
+
+func (s *Syncer) sync(ctx context.Context, source *model.CatalogSource) (*SyncResult, error) {
+	root := source.URL
+	useGit := false
+	result := &SyncResult{}
+
+	switch source.Kind {
+	case model.CatalogSourceGit:
+		root = s.ClonePath(source)
+		commit, err := checkout(ctx, source.URL, source.Branch, root)
+		if err != nil {
+			return nil, err
+		}
+		result.Commit = commit
+		useGit = true
+	case model.CatalogSourceDir:
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("catalog directory %s does not exist", root)
+		}
+		if isGitWorkTree(ctx, root) {
+			commit, err := headCommit(ctx, root)
+			if err != nil {
+				return nil, err
+			}
+			result.Commit = commit
+			useGit = true
+		} else {
+			hash, err := HashDir(root)
+			if err != nil {
+				return nil, err
+			}
+			result.Commit = hash
+		}
+	default:
+		return nil, fmt.Errorf("unknown catalog source kind %q", source.Kind)
+	}
+
+	entries, errs := Load(root)
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Manifest.Name
+		seen[name] = true
+
+		commit, err := s.entryCommit(ctx, root, entry, useGit)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Folder, err))
+			continue
+		}
+		state, err := s.syncEntry(source, entry, commit)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Folder, err))
+			continue
+		}
+		switch state {
+		case "created":
+			result.Created = append(result.Created, name)
+		case "updated":
+			result.Updated = append(result.Updated, name)
+		case "unchanged":
+			result.Unchanged = append(result.Unchanged, name)
+		case "skipped":
+			result.Skipped = append(result.Skipped, name)
+		}
+	}
+
+	apps, err := s.apps.ListByCatalogSource(source.ID)
+	if err != nil {
+		return result, err
+	}
+	for _, app := range apps {
+		if !seen[app.Name] {
+			result.Missing = append(result.Missing, app.Name)
+		}
+	}
+	return result, nil
+}
+
+// entryCommit returns the commit an app folder comes from, a digest of the
+// folder when it is not committed.
+func (s *Syncer) entryCommit(ctx context.Context, root string, entry *Entry, useGit bool) (string, error) {
+	if useGit {
+		dirty, err := folderDirty(ctx, root, entry.Folder)
+		if err != nil {
+			return "", err
+		}
+		if !dirty {
+			return folderCommit(ctx, root, entry.Folder)
+		}
+	}
+	return HashDir(entry.Dir)
+}
+
+func (s *Syncer) syncEntry(source *model.CatalogSource, entry *Entry, commit string) (string, error) {
+	existing, err := s.apps.GetByName(entry.Manifest.Name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		if existing.CatalogSourceID == nil || *existing.CatalogSourceID != source.ID {
+			return "skipped", nil
+		}
+		if existing.Version == entry.Manifest.Version && existing.CatalogCommit != nil && *existing.CatalogCommit == commit {
+			return "unchanged", nil
+		}
+	}
+
+	app := entry.Manifest.ToApp()
+	if entry.Manifest.InstallerURL != "" {
+		app.StaticPath = &entry.Manifest.InstallerURL
+	}
+	installerTarget := ""
+	if entry.Installer() != "" {
+		installerTarget = filepath.Join(s.dataPath, options.STATIC_BASE_PATH, app.Name, entry.Manifest.Installer)
+		app.StaticPath = &installerTarget
+	}
+	if existing != nil {
+		app.ID = existing.ID
+		// a catalog change without a version bump is refused
+		if err := s.versions.Check(app); err != nil {
+			return "", err
+		}
+	}
+	// the files are staged next to the workspaces and replace the old ones
+	// only once the app row is written, a failure puts the old ones back
+	staging, err := os.MkdirTemp(filepath.Join(s.dataPath, options.WORK_SPACE_BASE_PATH), ".sync-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+	stagedWorkspace := filepath.Join(staging, "workspace")
+	if src := entry.Workspace(); src != "" {
+		if err := fsutil.CopyDir(src, stagedWorkspace); err != nil {
+			return "", err
+		}
+	} else if err := os.MkdirAll(stagedWorkspace, 0o755); err != nil {
+		return "", err
+	}
+	stagedInstaller := filepath.Join(staging, "installer")
+	if installerTarget != "" {
+		if err := fsutil.CopyFile(entry.Installer(), stagedInstaller); err != nil {
+			return "", err
+		}
+	}
+
+	var swaps []*fsutil.Swap
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(swaps) - 1; i >= 0; i-- {
+			swaps[i].Rollback()
+		}
+	}()
+	swap, err := fsutil.Replace(stagedWorkspace, filepath.Join(s.dataPath, options.WORK_SPACE_BASE_PATH, app.Name))
+	if err != nil {
+		return "", err
+	}
+	swaps = append(swaps, swap)
+	if installerTarget != "" {
+		swap, err := fsutil.Replace(stagedInstaller, installerTarget)
+		if err != nil {
+			return "", err
+		}
+		swaps = append(swaps, swap)
+	}
+
+	state := "updated"
+	if existing != nil {
+		err = s.apps.Update(app)
+	} else {
+		state = "created"
+		err = s.apps.Create(app)
+	}
+	if err != nil {
+		return "", err
+	}
+	committed = true
+	for _, swap := range swaps {
+		swap.Commit()
+	}
+	if err := s.apps.UpdateCatalog(app.ID, source.ID, commit); err != nil {
+		return "", err
+	}
+	if _, err := s.versions.Publish(app); err != nil {
+		return "", err
+	}
+	return state, nil
+}
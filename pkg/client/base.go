@@ -1,20 +1,41 @@
 package client
 
-import httpClient "github.com/cloudwego/hertz/pkg/app/client"
+import (
+	"crypto/tls"
+	"fmt"
+
+	httpClient "github.com/cloudwego/hertz/pkg/app/client"
+)
 
 const (
 	RegistryPath    = "/registry"
 	DockerEventPath = "/docker_event"
+	NodeMetricsPath = "/node_metrics"
 )
 
 type BaseClient struct {
 	httpClient *httpClient.Client
+	// scheme of the master, https when it is served over TLS
+	scheme string
 }
 
-func NewBaseClient() *BaseClient {
+func NewBaseClient(masterTLS bool) *BaseClient {
+	if masterTLS {
+		httpClient, _ := httpClient.NewClient(httpClient.WithTLSConfig(&tls.Config{}))
+		return &BaseClient{
+			httpClient: httpClient,
+			scheme:     "https",
+		}
+	}
+
 	httpClient, _ := httpClient.NewClient()
 
 	return &BaseClient{
 		httpClient: httpClient,
+		scheme:     "http",
 	}
 }
+
+func (c *BaseClient) url(host string, port int, path string) string {
+	return fmt.Sprintf("%s://%s:%d%s", c.scheme, host, port, path)
+}
@@ -3,7 +3,6 @@ package client
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/cloudwego/hertz/pkg/protocol"
@@ -13,7 +12,7 @@ func (c *BaseClient) DockerEvent(host string, port int, body *model.DockerEvent)
 	req := protocol.AcquireRequest()
 	defer protocol.ReleaseRequest(req)
 
-	url := fmt.Sprintf("http://%s:%d%s", host, port, DockerEventPath)
+	url := c.url(host, port, DockerEventPath)
 	req.SetRequestURI(url)
 	req.Header.SetMethod("POST")
 	req.Header.SetContentTypeBytes([]byte("application/json"))
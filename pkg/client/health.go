@@ -16,7 +16,7 @@ func (c *BaseClient) HealthCheck(host string, port int) error {
 	req := protocol.AcquireRequest()
 	defer protocol.ReleaseRequest(req)
 
-	url := fmt.Sprintf("http://%s:%d%s", host, port, HealthCheckPath)
+	url := c.url(host, port, HealthCheckPath)
 	req.SetRequestURI(url)
 	req.Header.SetMethod("GET")
 	req.Header.SetContentTypeBytes([]byte("application/json"))
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/cloudwego/hertz/pkg/protocol"
+)
+
+func (c *BaseClient) NodeMetrics(host string, port int, body *model.NodeMetric) error {
+	req := protocol.AcquireRequest()
+	defer protocol.ReleaseRequest(req)
+
+	url := c.url(host, port, NodeMetricsPath)
+	req.SetRequestURI(url)
+	req.Header.SetMethod("POST")
+	req.Header.SetContentTypeBytes([]byte("application/json"))
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req.SetBody(jsonBody)
+
+	resp := protocol.AcquireResponse()
+	defer protocol.ReleaseResponse(resp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.httpClient.Do(ctx, req, resp); err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("node metrics request failed, status code: %d, response: %s", resp.StatusCode(), string(resp.Body()))
+	}
+	return nil
+}
@@ -15,7 +15,7 @@ func (c *BaseClient) Registry(host string, port int, body *model.RegistryRequest
 	req := protocol.AcquireRequest()
 	defer protocol.ReleaseRequest(req)
 
-	url := fmt.Sprintf("http://%s:%d%s", host, port, RegistryPath)
+	url := c.url(host, port, RegistryPath)
 	req.SetRequestURI(url)
 	req.Header.SetMethod("POST")
 	req.Header.SetContentTypeBytes([]byte("application/json"))
@@ -0,0 +1,185 @@
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AdoptFile turns the compose files of a project started by hand into one
+// file. Files are merged in order and relative host paths are made absolute
+// against workingDir, so the service keeps its data when it is deployed from
+// another directory.
+func AdoptFile(files [][]byte, workingDir string) (string, error) {
+	if len(files) == 0 {
+		return "", errors.New("no compose file")
+	}
+
+	var root *yaml.Node
+	for _, file := range files {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(file, &doc); err != nil {
+			return "", err
+		}
+		if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+			continue
+		}
+		if root == nil {
+			root = doc.Content[0]
+			continue
+		}
+		mergeNode(root, doc.Content[0])
+	}
+	if root == nil || root.Kind != yaml.MappingNode {
+		return "", errors.New("invalid compose file")
+	}
+
+	resolvePaths(root, workingDir)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// mergeNode merges override into base the way compose merges override files
+// for the common cases: mappings are merged, sequences are appended and
+// scalars are replaced.
+func mergeNode(base *yaml.Node, override *yaml.Node) {
+	if base.Kind != override.Kind {
+		*base = *override
+		return
+	}
+	switch base.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(override.Content); i += 2 {
+			key, value := override.Content[i], override.Content[i+1]
+			if existing := lookup(base, key.Value); existing != nil {
+				mergeNode(existing, value)
+				continue
+			}
+			base.Content = append(base.Content, key, value)
+		}
+	case yaml.SequenceNode:
+		base.Content = append(base.Content, override.Content...)
+	default:
+		*base = *override
+	}
+}
+
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// resolvePaths rewrites the host paths of bind mounts, build contexts, env
+// files, configs and secrets.
+func resolvePaths(root *yaml.Node, workingDir string) {
+	// builds, env files, configs and secrets never name a volume
+	abs := func(node *yaml.Node) {
+		if node != nil && node.Kind == yaml.ScalarNode && node.Value != "" &&
+			!path.IsAbs(node.Value) && !strings.Contains(node.Value, "://") {
+			node.Value = path.Join(workingDir, node.Value)
+		}
+	}
+
+	services := lookup(root, "services")
+	if services != nil && services.Kind == yaml.MappingNode {
+		for i := 1; i < len(services.Content); i += 2 {
+			svc := services.Content[i]
+
+			if volumes := lookup(svc, "volumes"); volumes != nil && volumes.Kind == yaml.SequenceNode {
+				for _, v := range volumes.Content {
+					switch v.Kind {
+					case yaml.ScalarNode:
+						source, rest, found := strings.Cut(v.Value, ":")
+						if found && isRelative(source) {
+							v.Value = path.Join(workingDir, source) + ":" + rest
+						}
+					case yaml.MappingNode:
+						if t := lookup(v, "type"); t != nil && t.Value == "bind" {
+							abs(lookup(v, "source"))
+						}
+					}
+				}
+			}
+
+			if build := lookup(svc, "build"); build != nil {
+				if build.Kind == yaml.ScalarNode {
+					abs(build)
+				} else {
+					abs(lookup(build, "context"))
+				}
+			}
+
+			if envFile := lookup(svc, "env_file"); envFile != nil {
+				switch envFile.Kind {
+				case yaml.ScalarNode:
+					abs(envFile)
+				case yaml.SequenceNode:
+					for _, f := range envFile.Content {
+						if f.Kind == yaml.MappingNode {
+							abs(lookup(f, "path"))
+						} else {
+							abs(f)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, section := range []string{"configs", "secrets"} {
+		items := lookup(root, section)
+		if items == nil || items.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(items.Content); i += 2 {
+			abs(lookup(items.Content[i], "file"))
+		}
+	}
+}
+
+// isRelative reports whether the source of a short volume is a path relative
+// to the project, named volumes and absolute paths are left alone.
+func isRelative(p string) bool {
+	return p == "." || p == ".." || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../")
+}
+
+// EscapeTemplate makes a compose file usable as the template of an app, the
+// text template delimiters it contains are kept literal.
+func EscapeTemplate(file string) string {
+	if !strings.Contains(file, "{{") && !strings.Contains(file, "}}") {
+		return file
+	}
+	var b strings.Builder
+	for i := 0; i < len(file); i++ {
+		switch {
+		case strings.HasPrefix(file[i:], "{{"):
+			b.WriteString(`{{"{{"}}`)
+			i++
+		case strings.HasPrefix(file[i:], "}}"):
+			b.WriteString(`{{"}}"}}`)
+			i++
+		default:
+			b.WriteByte(file[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package compose
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/tmpl"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServicesInOrder(t *testing.T) {
+	project, err := LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+    depends_on:
+      - api
+  api:
+    image: api
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres
+  worker:
+    image: api
+    depends_on:
+      - db
+`, "docker-compose.yml", "/srv/app", "My App", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "myapp", project.Name)
+
+	order, err := ServicesInOrder(project)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "api", "web", "worker"}, order)
+}
+
+func TestServicesInOrder_Cycle(t *testing.T) {
+	project, err := LoadProject(context.Background(), `services:
+  a:
+    image: a
+    depends_on: [b]
+  b:
+    image: b
+    depends_on: [a]
+`, "docker-compose.yml", "/srv/app", "app", nil)
+	if err != nil {
+		// the loader may already reject the cycle
+		assert.Contains(t, err.Error(), "cycle")
+		return
+	}
+	_, err = ServicesInOrder(project)
+	assert.ErrorContains(t, err, "cycle")
+}
+
+func TestResolveEnvironment(t *testing.T) {
+	project, err := LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+    env_file: web.env
+    environment:
+      MODE: prod
+      TOKEN:
+`, "docker-compose.yml", "/srv/app", "app", map[string]string{"TOKEN": "secret"})
+	assert.NoError(t, err)
+
+	err = ResolveEnvironment(project, map[string]string{"TOKEN": "secret"}, func(path string) ([]byte, error) {
+		assert.Equal(t, "/srv/app/web.env", path)
+		return []byte("MODE=dev\nLEVEL=debug\n"), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"LEVEL=debug", "MODE=prod", "TOKEN=secret"}, toEnv(project.Services["web"].Environment))
+}
+
+func TestToContainerSpec(t *testing.T) {
+	project, err := LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+    restart: on-failure:3
+    ports:
+      - 127.0.0.1:8080:80
+      - 53:53/udp
+    volumes:
+      - ./html:/usr/share/nginx/html:ro
+      - data:/data
+    networks:
+      front:
+        aliases: [www]
+      back: {}
+    labels:
+      app: web
+volumes:
+  data: {}
+networks:
+  front: {}
+  back: {}
+`, "docker-compose.yml", "/srv/app", "app", nil)
+	assert.NoError(t, err)
+
+	svc := project.Services["web"]
+	spec, err := toContainerSpec(project, svc, 2, "hash")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "nginx", spec.config.Image)
+	assert.Equal(t, map[string]string{
+		"app":                "web",
+		ProjectLabel:         "app",
+		ServiceLabel:         "web",
+		ContainerNumberLabel: "2",
+		OneoffLabel:          "False",
+		ConfigHashLabel:      "hash",
+		WorkingDirLabel:      "/srv/app",
+	}, spec.config.Labels)
+
+	assert.Equal(t, container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 3}, spec.hostConfig.RestartPolicy)
+	assert.Equal(t, nat.PortMap{
+		"80/tcp": {{HostIP: "127.0.0.1", HostPort: "8080"}},
+		"53/udp": {{HostPort: "53"}},
+	}, spec.hostConfig.PortBindings)
+
+	assert.Equal(t, []string{"/srv/app/html:/usr/share/nginx/html:ro"}, spec.hostConfig.Binds)
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "app_data", Target: "/data", VolumeOptions: &mount.VolumeOptions{}},
+	}, spec.hostConfig.Mounts)
+
+	assert.Equal(t, container.NetworkMode("app_back"), spec.hostConfig.NetworkMode)
+	assert.Len(t, spec.networks, 2)
+	assert.Equal(t, "app_front", spec.networks[1].name)
+	assert.Equal(t, []string{"web", "www"}, spec.networks[1].settings.Aliases)
+}
+
+func TestConfigHash(t *testing.T) {
+	project, err := LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+`, "docker-compose.yml", "/srv/app", "app", nil)
+	assert.NoError(t, err)
+
+	svc := project.Services["web"]
+	a, err := ConfigHash(svc, "sha256:1")
+	assert.NoError(t, err)
+
+	// scale does not change the containers
+	svc.SetScale(3)
+	b, err := ConfigHash(svc, "sha256:1")
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	// the tag pulled again with new content
+	c, err := ConfigHash(svc, "sha256:2")
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, c)
+
+	svc.Image = "nginx:1.27"
+	d, err := ConfigHash(svc, "sha256:1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, d)
+}
+
+func TestGroupProjects(t *testing.T) {
+	labels := func(project, service string, extra ...string) map[string]string {
+		l := map[string]string{
+			ProjectLabel:     project,
+			ServiceLabel:     service,
+			WorkingDirLabel:  "/srv/" + project,
+			ConfigFilesLabel: "/srv/" + project + "/compose.yml,/srv/" + project + "/compose.override.yml",
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			l[extra[i]] = extra[i+1]
+		}
+		return l
+	}
+	projects := GroupProjects([]container.Summary{
+		{ID: "1", Names: []string{"/blog-web-1"}, Image: "nginx", State: "running", Labels: labels("blog", "web")},
+		{ID: "2", Names: []string{"/blog-db-1"}, Image: "mysql", State: "exited", Labels: labels("blog", "db")},
+		{ID: "3", Names: []string{"/blog-web-run-1"}, Labels: labels("blog", "web", OneoffLabel, "True")},
+		{ID: "4", Names: []string{"/app-api-1"}, Labels: labels("app", "api", "com.lai-panel.managed-by", "lai-panel")},
+	}, "com.lai-panel.managed-by")
+
+	assert.Len(t, projects, 2)
+	assert.Equal(t, "app", projects[0].Name)
+	assert.True(t, projects[0].Managed)
+
+	blog := projects[1]
+	assert.False(t, blog.Managed)
+	assert.Equal(t, "/srv/blog", blog.WorkingDir)
+	assert.Equal(t, []string{"/srv/blog/compose.yml", "/srv/blog/compose.override.yml"}, blog.ConfigFiles)
+	assert.Equal(t, []string{"db", "web"}, blog.Services)
+	assert.Equal(t, []DiscoveredContainer{
+		{ID: "2", Name: "blog-db-1", Service: "db", Image: "mysql", State: "exited"},
+		{ID: "1", Name: "blog-web-1", Service: "web", Image: "nginx", State: "running"},
+	}, blog.Containers)
+}
+
+func TestAdoptFile(t *testing.T) {
+	file, err := AdoptFile([][]byte{[]byte(`services:
+  web:
+    build: ./web
+    image: blog
+    env_file: .env.web
+    labels:
+      traefik.rule: "Host({{ .Host }})"
+    volumes:
+      - ./data:/data:ro
+      - cache:/cache
+      - /etc/localtime:/etc/localtime
+      - type: bind
+        source: ../shared
+        target: /shared
+    ports:
+      - "80:80"
+configs:
+  nginx:
+    file: ./nginx.conf
+volumes:
+  cache: {}
+`), []byte(`services:
+  web:
+    ports:
+      - "443:443"
+    environment:
+      MODE: prod
+`)}, "/srv/blog")
+	assert.NoError(t, err)
+
+	project, err := LoadProject(context.Background(), file, "docker-compose.yml", "/other", "blog", nil)
+	assert.NoError(t, err)
+	web := project.Services["web"]
+	assert.Equal(t, "/srv/blog/web", web.Build.Context)
+	assert.Equal(t, "/srv/blog/.env.web", web.EnvFiles[0].Path)
+	assert.Equal(t, "Host({{ .Host }})", web.Labels["traefik.rule"])
+	assert.Equal(t, "/srv/blog/data", web.Volumes[0].Source)
+	assert.Equal(t, "cache", web.Volumes[1].Source)
+	assert.Equal(t, "/etc/localtime", web.Volumes[2].Source)
+	assert.Equal(t, "/srv/shared", web.Volumes[3].Source)
+	assert.Len(t, web.Ports, 2)
+	assert.Equal(t, "prod", *web.Environment["MODE"])
+	assert.Equal(t, "/srv/blog/nginx.conf", project.Configs["nginx"].File)
+
+	escaped := EscapeTemplate(file)
+	assert.Contains(t, escaped, `Host({{"{{"}} .Host {{"}}"}})`)
+	rendered, err := tmpl.ParseWithEnv("adopted", escaped, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, file, rendered)
+}
+
+func TestHealthy(t *testing.T) {
+	running := &container.State{Status: container.StateRunning, Running: true}
+	starting := &container.State{Status: container.StateRunning, Running: true, Health: &container.Health{Status: container.Starting}}
+	passing := &container.State{Status: container.StateRunning, Running: true, Health: &container.Health{Status: container.Healthy}}
+	failing := &container.State{Status: container.StateRunning, Running: true, Health: &container.Health{Status: container.Unhealthy}}
+	exited := &container.State{Status: container.StateExited}
+
+	ready, err := healthy([]*container.State{running, passing})
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	ready, err = healthy([]*container.State{running, starting})
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	_, err = healthy([]*container.State{passing, failing})
+	assert.Error(t, err)
+	_, err = healthy([]*container.State{exited})
+	assert.Error(t, err)
+	_, err = healthy(nil)
+	assert.Error(t, err)
+}
+
+func TestAllRunning(t *testing.T) {
+	assert.True(t, allRunning([]container.Summary{{State: container.StateRunning}, {State: container.StateRunning}}))
+	assert.False(t, allRunning([]container.Summary{{State: container.StateRunning}, {State: container.StateExited}}))
+	assert.False(t, allRunning(nil))
+}
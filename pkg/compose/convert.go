@@ -0,0 +1,404 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+)
+
+// ConfigHash identifies the configuration of a service and the image it
+// runs, containers are only recreated when it changes. imageID is the
+// resolved id of the image, a tag pulled again with new content changes it.
+func ConfigHash(svc types.ServiceConfig, imageID string) (string, error) {
+	// scale does not change the containers
+	svc.Scale = nil
+	if svc.Deploy != nil {
+		deploy := *svc.Deploy
+		deploy.Replicas = nil
+		svc.Deploy = &deploy
+	}
+	data, err := json.Marshal(svc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append(data, imageID...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// containerSpec is everything needed to create the container of a service.
+type containerSpec struct {
+	config     *container.Config
+	hostConfig *container.HostConfig
+	// the first network is connected at creation, the others afterwards
+	networks []endpoint
+}
+
+type endpoint struct {
+	name     string
+	settings *network.EndpointSettings
+}
+
+func toContainerSpec(project *types.Project, svc types.ServiceConfig, number int, hash string) (*containerSpec, error) {
+	labels := map[string]string{}
+	for k, v := range svc.Labels {
+		labels[k] = v
+	}
+	labels[ProjectLabel] = project.Name
+	labels[ServiceLabel] = svc.Name
+	labels[ContainerNumberLabel] = strconv.Itoa(number)
+	labels[OneoffLabel] = "False"
+	labels[ConfigHashLabel] = hash
+	labels[WorkingDirLabel] = project.WorkingDir
+
+	exposed, bindings, err := toPorts(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &container.Config{
+		Hostname:     svc.Hostname,
+		Domainname:   svc.DomainName,
+		User:         svc.User,
+		ExposedPorts: exposed,
+		Tty:          svc.Tty,
+		OpenStdin:    svc.StdinOpen,
+		Env:          toEnv(svc.Environment),
+		Cmd:          []string(svc.Command),
+		Healthcheck:  toHealthcheck(svc.HealthCheck),
+		Image:        svc.Image,
+		WorkingDir:   svc.WorkingDir,
+		Entrypoint:   []string(svc.Entrypoint),
+		Labels:       labels,
+		StopSignal:   svc.StopSignal,
+	}
+	if svc.StopGracePeriod != nil {
+		timeout := int(time.Duration(*svc.StopGracePeriod).Seconds())
+		config.StopTimeout = &timeout
+	}
+
+	restart, err := toRestartPolicy(svc.Restart)
+	if err != nil {
+		return nil, err
+	}
+
+	binds, mounts := toMounts(project, svc)
+	hostConfig := &container.HostConfig{
+		Binds:          binds,
+		Mounts:         mounts,
+		PortBindings:   bindings,
+		RestartPolicy:  restart,
+		CapAdd:         svc.CapAdd,
+		CapDrop:        svc.CapDrop,
+		DNS:            svc.DNS,
+		DNSOptions:     svc.DNSOpts,
+		DNSSearch:      svc.DNSSearch,
+		ExtraHosts:     svc.ExtraHosts.AsList(":"),
+		GroupAdd:       svc.GroupAdd,
+		IpcMode:        container.IpcMode(svc.Ipc),
+		PidMode:        container.PidMode(svc.Pid),
+		Privileged:     svc.Privileged,
+		ReadonlyRootfs: svc.ReadOnly,
+		SecurityOpt:    svc.SecurityOpt,
+		Tmpfs:          toTmpfs(svc.Tmpfs),
+		UTSMode:        container.UTSMode(svc.Uts),
+		UsernsMode:     container.UsernsMode(svc.UserNSMode),
+		ShmSize:        int64(svc.ShmSize),
+		Sysctls:        svc.Sysctls,
+		Runtime:        svc.Runtime,
+		Init:           svc.Init,
+		Resources:      toResources(svc),
+	}
+	if svc.Logging != nil {
+		hostConfig.LogConfig = container.LogConfig{
+			Type:   svc.Logging.Driver,
+			Config: svc.Logging.Options,
+		}
+	}
+
+	spec := &containerSpec{
+		config:     config,
+		hostConfig: hostConfig,
+	}
+
+	if svc.NetworkMode != "" {
+		hostConfig.NetworkMode = container.NetworkMode(svc.NetworkMode)
+		return spec, nil
+	}
+
+	for i, key := range svc.NetworksByPriority() {
+		cfg := svc.Networks[key]
+		nw, ok := project.Networks[key]
+		if !ok {
+			return nil, fmt.Errorf("service %q refers to undefined network %q", svc.Name, key)
+		}
+
+		settings := &network.EndpointSettings{
+			Aliases: []string{svc.Name},
+		}
+		if cfg != nil {
+			settings.Aliases = append(settings.Aliases, cfg.Aliases...)
+			settings.MacAddress = cfg.MacAddress
+			settings.DriverOpts = cfg.DriverOpts
+			settings.GwPriority = cfg.GatewayPriority
+			if cfg.Ipv4Address != "" || cfg.Ipv6Address != "" || len(cfg.LinkLocalIPs) > 0 {
+				settings.IPAMConfig = &network.EndpointIPAMConfig{
+					IPv4Address:  cfg.Ipv4Address,
+					IPv6Address:  cfg.Ipv6Address,
+					LinkLocalIPs: cfg.LinkLocalIPs,
+				}
+			}
+		}
+		if i == 0 {
+			hostConfig.NetworkMode = container.NetworkMode(nw.Name)
+		}
+		spec.networks = append(spec.networks, endpoint{name: nw.Name, settings: settings})
+	}
+
+	return spec, nil
+}
+
+func toEnv(environment types.MappingWithEquals) []string {
+	env := make([]string, 0, len(environment))
+	for k, v := range environment {
+		if v == nil {
+			continue
+		}
+		env = append(env, k+"="+*v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+func toPorts(svc types.ServiceConfig) (nat.PortSet, nat.PortMap, error) {
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+
+	for _, e := range svc.Expose {
+		proto, port := nat.SplitProtoPort(e)
+		p, err := nat.NewPort(proto, port)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %q: invalid expose %q: %w", svc.Name, e, err)
+		}
+		exposed[p] = struct{}{}
+	}
+
+	for _, cfg := range svc.Ports {
+		protocol := cfg.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		p, err := nat.NewPort(protocol, strconv.FormatUint(uint64(cfg.Target), 10))
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %q: invalid port %d: %w", svc.Name, cfg.Target, err)
+		}
+		exposed[p] = struct{}{}
+		bindings[p] = append(bindings[p], nat.PortBinding{
+			HostIP:   cfg.HostIP,
+			HostPort: cfg.Published,
+		})
+	}
+	return exposed, bindings, nil
+}
+
+func toHealthcheck(hc *types.HealthCheckConfig) *container.HealthConfig {
+	if hc == nil {
+		return nil
+	}
+	if hc.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}
+	}
+
+	config := &container.HealthConfig{
+		Test: hc.Test,
+	}
+	if hc.Interval != nil {
+		config.Interval = time.Duration(*hc.Interval)
+	}
+	if hc.Timeout != nil {
+		config.Timeout = time.Duration(*hc.Timeout)
+	}
+	if hc.StartPeriod != nil {
+		config.StartPeriod = time.Duration(*hc.StartPeriod)
+	}
+	if hc.StartInterval != nil {
+		config.StartInterval = time.Duration(*hc.StartInterval)
+	}
+	if hc.Retries != nil {
+		config.Retries = int(*hc.Retries)
+	}
+	return config
+}
+
+func toRestartPolicy(restart string) (container.RestartPolicy, error) {
+	name, count, _ := strings.Cut(restart, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if name == "" {
+		policy.Name = container.RestartPolicyDisabled
+	}
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return policy, fmt.Errorf("invalid restart policy %q", restart)
+		}
+		policy.MaximumRetryCount = n
+	}
+	return policy, nil
+}
+
+// toMounts converts the service volumes: bind mounts use the Binds form so
+// docker creates missing host paths when create_host_path is set.
+func toMounts(project *types.Project, svc types.ServiceConfig) ([]string, []mount.Mount) {
+	binds := []string{}
+	mounts := []mount.Mount{}
+
+	for _, v := range svc.Volumes {
+		switch v.Type {
+		case types.VolumeTypeBind:
+			if v.Bind == nil || v.Bind.CreateHostPath {
+				options := []string{}
+				if v.ReadOnly {
+					options = append(options, "ro")
+				}
+				if v.Bind != nil && v.Bind.SELinux != "" {
+					options = append(options, v.Bind.SELinux)
+				}
+				if v.Bind != nil && v.Bind.Propagation != "" {
+					options = append(options, v.Bind.Propagation)
+				}
+				bind := v.Source + ":" + v.Target
+				if len(options) > 0 {
+					bind += ":" + strings.Join(options, ",")
+				}
+				binds = append(binds, bind)
+				continue
+			}
+			mounts = append(mounts, mount.Mount{
+				Type:        mount.TypeBind,
+				Source:      v.Source,
+				Target:      v.Target,
+				ReadOnly:    v.ReadOnly,
+				BindOptions: &mount.BindOptions{Propagation: mount.Propagation(v.Bind.Propagation)},
+			})
+		case types.VolumeTypeVolume:
+			source := v.Source
+			if vol, ok := project.Volumes[v.Source]; ok && vol.Name != "" {
+				source = vol.Name
+			}
+			m := mount.Mount{
+				Type:     mount.TypeVolume,
+				Source:   source,
+				Target:   v.Target,
+				ReadOnly: v.ReadOnly,
+			}
+			if v.Volume != nil {
+				m.VolumeOptions = &mount.VolumeOptions{
+					NoCopy:  v.Volume.NoCopy,
+					Subpath: v.Volume.Subpath,
+				}
+			}
+			mounts = append(mounts, m)
+		case types.VolumeTypeTmpfs:
+			m := mount.Mount{
+				Type:   mount.TypeTmpfs,
+				Target: v.Target,
+			}
+			if v.Tmpfs != nil {
+				m.TmpfsOptions = &mount.TmpfsOptions{
+					SizeBytes: int64(v.Tmpfs.Size),
+				}
+			}
+			mounts = append(mounts, m)
+		}
+	}
+	return binds, mounts
+}
+
+func toTmpfs(list types.StringList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	tmpfs := map[string]string{}
+	for _, entry := range list {
+		target, options, _ := strings.Cut(entry, ":")
+		tmpfs[target] = options
+	}
+	return tmpfs
+}
+
+func toResources(svc types.ServiceConfig) container.Resources {
+	resources := container.Resources{
+		CPUShares:         svc.CPUShares,
+		CPUPeriod:         svc.CPUPeriod,
+		CPUQuota:          svc.CPUQuota,
+		CpusetCpus:        svc.CPUSet,
+		NanoCPUs:          int64(svc.CPUS * 1e9),
+		Memory:            int64(svc.MemLimit),
+		MemoryReservation: int64(svc.MemReservation),
+		MemorySwap:        int64(svc.MemSwapLimit),
+		OomKillDisable:    &svc.OomKillDisable,
+		CgroupParent:      svc.CgroupParent,
+	}
+	if svc.PidsLimit != 0 {
+		resources.PidsLimit = &svc.PidsLimit
+	}
+
+	if svc.Deploy != nil && svc.Deploy.Resources.Limits != nil {
+		limits := svc.Deploy.Resources.Limits
+		if limits.MemoryBytes != 0 {
+			resources.Memory = int64(limits.MemoryBytes)
+		}
+		if limits.NanoCPUs != 0 {
+			resources.NanoCPUs = int64(float64(limits.NanoCPUs) * 1e9)
+		}
+		if limits.Pids != 0 {
+			resources.PidsLimit = &limits.Pids
+		}
+	}
+	if svc.Deploy != nil && svc.Deploy.Resources.Reservations != nil && svc.Deploy.Resources.Reservations.MemoryBytes != 0 {
+		resources.MemoryReservation = int64(svc.Deploy.Resources.Reservations.MemoryBytes)
+	}
+
+	for _, d := range svc.Devices {
+		permissions := d.Permissions
+		if permissions == "" {
+			permissions = "rwm"
+		}
+		target := d.Target
+		if target == "" {
+			target = d.Source
+		}
+		resources.Devices = append(resources.Devices, container.DeviceMapping{
+			PathOnHost:        d.Source,
+			PathInContainer:   target,
+			CgroupPermissions: permissions,
+		})
+	}
+
+	names := make([]string, 0, len(svc.Ulimits))
+	for name := range svc.Ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := svc.Ulimits[name]
+		soft, hard := int64(limit.Soft), int64(limit.Hard)
+		if limit.Single != 0 {
+			soft, hard = int64(limit.Single), int64(limit.Single)
+		}
+		resources.Ulimits = append(resources.Ulimits, &units.Ulimit{Name: name, Soft: soft, Hard: hard})
+	}
+
+	return resources
+}
@@ -0,0 +1,119 @@
+package compose
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerClient "github.com/docker/docker/client"
+)
+
+// ConfigFilesLabel lists the compose files a project was started from.
+const ConfigFilesLabel = "com.docker.compose.project.config_files"
+
+// DiscoveredProject is a compose project found on a node from the labels of
+// its containers.
+type DiscoveredProject struct {
+	Name       string `json:"name"`
+	WorkingDir string `json:"working_dir"`
+	// ConfigFiles are absolute paths on the node, in the order compose
+	// loaded them
+	ConfigFiles []string              `json:"config_files"`
+	Services    []string              `json:"services"`
+	Containers  []DiscoveredContainer `json:"containers"`
+	// Managed is set when the containers carry the labels of the panel
+	Managed bool `json:"managed"`
+}
+
+type DiscoveredContainer struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Service string `json:"service"`
+	Image   string `json:"image"`
+	State   string `json:"state"`
+}
+
+// Discover lists the compose projects running on the node.
+func Discover(ctx context.Context, dc *dockerClient.Client, managedLabel string) ([]*DiscoveredProject, error) {
+	containers, err := dc.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", ProjectLabel)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GroupProjects(containers, managedLabel), nil
+}
+
+// GroupProjects groups containers by compose project. One-off containers
+// of compose run are left out, a project is managed when one of its
+// containers has managedLabel.
+func GroupProjects(containers []container.Summary, managedLabel string) []*DiscoveredProject {
+	byName := map[string]*DiscoveredProject{}
+	for _, c := range containers {
+		name := c.Labels[ProjectLabel]
+		if name == "" || c.Labels[OneoffLabel] == "True" {
+			continue
+		}
+		p, ok := byName[name]
+		if !ok {
+			p = &DiscoveredProject{Name: name, ConfigFiles: []string{}, Services: []string{}}
+			byName[name] = p
+		}
+		if p.WorkingDir == "" {
+			p.WorkingDir = c.Labels[WorkingDirLabel]
+		}
+		if len(p.ConfigFiles) == 0 {
+			p.ConfigFiles = splitConfigFiles(c.Labels[ConfigFilesLabel])
+		}
+		if _, ok := c.Labels[managedLabel]; ok {
+			p.Managed = true
+		}
+
+		service := c.Labels[ServiceLabel]
+		if service != "" && !contains(p.Services, service) {
+			p.Services = append(p.Services, service)
+		}
+		p.Containers = append(p.Containers, DiscoveredContainer{
+			ID:      c.ID,
+			Name:    containerName(c.Names),
+			Service: service,
+			Image:   c.Image,
+			State:   c.State,
+		})
+	}
+
+	projects := make([]*DiscoveredProject, 0, len(byName))
+	for _, p := range byName {
+		sort.Strings(p.Services)
+		sort.Slice(p.Containers, func(i, j int) bool {
+			return p.Containers[i].Name < p.Containers[j].Name
+		})
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+	return projects
+}
+
+func splitConfigFiles(label string) []string {
+	files := []string{}
+	for _, f := range strings.Split(label, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,732 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/sync/errgroup"
+)
+
+// dependencyTimeout bounds the wait for service_healthy and
+// service_completed_successfully conditions.
+const dependencyTimeout = 5 * time.Minute
+
+// Engine runs compose projects through the docker API, without the
+// docker compose command on the node.
+type Engine struct {
+	dc           *dockerClient.Client
+	report       func(string)
+	registryAuth func(ref string) (string, error)
+}
+
+// NewEngine creates an engine for a node docker client, progress messages are
+// passed to report.
+func NewEngine(dc *dockerClient.Client, report func(string)) *Engine {
+	if report == nil {
+		report = func(string) {}
+	}
+	return &Engine{
+		dc:     dc,
+		report: report,
+	}
+}
+
+// WithRegistryAuth pulls the images with the encoded registry credential
+// returned by auth for the image reference, empty when there is none.
+func (e *Engine) WithRegistryAuth(auth func(ref string) (string, error)) *Engine {
+	e.registryAuth = auth
+	return e
+}
+
+// Up creates or updates the networks, volumes and containers of the project
+// and starts them in dependency order. Containers whose configuration did not
+// change are kept.
+func (e *Engine) Up(ctx context.Context, project *types.Project) error {
+	order, err := ServicesInOrder(project)
+	if err != nil {
+		return err
+	}
+
+	if err := e.ensureNetworks(ctx, project); err != nil {
+		return err
+	}
+	if err := e.ensureVolumes(ctx, project); err != nil {
+		return err
+	}
+
+	// container ids by service, used by network_mode: service:<name>
+	ids := map[string][]string{}
+	for _, name := range order {
+		svc := project.Services[name]
+		if err := e.waitDependencies(ctx, project, svc); err != nil {
+			return err
+		}
+		svcIDs, err := e.upService(ctx, project, svc, ids)
+		if err != nil {
+			return fmt.Errorf("service %q: %w", name, err)
+		}
+		ids[name] = svcIDs
+	}
+
+	return e.warnOrphans(ctx, project)
+}
+
+// Down stops and removes the containers and networks of a project, volumes
+// are removed as well when removeVolumes is set.
+func (e *Engine) Down(ctx context.Context, projectName string, removeVolumes bool) error {
+	containers, err := e.projectContainers(ctx, projectName, true)
+	if err != nil {
+		return err
+	}
+	for _, ctr := range containers {
+		e.report("removing container " + containerName(ctr.Names))
+		if err := e.removeContainer(ctx, ctr.ID); err != nil {
+			return err
+		}
+	}
+
+	networks, err := e.dc.NetworkList(ctx, network.ListOptions{Filters: projectFilter(projectName)})
+	if err != nil {
+		return err
+	}
+	for _, nw := range networks {
+		e.report("removing network " + nw.Name)
+		if err := e.dc.NetworkRemove(ctx, nw.ID); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if !removeVolumes {
+		return nil
+	}
+	volumes, err := e.dc.VolumeList(ctx, volume.ListOptions{Filters: projectFilter(projectName)})
+	if err != nil {
+		return err
+	}
+	for _, vol := range volumes.Volumes {
+		e.report("removing volume " + vol.Name)
+		if err := e.dc.VolumeRemove(ctx, vol.Name, false); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restart restarts all containers of a project.
+func (e *Engine) Restart(ctx context.Context, projectName string) error {
+	containers, err := e.projectContainers(ctx, projectName, true)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no containers found for project %q", projectName)
+	}
+	for _, ctr := range containers {
+		e.report("restarting container " + containerName(ctr.Names))
+		if err := e.dc.ContainerRestart(ctx, ctr.ID, container.StopOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Logs streams the logs of the containers of a project, every line is
+// prefixed with the container name.
+func (e *Engine) Logs(ctx context.Context, projectName string, tail string, follow bool, onLine func(string)) error {
+	containers, err := e.projectContainers(ctx, projectName, true)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no containers found for project %q", projectName)
+	}
+
+	var mu sync.Mutex
+	g, ctx := errgroup.WithContext(ctx)
+	for _, ctr := range containers {
+		g.Go(func() error {
+			name := containerName(ctr.Names)
+			writer := &lineWriter{onLine: func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				onLine(name + " | " + line)
+			}}
+			defer writer.Flush()
+
+			info, err := e.dc.ContainerInspect(ctx, ctr.ID)
+			if err != nil {
+				return err
+			}
+			logs, err := e.dc.ContainerLogs(ctx, ctr.ID, container.LogsOptions{
+				ShowStdout: true,
+				ShowStderr: true,
+				Follow:     follow,
+				Tail:       tail,
+			})
+			if err != nil {
+				return err
+			}
+			defer logs.Close()
+
+			if info.Config != nil && info.Config.Tty {
+				_, err = io.Copy(writer, logs)
+			} else {
+				_, err = stdcopy.StdCopy(writer, writer, logs)
+			}
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// lineWriter splits a stream into lines.
+type lineWriter struct {
+	buf    []byte
+	onLine func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (e *Engine) ensureNetworks(ctx context.Context, project *types.Project) error {
+	keys := make([]string, 0, len(project.Networks))
+	for key := range project.Networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		nw := project.Networks[key]
+		if !isUsedNetwork(project, key) {
+			continue
+		}
+		existing, err := e.dc.NetworkInspect(ctx, nw.Name, network.InspectOptions{})
+		if err == nil {
+			if !bool(nw.External) && existing.Labels[ProjectLabel] != project.Name {
+				e.report(fmt.Sprintf("warning: network %s exists but was not created for project %s", nw.Name, project.Name))
+			}
+			continue
+		}
+		if !errdefs.IsNotFound(err) {
+			return err
+		}
+		if bool(nw.External) {
+			return fmt.Errorf("external network %s not found", nw.Name)
+		}
+
+		labels := map[string]string{}
+		for k, v := range nw.Labels {
+			labels[k] = v
+		}
+		labels[ProjectLabel] = project.Name
+		labels[NetworkLabel] = key
+
+		options := network.CreateOptions{
+			Driver:     nw.Driver,
+			Options:    nw.DriverOpts,
+			Internal:   nw.Internal,
+			Attachable: nw.Attachable,
+			Labels:     labels,
+			EnableIPv6: nw.EnableIPv6,
+		}
+		if nw.Ipam.Driver != "" || len(nw.Ipam.Config) > 0 {
+			options.IPAM = &network.IPAM{Driver: nw.Ipam.Driver}
+			for _, cfg := range nw.Ipam.Config {
+				options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{
+					Subnet:     cfg.Subnet,
+					IPRange:    cfg.IPRange,
+					Gateway:    cfg.Gateway,
+					AuxAddress: cfg.AuxiliaryAddresses,
+				})
+			}
+		}
+
+		e.report("creating network " + nw.Name)
+		if _, err := e.dc.NetworkCreate(ctx, nw.Name, options); err != nil {
+			return fmt.Errorf("failed to create network %s: %w", nw.Name, err)
+		}
+	}
+	return nil
+}
+
+func isUsedNetwork(project *types.Project, key string) bool {
+	for _, svc := range project.Services {
+		if _, ok := svc.Networks[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) ensureVolumes(ctx context.Context, project *types.Project) error {
+	keys := make([]string, 0, len(project.Volumes))
+	for key := range project.Volumes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		vol := project.Volumes[key]
+		_, err := e.dc.VolumeInspect(ctx, vol.Name)
+		if err == nil {
+			continue
+		}
+		if !errdefs.IsNotFound(err) {
+			return err
+		}
+		if bool(vol.External) {
+			return fmt.Errorf("external volume %s not found", vol.Name)
+		}
+
+		labels := map[string]string{}
+		for k, v := range vol.Labels {
+			labels[k] = v
+		}
+		labels[ProjectLabel] = project.Name
+		labels[VolumeLabel] = key
+
+		e.report("creating volume " + vol.Name)
+		if _, err := e.dc.VolumeCreate(ctx, volume.CreateOptions{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			DriverOpts: vol.DriverOpts,
+			Labels:     labels,
+		}); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", vol.Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) upService(ctx context.Context, project *types.Project, svc types.ServiceConfig, ids map[string][]string) ([]string, error) {
+	if svc.Image == "" {
+		return nil, errors.New("build is not supported by the native compose engine, set an image")
+	}
+
+	if strings.HasPrefix(svc.NetworkMode, "service:") {
+		target := strings.TrimPrefix(svc.NetworkMode, "service:")
+		if len(ids[target]) == 0 {
+			return nil, fmt.Errorf("network_mode refers to service %q without containers", target)
+		}
+		svc.NetworkMode = "container:" + ids[target][0]
+	}
+
+	scale := 1
+	if s := svc.GetScale(); s >= 0 {
+		scale = s
+	}
+	if svc.ContainerName != "" && scale > 1 {
+		return nil, errors.New("container_name can not be used with more than one replica")
+	}
+
+	existing, err := e.serviceContainers(ctx, project.Name, svc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	imageID, err := e.ensureImage(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := ConfigHash(svc, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for number := 1; number <= scale; number++ {
+		name := svc.ContainerName
+		if name == "" {
+			name = fmt.Sprintf("%s-%s-%d", project.Name, svc.Name, number)
+		}
+
+		ctr, ok := existing[number]
+		delete(existing, number)
+		if ok && ctr.Labels[ConfigHashLabel] == hash {
+			if ctr.State != container.StateRunning {
+				e.report("starting container " + name)
+				if err := e.dc.ContainerStart(ctx, ctr.ID, container.StartOptions{}); err != nil {
+					return nil, err
+				}
+			} else {
+				e.report("container " + name + " is up to date")
+			}
+			result = append(result, ctr.ID)
+			continue
+		}
+		if ok {
+			e.report("recreating container " + name)
+			if err := e.removeContainer(ctx, ctr.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		id, err := e.createContainer(ctx, project, svc, number, hash, name)
+		if err != nil {
+			return nil, err
+		}
+		e.report("starting container " + name)
+		if err := e.dc.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+
+	// scaled down
+	for _, ctr := range existing {
+		e.report("removing container " + containerName(ctr.Names))
+		if err := e.removeContainer(ctx, ctr.ID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (e *Engine) createContainer(ctx context.Context, project *types.Project, svc types.ServiceConfig, number int, hash string, name string) (string, error) {
+	spec, err := toContainerSpec(project, svc, number, hash)
+	if err != nil {
+		return "", err
+	}
+
+	var networking *network.NetworkingConfig
+	if len(spec.networks) > 0 {
+		first := spec.networks[0]
+		networking = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{first.name: first.settings},
+		}
+	}
+
+	e.report("creating container " + name)
+	created, err := e.dc.ContainerCreate(ctx, spec.config, spec.hostConfig, networking, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container %s: %w", name, err)
+	}
+	for _, ep := range spec.networks[min(1, len(spec.networks)):] {
+		if err := e.dc.NetworkConnect(ctx, ep.name, created.ID, ep.settings); err != nil {
+			return "", fmt.Errorf("failed to connect container %s to network %s: %w", name, ep.name, err)
+		}
+	}
+	return created.ID, nil
+}
+
+// ensureImage pulls the image of the service when needed and returns its id.
+func (e *Engine) ensureImage(ctx context.Context, svc types.ServiceConfig) (string, error) {
+	if svc.PullPolicy != types.PullPolicyAlways {
+		inspect, err := e.dc.ImageInspect(ctx, svc.Image)
+		if err == nil {
+			return inspect.ID, nil
+		}
+		if !errdefs.IsNotFound(err) {
+			return "", err
+		}
+		if svc.PullPolicy == types.PullPolicyNever {
+			return "", fmt.Errorf("image %s not found and pull_policy is never", svc.Image)
+		}
+	}
+
+	auth := ""
+	if e.registryAuth != nil {
+		var err error
+		auth, err = e.registryAuth(svc.Image)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	e.report("pulling image " + svc.Image)
+	reader, err := e.dc.ImagePull(ctx, svc.Image, image.PullOptions{
+		Platform:     svc.Platform,
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %w", svc.Image, err)
+	}
+	defer reader.Close()
+	// the pull is finished once the progress stream is drained, a failed
+	// pull is reported in the stream
+	err = node.DecodeImageProgress(reader, func(msg string) {
+		e.report(svc.Image + ": " + msg)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %w", svc.Image, err)
+	}
+
+	inspect, err := e.dc.ImageInspect(ctx, svc.Image)
+	if err != nil {
+		return "", err
+	}
+	return inspect.ID, nil
+}
+
+// waitDependencies blocks until the dependencies of svc satisfy their
+// depends_on conditions, service_started is satisfied by the ordering.
+func (e *Engine) waitDependencies(ctx context.Context, project *types.Project, svc types.ServiceConfig) error {
+	deps := make([]string, 0, len(svc.DependsOn))
+	for dep := range svc.DependsOn {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+
+	for _, dep := range deps {
+		condition := svc.DependsOn[dep].Condition
+		if condition != types.ServiceConditionHealthy && condition != types.ServiceConditionCompletedSuccessfully {
+			continue
+		}
+		if _, ok := project.Services[dep]; !ok {
+			continue
+		}
+
+		e.report(fmt.Sprintf("waiting for %s (%s)", dep, condition))
+		if err := e.waitCondition(ctx, project.Name, dep, condition); err != nil {
+			return fmt.Errorf("service %q: dependency %q: %w", svc.Name, dep, err)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) waitCondition(ctx context.Context, projectName string, service string, condition string) error {
+	ctx, cancel := context.WithTimeout(ctx, dependencyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		containers, err := e.serviceContainers(ctx, projectName, service)
+		if err != nil {
+			return err
+		}
+
+		ready := len(containers) > 0
+		for _, ctr := range containers {
+			info, err := e.dc.ContainerInspect(ctx, ctr.ID)
+			if err != nil {
+				return err
+			}
+			ok, err := conditionMet(condition, info.State)
+			if err != nil {
+				return err
+			}
+			ready = ready && ok
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s", condition)
+		case <-ticker.C:
+		}
+	}
+}
+
+func conditionMet(condition string, state *container.State) (bool, error) {
+	if state == nil {
+		return false, nil
+	}
+	switch condition {
+	case types.ServiceConditionHealthy:
+		if state.Health == nil {
+			return false, errors.New("container has no healthcheck")
+		}
+		if state.Health.Status == container.Unhealthy {
+			return false, errors.New("container is unhealthy")
+		}
+		return state.Health.Status == container.Healthy, nil
+	case types.ServiceConditionCompletedSuccessfully:
+		if state.Running || state.Status == container.StateCreated {
+			return false, nil
+		}
+		if state.ExitCode != 0 {
+			return false, fmt.Errorf("container exited with code %d", state.ExitCode)
+		}
+		return true, nil
+	}
+	return true, nil
+}
+
+// WaitHealthy blocks until every container of the project runs and passes
+// its healthcheck, it fails as soon as a container stopped or is unhealthy.
+func (e *Engine) WaitHealthy(ctx context.Context, projectName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		containers, err := e.projectContainers(ctx, projectName, true)
+		if err != nil {
+			return err
+		}
+		states := make([]*container.State, 0, len(containers))
+		for _, ctr := range containers {
+			if ctr.Labels[OneoffLabel] == "True" {
+				continue
+			}
+			info, err := e.dc.ContainerInspect(ctx, ctr.ID)
+			if err != nil {
+				return err
+			}
+			states = append(states, info.State)
+		}
+		ready, err := healthy(states)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for the containers to be healthy")
+		case <-ticker.C:
+		}
+	}
+}
+
+// Running reports whether the project has containers and all of them run.
+func (e *Engine) Running(ctx context.Context, projectName string) (bool, error) {
+	containers, err := e.projectContainers(ctx, projectName, true)
+	if err != nil {
+		return false, err
+	}
+	return allRunning(containers), nil
+}
+
+func allRunning(containers []container.Summary) bool {
+	if len(containers) == 0 {
+		return false
+	}
+	for _, ctr := range containers {
+		if ctr.State != container.StateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// healthy reports whether all containers are up, containers still starting
+// are not ready yet.
+func healthy(states []*container.State) (bool, error) {
+	if len(states) == 0 {
+		return false, errors.New("no containers found")
+	}
+	ready := true
+	for _, state := range states {
+		if state == nil {
+			return false, nil
+		}
+		switch {
+		case state.Restarting || state.Status == container.StateCreated:
+			ready = false
+		case !state.Running:
+			return false, fmt.Errorf("container is %s", state.Status)
+		case state.Health == nil:
+		case state.Health.Status == container.Unhealthy:
+			return false, errors.New("container is unhealthy")
+		case state.Health.Status != container.Healthy:
+			ready = false
+		}
+	}
+	return ready, nil
+}
+
+func (e *Engine) warnOrphans(ctx context.Context, project *types.Project) error {
+	containers, err := e.projectContainers(ctx, project.Name, true)
+	if err != nil {
+		return err
+	}
+	for _, ctr := range containers {
+		if _, ok := project.Services[ctr.Labels[ServiceLabel]]; !ok {
+			e.report(fmt.Sprintf("warning: found orphan container %s for service %q", containerName(ctr.Names), ctr.Labels[ServiceLabel]))
+		}
+	}
+	return nil
+}
+
+func (e *Engine) removeContainer(ctx context.Context, id string) error {
+	if err := e.dc.ContainerStop(ctx, id, container.StopOptions{}); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	if err := e.dc.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (e *Engine) projectContainers(ctx context.Context, projectName string, all bool) ([]container.Summary, error) {
+	args := projectFilter(projectName)
+	args.Add("label", OneoffLabel+"=False")
+	return e.dc.ContainerList(ctx, container.ListOptions{All: all, Filters: args})
+}
+
+// serviceContainers returns the containers of a service by container number.
+func (e *Engine) serviceContainers(ctx context.Context, projectName string, service string) (map[int]container.Summary, error) {
+	args := projectFilter(projectName)
+	args.Add("label", OneoffLabel+"=False")
+	args.Add("label", ServiceLabel+"="+service)
+	containers, err := e.dc.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]container.Summary, len(containers))
+	for _, ctr := range containers {
+		number, err := strconv.Atoi(ctr.Labels[ContainerNumberLabel])
+		if err != nil {
+			number = 1
+		}
+		result[number] = ctr
+	}
+	return result, nil
+}
+
+func projectFilter(projectName string) filters.Args {
+	return filters.NewArgs(filters.Arg("label", ProjectLabel+"="+projectName))
+}
+
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
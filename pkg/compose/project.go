@@ -0,0 +1,161 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/constant"
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/compose-spec/compose-go/v2/loader"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// labels set by docker compose, the native engine uses the same ones so
+// projects can be handled by both backends.
+const (
+	ProjectLabel         = "com.docker.compose.project"
+	ServiceLabel         = "com.docker.compose.service"
+	ContainerNumberLabel = "com.docker.compose.container-number"
+	OneoffLabel          = "com.docker.compose.oneoff"
+	ConfigHashLabel      = "com.docker.compose.config-hash"
+	WorkingDirLabel      = "com.docker.compose.project.working_dir"
+	NetworkLabel         = "com.docker.compose.network"
+	VolumeLabel          = "com.docker.compose.volume"
+)
+
+// LoadProject parses and validates a compose file against the compose
+// specification. Paths are resolved against workingDir, which may only exist
+// on the target node, so env files are left to ResolveEnvironment.
+func LoadProject(ctx context.Context, file string, fileName string, workingDir string, name string, env map[string]string) (*types.Project, error) {
+	return loader.LoadWithContext(ctx, types.ConfigDetails{
+		WorkingDir: workingDir,
+		ConfigFiles: []types.ConfigFile{
+			{Filename: fileName, Content: []byte(file)},
+		},
+		Environment: env,
+	}, func(o *loader.Options) {
+		o.SetProjectName(ProjectName(name), true)
+		o.ResolvePaths = true
+		// env files live on the target node
+		o.SkipResolveEnvironment = true
+	})
+}
+
+// ProjectName returns the compose project name of a service, docker compose
+// derives the same name from the service directory.
+func ProjectName(name string) string {
+	return loader.NormalizeProjectName(name)
+}
+
+// ServiceFilters selects the containers of a panel service. project is the
+// compose project of an adopted service, it carries the labels of the panel
+// after a deploy only.
+func ServiceFilters(name string, project string) filters.Args {
+	if project != "" {
+		return filters.NewArgs(filters.Arg("label", ProjectLabel+"="+project))
+	}
+	return filters.NewArgs(
+		filters.Arg("label", constant.OwnerLabel+"="+constant.ProjectId),
+		filters.Arg("label", constant.ManagedByLabel+"="+constant.ProjectId),
+		filters.Arg("label", constant.ServiceLabel+"="+name),
+	)
+}
+
+// ResolveEnvironment computes the container environment of every service:
+// env_file entries are merged with environment, which wins, and variables
+// without a value are looked up in env. readFile reads from the node.
+func ResolveEnvironment(project *types.Project, env map[string]string, readFile func(path string) ([]byte, error)) error {
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	for name, svc := range project.Services {
+		environment := types.MappingWithEquals{}
+		for _, envFile := range svc.EnvFiles {
+			content, err := readFile(envFile.Path)
+			if err != nil {
+				if !envFile.Required {
+					continue
+				}
+				return fmt.Errorf("service %q: failed to read env file %s: %w", name, envFile.Path, err)
+			}
+			values, err := dotenv.ParseWithLookup(bytes.NewReader(content), lookup)
+			if err != nil {
+				return fmt.Errorf("service %q: failed to parse env file %s: %w", name, envFile.Path, err)
+			}
+			for k, v := range values {
+				environment[k] = &v
+			}
+		}
+		for k, v := range svc.Environment {
+			environment[k] = v
+		}
+
+		svc.Environment = environment.Resolve(lookup).RemoveEmpty()
+		svc.EnvFiles = nil
+		project.Services[name] = svc
+	}
+	return nil
+}
+
+// ServicesInOrder sorts the services so that every service comes after the
+// services it depends on.
+func ServicesInOrder(project *types.Project) ([]string, error) {
+	names := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int, len(names))
+	ordered := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+		state[name] = visiting
+
+		svc := project.Services[name]
+		deps := make([]string, 0, len(svc.DependsOn))
+		for dep := range svc.DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := project.Services[dep]; !ok {
+				if svc.DependsOn[dep].Required {
+					return fmt.Errorf("service %q depends on undefined service %q", name, dep)
+				}
+				continue
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		ordered = append(ordered, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,250 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"gopkg.in/yaml.v3"
+)
+
+// Service is a container definition read from a docker run command or a
+// running container, ready to be written as a compose service.
+type Service struct {
+	Name        string
+	Image       string
+	Entrypoint  []string
+	Command     []string
+	Environment map[string]string
+	// PassEnv are variables docker run takes from the shell, their value is
+	// unknown
+	PassEnv     []string
+	EnvFiles    []string
+	Ports       []string
+	Volumes     []string
+	Restart     string
+	NetworkMode string
+	Networks    []string
+	Labels      map[string]string
+	Hostname    string
+	User        string
+	WorkingDir  string
+	Privileged  bool
+	CapAdd      []string
+	ExtraHosts  []string
+	Devices     []string
+	// Warnings lists the options left out of the compose service
+	Warnings []string
+}
+
+func newService() *Service {
+	return &Service{
+		Environment: map[string]string{},
+		Labels:      map[string]string{},
+	}
+}
+
+type composeService struct {
+	Image       string            `yaml:"image"`
+	Hostname    string            `yaml:"hostname,omitempty"`
+	User        string            `yaml:"user,omitempty"`
+	WorkingDir  string            `yaml:"working_dir,omitempty"`
+	Entrypoint  []string          `yaml:"entrypoint,omitempty"`
+	Command     []string          `yaml:"command,omitempty"`
+	Restart     string            `yaml:"restart,omitempty"`
+	Privileged  bool              `yaml:"privileged,omitempty"`
+	CapAdd      []string          `yaml:"cap_add,omitempty"`
+	NetworkMode string            `yaml:"network_mode,omitempty"`
+	Networks    []string          `yaml:"networks,omitempty"`
+	Ports       []string          `yaml:"ports,omitempty"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+	EnvFile     []string          `yaml:"env_file,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	ExtraHosts  []string          `yaml:"extra_hosts,omitempty"`
+	Devices     []string          `yaml:"devices,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+}
+
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+	Networks map[string]composeNetwork `yaml:"networks,omitempty"`
+	Volumes  map[string]composeVolume  `yaml:"volumes,omitempty"`
+}
+
+type composeNetwork struct {
+	External bool `yaml:"external"`
+}
+
+type composeVolume struct {
+	Name string `yaml:"name"`
+}
+
+var serviceNamePattern = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// ServiceName returns a compose service name for a container name or image.
+func ServiceName(s *Service) string {
+	name := s.Name
+	if name == "" {
+		name = s.Image
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if i := strings.IndexAny(name, ":@"); i >= 0 {
+			name = name[:i]
+		}
+	}
+	name = strings.Trim(serviceNamePattern.ReplaceAllString(strings.ToLower(name), "-"), "-._")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+// Template writes the service as the compose template of an app. Environment
+// values are moved to QA items, the template refers to them by compose
+// interpolation so the values are read from the env file of the deploy.
+func Template(s *Service) (string, []*model.AppQAItem, error) {
+	svc := composeService{
+		Image:       escape(s.Image),
+		Hostname:    escape(s.Hostname),
+		User:        escape(s.User),
+		WorkingDir:  escape(s.WorkingDir),
+		Entrypoint:  escapeAll(s.Entrypoint),
+		Command:     escapeAll(s.Command),
+		Restart:     s.Restart,
+		Privileged:  s.Privileged,
+		CapAdd:      s.CapAdd,
+		NetworkMode: s.NetworkMode,
+		Networks:    s.Networks,
+		Ports:       escapeAll(s.Ports),
+		Volumes:     escapeAll(s.Volumes),
+		EnvFile:     escapeAll(s.EnvFiles),
+		ExtraHosts:  s.ExtraHosts,
+		Devices:     escapeAll(s.Devices),
+		Labels:      map[string]string{},
+		Environment: map[string]string{},
+	}
+	for k, v := range s.Labels {
+		svc.Labels[k] = escape(v)
+	}
+
+	items := []*model.AppQAItem{}
+	for k, v := range s.Environment {
+		svc.Environment[k] = "${" + k + "}"
+		items = append(items, suggestItem(k, v, false))
+	}
+	for _, k := range s.PassEnv {
+		svc.Environment[k] = "${" + k + "}"
+		items = append(items, suggestItem(k, "", true))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	file := composeFile{
+		Services: map[string]composeService{ServiceName(s): svc},
+		Networks: map[string]composeNetwork{},
+		Volumes:  map[string]composeVolume{},
+	}
+	// networks and named volumes of docker run exist outside of any project
+	for _, n := range s.Networks {
+		file.Networks[n] = composeNetwork{External: true}
+	}
+	for _, v := range s.Volumes {
+		source, _, found := strings.Cut(v, ":")
+		if found && isNamedVolume(source) {
+			file.Volumes[source] = composeVolume{Name: source}
+		}
+	}
+
+	out, err := yaml.Marshal(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return compose.EscapeTemplate(string(out)), items, nil
+}
+
+// suggestItem guesses the QA type of a variable from its name and value. The
+// value of a secret is not kept as default, SecretValues returns it.
+func suggestItem(name string, value string, required bool) *model.AppQAItem {
+	item := &model.AppQAItem{
+		Name:         name,
+		Type:         qa.TypeString,
+		DefaultValue: value,
+		Required:     required,
+	}
+	if required {
+		item.Description = "taken from the environment of docker run"
+	}
+
+	upper := strings.ToUpper(name)
+	_, intErr := strconv.Atoi(value)
+	switch {
+	case isSecret(upper):
+		item.Type = qa.TypePassword
+		item.DefaultValue = ""
+		item.Required = true
+	case value == "true" || value == "false":
+		item.Type = qa.TypeBool
+	case intErr == nil && strings.HasSuffix(upper, "PORT"):
+		item.Type = qa.TypePort
+	case intErr == nil:
+		item.Type = qa.TypeInt
+	}
+	return item
+}
+
+func isSecret(upper string) bool {
+	return containsAny(upper, "PASSWORD", "PASSWD", "SECRET", "TOKEN", "API_KEY", "PRIVATE_KEY")
+}
+
+// SecretValues returns the values of the variables of the service left out
+// of the QA defaults, they are the answers of a service deployed from the app.
+func SecretValues(s *Service) map[string]string {
+	values := map[string]string{}
+	for k, v := range s.Environment {
+		if isSecret(strings.ToUpper(k)) {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNamedVolume(source string) bool {
+	return source != "" && !strings.HasPrefix(source, "/") &&
+		!strings.HasPrefix(source, ".") && !strings.HasPrefix(source, "~")
+}
+
+// escape keeps a literal value out of compose interpolation.
+func escape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+func escapeAll(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = escape(s)
+	}
+	return out
+}
+
+func (s *Service) warn(format string, args ...interface{}) {
+	s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+}
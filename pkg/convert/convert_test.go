@@ -0,0 +1,164 @@
+package convert
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/benlocal/lai-panel/pkg/tmpl"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRun(t *testing.T) {
+	s, err := FromRun(`sudo docker run -d --name My_Blog \
+  -p 8080:80 --publish=443:443/tcp \
+  -v blog-data:/var/lib/mysql -v /etc/nginx:/etc/nginx:ro \
+  --mount type=bind,source=/srv/logs,target=/logs,readonly \
+  -e MYSQL_ROOT_PASSWORD='s3cr$t' -e DB_PORT=3306 -e DEBUG=false --env HOME_DIR \
+  --restart unless-stopped --network backend --net=backend \
+  -l "traefik.rule=Host(\"blog.local\")" --memory 512m -itP \
+  wordpress:6 php-fpm -F`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "My_Blog", s.Name)
+	assert.Equal(t, "wordpress:6", s.Image)
+	assert.Equal(t, []string{"php-fpm", "-F"}, s.Command)
+	assert.Equal(t, []string{"8080:80", "443:443/tcp"}, s.Ports)
+	assert.Equal(t, []string{"blog-data:/var/lib/mysql", "/etc/nginx:/etc/nginx:ro", "/srv/logs:/logs:ro"}, s.Volumes)
+	assert.Equal(t, map[string]string{"MYSQL_ROOT_PASSWORD": "s3cr$t", "DB_PORT": "3306", "DEBUG": "false"}, s.Environment)
+	assert.Equal(t, []string{"HOME_DIR"}, s.PassEnv)
+	assert.Equal(t, "unless-stopped", s.Restart)
+	assert.Equal(t, []string{"backend"}, s.Networks)
+	assert.Equal(t, `Host("blog.local")`, s.Labels["traefik.rule"])
+	assert.Len(t, s.Warnings, 2)
+
+	_, err = FromRun("docker ps -a")
+	assert.Error(t, err)
+	_, err = FromRun("docker run -d -p 80:80")
+	assert.Error(t, err)
+
+	s, err = FromRun("docker container run --network host -- alpine")
+	assert.NoError(t, err)
+	assert.Equal(t, "alpine", s.Image)
+	assert.Equal(t, "host", s.NetworkMode)
+
+	// options without a compose equivalent keep their value off the image
+	for _, option := range []string{
+		"--volumes-from data", "--pids-limit 100", "--userns host", "--cgroupns private",
+		"--domainname example.com", "--cpu-period 100000", "--cpu-quota 50000",
+		"--oom-score-adj 500", "--memory-swappiness 0", "--blkio-weight 300",
+		"--device-cgroup-rule 'c 1:3 mr'", "--cgroup-parent /docker", "--isolation default",
+		"--storage-opt size=10G", "--oom-kill-disable",
+	} {
+		s, err = FromRun("docker run -d " + option + " nginx nginx -g 'daemon off;'")
+		if assert.NoError(t, err, option) {
+			assert.Equal(t, "nginx", s.Image, option)
+			assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, s.Command, option)
+			assert.Len(t, s.Warnings, 1, option)
+		}
+	}
+
+	// an unknown option is only skipped when its value can't be mistaken
+	_, err = FromRun("docker run --made-up data nginx")
+	assert.Error(t, err)
+	s, err = FromRun("docker run --made-up=data nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx", s.Image)
+	_, err = FromRun("docker run -x nginx")
+	assert.Error(t, err)
+	_, err = FromRun("docker run --volumes-from")
+	assert.Error(t, err)
+}
+
+func TestFromInspect(t *testing.T) {
+	info := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:   "0123456789ab",
+			Name: "/cache",
+			HostConfig: &container.HostConfig{
+				RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 3},
+				NetworkMode:   "backend",
+				PortBindings: nat.PortMap{
+					"6379/tcp": {{HostIP: "127.0.0.1", HostPort: "6379"}},
+					"53/udp":   {{HostPort: "5353"}},
+				},
+			},
+		},
+		Config: &container.Config{
+			Hostname: "0123456789ab",
+			Image:    "redis:7",
+			Env:      []string{"PATH=/usr/bin", "REDIS_PASSWORD=pw"},
+			Cmd:      []string{"redis-server", "--appendonly", "yes"},
+			Labels:   map[string]string{"maintainer": "redis", "team": "infra"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"backend": {}, "monitoring": {}},
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "redis-data", Destination: "/data", RW: true},
+			{Type: mount.TypeVolume, Name: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", Destination: "/tmp/anon", RW: true},
+			{Type: mount.TypeBind, Source: "/etc/redis.conf", Destination: "/etc/redis.conf"},
+		},
+	}
+	s := FromInspect(info, &ImageDefaults{
+		Env:    []string{"PATH=/usr/bin"},
+		Cmd:    []string{"redis-server"},
+		Labels: map[string]string{"maintainer": "redis"},
+	})
+
+	assert.Equal(t, "cache", s.Name)
+	assert.Empty(t, s.Hostname)
+	assert.Equal(t, []string{"redis-server", "--appendonly", "yes"}, s.Command)
+	assert.Nil(t, s.Entrypoint)
+	assert.Equal(t, map[string]string{"REDIS_PASSWORD": "pw"}, s.Environment)
+	assert.Equal(t, map[string]string{"team": "infra"}, s.Labels)
+	assert.Equal(t, "on-failure:3", s.Restart)
+	assert.Equal(t, []string{"127.0.0.1:6379:6379", "5353:53/udp"}, s.Ports)
+	assert.Equal(t, []string{"backend", "monitoring"}, s.Networks)
+	assert.Equal(t, []string{"redis-data:/data", "/etc/redis.conf:/etc/redis.conf:ro"}, s.Volumes)
+}
+
+func TestTemplate(t *testing.T) {
+	s, err := FromRun(`docker run --name web -p 8080:80 -v data:/data -e APP_PORT=8080 -e API_TOKEN=abc -e GREETING='hi $USER' -e HOME_DIR --network front -l 'rule={{ .Host }}' nginx sh -c 'echo $HOME'`)
+	assert.NoError(t, err)
+
+	file, items, err := Template(s)
+	assert.NoError(t, err)
+
+	types := map[string]string{}
+	for _, item := range items {
+		types[item.Name] = item.Type
+	}
+	assert.Equal(t, map[string]string{
+		"APP_PORT":  qa.TypePort,
+		"API_TOKEN": qa.TypePassword,
+		"GREETING":  qa.TypeString,
+		"HOME_DIR":  qa.TypeString,
+	}, types)
+	assert.True(t, items[3].Required)
+	// the token is not a default of the app
+	assert.Equal(t, "API_TOKEN", items[0].Name)
+	assert.Empty(t, items[0].DefaultValue)
+	assert.Equal(t, map[string]string{"API_TOKEN": "abc"}, SecretValues(s))
+
+	values, err := qa.Apply(items, map[string]string{"HOME_DIR": "/home/web", "API_TOKEN": "abc"})
+	assert.NoError(t, err)
+	rendered, err := tmpl.ParseWithEnv("converted", file, values)
+	assert.NoError(t, err)
+	project, err := compose.LoadProject(context.Background(), rendered, "docker-compose.yml", "/srv/web", "web", values)
+	assert.NoError(t, err)
+
+	web := project.Services["web"]
+	assert.Equal(t, "nginx", web.Image)
+	assert.Equal(t, "hi $USER", *web.Environment["GREETING"])
+	assert.Equal(t, "/home/web", *web.Environment["HOME_DIR"])
+	assert.Equal(t, []string{"sh", "-c", "echo $HOME"}, []string(web.Command))
+	assert.Equal(t, "{{ .Host }}", web.Labels["rule"])
+	assert.Equal(t, "data", project.Volumes["data"].Name)
+	assert.True(t, bool(project.Networks["front"].External))
+}
@@ -0,0 +1,174 @@
+package convert
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// ImageDefaults is the configuration a container inherits from its image,
+// it is left out of the converted service.
+type ImageDefaults struct {
+	Env        []string
+	Entrypoint []string
+	Cmd        []string
+	WorkingDir string
+	User       string
+	Labels     map[string]string
+}
+
+// FromInspect reads the definition of a container. image may be nil when the
+// image is no longer available, its defaults are kept in the service then.
+func FromInspect(info container.InspectResponse, image *ImageDefaults) *Service {
+	if image == nil {
+		image = &ImageDefaults{}
+	}
+	s := newService()
+	if info.ContainerJSONBase != nil {
+		s.Name = strings.TrimPrefix(info.Name, "/")
+	}
+
+	if cfg := info.Config; cfg != nil {
+		s.Image = cfg.Image
+		if cfg.Hostname != "" && !strings.HasPrefix(info.ID, cfg.Hostname) {
+			s.Hostname = cfg.Hostname
+		}
+		if cfg.User != image.User {
+			s.User = cfg.User
+		}
+		if cfg.WorkingDir != image.WorkingDir {
+			s.WorkingDir = cfg.WorkingDir
+		}
+		// docker run resets the command of the image with a new entrypoint
+		if !equal(cfg.Entrypoint, image.Entrypoint) {
+			s.Entrypoint = cfg.Entrypoint
+			s.Command = cfg.Cmd
+		} else if !equal(cfg.Cmd, image.Cmd) {
+			s.Command = cfg.Cmd
+		}
+
+		inherited := map[string]bool{}
+		for _, e := range image.Env {
+			inherited[e] = true
+		}
+		for _, e := range cfg.Env {
+			if inherited[e] {
+				continue
+			}
+			k, v, _ := strings.Cut(e, "=")
+			s.Environment[k] = v
+		}
+
+		for k, v := range cfg.Labels {
+			if strings.HasPrefix(k, "com.docker.compose.") {
+				continue
+			}
+			if iv, ok := image.Labels[k]; ok && iv == v {
+				continue
+			}
+			s.Labels[k] = v
+		}
+	}
+
+	if hc := info.HostConfig; hc != nil {
+		if hc.RestartPolicy.Name != "" && hc.RestartPolicy.Name != container.RestartPolicyDisabled {
+			s.Restart = string(hc.RestartPolicy.Name)
+			if hc.RestartPolicy.IsOnFailure() && hc.RestartPolicy.MaximumRetryCount > 0 {
+				s.Restart += ":" + strconv.Itoa(hc.RestartPolicy.MaximumRetryCount)
+			}
+		}
+		s.Privileged = hc.Privileged
+		s.CapAdd = hc.CapAdd
+		s.ExtraHosts = hc.ExtraHosts
+		for _, d := range hc.Devices {
+			device := d.PathOnHost + ":" + d.PathInContainer
+			if d.CgroupPermissions != "" && d.CgroupPermissions != "rwm" {
+				device += ":" + d.CgroupPermissions
+			}
+			s.Devices = append(s.Devices, device)
+		}
+
+		for port, bindings := range hc.PortBindings {
+			for _, b := range bindings {
+				published := b.HostPort
+				if b.HostIP != "" && b.HostIP != "0.0.0.0" {
+					published = b.HostIP + ":" + published
+				}
+				target := port.Port()
+				if port.Proto() != "tcp" {
+					target += "/" + port.Proto()
+				}
+				if published == "" {
+					s.Ports = append(s.Ports, target)
+				} else {
+					s.Ports = append(s.Ports, published+":"+target)
+				}
+			}
+		}
+		sort.Strings(s.Ports)
+
+		s.setNetwork(string(hc.NetworkMode))
+		if hc.PublishAllPorts {
+			s.warn("publish-all is not converted, publish the ports explicitly")
+		}
+	}
+
+	if ns := info.NetworkSettings; ns != nil {
+		names := make([]string, 0, len(ns.Networks))
+		for name := range ns.Networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if s.NetworkMode == "" {
+				s.setNetwork(name)
+			}
+		}
+	}
+
+	for _, m := range info.Mounts {
+		var volume string
+		switch m.Type {
+		case mount.TypeBind:
+			volume = m.Source + ":" + m.Destination
+		case mount.TypeVolume:
+			// anonymous volumes of the image are created again
+			if len(m.Name) == 64 && isHex(m.Name) {
+				continue
+			}
+			volume = m.Name + ":" + m.Destination
+		default:
+			s.warn("%s mount %s is not converted", m.Type, m.Destination)
+			continue
+		}
+		if !m.RW {
+			volume += ":ro"
+		}
+		s.Volumes = append(s.Volumes, volume)
+	}
+	return s
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,307 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// options of docker run taking a value, mapped to their long name
+var valueOptions = map[string]string{
+	"p": "publish", "publish": "publish",
+	"v": "volume", "volume": "volume",
+	"mount": "mount",
+	"e":     "env", "env": "env",
+	"env-file": "env-file",
+	"restart":  "restart",
+	"network":  "network", "net": "network",
+	"l": "label", "label": "label",
+	"name": "name",
+	"w":    "workdir", "workdir": "workdir",
+	"u": "user", "user": "user",
+	"entrypoint": "entrypoint",
+	"h":          "hostname", "hostname": "hostname",
+	"cap-add":  "cap-add",
+	"add-host": "add-host",
+	"device":   "device",
+}
+
+// options of docker run without a value
+var boolOptions = map[string]string{
+	"d": "detach", "detach": "detach",
+	"i": "interactive", "interactive": "interactive",
+	"t": "tty", "tty": "tty",
+	"rm":         "rm",
+	"privileged": "privileged",
+	"P":          "publish-all", "publish-all": "publish-all",
+	"init":      "init",
+	"read-only": "read-only",
+	"q":         "quiet", "quiet": "quiet",
+}
+
+// options taking a value without a compose equivalent here, they are
+// reported as warnings
+var ignoredValueOptions = map[string]bool{
+	"m": true, "memory": true, "memory-swap": true, "memory-reservation": true,
+	"c": true, "cpu-shares": true, "cpus": true, "cpuset-cpus": true,
+	"log-driver": true, "log-opt": true, "shm-size": true, "ulimit": true,
+	"dns": true, "dns-search": true, "dns-option": true, "pull": true,
+	"platform": true, "gpus": true, "ipc": true, "pid": true, "tmpfs": true,
+	"sysctl": true, "security-opt": true, "cap-drop": true, "stop-signal": true,
+	"stop-timeout": true, "health-cmd": true, "health-interval": true,
+	"health-retries": true, "health-timeout": true, "health-start-period": true,
+	"link": true, "expose": true, "group-add": true, "ip": true, "ip6": true,
+	"mac-address": true, "network-alias": true, "runtime": true, "a": true,
+	"attach": true, "cidfile": true, "detach-keys": true, "label-file": true,
+	"volumes-from": true, "pids-limit": true, "userns": true, "cgroupns": true,
+	"domainname": true, "cpu-period": true, "cpu-quota": true, "cpu-rt-period": true,
+	"cpu-rt-runtime": true, "cpuset-mems": true, "oom-score-adj": true,
+	"memory-swappiness": true, "kernel-memory": true, "blkio-weight": true,
+	"blkio-weight-device": true, "device-read-bps": true, "device-write-bps": true,
+	"device-read-iops": true, "device-write-iops": true, "device-cgroup-rule": true,
+	"cgroup-parent": true, "isolation": true, "storage-opt": true, "uts": true,
+	"annotation": true, "dns-opt": true, "health-start-interval": true,
+	"link-local-ip": true, "volume-driver": true,
+}
+
+// options without a value nor a compose equivalent here, they are reported
+// as warnings
+var ignoredBoolOptions = map[string]bool{
+	"oom-kill-disable": true, "no-healthcheck": true, "sig-proxy": true,
+	"disable-content-trust": true, "help": true,
+}
+
+// FromRun reads a docker run command line, line continuations included.
+func FromRun(command string) (*Service, error) {
+	command = strings.NewReplacer("\\\r\n", " ", "\\\n", " ").Replace(command)
+	args, err := shellwords.Parse(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0] == "sudo" {
+		args = args[1:]
+	}
+	switch {
+	case len(args) >= 2 && args[0] == "docker" && args[1] == "run":
+		args = args[2:]
+	case len(args) >= 3 && args[0] == "docker" && args[1] == "container" && args[2] == "run":
+		args = args[3:]
+	default:
+		return nil, errors.New("not a docker run command")
+	}
+
+	s := newService()
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			if arg == "--" {
+				if len(args) == 0 {
+					break
+				}
+				arg, args = args[0], args[1:]
+			}
+			s.Image = arg
+			s.Command = args
+			break
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if long, ok := valueOptions[name]; ok {
+				if !hasValue {
+					if len(args) == 0 {
+						return nil, fmt.Errorf("option --%s needs a value", name)
+					}
+					value, args = args[0], args[1:]
+				}
+				if err := s.set(long, value); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if long, ok := boolOptions[name]; ok {
+				if !hasValue || value == "true" {
+					s.setBool(long)
+				}
+				continue
+			}
+			if ignoredValueOptions[name] {
+				if !hasValue {
+					if len(args) == 0 {
+						return nil, fmt.Errorf("option --%s needs a value", name)
+					}
+					args = args[1:]
+				}
+				s.warn("option --%s is not converted", name)
+				continue
+			}
+			// an unknown option may take the next argument, guessing could
+			// take the image for its value
+			if !ignoredBoolOptions[name] && !hasValue {
+				return nil, fmt.Errorf("unknown option --%s, write it as --%s=<value> if it takes a value", name, name)
+			}
+			s.warn("option --%s is not converted", name)
+			continue
+		}
+
+		// short options may be grouped, the last one may take a value
+		flags := arg[1:]
+		for i := 0; i < len(flags); i++ {
+			name := flags[i : i+1]
+			if long, ok := valueOptions[name]; ok {
+				value := strings.TrimPrefix(flags[i+1:], "=")
+				if value == "" {
+					if len(args) == 0 {
+						return nil, fmt.Errorf("option -%s needs a value", name)
+					}
+					value, args = args[0], args[1:]
+				}
+				if err := s.set(long, value); err != nil {
+					return nil, err
+				}
+				break
+			}
+			if long, ok := boolOptions[name]; ok {
+				s.setBool(long)
+				continue
+			}
+			if ignoredValueOptions[name] {
+				if i == len(flags)-1 && len(args) > 0 {
+					args = args[1:]
+				}
+				s.warn("option -%s is not converted", name)
+				break
+			}
+			return nil, fmt.Errorf("unknown option -%s", name)
+		}
+	}
+
+	if s.Image == "" {
+		return nil, errors.New("the image is missing")
+	}
+	return s, nil
+}
+
+func (s *Service) set(option string, value string) error {
+	switch option {
+	case "publish":
+		s.Ports = append(s.Ports, value)
+	case "volume":
+		s.Volumes = append(s.Volumes, value)
+	case "mount":
+		volume, err := mountToVolume(value)
+		if err != nil {
+			s.warn("mount %s is not converted: %v", value, err)
+			return nil
+		}
+		s.Volumes = append(s.Volumes, volume)
+	case "env":
+		k, v, found := strings.Cut(value, "=")
+		if !found {
+			s.PassEnv = append(s.PassEnv, k)
+			return nil
+		}
+		s.Environment[k] = v
+	case "env-file":
+		s.EnvFiles = append(s.EnvFiles, value)
+		s.warn("env file %s must exist on the node", value)
+	case "restart":
+		s.Restart = value
+	case "network":
+		s.setNetwork(value)
+	case "label":
+		k, v, _ := strings.Cut(value, "=")
+		s.Labels[k] = v
+	case "name":
+		s.Name = value
+	case "workdir":
+		s.WorkingDir = value
+	case "user":
+		s.User = value
+	case "entrypoint":
+		s.Entrypoint = []string{value}
+	case "hostname":
+		s.Hostname = value
+	case "cap-add":
+		s.CapAdd = append(s.CapAdd, value)
+	case "add-host":
+		s.ExtraHosts = append(s.ExtraHosts, value)
+	case "device":
+		s.Devices = append(s.Devices, value)
+	default:
+		return fmt.Errorf("unknown option %s", option)
+	}
+	return nil
+}
+
+func (s *Service) setBool(option string) {
+	switch option {
+	case "privileged":
+		s.Privileged = true
+	case "publish-all":
+		s.warn("publish-all is not converted, publish the ports explicitly")
+	case "init", "read-only":
+		s.warn("option --%s is not converted", option)
+	}
+}
+
+// setNetwork splits network modes from user defined networks.
+func (s *Service) setNetwork(network string) {
+	switch {
+	case network == "" || network == "default" || network == "bridge":
+	case network == "host" || network == "none" || strings.HasPrefix(network, "container:"):
+		s.NetworkMode = network
+	default:
+		for _, n := range s.Networks {
+			if n == network {
+				return
+			}
+		}
+		s.Networks = append(s.Networks, network)
+	}
+}
+
+// mountToVolume writes a --mount option of a bind or volume mount in the
+// short volume syntax.
+func mountToVolume(value string) (string, error) {
+	fields := map[string]string{}
+	for _, field := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(field, "=")
+		fields[k] = v
+	}
+	typ := fields["type"]
+	if typ == "" {
+		typ = "volume"
+	}
+	source := fields["source"]
+	if source == "" {
+		source = fields["src"]
+	}
+	target := fields["target"]
+	if target == "" {
+		target = fields["destination"]
+	}
+	if target == "" {
+		target = fields["dst"]
+	}
+	if typ != "bind" && typ != "volume" {
+		return "", fmt.Errorf("type %s", typ)
+	}
+	if target == "" {
+		return "", errors.New("no target")
+	}
+
+	volume := target
+	if source != "" {
+		volume = source + ":" + target
+	}
+	if _, ok := fields["readonly"]; ok && fields["readonly"] != "false" {
+		volume += ":ro"
+	} else if _, ok := fields["ro"]; ok && fields["ro"] != "false" {
+		volume += ":ro"
+	}
+	return volume, nil
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/catalog"
 	"github.com/benlocal/lai-panel/pkg/docker"
 	"github.com/benlocal/lai-panel/pkg/hub"
 	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/oci"
 	"github.com/benlocal/lai-panel/pkg/options"
 	"github.com/benlocal/lai-panel/pkg/repository"
 )
@@ -22,22 +25,44 @@ type AppCtx struct {
 	signalrServer     *hub.SignalRServer
 	kvRepository      *repository.KvRepository
 	serverStore       *ServerStore
+
+	imageRegistryRepository     *repository.ImageRegistryRepository
+	portAllocationRepository    *repository.PortAllocationRepository
+	catalogSourceRepository     *repository.CatalogSourceRepository
+	catalogSyncer               *catalog.Syncer
+	appVersionRepository        *repository.AppVersionRepository
+	appVersionStore             *appversion.Store
+	serviceDependencyRepository *repository.ServiceDependencyRepository
+	nodeMetricRepository        *repository.NodeMetricRepository
+	nodeJobRepository           *repository.NodeJobRepository
+	ociStore                    *oci.Store
 }
 
 func NewAppCtx(opt options.IOptions, dockerProxy *docker.DockerProxy) (*AppCtx, error) {
 	// server
 	if !opt.Agent() {
-		ss := GetServerStoreForLocal(opt.(*options.ServeOptions))
+		so := opt.(*options.ServeOptions)
+		ss := GetServerStoreForLocal(so)
 		if ss == nil {
 			return nil, errors.New("failed to get server store for local")
 		}
 
+		ociStore, err := oci.NewStore(so.RegistryDataPath())
+		if err != nil {
+			return nil, err
+		}
+
 		nodeRepository := repository.NewNodeRepository()
 		nodeManager := node.NewNodeManager(nodeRepository)
 		appRepository := repository.NewAppRepository()
 		serviceRepository := repository.NewServiceRepository()
 		kvRepository := repository.NewKvRepository()
 		envRepository := repository.NewEnvRepository()
+		imageRegistryRepository := repository.NewImageRegistryRepository()
+		portAllocationRepository := repository.NewPortAllocationRepository()
+		catalogSourceRepository := repository.NewCatalogSourceRepository()
+		appVersionRepository := repository.NewAppVersionRepository()
+		appVersionStore := appversion.NewStore(appVersionRepository, so.DataPath())
 		h := hub.NewSimpleHub(nodeRepository, nodeManager)
 		signalrServer, _ := hub.NewSignalRServer(context.Background(), h)
 
@@ -52,6 +77,17 @@ func NewAppCtx(opt options.IOptions, dockerProxy *docker.DockerProxy) (*AppCtx,
 			dockerProxy:       dockerProxy,
 			serverStore:       ss,
 			envRepository:     envRepository,
+
+			imageRegistryRepository:     imageRegistryRepository,
+			portAllocationRepository:    portAllocationRepository,
+			catalogSourceRepository:     catalogSourceRepository,
+			catalogSyncer:               catalog.NewSyncer(catalogSourceRepository, appRepository, appVersionStore, so.DataPath()),
+			appVersionRepository:        appVersionRepository,
+			appVersionStore:             appVersionStore,
+			serviceDependencyRepository: repository.NewServiceDependencyRepository(),
+			nodeMetricRepository:        repository.NewNodeMetricRepository(),
+			nodeJobRepository:           repository.NewNodeJobRepository(),
+			ociStore:                    ociStore,
 		}, nil
 	}
 
@@ -67,6 +103,10 @@ func NewAppCtx(opt options.IOptions, dockerProxy *docker.DockerProxy) (*AppCtx,
 	}, nil
 }
 
+func (a *AppCtx) ServerStore() *ServerStore {
+	return a.serverStore
+}
+
 func (a *AppCtx) SignalRServer() *hub.SignalRServer {
 	return a.signalrServer
 }
@@ -102,3 +142,43 @@ func (a *AppCtx) NodeManager() *node.NodeManager {
 func (a *AppCtx) EnvRepository() *repository.EnvRepository {
 	return a.envRepository
 }
+
+func (a *AppCtx) ImageRegistryRepository() *repository.ImageRegistryRepository {
+	return a.imageRegistryRepository
+}
+
+func (a *AppCtx) PortAllocationRepository() *repository.PortAllocationRepository {
+	return a.portAllocationRepository
+}
+
+func (a *AppCtx) CatalogSourceRepository() *repository.CatalogSourceRepository {
+	return a.catalogSourceRepository
+}
+
+func (a *AppCtx) CatalogSyncer() *catalog.Syncer {
+	return a.catalogSyncer
+}
+
+func (a *AppCtx) AppVersionRepository() *repository.AppVersionRepository {
+	return a.appVersionRepository
+}
+
+func (a *AppCtx) AppVersionStore() *appversion.Store {
+	return a.appVersionStore
+}
+
+func (a *AppCtx) ServiceDependencyRepository() *repository.ServiceDependencyRepository {
+	return a.serviceDependencyRepository
+}
+
+func (a *AppCtx) NodeMetricRepository() *repository.NodeMetricRepository {
+	return a.nodeMetricRepository
+}
+
+func (a *AppCtx) NodeJobRepository() *repository.NodeJobRepository {
+	return a.nodeJobRepository
+}
+
+func (a *AppCtx) OCIStore() *oci.Store {
+	return a.ociStore
+}
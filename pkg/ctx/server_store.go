@@ -3,6 +3,9 @@ package ctx
 import (
 	"fmt"
 	"log"
+	"os"
+	"path"
+	"strings"
 	"sync"
 
 	"github.com/benlocal/lai-panel/pkg/options"
@@ -22,7 +25,10 @@ func GetServerStore(opt *options.AgentOptions) *ServerStore {
 			opt.Name,
 			opt.Port,
 			opt.Address,
+			opt.Tunnel,
 			&dataPath)
+		GlobalServerStore.agentToken = readAgentToken(dataPath)
+		GlobalServerStore.masterTLS = opt.MasterTLS()
 		log.Println(GlobalServerStore.str())
 	})
 
@@ -38,6 +44,7 @@ func GetServerStoreForLocal(opt *options.ServeOptions) *ServerStore {
 			"local",
 			opt.Port,
 			baseIP,
+			false,
 			&dataPath)
 		log.Println(GlobalServerStore.str())
 	})
@@ -52,7 +59,12 @@ type ServerStore struct {
 	id         int64
 	agentPort  int
 	address    string
+	tunnel     bool
 	dataPath   *string
+	// agentToken is issued by the master when the agent registers
+	agentToken string
+	// masterTLS is set when the master is served over TLS
+	masterTLS bool
 
 	mu sync.Mutex
 }
@@ -63,6 +75,7 @@ func newServerStore(isLocal bool,
 	name string,
 	agentPort int,
 	address string,
+	tunnel bool,
 	dataPath *string) *ServerStore {
 	return &ServerStore{
 		isLocal:    isLocal,
@@ -71,18 +84,20 @@ func newServerStore(isLocal bool,
 		name:       name,
 		agentPort:  agentPort,
 		address:    address,
+		tunnel:     tunnel,
 		dataPath:   dataPath,
 	}
 }
 
 func (s *ServerStore) str() string {
-	return fmt.Sprintf(`ServerStore{isLocal: %v, masterHost: %s, masterPort: %d, name: %s, agentPort: %d, address: %s, dataPath: %v}`,
+	return fmt.Sprintf(`ServerStore{isLocal: %v, masterHost: %s, masterPort: %d, name: %s, agentPort: %d, address: %s, tunnel: %v, dataPath: %v}`,
 		s.isLocal,
 		s.masterHost,
 		s.masterPort,
 		s.name,
 		s.agentPort,
 		s.address,
+		s.tunnel,
 		*s.dataPath)
 }
 
@@ -110,6 +125,10 @@ func (s *ServerStore) GetMasterPort() int {
 	return s.masterPort
 }
 
+func (s *ServerStore) IsMasterTLS() bool {
+	return s.masterTLS
+}
+
 func (s *ServerStore) IsLocal() bool {
 	return s.isLocal
 }
@@ -122,6 +141,46 @@ func (s *ServerStore) GetAgentPort() int {
 	return s.agentPort
 }
 
+// IsTunnel tells whether the agent is reached through its tunnel.
+func (s *ServerStore) IsTunnel() bool {
+	return s.tunnel
+}
+
 func (s *ServerStore) GetDataPath() *string {
 	return s.dataPath
 }
+
+// GetAgentToken returns the token the master issued to the agent, empty
+// before the agent registered.
+func (s *ServerStore) GetAgentToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.agentToken
+}
+
+// SetAgentToken keeps the token issued by the master, it is saved next to
+// the data of the agent so the agent can register again after a restart.
+func (s *ServerStore) SetAgentToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token == s.agentToken {
+		return nil
+	}
+	if err := os.WriteFile(path.Join(*s.dataPath, AgentTokenFile), []byte(token), 0600); err != nil {
+		return err
+	}
+	s.agentToken = token
+	return nil
+}
+
+// AgentTokenFile is the file of the agent token in the data path of the
+// agent.
+const AgentTokenFile = "agent.token"
+
+func readAgentToken(dataPath string) string {
+	data, err := os.ReadFile(path.Join(dataPath, AgentTokenFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
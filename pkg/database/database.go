@@ -54,6 +54,13 @@ func GetDB() *sqlx.DB {
 	return DB
 }
 
+// IsUniqueViolation tells whether err is a write refused by a unique index.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
 type sqlInterceptor struct {
 	sqlmw.NullInterceptor
 }
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -14,15 +15,37 @@ func LocalDockerClient() (*client.Client, error) {
 	return client.NewClientWithOpts(client.WithAPIVersionNegotiation())
 }
 
-func AgentDockerClient(host string, port int) (*client.Client, error) {
-	return agentDockerClient(host, port, true)
+// AgentDockerClient reaches the docker proxy of an agent, token is the
+// agent token the proxy is guarded with.
+func AgentDockerClient(host string, port int, token string) (*client.Client, error) {
+	return agentDockerClient(host, port, token, true)
 }
 
-func agentDockerClient(host string, port int, withoutProxy bool) (*client.Client, error) {
+// TunnelDockerClient reaches the docker proxy of an agent through its
+// tunnel, every connection of the client, hijacked ones included, is a
+// stream dialed with dial. The host is only used to build the URLs.
+func TunnelDockerClient(name string, dial func(ctx context.Context) (net.Conn, error), token string) (*client.Client, error) {
+	transport := &http.Transport{
+		MaxIdleConns:    6,
+		IdleConnTimeout: 30 * time.Second,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}
+	return client.NewClientWithOpts(
+		client.WithHost(fmt.Sprintf("tcp://%s:80/docker.proxy", name)),
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithHTTPHeaders(agentHeaders(token)),
+		client.WithAPIVersionNegotiation(),
+	)
+}
+
+func agentDockerClient(host string, port int, token string, withoutProxy bool) (*client.Client, error) {
 	hostURL := fmt.Sprintf("tcp://%s:%d/docker.proxy", host, port)
 
 	opts := []client.Opt{
 		client.WithHost(hostURL),
+		client.WithHTTPHeaders(agentHeaders(token)),
 		client.WithAPIVersionNegotiation(),
 	}
 
@@ -37,6 +60,10 @@ func agentDockerClient(host string, port int, withoutProxy bool) (*client.Client
 	return client.NewClientWithOpts(opts...)
 }
 
+func agentHeaders(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
 func customWithoutProxyHTTPClient() (*http.Client, error) {
 	transport := &http.Transport{}
 	transport.MaxIdleConns = 6
@@ -81,7 +81,7 @@ func TestAgentDockerClient(t *testing.T) {
 	})
 	defer engine.Close()
 
-	dockerClient, err := AgentDockerClient(host, port)
+	dockerClient, err := AgentDockerClient(host, port, "")
 	if err != nil {
 		t.Fatalf("failed to create agent docker client: %v", err)
 	}
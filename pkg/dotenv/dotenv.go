@@ -0,0 +1,137 @@
+package dotenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	composeDotenv "github.com/compose-spec/compose-go/v2/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// supported formats
+const (
+	FormatEnv  = "env"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Parse reads variables from a .env, YAML or JSON document. YAML and JSON
+// documents are flat objects, numbers and booleans are converted to strings.
+func Parse(format string, data []byte) (map[string]string, error) {
+	var values map[string]string
+	switch format {
+	case FormatEnv, "dotenv", ".env":
+		v, err := composeDotenv.UnmarshalBytesWithLookup(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		values = v
+	case FormatYAML, "yml":
+		raw := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		v, err := toStrings(raw)
+		if err != nil {
+			return nil, err
+		}
+		values = v
+	case FormatJSON:
+		raw := map[string]interface{}{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		v, err := toStrings(raw)
+		if err != nil {
+			return nil, err
+		}
+		values = v
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	for key := range values {
+		if !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+	}
+	return values, nil
+}
+
+func toStrings(raw map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch t := v.(type) {
+		case nil:
+			values[k] = ""
+		case string:
+			values[k] = t
+		case bool, int, int64, float64, json.Number:
+			values[k] = fmt.Sprint(t)
+		default:
+			return nil, fmt.Errorf("value of %q must be a scalar", k)
+		}
+	}
+	return values, nil
+}
+
+// Format writes variables in the given format, keys are sorted.
+func Format(format string, values map[string]string) ([]byte, error) {
+	switch format {
+	case FormatEnv, "dotenv", ".env":
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		for _, k := range keys {
+			buf.WriteString(k)
+			buf.WriteByte('=')
+			buf.WriteString(quote(values[k]))
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	case FormatYAML, "yml":
+		// yaml.v3 sorts map keys
+		return yaml.Marshal(values)
+	case FormatJSON:
+		return json.MarshalIndent(values, "", "  ")
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// quote returns the value as is when it reads back unchanged, a double
+// quoted string otherwise.
+func quote(value string) string {
+	if value == "" {
+		return ""
+	}
+	if !strings.ContainsAny(value, " \t\r\n\"'`#$\\=") {
+		return value
+	}
+	// strconv.Quote escapes like the double quoted dotenv syntax, $ must be
+	// escaped as well to stop interpolation
+	return strings.ReplaceAll(strconv.Quote(value), "$", `\$`)
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatYAML, "yml":
+		return "application/yaml"
+	case FormatJSON:
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
@@ -0,0 +1,61 @@
+package dotenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	values, err := Parse(FormatEnv, []byte(`# comment
+DB_HOST=localhost
+DB_PORT=5432
+export TOKEN="a b#c"
+EMPTY=
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"TOKEN":   "a b#c",
+		"EMPTY":   "",
+	}, values)
+
+	values, err = Parse(FormatYAML, []byte("DB_PORT: 5432\nDEBUG: true\nNAME: app\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_PORT": "5432", "DEBUG": "true", "NAME": "app"}, values)
+
+	values, err = Parse(FormatJSON, []byte(`{"DB_PORT": 5432, "RATIO": 0.5, "NAME": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_PORT": "5432", "RATIO": "0.5", "NAME": ""}, values)
+
+	_, err = Parse(FormatJSON, []byte(`{"NESTED": {"A": 1}}`))
+	assert.Error(t, err)
+	_, err = Parse(FormatYAML, []byte(`"bad key": 1`))
+	assert.Error(t, err)
+	_, err = Parse("toml", nil)
+	assert.Error(t, err)
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"PLAIN":     "value",
+		"SPACES":    "a b",
+		"QUOTES":    `say "hi" it's`,
+		"DOLLAR":    "pa$$word",
+		"MULTILINE": "line1\nline2",
+		"EMPTY":     "",
+	}
+
+	for _, format := range []string{FormatEnv, FormatYAML, FormatJSON} {
+		data, err := Format(format, values)
+		assert.NoError(t, err, format)
+		parsed, err := Parse(format, data)
+		assert.NoError(t, err, format)
+		assert.Equal(t, values, parsed, format)
+	}
+
+	data, err := Format(FormatEnv, map[string]string{"B": "2", "A": "1 2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "A=\"1 2\"\nB=2\n", string(data))
+}
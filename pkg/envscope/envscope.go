@@ -0,0 +1,198 @@
+package envscope
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// Layer is the level an env value applies to, from the least to the most
+// specific: global < environment < node < app < service.
+type Layer string
+
+const (
+	LayerGlobal      Layer = "global"
+	LayerEnvironment Layer = "environment"
+	LayerNode        Layer = "node"
+	LayerApp         Layer = "app"
+	LayerService     Layer = "service"
+)
+
+// scope prefixes, e.g. node:1 applies to the node with id 1
+const (
+	environmentPrefix = "env:"
+	nodePrefix        = "node:"
+	appPrefix         = "app:"
+	servicePrefix     = "service:"
+)
+
+func EnvironmentScope(name string) string {
+	return environmentPrefix + name
+}
+
+func NodeScope(id int64) string {
+	return nodePrefix + strconv.FormatInt(id, 10)
+}
+
+func AppScope(id int64) string {
+	return appPrefix + strconv.FormatInt(id, 10)
+}
+
+func ServiceScope(id int64) string {
+	return servicePrefix + strconv.FormatInt(id, 10)
+}
+
+// LayerOf returns the layer of a scope. Scopes without a known prefix,
+// including the empty scope, are global.
+func LayerOf(scope string) Layer {
+	switch {
+	case strings.HasPrefix(scope, environmentPrefix):
+		return LayerEnvironment
+	case strings.HasPrefix(scope, nodePrefix):
+		return LayerNode
+	case strings.HasPrefix(scope, appPrefix):
+		return LayerApp
+	case strings.HasPrefix(scope, servicePrefix):
+		return LayerService
+	}
+	return LayerGlobal
+}
+
+// Validate checks the id of node, app and service scopes.
+func Validate(scope string) error {
+	var id string
+	switch LayerOf(scope) {
+	case LayerEnvironment:
+		if strings.TrimPrefix(scope, environmentPrefix) == "" {
+			return fmt.Errorf("invalid scope %q: environment name is required", scope)
+		}
+		return nil
+	case LayerNode:
+		id = strings.TrimPrefix(scope, nodePrefix)
+	case LayerApp:
+		id = strings.TrimPrefix(scope, appPrefix)
+	case LayerService:
+		id = strings.TrimPrefix(scope, servicePrefix)
+	default:
+		return nil
+	}
+	if n, err := strconv.ParseInt(id, 10, 64); err != nil || n <= 0 {
+		return fmt.Errorf("invalid scope %q: expected a positive id", scope)
+	}
+	return nil
+}
+
+// Target is what a deploy resolves env values for.
+type Target struct {
+	Environment string
+	NodeID      int64
+	AppID       int64
+	ServiceID   int64
+}
+
+// rank returns the precedence of a scope for the target, -1 when the scope
+// does not apply.
+func (t Target) rank(scope string) int {
+	switch LayerOf(scope) {
+	case LayerGlobal:
+		if scope == "" {
+			return 1
+		}
+		// named global scopes predate the layers, the plain one wins over them
+		return 0
+	case LayerEnvironment:
+		if t.Environment != "" && scope == EnvironmentScope(t.Environment) {
+			return 2
+		}
+	case LayerNode:
+		if t.NodeID > 0 && scope == NodeScope(t.NodeID) {
+			return 3
+		}
+	case LayerApp:
+		if t.AppID > 0 && scope == AppScope(t.AppID) {
+			return 4
+		}
+	case LayerService:
+		if t.ServiceID > 0 && scope == ServiceScope(t.ServiceID) {
+			return 5
+		}
+	}
+	return -1
+}
+
+// Resolved is the effective value of a key for a target.
+type Resolved struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Scope string `json:"scope"`
+	Layer Layer  `json:"layer"`
+	// IsSecret is set when the value is a secret
+	IsSecret bool `json:"is_secret"`
+	// Overrides are the less specific values hidden by this one
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+type Override struct {
+	Value    string `json:"value"`
+	Scope    string `json:"scope"`
+	Layer    Layer  `json:"layer"`
+	IsSecret bool   `json:"is_secret"`
+}
+
+// secretMask replaces the secret values Mask hides.
+const secretMask = "******"
+
+// Mask hides the secret values of r and its overrides.
+func (r *Resolved) Mask() {
+	if r.IsSecret {
+		r.Value = secretMask
+	}
+	for i := range r.Overrides {
+		if r.Overrides[i].IsSecret {
+			r.Overrides[i].Value = secretMask
+		}
+	}
+}
+
+// Resolve picks the most specific value of every key for the target.
+func Resolve(envs []model.Env, target Target) map[string]*Resolved {
+	candidates := map[string][]model.Env{}
+	for _, e := range envs {
+		if target.rank(e.Scope) < 0 {
+			continue
+		}
+		candidates[e.Key] = append(candidates[e.Key], e)
+	}
+
+	result := make(map[string]*Resolved, len(candidates))
+	for key, list := range candidates {
+		sort.SliceStable(list, func(i, j int) bool {
+			ri, rj := target.rank(list[i].Scope), target.rank(list[j].Scope)
+			if ri != rj {
+				return ri > rj
+			}
+			return list[i].Scope < list[j].Scope
+		})
+
+		r := &Resolved{
+			Key:      key,
+			Value:    list[0].Value,
+			Scope:    list[0].Scope,
+			Layer:    LayerOf(list[0].Scope),
+			IsSecret: list[0].IsSecret,
+		}
+		for _, e := range list[1:] {
+			r.Overrides = append(r.Overrides, Override{
+				Value:    e.Value,
+				Scope:    e.Scope,
+				Layer:    LayerOf(e.Scope),
+				IsSecret: e.IsSecret,
+			})
+		}
+		result[key] = r
+	}
+	return result
+}
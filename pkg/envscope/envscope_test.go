@@ -0,0 +1,72 @@
+package envscope
+
+import (
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	envs := []model.Env{
+		{Key: "DB_HOST", Value: "db.global", Scope: ""},
+		{Key: "DB_HOST", Value: "db.prod", Scope: EnvironmentScope("prod")},
+		{Key: "DB_HOST", Value: "db.staging", Scope: EnvironmentScope("staging")},
+		{Key: "DB_HOST", Value: "db.node", Scope: NodeScope(1)},
+		{Key: "DB_HOST", Value: "db.other-node", Scope: NodeScope(2)},
+		{Key: "LOG_LEVEL", Value: "info", Scope: "legacy"},
+		{Key: "LOG_LEVEL", Value: "warn", Scope: ""},
+		{Key: "LOG_LEVEL", Value: "debug", Scope: ServiceScope(7)},
+		{Key: "REPLICAS", Value: "2", Scope: AppScope(3)},
+		{Key: "OTHER_APP", Value: "x", Scope: AppScope(4)},
+	}
+
+	resolved := Resolve(envs, Target{Environment: "prod", NodeID: 1, AppID: 3, ServiceID: 7})
+	assert.Len(t, resolved, 3)
+
+	assert.Equal(t, &Resolved{
+		Key:   "DB_HOST",
+		Value: "db.node",
+		Scope: "node:1",
+		Layer: LayerNode,
+		Overrides: []Override{
+			{Value: "db.prod", Scope: "env:prod", Layer: LayerEnvironment},
+			{Value: "db.global", Scope: "", Layer: LayerGlobal},
+		},
+	}, resolved["DB_HOST"])
+	assert.Equal(t, "debug", resolved["LOG_LEVEL"].Value)
+	assert.Equal(t, LayerService, resolved["LOG_LEVEL"].Layer)
+	assert.Equal(t, "2", resolved["REPLICAS"].Value)
+
+	resolved = Resolve(envs, Target{NodeID: 2})
+	assert.Equal(t, "db.other-node", resolved["DB_HOST"].Value)
+	assert.Equal(t, "warn", resolved["LOG_LEVEL"].Value)
+	assert.Nil(t, resolved["REPLICAS"])
+}
+
+func TestResolvedMask(t *testing.T) {
+	envs := []model.Env{
+		{Key: "DB_PASSWORD", Value: "global", Scope: "", IsSecret: true},
+		{Key: "DB_PASSWORD", Value: "plain", Scope: NodeScope(1)},
+		{Key: "API_TOKEN", Value: "token", Scope: "", IsSecret: true},
+	}
+
+	resolved := Resolve(envs, Target{NodeID: 1})
+	for _, r := range resolved {
+		r.Mask()
+	}
+	assert.Equal(t, "plain", resolved["DB_PASSWORD"].Value)
+	assert.Equal(t, secretMask, resolved["DB_PASSWORD"].Overrides[0].Value)
+	assert.Equal(t, secretMask, resolved["API_TOKEN"].Value)
+	assert.True(t, resolved["API_TOKEN"].IsSecret)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(""))
+	assert.NoError(t, Validate("anything"))
+	assert.NoError(t, Validate("env:prod"))
+	assert.NoError(t, Validate("service:12"))
+	assert.Error(t, Validate("env:"))
+	assert.Error(t, Validate("node:abc"))
+	assert.Error(t, Validate("app:0"))
+}
@@ -0,0 +1,28 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeFile(t, filepath.Join(src, "docker-compose.yml"), "services: {}")
+	writeFile(t, filepath.Join(src, "conf", "app.conf"), "listen 80")
+	assert.NoError(t, os.Chmod(filepath.Join(src, "conf", "app.conf"), 0o600))
+	assert.NoError(t, os.Symlink("/etc/passwd", filepath.Join(src, "passwd")))
+
+	dst := filepath.Join(dir, "dst")
+	assert.NoError(t, CopyDir(src, dst))
+	assert.Equal(t, "services: {}", readFile(t, filepath.Join(dst, "docker-compose.yml")))
+	assert.Equal(t, "listen 80", readFile(t, filepath.Join(dst, "conf", "app.conf")))
+	info, err := os.Stat(filepath.Join(dst, "conf", "app.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	_, err = os.Lstat(filepath.Join(dst, "passwd"))
+	assert.True(t, os.IsNotExist(err))
+}
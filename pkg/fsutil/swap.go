@@ -0,0 +1,73 @@
+// Package fsutil holds the file system helpers shared by the bundle import,
+// the catalog sync and the app versions.
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Swap is a path replaced by Replace, the previous content is kept aside
+// until Commit drops it or Rollback puts it back.
+type Swap struct {
+	target string
+	// backup holds the previous target as backup/old, empty when the
+	// target did not exist
+	backup string
+}
+
+// Replace moves src to target, an existing target is moved aside in the same
+// directory so both moves are renames. src and target may be files or
+// directories.
+func Replace(src string, target string) (*Swap, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+	swap := &Swap{target: target}
+	if _, err := os.Lstat(target); err == nil {
+		backup, err := os.MkdirTemp(filepath.Dir(target), ".swap-")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Rename(target, swap.old(backup)); err != nil {
+			os.RemoveAll(backup)
+			return nil, err
+		}
+		swap.backup = backup
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.Rename(src, target); err != nil {
+		return nil, errors.Join(err, swap.Rollback())
+	}
+	return swap, nil
+}
+
+func (s *Swap) old(backup string) string {
+	return filepath.Join(backup, "old")
+}
+
+// Commit drops the previous content of the target.
+func (s *Swap) Commit() error {
+	if s.backup == "" {
+		return nil
+	}
+	return os.RemoveAll(s.backup)
+}
+
+// Rollback removes the new content of the target and restores the previous
+// one.
+func (s *Swap) Rollback() error {
+	if err := os.RemoveAll(s.target); err != nil {
+		return err
+	}
+	if s.backup == "" {
+		return nil
+	}
+	if err := os.Rename(s.old(s.backup), s.target); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.backup)
+}
@@ -0,0 +1,62 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, p string, content string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+}
+
+func readFile(t *testing.T, p string) string {
+	t.Helper()
+	b, err := os.ReadFile(p)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "workspace", "app")
+
+	// commit keeps the new content
+	writeFile(t, filepath.Join(target, "docker-compose.yml"), "old")
+	writeFile(t, filepath.Join(dir, "staged", "docker-compose.yml"), "new")
+	swap, err := Replace(filepath.Join(dir, "staged"), target)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", readFile(t, filepath.Join(target, "docker-compose.yml")))
+	assert.NoError(t, swap.Commit())
+	entries, err := os.ReadDir(filepath.Dir(target))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// rollback restores the previous content
+	writeFile(t, filepath.Join(dir, "staged", "docker-compose.yml"), "newer")
+	swap, err = Replace(filepath.Join(dir, "staged"), target)
+	assert.NoError(t, err)
+	assert.NoError(t, swap.Rollback())
+	assert.Equal(t, "new", readFile(t, filepath.Join(target, "docker-compose.yml")))
+	entries, err = os.ReadDir(filepath.Dir(target))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// rollback of a new target removes it
+	writeFile(t, filepath.Join(dir, "installer"), "bin")
+	swap, err = Replace(filepath.Join(dir, "installer"), filepath.Join(dir, "static", "app", "installer"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bin", readFile(t, filepath.Join(dir, "static", "app", "installer")))
+	assert.NoError(t, swap.Rollback())
+	_, err = os.Stat(filepath.Join(dir, "static", "app", "installer"))
+	assert.True(t, os.IsNotExist(err))
+
+	// a failed move keeps the target
+	_, err = Replace(filepath.Join(dir, "missing"), target)
+	assert.Error(t, err)
+	assert.Equal(t, "new", readFile(t, filepath.Join(target, "docker-compose.yml")))
+}
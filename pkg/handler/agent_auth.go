@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// AgentAuth guards the endpoints of the agent which act on the node, only
+// the master holding the token it issued to the agent gets through.
+func (h *BaseHandler) AgentAuth(ctx context.Context, c *app.RequestContext) {
+	token := h.appCtx.ServerStore().GetAgentToken()
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "agent is not registered with the master"))
+		return
+	}
+	given, ok := strings.CutPrefix(string(c.GetHeader("Authorization")), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "invalid agent token"))
+		return
+	}
+	c.Next(ctx)
+}
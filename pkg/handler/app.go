@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/benlocal/lai-panel/pkg/scheduler"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
@@ -67,11 +69,24 @@ func (h *BaseHandler) AddApplicationHandler(ctx context.Context, c *app.RequestC
 		c.Error(err)
 		return
 	}
+	if err := qa.ValidateSchema(app.QA); err != nil {
+		writeQAError(c, err)
+		return
+	}
+	if _, err := scheduler.ParsePlacement(app.Metadata); err != nil {
+		c.Error(err)
+		return
+	}
 	appModel := app.ToModel()
 	if err := h.AppRepository().Create(appModel); err != nil {
 		c.Error(err)
 		return
 	}
+	if _, err := h.AppVersionStore().Publish(appModel); err != nil {
+		c.Error(err)
+		return
+	}
+	app.ID = appModel.ID
 	c.JSON(http.StatusOK, SuccessResponse(app))
 }
 
@@ -81,11 +96,28 @@ func (h *BaseHandler) UpdateApplicationHandler(ctx context.Context, c *app.Reque
 		c.Error(err)
 		return
 	}
+	if err := qa.ValidateSchema(app.QA); err != nil {
+		writeQAError(c, err)
+		return
+	}
+	if _, err := scheduler.ParsePlacement(app.Metadata); err != nil {
+		c.Error(err)
+		return
+	}
 	appModel := app.ToModel()
+	// a recorded version can not change, the app needs a new version
+	if err := h.AppVersionStore().Check(appModel); err != nil {
+		c.JSON(http.StatusOK, NewApiResponse(http.StatusConflict, err.Error(), nil))
+		return
+	}
 	if err := h.AppRepository().Update(appModel); err != nil {
 		c.Error(err)
 		return
 	}
+	if _, err := h.AppVersionStore().Publish(appModel); err != nil {
+		c.Error(err)
+		return
+	}
 	c.JSON(http.StatusOK, SuccessResponse(app))
 }
 
@@ -103,6 +135,10 @@ func (h *BaseHandler) DeleteApplicationHandler(ctx context.Context, c *app.Reque
 		c.Error(err)
 		return
 	}
+	if err := h.AppVersionStore().DeleteApp(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
 	c.JSON(http.StatusOK, EmptyResponse())
 }
 
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/bundle"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func (h *BaseHandler) BundleService() *bundle.Service {
+	return bundle.NewService(h.AppRepository(), h.AppVersionStore(), h.options.DataPath())
+}
+
+// ExportApplicationHandler downloads an app as a bundle archive.
+func (h *BaseHandler) ExportApplicationHandler(ctx context.Context, c *app.RequestContext) {
+	type exportApplicationRequest struct {
+		ID int64 `json:"id" query:"id"`
+	}
+
+	var req exportApplicationRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	application, err := h.AppRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.BundleService().Export(&buf, application); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.tar.gz", application.Name, application.Version))
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// ImportApplicationHandler creates an app from an uploaded bundle, the form
+// fields name and conflict (fail, rename or overwrite) are optional.
+func (h *BaseHandler) ImportApplicationHandler(ctx context.Context, c *app.RequestContext) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, fmt.Sprintf("bundle file is required: %v", err)))
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer src.Close()
+
+	application, err := h.BundleService().Import(src, bundle.ImportOptions{
+		Name:     string(c.FormValue("name")),
+		Conflict: string(c.FormValue("conflict")),
+	})
+	if err != nil {
+		if errors.Is(err, bundle.ErrConflict) || errors.Is(err, appversion.ErrImmutable) {
+			c.JSON(http.StatusOK, NewApiResponse(http.StatusConflict, err.Error(), nil))
+			return
+		}
+		writeQAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(application.ToView()))
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/bundle"
+	"github.com/benlocal/lai-panel/pkg/convert"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// inspectContainer reads a container of a node and the defaults of its image.
+func (h *BaseHandler) inspectContainer(ctx context.Context, nodeID int64, containerID string) (*convert.Service, error) {
+	state, err := h.NodeManager().GetNodeState(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := dc.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults *convert.ImageDefaults
+	if image, err := dc.ImageInspect(ctx, info.Image); err == nil && image.Config != nil {
+		defaults = &convert.ImageDefaults{
+			Env:        image.Config.Env,
+			Entrypoint: image.Config.Entrypoint,
+			Cmd:        image.Config.Cmd,
+			WorkingDir: image.Config.WorkingDir,
+			User:       image.Config.User,
+			Labels:     image.Config.Labels,
+		}
+	}
+	return convert.FromInspect(info, defaults), nil
+}
+
+// ConvertApplicationHandler creates an app from a docker run command or from
+// a container of a node. The environment of the container becomes the QA of
+// the app, dry_run only returns the app. Secret values are not kept in the
+// app, they are returned as the QA values of a service deployed from it.
+func (h *BaseHandler) ConvertApplicationHandler(ctx context.Context, c *app.RequestContext) {
+	type convertApplicationRequest struct {
+		Command     string `json:"command"`
+		NodeID      int64  `json:"node_id"`
+		ContainerID string `json:"container_id"`
+		// Name of the app, derived from the container when empty
+		Name   string `json:"name"`
+		DryRun bool   `json:"dry_run"`
+	}
+	type convertApplicationResponse struct {
+		App      *model.AppView    `json:"app"`
+		Warnings []string          `json:"warnings"`
+		QAValues map[string]string `json:"qa_values"`
+	}
+
+	var req convertApplicationRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var service *convert.Service
+	var err error
+	switch {
+	case req.Command != "":
+		service, err = convert.FromRun(req.Command)
+	case req.ContainerID != "":
+		service, err = h.inspectContainer(ctx, req.NodeID, req.ContainerID)
+	default:
+		err = errors.New("command or container_id is required")
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = convert.ServiceName(service)
+	}
+	if err := bundle.ValidateName(req.Name); err != nil {
+		c.Error(err)
+		return
+	}
+	file, items, err := convert.Template(service)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := qa.ValidateSchema(items); err != nil {
+		writeQAError(c, err)
+		return
+	}
+
+	appView := &model.AppView{
+		Name:          req.Name,
+		DockerCompose: &file,
+		Version:       initialAppVersion,
+		QA:            items,
+	}
+	warnings := service.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	if !req.DryRun {
+		existing, err := h.AppRepository().GetByName(req.Name)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if existing != nil {
+			c.Error(fmt.Errorf("app %s already exists", req.Name))
+			return
+		}
+		appModel := appView.ToModel()
+		if err := h.AppRepository().Create(appModel); err != nil {
+			c.Error(err)
+			return
+		}
+		if _, err := h.AppVersionStore().Publish(appModel); err != nil {
+			c.Error(err)
+			return
+		}
+		appView.ID = appModel.ID
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(convertApplicationResponse{
+		App:      appView,
+		Warnings: warnings,
+		QAValues: convert.SecretValues(service),
+	}))
+}
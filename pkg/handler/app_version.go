@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/pipe/deploypipe"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+)
+
+// resolveAppVersion returns the requested version of the app, the current
+// version of the app when versionID is nil.
+func (h *BaseHandler) resolveAppVersion(app *model.App, versionID *int64) (*model.AppVersion, error) {
+	if versionID == nil || *versionID == 0 {
+		return h.AppVersionStore().Publish(app)
+	}
+	version, err := h.AppVersionRepository().GetByID(*versionID)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil || version.AppID != app.ID {
+		return nil, errors.New("app version not found")
+	}
+	return version, nil
+}
+
+// pinnedApp returns the app as defined by the version the service is pinned
+// to and the workspace copy of that version.
+func (h *BaseHandler) pinnedApp(service *model.Service, app *model.App) (*model.App, string, error) {
+	if service.AppVersionID == nil || service.AppID != app.ID {
+		return app, "", nil
+	}
+	version, err := h.AppVersionRepository().GetByID(*service.AppVersionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if version == nil {
+		return nil, "", errors.New("app version not found")
+	}
+	return version.ApplyTo(app), h.AppVersionStore().Workspace(version), nil
+}
+
+func (h *BaseHandler) GetAppVersionListHandler(ctx context.Context, c *app.RequestContext) {
+	type getAppVersionListRequest struct {
+		AppID int64 `json:"app_id"`
+	}
+
+	var req getAppVersionListRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	versions, err := h.AppVersionRepository().ListByApp(req.AppID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	views := make([]*model.AppVersionView, 0, len(versions))
+	for _, version := range versions {
+		view := version.ToView()
+		view.Services, err = h.ServiceRepository().CountByAppVersion(version.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		views = append(views, view)
+	}
+	c.JSON(http.StatusOK, SuccessResponse(views))
+}
+
+// DeleteAppVersionHandler removes a version no service is pinned to, the
+// current version of the app can not be removed.
+func (h *BaseHandler) DeleteAppVersionHandler(ctx context.Context, c *app.RequestContext) {
+	type deleteAppVersionRequest struct {
+		ID int64 `json:"id"`
+	}
+
+	var req deleteAppVersionRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	version, err := h.AppVersionRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if version == nil {
+		c.Error(errors.New("app version not found"))
+		return
+	}
+	app, err := h.AppRepository().GetByID(version.AppID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if app != nil && app.Version == version.Version {
+		c.Error(errors.New("the current version of the app can not be deleted"))
+		return
+	}
+	count, err := h.ServiceRepository().CountByAppVersion(version.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if count > 0 {
+		c.Error(errors.New("app version is used by services"))
+		return
+	}
+
+	if err := h.AppVersionStore().Delete(version); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+type serviceUpgradeRequest struct {
+	ServiceID int64             `json:"service_id"`
+	VersionID int64             `json:"version_id"`
+	QAValues  map[string]string `json:"qa_values"`
+}
+
+type serviceUpgradePlan struct {
+	service   *model.Service
+	app       *model.App
+	from      *model.AppVersion
+	to        *model.AppVersion
+	changes   []qa.Change
+	migration *qa.Migration
+	// err is the validation error of the migrated values
+	err error
+}
+
+// planServiceUpgrade loads the service and both versions and migrates the
+// QA values, a failed validation of the values is kept on the plan.
+func (h *BaseHandler) planServiceUpgrade(req *serviceUpgradeRequest) (*serviceUpgradePlan, error) {
+	service, err := h.ServiceRepository().GetByID(req.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	if service == nil {
+		return nil, errors.New("service not found")
+	}
+	app, err := h.AppRepository().GetByID(service.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return nil, errors.New("app not found")
+	}
+	from, err := h.resolveAppVersion(app, service.AppVersionID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := h.resolveAppVersion(app, &req.VersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &serviceUpgradePlan{
+		service: service,
+		app:     app,
+		from:    from,
+		to:      to,
+		changes: qa.Diff(from.GetQA(), to.GetQA()),
+	}
+	plan.migration, plan.err = qa.Migrate(from.GetQA(), to.GetQA(), service.ToView().QAValues, req.QAValues)
+	return plan, nil
+}
+
+// PreviewServiceUpgradeHandler shows the QA changes between the version of
+// the service and the target version and the migrated values.
+func (h *BaseHandler) PreviewServiceUpgradeHandler(ctx context.Context, c *app.RequestContext) {
+	type previewServiceUpgradeResponse struct {
+		From      *model.AppVersionView `json:"from"`
+		To        *model.AppVersionView `json:"to"`
+		Changes   []qa.Change           `json:"changes"`
+		Migration *qa.Migration         `json:"migration"`
+		Errors    []qa.FieldError       `json:"errors"`
+	}
+
+	var req serviceUpgradeRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	plan, err := h.planServiceUpgrade(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	resp := previewServiceUpgradeResponse{
+		From:      plan.from.ToView(),
+		To:        plan.to.ToView(),
+		Changes:   plan.changes,
+		Migration: plan.migration,
+		Errors:    []qa.FieldError{},
+	}
+	if plan.err != nil {
+		var verr *qa.ValidationError
+		if !errors.As(plan.err, &verr) {
+			c.Error(plan.err)
+			return
+		}
+		resp.Errors = verr.Errors
+	}
+	c.JSON(http.StatusOK, SuccessResponse(resp))
+}
+
+// HandleServiceUpgrade deploys the service with the target version and the
+// migrated QA values, and pins it to them once the deploy succeeded.
+func (h *BaseHandler) HandleServiceUpgrade(ctx context.Context, c *app.RequestContext) {
+	var req serviceUpgradeRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	plan, err := h.planServiceUpgrade(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if plan.err != nil {
+		writeQAError(c, plan.err)
+		return
+	}
+
+	view := plan.service.ToView()
+	view.QAValues = plan.migration.Values
+	view.AppVersionID = &plan.to.ID
+	service := view.ToModel()
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+
+	deployCtx := deploypipe.NewDeployCtx(
+		h.options,
+		writer,
+		plan.migration.Values,
+		h.appCtx,
+	)
+	deployCtx.Send("info", fmt.Sprintf("upgrading %s from %s to %s", plan.service.Name, plan.from.Version, plan.to.Version))
+	// the service keeps its version and values unless the deploy succeeds
+	if err := h.deployService(ctx, deployCtx, service, service.AppID, service.NodeID); err != nil {
+		return
+	}
+	if err := h.ServiceRepository().Update(service); err != nil {
+		deployCtx.Send("error", err.Error())
+	}
+}
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/appversion"
+	"github.com/benlocal/lai-panel/pkg/catalog"
 	"github.com/benlocal/lai-panel/pkg/ctx"
 	"github.com/benlocal/lai-panel/pkg/docker"
 	"github.com/benlocal/lai-panel/pkg/hub"
 	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/oci"
 	"github.com/benlocal/lai-panel/pkg/options"
 	"github.com/benlocal/lai-panel/pkg/pipe"
 	"github.com/benlocal/lai-panel/pkg/repository"
@@ -15,6 +20,10 @@ type BaseHandler struct {
 	appCtx  *ctx.AppCtx
 
 	deployPipeline *pipe.DeployPipeline
+	nodePipeline   *pipe.NodePipeline
+	agentPipeline  *pipe.AgentPipeline
+	// nodes with a running agent job
+	agentJobs sync.Map
 }
 
 func NewBaseHandler(appCtx *ctx.AppCtx) *BaseHandler {
@@ -24,6 +33,8 @@ func NewBaseHandler(appCtx *ctx.AppCtx) *BaseHandler {
 		appCtx:         appCtx,
 		options:        appCtx.Options(),
 		deployPipeline: deployPipeline,
+		nodePipeline:   pipe.NewNodePipeline(),
+		agentPipeline:  pipe.NewAgentPipeline(),
 	}
 }
 
@@ -59,6 +70,46 @@ func (h *BaseHandler) EnvRepository() *repository.EnvRepository {
 	return h.appCtx.EnvRepository()
 }
 
+func (h *BaseHandler) ImageRegistryRepository() *repository.ImageRegistryRepository {
+	return h.appCtx.ImageRegistryRepository()
+}
+
+func (h *BaseHandler) PortAllocationRepository() *repository.PortAllocationRepository {
+	return h.appCtx.PortAllocationRepository()
+}
+
+func (h *BaseHandler) CatalogSourceRepository() *repository.CatalogSourceRepository {
+	return h.appCtx.CatalogSourceRepository()
+}
+
+func (h *BaseHandler) CatalogSyncer() *catalog.Syncer {
+	return h.appCtx.CatalogSyncer()
+}
+
+func (h *BaseHandler) AppVersionRepository() *repository.AppVersionRepository {
+	return h.appCtx.AppVersionRepository()
+}
+
+func (h *BaseHandler) AppVersionStore() *appversion.Store {
+	return h.appCtx.AppVersionStore()
+}
+
+func (h *BaseHandler) ServiceDependencyRepository() *repository.ServiceDependencyRepository {
+	return h.appCtx.ServiceDependencyRepository()
+}
+
+func (h *BaseHandler) NodeMetricRepository() *repository.NodeMetricRepository {
+	return h.appCtx.NodeMetricRepository()
+}
+
+func (h *BaseHandler) NodeJobRepository() *repository.NodeJobRepository {
+	return h.appCtx.NodeJobRepository()
+}
+
+func (h *BaseHandler) OCIStore() *oci.Store {
+	return h.appCtx.OCIStore()
+}
+
 func (h *BaseHandler) Options() options.IOptions {
 	return h.options
 }
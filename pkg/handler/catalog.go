@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/catalog"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func (h *BaseHandler) GetCatalogSourceListHandler(ctx context.Context, c *app.RequestContext) {
+	sources, err := h.CatalogSourceRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if sources == nil {
+		sources = []model.CatalogSource{}
+	}
+	c.JSON(http.StatusOK, SuccessResponse(sources))
+}
+
+func (h *BaseHandler) SaveCatalogSourceHandler(ctx context.Context, c *app.RequestContext) {
+	type saveCatalogSourceRequest struct {
+		ID     int64  `json:"id"`
+		Name   string `json:"name"`
+		Kind   string `json:"kind"`
+		URL    string `json:"url"`
+		Branch string `json:"branch"`
+	}
+	type saveCatalogSourceResponse struct {
+		ID int64 `json:"id"`
+	}
+
+	var req saveCatalogSourceRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.URL = strings.TrimSpace(req.URL)
+	if req.Name == "" {
+		c.Error(errors.New("name is required"))
+		return
+	}
+	if req.URL == "" {
+		c.Error(errors.New("url is required"))
+		return
+	}
+	switch req.Kind {
+	case model.CatalogSourceDir, model.CatalogSourceGit:
+	default:
+		c.Error(errors.New("kind must be dir or git"))
+		return
+	}
+
+	source := &model.CatalogSource{
+		ID:     req.ID,
+		Name:   req.Name,
+		Kind:   req.Kind,
+		URL:    req.URL,
+		Branch: strings.TrimSpace(req.Branch),
+	}
+	if source.ID == 0 {
+		if err := h.CatalogSourceRepository().Create(source); err != nil {
+			c.Error(err)
+			return
+		}
+	} else {
+		if err := h.CatalogSourceRepository().Update(source); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(saveCatalogSourceResponse{
+		ID: source.ID,
+	}))
+}
+
+// DeleteCatalogSourceHandler removes the source, its apps are kept as
+// regular apps.
+func (h *BaseHandler) DeleteCatalogSourceHandler(ctx context.Context, c *app.RequestContext) {
+	type deleteCatalogSourceRequest struct {
+		ID int64 `json:"id"`
+	}
+
+	var req deleteCatalogSourceRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	source, err := h.CatalogSourceRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if source == nil {
+		c.Error(errors.New("catalog source not found"))
+		return
+	}
+
+	if err := h.CatalogSourceRepository().Delete(source.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	if source.Kind == model.CatalogSourceGit {
+		os.RemoveAll(h.CatalogSyncer().ClonePath(source))
+	}
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+// SyncCatalogSourceHandler syncs a source now instead of waiting for the
+// sync job.
+func (h *BaseHandler) SyncCatalogSourceHandler(ctx context.Context, c *app.RequestContext) {
+	type syncCatalogSourceRequest struct {
+		ID int64 `json:"id"`
+	}
+
+	var req syncCatalogSourceRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	source, err := h.CatalogSourceRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if source == nil {
+		c.Error(errors.New("catalog source not found"))
+		return
+	}
+
+	result, err := h.CatalogSyncer().Sync(ctx, source)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(result))
+}
+
+// GetOutdatedServicesHandler lists the services running an older version
+// than their catalog app.
+func (h *BaseHandler) GetOutdatedServicesHandler(ctx context.Context, c *app.RequestContext) {
+	apps, err := h.AppRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	services, err := h.ServiceRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(catalog.FindOutdated(apps, services)))
+}
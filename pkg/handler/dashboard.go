@@ -10,13 +10,36 @@ import (
 func (h *BaseHandler) DashboardStatsHandler(ctx context.Context, c *app.RequestContext) {
 	type dashboardStatsResponse struct {
 		TotalNodes        int `json:"total_nodes"`
+		OnlineNodes       int `json:"online_nodes"`
 		TotalApplications int `json:"total_applications"`
 		TotalServices     int `json:"total_services"`
 	}
 
+	totalNodes, err := h.NodeRepository().Count()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	onlineNodes, err := h.NodeRepository().CountByStatus("online")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	totalApplications, err := h.AppRepository().Count()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	totalServices, err := h.ServiceRepository().Count()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	c.JSON(http.StatusOK, SuccessResponse(dashboardStatsResponse{
-		TotalNodes:        10,
-		TotalApplications: 10,
-		TotalServices:     10,
+		TotalNodes:        totalNodes,
+		OnlineNodes:       onlineNodes,
+		TotalApplications: totalApplications,
+		TotalServices:     totalServices,
 	}))
 }
@@ -3,15 +3,28 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/benlocal/lai-panel/pkg/pipe/deploypipe"
+	"github.com/benlocal/lai-panel/pkg/servicedep"
 	"github.com/benlocal/lai-panel/pkg/tmpl"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol/sse"
 )
 
+const (
+	// dependencyTimeout bounds the wait for a dependency to become healthy.
+	dependencyTimeout = 5 * time.Minute
+	// logKeepAliveInterval is how often followed logs check the client is
+	// still there
+	logKeepAliveInterval = 15 * time.Second
+)
+
 func (b *BaseHandler) HandleDockerComposeConfig(ctx context.Context, c *app.RequestContext) {
 	type dockerComposeConfigRequest struct {
 		DockerCompose string            `json:"docker_compose"`
@@ -54,7 +67,6 @@ func (b *BaseHandler) HandleDockerComposeDeploy(ctx context.Context, c *app.Requ
 		req.QAValues,
 		b.appCtx,
 	)
-
 	service, err := b.ServiceRepository().GetByID(req.ServiceId)
 	if err != nil {
 		deployCtx.Send("error", err.Error())
@@ -64,38 +76,105 @@ func (b *BaseHandler) HandleDockerComposeDeploy(ctx context.Context, c *app.Requ
 		deployCtx.Send("error", "service not found")
 		return
 	}
-	deployCtx.Service = service
+	b.deployService(ctx, deployCtx, service, req.AppId, req.NodeId)
+}
 
-	app, err := b.AppRepository().GetByID(req.AppId)
+// deployService deploys the dependencies of the service which are not
+// running, waits for them to be healthy and runs the up pipeline for
+// the service. Failures are sent to the deploy context and returned.
+func (b *BaseHandler) deployService(ctx context.Context, deployCtx *deploypipe.DeployCtx, service *model.Service, appID, nodeID int64) error {
+	err := b.deployDependencies(ctx, deployCtx, service)
+	if err == nil {
+		err = b.deploy(ctx, deployCtx, service, appID, nodeID)
+	}
 	if err != nil {
 		deployCtx.Send("error", err.Error())
-		return
+	}
+	return err
+}
+
+// deployDependencies walks the dependencies of the service in dependency
+// order.
+func (b *BaseHandler) deployDependencies(ctx context.Context, deployCtx *deploypipe.DeployCtx, service *model.Service) error {
+	deps, err := b.ServiceDependencyRepository().List()
+	if err != nil {
+		return err
+	}
+	order, err := servicedep.NewGraph(deps).Order(service.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range order {
+		dep, err := b.ServiceRepository().GetByID(id)
+		if err != nil {
+			return err
+		}
+		if dep == nil {
+			return fmt.Errorf("dependency %d not found", id)
+		}
+		state, err := b.NodeManager().GetNodeState(dep.NodeID)
+		if err != nil {
+			return err
+		}
+		downCtx := deploypipe.NewDownCtx(b.options, dep, state, nil)
+
+		// the deploy info stays after an undeploy, the containers tell
+		// whether the dependency is up
+		running := false
+		if dep.DeployInfo != nil {
+			running, err = deploypipe.ServiceRunning(ctx, downCtx)
+			if err != nil {
+				return err
+			}
+		}
+		if !running {
+			deployCtx.Send("info", "deploying dependency "+dep.Name)
+			depCtx := deployCtx.NewDependencyCtx(dep.ToView().QAValues)
+			if err := b.deploy(ctx, depCtx, dep, dep.AppID, dep.NodeID); err != nil {
+				return fmt.Errorf("failed to deploy dependency %s: %w", dep.Name, err)
+			}
+		}
+
+		deployCtx.Send("info", "waiting for dependency "+dep.Name+" to be healthy")
+		if err := deploypipe.WaitServiceHealthy(ctx, downCtx, dependencyTimeout); err != nil {
+			return fmt.Errorf("dependency %s is not healthy: %w", dep.Name, err)
+		}
+	}
+	return nil
+}
+
+// deploy runs the up pipeline for the service and records the deploy info.
+func (b *BaseHandler) deploy(ctx context.Context, deployCtx *deploypipe.DeployCtx, service *model.Service, appID, nodeID int64) error {
+	deployCtx.Service = service
+
+	app, err := b.AppRepository().GetByID(appID)
+	if err != nil {
+		return err
 	}
 	if app == nil {
-		deployCtx.Send("error", "app not found")
-		return
+		return errors.New("app not found")
+	}
+	app, workspace, err := b.pinnedApp(service, app)
+	if err != nil {
+		return err
 	}
 	deployCtx.App = app
+	deployCtx.Workspace = workspace
 
-	state, err := b.NodeManager().GetNodeState(req.NodeId)
+	state, err := b.NodeManager().GetNodeState(nodeID)
 	if err != nil {
-		deployCtx.Send("error", err.Error())
-		return
+		return err
 	}
 	deployCtx.NodeState = state
 
 	res, err := b.deployPipeline.Up(ctx, deployCtx)
 	if err != nil {
-		deployCtx.Send("error", err.Error())
-		return
+		return err
 	}
 
 	// update service deploy info and status
-	err = b.updateServiceDeployInfo(service, res.GetDeployInfo())
-	if err != nil {
-		deployCtx.Send("error", err.Error())
-		return
-	}
+	return b.updateServiceDeployInfo(service, res.GetDeployInfo())
 }
 
 func (b *BaseHandler) HandleDockerComposeUndeploy(ctx context.Context, c *app.RequestContext) {
@@ -125,6 +204,116 @@ func (b *BaseHandler) HandleDockerComposeUndeploy(ctx context.Context, c *app.Re
 
 }
 
+func (b *BaseHandler) HandleDockerComposeRestart(ctx context.Context, c *app.RequestContext) {
+	type dockerComposeRestartRequest struct {
+		ServiceId int64 `json:"service_id"`
+	}
+	var req dockerComposeRestartRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	service, err := b.ServiceRepository().GetByID(req.ServiceId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if service == nil {
+		c.Error(errors.New("service not found"))
+		return
+	}
+	state, err := b.NodeManager().GetNodeState(service.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	downCtx := deploypipe.NewDownCtx(b.options, service, state, nil)
+	if _, err := b.deployPipeline.Restart(ctx, downCtx); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+func (b *BaseHandler) HandleDockerComposeLogs(ctx context.Context, c *app.RequestContext) {
+	type dockerComposeLogsRequest struct {
+		ServiceId int64  `json:"service_id"`
+		Tail      string `json:"tail"`
+		Follow    bool   `json:"follow"`
+	}
+	var req dockerComposeLogsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Tail == "" {
+		req.Tail = "200"
+	}
+
+	service, err := b.ServiceRepository().GetByID(req.ServiceId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if service == nil {
+		c.Error(errors.New("service not found"))
+		return
+	}
+	state, err := b.NodeManager().GetNodeState(service.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+
+	// the request context outlives the client, the logs stop once the
+	// client can't be written to
+	logCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	send := func(write func() error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := write(); err != nil {
+			cancel()
+		}
+	}
+	if req.Follow {
+		go func() {
+			ticker := time.NewTicker(logKeepAliveInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-logCtx.Done():
+					return
+				case <-ticker.C:
+					send(writer.WriteKeepAlive)
+				}
+			}
+		}()
+	}
+
+	downCtx := deploypipe.NewDownCtx(b.options, service, state, nil)
+	err = deploypipe.DockerComposeLogs(logCtx, downCtx, req.Tail, req.Follow, func(line string) {
+		send(func() error {
+			return writer.WriteEvent("", "info", []byte(line))
+		})
+	})
+	if logCtx.Err() != nil && ctx.Err() == nil {
+		// the client went away
+		return
+	}
+	if err != nil {
+		writer.WriteEvent("", "error", []byte(err.Error()))
+		return
+	}
+	writer.WriteEvent("", "done", []byte("done"))
+}
+
 func (b *BaseHandler) updateServiceDeployInfo(service *model.Service, deployInfo map[string]string) error {
 	jsonStr, err := json.Marshal(deployInfo)
 	if err != nil {
@@ -343,9 +343,11 @@ func (h *BaseHandler) DockerImagePullAuto(ctx context.Context, c *app.RequestCon
 
 func (h *BaseHandler) DockerImagePushTo(ctx context.Context, c *app.RequestContext) {
 	type imageActionRequest struct {
-		ImageId       string `json:"image_id"`
-		PushToNodeID  int64  `json:"push_to_node_id"`
-		CurrentNodeID int64  `json:"current_node_id"`
+		ImageId        string `json:"image_id"`
+		PushToNodeID   int64  `json:"push_to_node_id"`
+		CurrentNodeID  int64  `json:"current_node_id"`
+		Compression    string `json:"compression"`
+		BandwidthLimit int64  `json:"bandwidth_limit"`
 	}
 	var req imageActionRequest
 	if err := c.BindAndValidate(&req); err != nil {
@@ -364,7 +366,14 @@ func (h *BaseHandler) DockerImagePushTo(ctx context.Context, c *app.RequestConte
 	}
 	writer := sse.NewWriter(c)
 	defer writer.Close()
-	err = node.CopyImageBetweenNodes(ctx, srcNodeState, dstNodeState, req.ImageId, func(ctx context.Context, reader io.ReadCloser) error {
+	opt := &node.ImageTransferOptions{
+		Compression:    req.Compression,
+		BandwidthLimit: req.BandwidthLimit,
+		Report: func(msg string) {
+			writer.WriteEvent("", "info", []byte(msg))
+		},
+	}
+	err = node.CopyImageBetweenNodesWithOptions(ctx, srcNodeState, dstNodeState, req.ImageId, opt, func(ctx context.Context, reader io.ReadCloser) error {
 		_, err := io.Copy(&CopyWriter{writer}, reader)
 		if err != nil {
 			return err
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerClient "github.com/docker/docker/client"
+)
+
+const (
+	defaultStatsInterval = 2 * time.Second
+	// containers started or restarted after the stream began are picked up
+	// this often
+	statsRefreshInterval = 10 * time.Second
+)
+
+// containerStatsStream follows the docker stats streams of a set of
+// containers and keeps the last sample of each.
+type containerStatsStream struct {
+	ctx     context.Context
+	dc      *dockerClient.Client
+	filters filters.Args
+	// id of the container of a single container stream
+	containerID string
+
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+	stats map[string]*metrics.ContainerStats
+	// containers with a running stream
+	watched map[string]bool
+}
+
+// refresh starts a stream for every running container not followed yet.
+func (s *containerStatsStream) refresh() error {
+	ids := []string{s.containerID}
+	if s.containerID == "" {
+		containers, err := s.dc.ContainerList(s.ctx, container.ListOptions{Filters: s.filters})
+		if err != nil {
+			return err
+		}
+		ids = ids[:0]
+		for _, c := range containers {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if s.watched[id] {
+			continue
+		}
+		s.watched[id] = true
+		s.wg.Add(1)
+		go s.watch(id)
+	}
+	return nil
+}
+
+func (s *containerStatsStream) watch(id string) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watched, id)
+		delete(s.stats, id)
+		s.mu.Unlock()
+	}()
+
+	resp, err := s.dc.ContainerStats(s.ctx, id, true)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var sample container.StatsResponse
+		// the stream ends with the container or when the client leaves
+		if err := decoder.Decode(&sample); err != nil {
+			return
+		}
+		stats := metrics.NewContainerStats(&sample)
+		s.mu.Lock()
+		s.stats[id] = stats
+		s.mu.Unlock()
+	}
+}
+
+// snapshot returns the last samples ordered by container name.
+func (s *containerStatsStream) snapshot() []*metrics.ContainerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*metrics.ContainerStats, 0, len(s.stats))
+	for _, stats := range s.stats {
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// DockerContainerStats streams the resource usage of one container, of the
+// containers of a service, or of every running container of the node in the
+// X-Node-ID header. A "stats" event carrying the usage of the containers is
+// sent every interval seconds until the client disconnects.
+func (h *BaseHandler) DockerContainerStats(ctx context.Context, c *app.RequestContext) {
+	type dockerContainerStatsRequest struct {
+		ContainerID string `json:"container_id"`
+		ServiceID   int64  `json:"service_id"`
+		// Interval is in seconds, 2 by default
+		Interval int `json:"interval"`
+	}
+
+	var req dockerContainerStatsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	interval := defaultStatsInterval
+	if req.Interval > 0 {
+		interval = time.Duration(req.Interval) * time.Second
+	}
+
+	var nodeID int64
+	args := filters.NewArgs()
+	if req.ServiceID > 0 {
+		service, err := h.ServiceRepository().GetByID(req.ServiceID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if service == nil {
+			c.Error(errors.New("service not found"))
+			return
+		}
+		nodeID = service.NodeID
+		project := ""
+		if service.ComposeProject != nil {
+			project = *service.ComposeProject
+		}
+		args = compose.ServiceFilters(service.Name, project)
+	} else {
+		id, err := h.getNodeIDFromRequest(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		nodeID = id
+	}
+	state, err := h.NodeManager().GetNodeState(nodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// closing the streams ends their requests to the docker daemon
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream := &containerStatsStream{
+		ctx:         streamCtx,
+		dc:          dc,
+		filters:     args,
+		containerID: req.ContainerID,
+		stats:       map[string]*metrics.ContainerStats{},
+		watched:     map[string]bool{},
+	}
+	defer func() {
+		cancel()
+		stream.wg.Wait()
+	}()
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+	if err := stream.refresh(); err != nil {
+		writer.WriteEvent("", "error", []byte(err.Error()))
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refresh := time.NewTicker(statsRefreshInterval)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refresh.C:
+			if err := stream.refresh(); err != nil {
+				writer.WriteEvent("", "error", []byte(err.Error()))
+				return
+			}
+		case <-ticker.C:
+			data, err := json.Marshal(stream.snapshot())
+			if err != nil {
+				writer.WriteEvent("", "error", []byte(err.Error()))
+				return
+			}
+			// a failed write means the client went away
+			if err := writer.WriteEvent("", "stats", data); err != nil {
+				return
+			}
+		}
+	}
+}
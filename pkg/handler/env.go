@@ -2,9 +2,17 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
+	"github.com/benlocal/lai-panel/pkg/dotenv"
+	"github.com/benlocal/lai-panel/pkg/envscope"
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
@@ -70,10 +78,11 @@ func (b *BaseHandler) DeleteEnv(ctx context.Context, c *app.RequestContext) {
 
 func (b *BaseHandler) AddOrUpdateEnv(ctx context.Context, c *app.RequestContext) {
 	type addOrUpdateEnvRequest struct {
-		ID    int64  `json:"id"`
-		Key   string `json:"key"`
-		Value string `json:"value"`
-		Scope string `json:"scope"`
+		ID       int64  `json:"id"`
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		Scope    string `json:"scope"`
+		IsSecret bool   `json:"is_secret"`
 	}
 
 	var req addOrUpdateEnvRequest
@@ -81,12 +90,17 @@ func (b *BaseHandler) AddOrUpdateEnv(ctx context.Context, c *app.RequestContext)
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := envscope.Validate(req.Scope); err != nil {
+		c.Error(err)
+		return
+	}
 
 	if req.ID == 0 {
 		m := &model.Env{
-			Key:   req.Key,
-			Value: req.Value,
-			Scope: req.Scope,
+			Key:      req.Key,
+			Value:    req.Value,
+			Scope:    req.Scope,
+			IsSecret: req.IsSecret,
 		}
 		err := b.EnvRepository().Create(m)
 		if err != nil {
@@ -95,10 +109,11 @@ func (b *BaseHandler) AddOrUpdateEnv(ctx context.Context, c *app.RequestContext)
 		}
 	} else {
 		m := &model.Env{
-			ID:    req.ID,
-			Key:   req.Key,
-			Value: req.Value,
-			Scope: req.Scope,
+			ID:       req.ID,
+			Key:      req.Key,
+			Value:    req.Value,
+			Scope:    req.Scope,
+			IsSecret: req.IsSecret,
 		}
 		err := b.EnvRepository().Update(m)
 		if err != nil {
@@ -108,3 +123,146 @@ func (b *BaseHandler) AddOrUpdateEnv(ctx context.Context, c *app.RequestContext)
 	}
 	c.JSON(http.StatusOK, SuccessResponse(nil))
 }
+
+func (b *BaseHandler) ImportEnv(ctx context.Context, c *app.RequestContext) {
+	type importEnvRequest struct {
+		Scope    string `json:"scope"`
+		Format   string `json:"format"`
+		Strategy string `json:"strategy"`
+		Content  string `json:"content"`
+		// mark the imported variables as secrets
+		Secret bool `json:"secret"`
+	}
+
+	var req importEnvRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := envscope.Validate(req.Scope); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Format == "" {
+		req.Format = dotenv.FormatEnv
+	}
+	if req.Strategy == "" {
+		req.Strategy = repository.EnvImportSkip
+	}
+
+	values, err := dotenv.Parse(req.Format, []byte(req.Content))
+	if err != nil {
+		c.Error(fmt.Errorf("invalid %s content: %w", req.Format, err))
+		return
+	}
+
+	result, err := b.EnvRepository().Import(req.Scope, values, req.Strategy, req.Secret)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(result))
+}
+
+// ExportEnv downloads the variables of a scope, secrets are only included
+// when asked for.
+func (b *BaseHandler) ExportEnv(ctx context.Context, c *app.RequestContext) {
+	type exportEnvRequest struct {
+		Scope          string `json:"scope" query:"scope"`
+		Format         string `json:"format" query:"format"`
+		IncludeSecrets bool   `json:"include_secrets" query:"include_secrets"`
+	}
+
+	var req exportEnvRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Format == "" {
+		req.Format = dotenv.FormatEnv
+	}
+
+	envs, err := b.EnvRepository().ListByScope(req.Scope)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	values := make(map[string]string, len(envs))
+	for _, e := range envs {
+		if e.IsSecret && !req.IncludeSecrets {
+			continue
+		}
+		values[e.Key] = e.Value
+	}
+
+	data, err := dotenv.Format(req.Format, values)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	name := "global"
+	if req.Scope != "" {
+		name = strings.ReplaceAll(req.Scope, ":", "-")
+	}
+	ext := req.Format
+	if ext == dotenv.FormatEnv {
+		ext = "env"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, ext))
+	c.Data(http.StatusOK, dotenv.ContentType(req.Format), data)
+}
+
+// GetEffectiveEnv returns the values panel_env resolves for a service and the
+// layer each value comes from, secrets are masked unless reveal_secrets is
+// set.
+func (b *BaseHandler) GetEffectiveEnv(ctx context.Context, c *app.RequestContext) {
+	type getEffectiveEnvRequest struct {
+		ServiceID     int64 `json:"service_id"`
+		RevealSecrets bool  `json:"reveal_secrets"`
+	}
+
+	var req getEffectiveEnvRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	service, err := b.ServiceRepository().GetByID(req.ServiceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if service == nil {
+		c.Error(errors.New("service not found"))
+		return
+	}
+
+	envs, err := b.EnvRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	target := envscope.Target{
+		NodeID:    service.NodeID,
+		AppID:     service.AppID,
+		ServiceID: service.ID,
+	}
+	if so, ok := b.options.(*options.ServeOptions); ok {
+		target.Environment = so.Environment
+	}
+
+	resolved := envscope.Resolve(envs, target)
+	lst := make([]*envscope.Resolved, 0, len(resolved))
+	for _, r := range resolved {
+		if !req.RevealSecrets {
+			r.Mask()
+		}
+		lst = append(lst, r)
+	}
+	sort.Slice(lst, func(i, j int) bool {
+		return lst[i].Key < lst[j].Key
+	})
+	c.JSON(http.StatusOK, SuccessResponse(lst))
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func (h *BaseHandler) GetImageRegistryListHandler(ctx context.Context, c *app.RequestContext) {
+	registries, err := h.ImageRegistryRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	views := make([]*model.ImageRegistryView, 0, len(registries))
+	for _, registry := range registries {
+		views = append(views, registry.ToView())
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(views))
+}
+
+func (h *BaseHandler) SaveImageRegistryHandler(ctx context.Context, c *app.RequestContext) {
+	type saveImageRegistryResponse struct {
+		ID int64 `json:"id"`
+	}
+
+	var req model.ImageRegistryView
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.Host == "" {
+		c.Error(errors.New("host is required"))
+		return
+	}
+
+	registry, err := req.ToModel()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.ID == 0 {
+		if registry.Password == "" {
+			c.Error(errors.New("password is required"))
+			return
+		}
+		if err := h.ImageRegistryRepository().Create(registry); err != nil {
+			c.Error(err)
+			return
+		}
+	} else {
+		if err := h.ImageRegistryRepository().Update(registry); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(saveImageRegistryResponse{
+		ID: registry.ID,
+	}))
+}
+
+func (h *BaseHandler) DeleteImageRegistryHandler(ctx context.Context, c *app.RequestContext) {
+	type deleteImageRegistryRequest struct {
+		ID int64 `json:"id"`
+	}
+
+	var req deleteImageRegistryRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.ID <= 0 {
+		c.Error(errors.New("ID is required"))
+		return
+	}
+
+	if err := h.ImageRegistryRepository().Delete(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, EmptyResponse())
+}
@@ -72,6 +72,12 @@ func (h *BaseHandler) UpdateNodeHandler(ctx context.Context, c *app.RequestConte
 		c.Error(err)
 		return
 	}
+	if node.AgentAutoReinstall != nil {
+		if err := h.NodeRepository().UpdateAgentAutoReinstall(node.ID, *node.AgentAutoReinstall); err != nil {
+			c.Error(err)
+			return
+		}
+	}
 	h.NodeManager().RemoveNode(node.ID)
 
 	c.JSON(http.StatusOK, SuccessResponse(node))
@@ -92,11 +98,22 @@ func (h *BaseHandler) DeleteNodeHandler(ctx context.Context, c *app.RequestConte
 		c.Error(errors.New("ID is required"))
 		return
 	}
+	if node, err := h.NodeRepository().GetByID(req.ID); err == nil {
+		h.NodeManager().Tunnels().Disconnect(node.Name)
+	}
 	h.NodeManager().RemoveNode(req.ID)
 	if err := h.NodeRepository().Delete(req.ID); err != nil {
 		c.Error(err)
 		return
 	}
+	if err := h.NodeMetricRepository().DeleteByNode(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.NodeJobRepository().DeleteByNode(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, EmptyResponse())
 }
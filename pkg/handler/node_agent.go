@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/pipe/agentpipe"
+	"github.com/benlocal/lai-panel/pkg/version"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+)
+
+const defaultNodeJobsLimit = 20
+
+// RunAgentJob installs, reinstalls, upgrades or uninstalls the agent of a
+// node over ssh and records the run in the job history of the node. The
+// output is streamed to writer when it is not nil.
+func (h *BaseHandler) RunAgentJob(ctx context.Context, node *model.Node, action string, triggeredBy string, writer *sse.Writer) error {
+	switch action {
+	case model.NodeJobInstall, model.NodeJobReinstall, model.NodeJobUninstall, model.NodeJobUpgrade:
+	default:
+		return fmt.Errorf("invalid agent action: %s", action)
+	}
+	// the jobs run over ssh, which a node behind a nat can't be reached with
+	if node.AgentTunnel {
+		return errors.New("agent jobs need ssh access, they are not available for nodes behind a tunnel")
+	}
+	if _, running := h.agentJobs.LoadOrStore(node.ID, true); running {
+		return errors.New("an agent job is already running on the node")
+	}
+	defer h.agentJobs.Delete(node.ID)
+
+	job := &model.NodeJob{
+		NodeID:      node.ID,
+		Action:      action,
+		TriggeredBy: triggeredBy,
+		Status:      model.NodeJobRunning,
+	}
+	if err := h.NodeJobRepository().Create(job); err != nil {
+		return err
+	}
+
+	agentCtx, err := h.runAgentPipeline(ctx, node, action, writer)
+	output, errMsg := "", ""
+	if agentCtx != nil {
+		output = agentCtx.Output()
+	}
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if finishErr := h.NodeJobRepository().Finish(job.ID, output, errMsg); finishErr != nil && err == nil {
+		return finishErr
+	}
+	return err
+}
+
+func (h *BaseHandler) runAgentPipeline(ctx context.Context, node *model.Node, action string, writer *sse.Writer) (*agentpipe.AgentCtx, error) {
+	state, err := h.NodeManager().GetNodeState(node.ID)
+	if err != nil {
+		return nil, err
+	}
+	agentCtx := agentpipe.NewAgentCtx(h.options, h.NodeRepository(), node, state, action, writer)
+	switch action {
+	case model.NodeJobUninstall:
+		_, err = h.agentPipeline.Uninstall(ctx, agentCtx)
+	case model.NodeJobUpgrade:
+		_, err = h.agentPipeline.Upgrade(ctx, agentCtx)
+	default:
+		_, err = h.agentPipeline.Install(ctx, agentCtx)
+	}
+	return agentCtx, err
+}
+
+// NodeAgentHandler installs, reinstalls, upgrades or uninstalls the agent of a
+// node and streams the output, the stream ends with a "done" or an "error"
+// event.
+func (h *BaseHandler) NodeAgentHandler(ctx context.Context, c *app.RequestContext) {
+	type nodeAgentRequest struct {
+		ID     int64  `json:"id"`
+		Action string `json:"action"`
+	}
+
+	var req nodeAgentRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ID <= 0 {
+		c.Error(errors.New("ID is required"))
+		return
+	}
+	node, err := h.NodeRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+	if err := h.RunAgentJob(ctx, node, req.Action, model.NodeJobManual, writer); err != nil {
+		writer.WriteEvent("", "error", []byte(err.Error()))
+		return
+	}
+	writer.WriteEvent("", "done", []byte("done"))
+}
+
+// NodeAgentUpgradeHandler upgrades the agents of nodes one after the other
+// and stops at the first failed upgrade, whose node is rolled back. Without
+// node_ids the agents running another version than the master are upgraded.
+func (h *BaseHandler) NodeAgentUpgradeHandler(ctx context.Context, c *app.RequestContext) {
+	type nodeAgentUpgradeRequest struct {
+		NodeIDs []int64 `json:"node_ids"`
+	}
+
+	var req nodeAgentUpgradeRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var nodes []*model.Node
+	if len(req.NodeIDs) > 0 {
+		for _, id := range req.NodeIDs {
+			node, err := h.NodeRepository().GetByID(id)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			nodes = append(nodes, node)
+		}
+	} else {
+		all, err := h.NodeRepository().List()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for i := range all {
+			node := &all[i]
+			if !node.IsLocal && node.SSHUser != "" && !node.AgentTunnel && version.Outdated(node.AgentVersion) {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+	for i, node := range nodes {
+		writer.WriteEvent("", "info", []byte(fmt.Sprintf("upgrading the agent of %s (%d/%d)", node.Name, i+1, len(nodes))))
+		if err := h.RunAgentJob(ctx, node, model.NodeJobUpgrade, model.NodeJobManual, writer); err != nil {
+			writer.WriteEvent("", "error", []byte(fmt.Sprintf("upgrade of %s failed: %s", node.Name, err.Error())))
+			return
+		}
+	}
+	writer.WriteEvent("", "done", []byte("done"))
+}
+
+// GetNodeJobsHandler returns the last agent jobs of a node, without their
+// output.
+func (h *BaseHandler) GetNodeJobsHandler(ctx context.Context, c *app.RequestContext) {
+	type getNodeJobsRequest struct {
+		NodeID int64 `json:"node_id"`
+		Limit  int   `json:"limit"`
+	}
+
+	var req getNodeJobsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.NodeID <= 0 {
+		c.Error(errors.New("node_id is required"))
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultNodeJobsLimit
+	}
+
+	jobs, err := h.NodeJobRepository().ListByNode(req.NodeID, req.Limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(jobs))
+}
+
+// GetNodeJobHandler returns an agent job with its output.
+func (h *BaseHandler) GetNodeJobHandler(ctx context.Context, c *app.RequestContext) {
+	type getNodeJobRequest struct {
+		ID int64 `json:"id"`
+	}
+
+	var req getNodeJobRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := h.NodeJobRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if job == nil {
+		c.Error(errors.New("job not found"))
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(job))
+}
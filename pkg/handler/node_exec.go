@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// node exec endpoints of the agent, they back node.AgentNodeExec for nodes
+// the master can not reach over ssh.
+
+func (h *BaseHandler) HandleNodeExecReadFile(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	if path == "" {
+		c.Error(errors.New("path is required"))
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		c.Error(err)
+		return
+	}
+	c.SetContentType("application/octet-stream")
+	c.SetBodyStream(file, int(info.Size()))
+}
+
+func (h *BaseHandler) HandleNodeExecWriteFile(ctx context.Context, c *app.RequestContext) {
+	path := c.Query("path")
+	if path == "" {
+		c.Error(errors.New("path is required"))
+		return
+	}
+
+	exec := node.NewLocalNodeExec()
+	if mode := c.Query("mode"); mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			c.Error(errors.New("invalid mode"))
+			return
+		}
+		if err := exec.WriteFileWithMode(path, c.Request.Body(), os.FileMode(perm).Perm()); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, EmptyResponse())
+		return
+	}
+
+	if err := exec.WriteFileStream(path, c.Request.BodyStream()); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+func (h *BaseHandler) HandleNodeExecCommand(ctx context.Context, c *app.RequestContext) {
+	var req node.AgentCommandRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Command == "" {
+		c.Error(errors.New("command is required"))
+		return
+	}
+
+	opt := node.NewNodeExecuteCommandOptions()
+	if req.Env != nil {
+		opt.SetEnv(req.Env)
+	}
+	opt.SetWorkingDir(req.WorkingDir)
+	// the command stops once its output can't be sent, the master went away
+	cmdCtx, cancel := context.WithCancel(context.Background())
+	opt.SetContext(cmdCtx)
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer cancel()
+		out := node.NewAgentCommandWriter(&cancelWriter{w: writer, cancel: cancel})
+		err := node.NewLocalNodeExec().ExecuteCommand(req.Command, opt, out.Stdout, out.Stderr)
+		out.Exit(err)
+		writer.Close()
+	}()
+
+	c.SetContentType("text/plain; charset=utf-8")
+	c.SetBodyStream(reader, -1)
+}
+
+// cancelWriter cancels the command writing to w once a write fails.
+type cancelWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.cancel()
+	}
+	return n, err
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/metrics"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// ReportNodeMetricsHandler stores a resource sample pushed by an agent.
+func (h *BaseHandler) ReportNodeMetricsHandler(ctx context.Context, c *app.RequestContext) {
+	var req model.NodeMetric
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.NodeID <= 0 {
+		c.Error(errors.New("node_id is required"))
+		return
+	}
+	req.Resolution = 0
+	if err := h.NodeMetricRepository().Create(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+// metricsTiers returns the tiers node metrics are kept at and the period of
+// the raw samples.
+func (h *BaseHandler) metricsTiers() ([]metrics.Tier, time.Duration) {
+	retention := 30 * 24 * time.Hour
+	interval := options.DefaultMetricsInterval
+	if so, ok := h.options.(*options.ServeOptions); ok {
+		retention = so.MetricsRetention
+		if so.MetricsInterval > 0 {
+			interval = so.MetricsInterval
+		}
+	}
+	return metrics.Tiers(retention), interval
+}
+
+// GetNodeMetricsHandler returns the metrics of a node between from and to,
+// in unix seconds. The resolution is the finest one holding the range
+// within metrics.MaxPoints samples.
+func (h *BaseHandler) GetNodeMetricsHandler(ctx context.Context, c *app.RequestContext) {
+	type getNodeMetricsRequest struct {
+		NodeID int64 `json:"node_id"`
+		// From defaults to one hour before To
+		From int64 `json:"from"`
+		// To defaults to now
+		To int64 `json:"to"`
+	}
+	type getNodeMetricsResponse struct {
+		Resolution int64              `json:"resolution"`
+		Metrics    []model.NodeMetric `json:"metrics"`
+	}
+
+	var req getNodeMetricsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.NodeID <= 0 {
+		c.Error(errors.New("node_id is required"))
+		return
+	}
+	now := time.Now()
+	if req.To <= 0 {
+		req.To = now.Unix()
+	}
+	if req.From <= 0 {
+		req.From = req.To - int64(time.Hour.Seconds())
+	}
+	if req.From > req.To {
+		c.Error(errors.New("from is after to"))
+		return
+	}
+
+	tiers, interval := h.metricsTiers()
+	tier := metrics.SelectTier(tiers, interval, time.Unix(req.From, 0), time.Unix(req.To, 0), now)
+	resolution := int64(tier.Resolution.Seconds())
+	list, err := h.NodeMetricRepository().Range(req.NodeID, resolution, req.From, req.To)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(getNodeMetricsResponse{
+		Resolution: resolution,
+		Metrics:    list,
+	}))
+}
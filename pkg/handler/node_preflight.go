@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/benlocal/lai-panel/pkg/pipe/nodepipe"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+)
+
+// NodePreflightHandler checks a node is ready to run services and streams
+// the progress. With bootstrap set docker and the compose plugin are
+// installed when missing. The report is sent in a "report" event, followed
+// by "done" or by "error" when a check failed.
+func (h *BaseHandler) NodePreflightHandler(ctx context.Context, c *app.RequestContext) {
+	type nodePreflightRequest struct {
+		ID        int64 `json:"id"`
+		Bootstrap bool  `json:"bootstrap"`
+		// Ports must be free on the node
+		Ports []int `json:"ports"`
+	}
+
+	var req nodePreflightRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ID <= 0 {
+		c.Error(errors.New("ID is required"))
+		return
+	}
+	node, err := h.NodeRepository().GetByID(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	state, err := h.NodeManager().GetNodeState(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+
+	nodeCtx := nodepipe.NewNodeCtx(node, state, writer)
+	nodeCtx.Bootstrap = req.Bootstrap
+	nodeCtx.Ports = req.Ports
+	if node.IsLocal {
+		nodeCtx.DataPath = h.options.DataPath()
+	} else if node.DataPath != nil {
+		nodeCtx.DataPath = *node.DataPath
+	}
+
+	_, err = h.nodePipeline.Run(ctx, nodeCtx)
+	if data, jsonErr := json.Marshal(nodeCtx.Report); jsonErr == nil {
+		nodeCtx.Send("report", string(data))
+	}
+	if err != nil {
+		nodeCtx.Send("error", err.Error())
+		return
+	}
+	nodeCtx.Send("done", "done")
+}
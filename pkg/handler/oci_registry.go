@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/oci"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+	"github.com/opencontainers/go-digest"
+)
+
+type ociError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func ociErrorResponse(c *app.RequestContext, err error) {
+	status, code := http.StatusInternalServerError, "UNKNOWN"
+	switch {
+	case errors.Is(err, oci.ErrBlobUnknown):
+		status, code = http.StatusNotFound, "BLOB_UNKNOWN"
+	case errors.Is(err, oci.ErrManifestUnknown):
+		status, code = http.StatusNotFound, "MANIFEST_UNKNOWN"
+	case errors.Is(err, oci.ErrNameUnknown):
+		status, code = http.StatusNotFound, "NAME_UNKNOWN"
+	case errors.Is(err, oci.ErrUploadUnknown):
+		status, code = http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN"
+	case errors.Is(err, oci.ErrDigestInvalid):
+		status, code = http.StatusBadRequest, "DIGEST_INVALID"
+	case errors.Is(err, oci.ErrNameInvalid):
+		status, code = http.StatusBadRequest, "NAME_INVALID"
+	case errors.Is(err, oci.ErrTagInvalid):
+		status, code = http.StatusBadRequest, "TAG_INVALID"
+	}
+	c.JSON(status, map[string][]ociError{
+		"errors": {{Code: code, Message: err.Error()}},
+	})
+}
+
+// OCIRegistryHandler serves the distribution api (/v2/) of the registry
+// embedded in the master, nodes push and pull images through it.
+func (h *BaseHandler) OCIRegistryHandler(ctx context.Context, c *app.RequestContext) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+
+	store := h.OCIStore()
+	so, ok := h.options.(*options.ServeOptions)
+	if store == nil || !ok || !so.RegistryEnabled {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if !oci.CheckBasicAuth(string(c.GetHeader("Authorization")), so.RegistryUsername, so.RegistryPassword) {
+		c.Header("WWW-Authenticate", `Basic realm="`+oci.Realm+`"`)
+		c.JSON(http.StatusUnauthorized, map[string][]ociError{
+			"errors": {{Code: "UNAUTHORIZED", Message: "authentication required"}},
+		})
+		c.Abort()
+		return
+	}
+
+	route := oci.ParseRoute(c.Param("path"))
+	method := string(c.Method())
+
+	switch {
+	case route.Kind == oci.RouteBase && method == http.MethodGet:
+		c.JSON(http.StatusOK, map[string]any{})
+	case route.Kind == oci.RouteCatalog && method == http.MethodGet:
+		h.ociCatalog(c)
+	case route.Kind == oci.RouteTags && method == http.MethodGet:
+		h.ociTags(c, route)
+	case route.Kind == oci.RouteManifest && (method == http.MethodGet || method == http.MethodHead):
+		h.ociGetManifest(c, route, method == http.MethodHead)
+	case route.Kind == oci.RouteManifest && method == http.MethodPut:
+		h.ociPutManifest(c, route)
+	case route.Kind == oci.RouteManifest && method == http.MethodDelete:
+		if err := store.DeleteManifest(route.Name, route.Reference); err != nil {
+			ociErrorResponse(c, err)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	case route.Kind == oci.RouteBlob && (method == http.MethodGet || method == http.MethodHead):
+		h.ociGetBlob(c, route, method == http.MethodHead)
+	case route.Kind == oci.RouteUploadStart && method == http.MethodPost:
+		h.ociStartUpload(c, route)
+	case route.Kind == oci.RouteUpload && method == http.MethodPatch:
+		h.ociPatchUpload(c, route)
+	case route.Kind == oci.RouteUpload && method == http.MethodPut:
+		h.ociFinishUpload(c, route)
+	case route.Kind == oci.RouteUpload && method == http.MethodGet:
+		size, err := store.UploadSize(route.Reference)
+		if err != nil {
+			ociErrorResponse(c, err)
+			return
+		}
+		setUploadHeaders(c, route, size)
+		c.Status(http.StatusNoContent)
+	case route.Kind == oci.RouteUpload && method == http.MethodDelete:
+		if err := store.CancelUpload(route.Reference); err != nil {
+			ociErrorResponse(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	case route.Kind == oci.RouteUnknown:
+		ociErrorResponse(c, oci.ErrNameInvalid)
+	default:
+		c.AbortWithStatus(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *BaseHandler) ociCatalog(c *app.RequestContext) {
+	names, err := h.OCIStore().Repositories()
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string][]string{"repositories": names})
+}
+
+func (h *BaseHandler) ociTags(c *app.RequestContext, route *oci.Route) {
+	tags, err := h.OCIStore().Tags(route.Name)
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"name": route.Name, "tags": tags})
+}
+
+func (h *BaseHandler) ociGetManifest(c *app.RequestContext, route *oci.Route, head bool) {
+	manifest, err := h.OCIStore().GetManifest(route.Name, route.Reference)
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Docker-Content-Digest", manifest.Digest.String())
+	c.SetContentType(manifest.MediaType)
+	if head {
+		c.Response.Header.SetContentLength(len(manifest.Content))
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Data(http.StatusOK, manifest.MediaType, manifest.Content)
+}
+
+func (h *BaseHandler) ociPutManifest(c *app.RequestContext, route *oci.Route) {
+	mediaType := string(c.ContentType())
+	d, err := h.OCIStore().PutManifest(route.Name, route.Reference, mediaType, c.Request.Body())
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/v2/%s/manifests/%s", route.Name, d))
+	c.Header("Docker-Content-Digest", d.String())
+	c.Status(http.StatusCreated)
+}
+
+func (h *BaseHandler) ociGetBlob(c *app.RequestContext, route *oci.Route, head bool) {
+	d := digest.Digest(route.Reference)
+	c.Header("Docker-Content-Digest", d.String())
+
+	if head {
+		size, err := h.OCIStore().StatBlob(d)
+		if err != nil {
+			ociErrorResponse(c, err)
+			return
+		}
+		c.SetContentType("application/octet-stream")
+		c.Response.Header.SetContentLength(int(size))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	f, size, err := h.OCIStore().OpenBlob(d)
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	c.SetContentType("application/octet-stream")
+	c.SetBodyStream(f, int(size))
+}
+
+func (h *BaseHandler) ociStartUpload(c *app.RequestContext, route *oci.Route) {
+	store := h.OCIStore()
+
+	// blobs are shared by all repositories, mounting only checks existence
+	if mount := c.Query("mount"); mount != "" {
+		if _, err := store.StatBlob(digest.Digest(mount)); err == nil {
+			c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", route.Name, mount))
+			c.Header("Docker-Content-Digest", mount)
+			c.Status(http.StatusCreated)
+			return
+		}
+	}
+
+	id, err := store.StartUpload()
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	route = &oci.Route{Kind: oci.RouteUpload, Name: route.Name, Reference: id}
+
+	// monolithic upload
+	if d := c.Query("digest"); d != "" {
+		h.ociFinishUpload(c, route)
+		return
+	}
+
+	setUploadHeaders(c, route, 0)
+	c.Status(http.StatusAccepted)
+}
+
+func (h *BaseHandler) ociPatchUpload(c *app.RequestContext, route *oci.Route) {
+	size, err := h.OCIStore().AppendUpload(route.Reference, c.Request.BodyStream())
+	if err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	setUploadHeaders(c, route, size)
+	c.Status(http.StatusAccepted)
+}
+
+func (h *BaseHandler) ociFinishUpload(c *app.RequestContext, route *oci.Route) {
+	store := h.OCIStore()
+	d := digest.Digest(c.Query("digest"))
+
+	// the final chunk, or the whole blob of a monolithic upload, comes with
+	// the request. Body() would drain the stream, so it is always appended.
+	if _, err := store.AppendUpload(route.Reference, c.Request.BodyStream()); err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+	if err := store.FinishUpload(route.Reference, d); err != nil {
+		ociErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", route.Name, d))
+	c.Header("Docker-Content-Digest", d.String())
+	c.Status(http.StatusCreated)
+}
+
+func setUploadHeaders(c *app.RequestContext, route *oci.Route, size int64) {
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", route.Name, route.Reference))
+	c.Header("Docker-Upload-UUID", route.Reference)
+	end := size - 1
+	if end < 0 {
+		end = 0
+	}
+	c.Header("Range", "0-"+strconv.FormatInt(end, 10))
+}
+
+func (h *BaseHandler) GetOCIRepositoryListHandler(ctx context.Context, c *app.RequestContext) {
+	store := h.OCIStore()
+	names, err := store.Repositories()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	repositories := make([]*oci.RepositoryInfo, 0, len(names))
+	for _, name := range names {
+		info, err := store.RepositoryInfo(name)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		repositories = append(repositories, info)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(repositories))
+}
+
+func (h *BaseHandler) DeleteOCIImageHandler(ctx context.Context, c *app.RequestContext) {
+	type deleteOCIImageRequest struct {
+		Name string `json:"name"`
+		// tag or digest
+		Reference string `json:"reference"`
+	}
+
+	var req deleteOCIImageRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.Name == "" || req.Reference == "" {
+		c.Error(errors.New("name and reference are required"))
+		return
+	}
+
+	if err := h.OCIStore().DeleteManifest(req.Name, req.Reference); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, EmptyResponse())
+}
+
+func (h *BaseHandler) OCIGarbageCollectHandler(ctx context.Context, c *app.RequestContext) {
+	type gcRequest struct {
+		DeleteUntagged bool `json:"delete_untagged"`
+	}
+
+	var req gcRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := h.OCIStore().GC(req.DeleteUntagged)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(result))
+}
+
+// PushImageToOCIRegistryHandler pushes an image of a node to the registry of
+// the master, an alternative to DockerImagePushTo which only sends the
+// layers the registry does not have yet.
+func (h *BaseHandler) PushImageToOCIRegistryHandler(ctx context.Context, c *app.RequestContext) {
+	type pushImageRequest struct {
+		ImageId       string `json:"image_id"`
+		CurrentNodeID int64  `json:"current_node_id"`
+	}
+
+	var req pushImageRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	so, ok := h.options.(*options.ServeOptions)
+	if !ok {
+		c.Error(errors.New("registry is only available on the server"))
+		return
+	}
+	if !so.RegistryEnabled {
+		c.Error(errors.New("the embedded registry is not enabled, set PANEL_REGISTRY_ENABLED"))
+		return
+	}
+
+	mirrorRef, err := oci.MirrorReference(so.RegistryAddress(), req.ImageId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	auth, err := oci.EncodeAuth(so.RegistryAddress(), so.RegistryUsername, so.RegistryPassword)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	nodeState, err := h.NodeManager().GetNodeState(req.CurrentNodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	writer := sse.NewWriter(c)
+	defer writer.Close()
+
+	err = node.PushImageToRegistry(ctx, nodeState, req.ImageId, mirrorRef, auth, func(msg string) {
+		writer.WriteEvent("", "info", []byte(msg))
+	})
+	if err != nil {
+		writer.WriteEvent("", "error", []byte(err.Error()))
+		return
+	}
+	writer.WriteEvent("", "done", []byte(mirrorRef))
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/ctx"
+	"github.com/benlocal/lai-panel/pkg/options"
+	hertzServer "github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+// runRegistry serves the registry handler the way the api server does, with
+// streamed request bodies, and returns its base url.
+func runRegistry(t *testing.T) string {
+	t.Helper()
+	so := options.NewServeOptions()
+	options.WithDataPath(t.TempDir())(so)
+	so.RegistryEnabled = true
+	so.RegistryUsername = "panel"
+	so.RegistryPassword = "secret"
+	appCtx, err := ctx.NewAppCtx(so, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewBaseHandler(appCtx)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	server := hertzServer.New(
+		hertzServer.WithHostPorts(addr),
+		hertzServer.WithMaxRequestBodySize(1024*1024*1024),
+		hertzServer.WithStreamBody(true),
+		hertzServer.WithDisablePrintRoute(true),
+	)
+	server.Any("/v2/*path", h.OCIRegistryHandler)
+	go server.Spin()
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+	})
+	for i := 0; i < 100; i++ {
+		if server.IsRunning() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "http://" + addr
+}
+
+func registryRequest(t *testing.T, method string, url string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("panel", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func getBlob(t *testing.T, base string, d digest.Digest) []byte {
+	t.Helper()
+	resp := registryRequest(t, http.MethodGet, fmt.Sprintf("%s/v2/test/app/blobs/%s", base, d), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestOCIRegistryUploads(t *testing.T) {
+	base := runRegistry(t)
+
+	// larger than the buffered part of a streamed body
+	blob := bytes.Repeat([]byte("layer"), 1024*1024)
+
+	t.Run("monolithic", func(t *testing.T) {
+		d := digest.FromBytes(blob)
+		resp := registryRequest(t, http.MethodPost, base+"/v2/test/app/blobs/uploads/?digest="+d.String(), blob)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, blob, getBlob(t, base, d))
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		content := append([]byte("small "), blob...)
+		d := digest.FromBytes(content)
+
+		resp := registryRequest(t, http.MethodPost, base+"/v2/test/app/blobs/uploads/", nil)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		location := resp.Header.Get("Location")
+
+		resp = registryRequest(t, http.MethodPatch, base+location, content[:len(content)/2])
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		// the last chunk comes with the closing put
+		resp = registryRequest(t, http.MethodPut, base+location+"?digest="+d.String(), content[len(content)/2:])
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, content, getBlob(t, base, d))
+	})
+
+	t.Run("small chunks", func(t *testing.T) {
+		content := []byte("config")
+		d := digest.FromBytes(content)
+
+		resp := registryRequest(t, http.MethodPost, base+"/v2/test/app/blobs/uploads/", nil)
+		location := resp.Header.Get("Location")
+		resp = registryRequest(t, http.MethodPatch, base+location, content[:3])
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		resp = registryRequest(t, http.MethodPut, base+location+"?digest="+d.String(), content[3:])
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, content, getBlob(t, base, d))
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		resp := registryRequest(t, http.MethodPost, base+"/v2/test/app/blobs/uploads/?digest="+digest.FromString("other").String(), []byte("config"))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		resp, err := http.Get(base + "/v2/")
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+}
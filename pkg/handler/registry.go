@@ -2,10 +2,17 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/version"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
@@ -41,53 +48,115 @@ func (h *BaseHandler) remote(req *model.RegistryRequest) (*model.RegistryRespons
 		return nil, err
 	}
 
+	token, err := agentToken(registry, req)
+	if err != nil {
+		return nil, err
+	}
+
 	if registry == nil {
 		// create new node
 		node := &model.Node{
-			Name:      req.Name,
-			Status:    req.Status,
-			IsLocal:   req.IsLocal,
-			AgentPort: req.AgentPort,
-			Address:   req.Address,
-			DataPath:  req.DataPath,
+			Name:              req.Name,
+			Status:            req.Status,
+			IsLocal:           req.IsLocal,
+			AgentPort:         req.AgentPort,
+			Address:           req.Address,
+			DataPath:          req.DataPath,
+			AgentVersion:      req.Version,
+			AgentCapabilities: strings.Join(req.Capabilities, ","),
+			AgentTunnel:       req.Tunnel,
+			AgentToken:        token,
 		}
 		err := h.NodeRepository().Create(node)
 		if err != nil {
 			return nil, err
 		}
 		return &model.RegistryResponse{
-			ID:   node.ID,
-			Name: node.Name,
+			ID:           node.ID,
+			Name:         node.Name,
+			Incompatible: checkAgent(node.Name, req),
+			Token:        token,
 		}, nil
 	} else {
 		// update node
 		node := &model.Node{
-			ID:        registry.ID,
-			Name:      registry.Name,
-			Status:    req.Status,
-			Address:   req.Address,
-			AgentPort: req.AgentPort,
-			DataPath:  req.DataPath,
+			ID:                registry.ID,
+			Name:              registry.Name,
+			Status:            req.Status,
+			Address:           req.Address,
+			AgentPort:         req.AgentPort,
+			DataPath:          req.DataPath,
+			AgentVersion:      req.Version,
+			AgentCapabilities: strings.Join(req.Capabilities, ","),
+			AgentTunnel:       req.Tunnel,
+			AgentToken:        token,
 		}
-		if needUpdateNode(registry, req) {
+		if needUpdateNode(registry, node) {
 			err = h.NodeRepository().UpdateRegistry(node)
 			if err != nil {
 				return nil, err
 			}
 		}
+		if needResetNode(registry, node) {
+			// the cached clients reach the agent the old way
+			if err := h.NodeManager().RemoveNode(node.ID); err != nil {
+				return nil, err
+			}
+		}
 
 		return &model.RegistryResponse{
-			ID:   node.ID,
-			Name: node.Name,
+			ID:           node.ID,
+			Name:         node.Name,
+			Incompatible: checkAgent(node.Name, req),
+			Token:        token,
 		}, nil
 	}
 }
 
-func needUpdateNode(registry *model.Node, req *model.RegistryRequest) bool {
-	return registry.Status != req.Status ||
-		registry.Address != req.Address ||
-		registry.AgentPort != req.AgentPort ||
-		registry.DataPath != req.DataPath
+func needUpdateNode(registry *model.Node, node *model.Node) bool {
+	return registry.Status != node.Status ||
+		registry.Address != node.Address ||
+		registry.AgentPort != node.AgentPort ||
+		registry.DataPath != node.DataPath ||
+		registry.AgentVersion != node.AgentVersion ||
+		registry.AgentCapabilities != node.AgentCapabilities ||
+		registry.AgentTunnel != node.AgentTunnel ||
+		registry.AgentToken != node.AgentToken
+}
+
+// needResetNode tells whether the agent is reached another way than before.
+func needResetNode(registry *model.Node, node *model.Node) bool {
+	return registry.Address != node.Address ||
+		registry.AgentPort != node.AgentPort ||
+		registry.AgentTunnel != node.AgentTunnel ||
+		registry.AgentToken != node.AgentToken
+}
+
+// agentToken returns the token of the registering agent. A new node, or a
+// node registered before agents had tokens, gets a new token, any other
+// agent has to present the token of the node.
+func agentToken(registry *model.Node, req *model.RegistryRequest) (string, error) {
+	if registry == nil || registry.AgentToken == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(registry.AgentToken)) != 1 {
+		return "", fmt.Errorf("agent token of node %s does not match, reinstall the agent", req.Name)
+	}
+	return registry.AgentToken, nil
+}
+
+// checkAgent logs and returns why the registering agent can't work with the
+// master, empty when it can.
+func checkAgent(name string, req *model.RegistryRequest) string {
+	if err := version.CheckAgent(req.Version, req.Capabilities); err != nil {
+		log.Printf("node %s: %v", name, err)
+		return err.Error()
+	}
+	return ""
 }
 
 func (h *BaseHandler) local(req *model.RegistryRequest) (*model.RegistryResponse, error) {
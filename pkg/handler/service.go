@@ -3,9 +3,14 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/benlocal/lai-panel/pkg/scheduler"
+	"github.com/benlocal/lai-panel/pkg/secret"
+	"github.com/benlocal/lai-panel/pkg/servicedep"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
@@ -34,9 +39,18 @@ func (h *BaseHandler) GetServicePageHandler(ctx context.Context, c *app.RequestC
 		Services []*model.ServiceView `json:"services"`
 	}
 
+	deps, err := h.ServiceDependencyRepository().List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	graph := servicedep.NewGraph(deps)
+
 	servicesView := make([]*model.ServiceView, 0)
 	for _, service := range services {
-		servicesView = append(servicesView, service.ToView())
+		view := service.ToView()
+		view.DependsOn = append([]int64{}, graph[service.ID]...)
+		servicesView = append(servicesView, view)
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse(getServicePageResponse{
@@ -50,6 +64,8 @@ func (h *BaseHandler) GetServicePageHandler(ctx context.Context, c *app.RequestC
 func (h *BaseHandler) SaveServiceHandler(ctx context.Context, c *app.RequestContext) {
 	type saveServiceResponse struct {
 		ID int64 `json:"id"`
+		// Placement is set when the node of the service was scheduled
+		Placement *scheduler.Decision `json:"placement,omitempty"`
 	}
 
 	var req model.ServiceView
@@ -63,6 +79,59 @@ func (h *BaseHandler) SaveServiceHandler(ctx context.Context, c *app.RequestCont
 		return
 	}
 
+	app, err := h.AppRepository().GetByID(req.AppID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if app == nil {
+		c.Error(errors.New("app not found"))
+		return
+	}
+
+	// keep the version of an existing service unless another one is asked for
+	versionID := req.AppVersionID
+	if versionID == nil && req.ID > 0 {
+		current, err := h.ServiceRepository().GetByID(req.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if current != nil && current.AppID == app.ID {
+			versionID = current.AppVersionID
+		}
+	}
+	version, err := h.resolveAppVersion(app, versionID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	values, err := qa.Apply(version.GetQA(), req.QAValues)
+	if err != nil {
+		writeQAError(c, err)
+		return
+	}
+	req.QAValues = values
+	req.AppVersionID = &version.ID
+
+	if req.DependsOn != nil {
+		if err := h.checkDependencies(req.ID, req.DependsOn); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// a service without a node is placed by the scheduler
+	var placement *scheduler.Decision
+	if req.NodeID == 0 {
+		placement, err = h.scheduleService(version.ApplyTo(app), req.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		req.NodeID = placement.NodeID
+	}
+
 	service := req.ToModel()
 	var id int64
 	if req.ID == 0 {
@@ -83,9 +152,16 @@ func (h *BaseHandler) SaveServiceHandler(ctx context.Context, c *app.RequestCont
 
 		id = service.ID
 	}
+	if req.DependsOn != nil {
+		if err := h.ServiceDependencyRepository().Replace(id, req.DependsOn); err != nil {
+			c.Error(err)
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, SuccessResponse(saveServiceResponse{
-		ID: id,
+		ID:        id,
+		Placement: placement,
 	}))
 }
 
@@ -111,6 +187,16 @@ func (h *BaseHandler) DeleteServiceHandler(ctx context.Context, c *app.RequestCo
 		return
 	}
 
+	dependents, err := h.ServiceDependencyRepository().ListDependents(req.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(dependents) > 0 {
+		c.Error(fmt.Errorf("service %s is a dependency of other services", currentService.Name))
+		return
+	}
+
 	// check if service is deployed
 	if currentService.DeployInfo != nil {
 		if !req.Force {
@@ -130,6 +216,55 @@ func (h *BaseHandler) DeleteServiceHandler(ctx context.Context, c *app.RequestCo
 		c.Error(err)
 		return
 	}
+	if err := secret.NewStore(h.KvRepository()).DeleteAll(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.PortAllocationRepository().DeleteByService(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.ServiceDependencyRepository().DeleteByService(req.ID); err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, SuccessResponse(nil))
 }
+
+// checkDependencies validates the dependencies of a service, a new service
+// has no id yet and can not be part of a cycle.
+func (h *BaseHandler) checkDependencies(serviceID int64, dependsOn []int64) error {
+	for _, id := range dependsOn {
+		if id == serviceID {
+			return errors.New("a service can not depend on itself")
+		}
+		dep, err := h.ServiceRepository().GetByID(id)
+		if err != nil {
+			return fmt.Errorf("dependency %d not found: %w", id, err)
+		}
+		if dep == nil {
+			return fmt.Errorf("dependency %d not found", id)
+		}
+	}
+	if serviceID == 0 {
+		return nil
+	}
+
+	deps, err := h.ServiceDependencyRepository().List()
+	if err != nil {
+		return err
+	}
+	_, err = servicedep.NewGraph(deps).With(serviceID, dependsOn).Order(serviceID)
+	return err
+}
+
+// writeQAError responds with the per-field errors of a QA validation.
+func writeQAError(c *app.RequestContext, err error) {
+	var verr *qa.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusOK, NewApiResponse(http.StatusBadRequest, verr.Error(), verr.Errors))
+		return
+	}
+	c.Error(err)
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+
+	"github.com/benlocal/lai-panel/pkg/bundle"
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/constant"
+	"github.com/benlocal/lai-panel/pkg/dotenv"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/pipe/deploypipe"
+	"github.com/benlocal/lai-panel/pkg/qa"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// initialAppVersion is the version of apps created from what runs on a node.
+const initialAppVersion = "1.0.0"
+
+type discoveredProject struct {
+	*compose.DiscoveredProject
+	// ServiceID is the service the project belongs to
+	ServiceID *int64 `json:"service_id"`
+}
+
+// discoverProjects scans the compose projects of the node and links them to
+// the services of the node.
+func (h *BaseHandler) discoverProjects(ctx context.Context, state *node.NodeState, nodeID int64) ([]*discoveredProject, error) {
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := compose.Discover(ctx, dc, constant.ManagedByLabel)
+	if err != nil {
+		return nil, err
+	}
+	services, err := h.ServiceRepository().List()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string]int64{}
+	for _, service := range services {
+		if service.NodeID != nodeID {
+			continue
+		}
+		name := compose.ProjectName(service.Name)
+		if service.ComposeProject != nil && *service.ComposeProject != "" {
+			name = *service.ComposeProject
+		}
+		owners[name] = service.ID
+	}
+
+	result := make([]*discoveredProject, 0, len(projects))
+	for _, p := range projects {
+		d := &discoveredProject{DiscoveredProject: p}
+		if id, ok := owners[p.Name]; ok {
+			d.ServiceID = &id
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// DiscoverComposeProjectsHandler lists the compose projects running on a
+// node, projects without a service can be adopted.
+func (h *BaseHandler) DiscoverComposeProjectsHandler(ctx context.Context, c *app.RequestContext) {
+	type discoverComposeProjectsRequest struct {
+		NodeID int64 `json:"node_id"`
+	}
+
+	var req discoverComposeProjectsRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	state, err := h.NodeManager().GetNodeState(req.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	projects, err := h.discoverProjects(ctx, state, req.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(projects))
+}
+
+// AdoptComposeProjectHandler creates an app from the compose files of a
+// project and a service bound to the node running it. The containers are
+// left untouched, the labels of the panel are added on the next deploy.
+func (h *BaseHandler) AdoptComposeProjectHandler(ctx context.Context, c *app.RequestContext) {
+	type adoptComposeProjectRequest struct {
+		NodeID  int64  `json:"node_id"`
+		Project string `json:"project"`
+		// Name of the app and the service, the project name when empty
+		Name string `json:"name"`
+	}
+	type adoptComposeProjectResponse struct {
+		AppID     int64 `json:"app_id"`
+		ServiceID int64 `json:"service_id"`
+	}
+
+	var req adoptComposeProjectRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Project
+	}
+	if err := bundle.ValidateName(req.Name); err != nil {
+		c.Error(err)
+		return
+	}
+
+	state, err := h.NodeManager().GetNodeState(req.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	projects, err := h.discoverProjects(ctx, state, req.NodeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var project *discoveredProject
+	for _, p := range projects {
+		if p.Name == req.Project {
+			project = p
+		}
+	}
+	switch {
+	case project == nil:
+		c.Error(fmt.Errorf("compose project %s not found", req.Project))
+		return
+	case project.ServiceID != nil || project.Managed:
+		c.Error(fmt.Errorf("compose project %s is already managed", req.Project))
+		return
+	case len(project.ConfigFiles) == 0 || project.WorkingDir == "":
+		c.Error(fmt.Errorf("the compose files of project %s are unknown", req.Project))
+		return
+	}
+
+	existing, err := h.AppRepository().GetByName(req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if existing != nil {
+		c.Error(fmt.Errorf("app %s already exists", req.Name))
+		return
+	}
+
+	exec, err := state.GetExec()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	files := make([][]byte, 0, len(project.ConfigFiles))
+	for _, f := range project.ConfigFiles {
+		data, err := exec.ReadFile(f)
+		if err != nil {
+			c.Error(fmt.Errorf("failed to read %s: %w", f, err))
+			return
+		}
+		files = append(files, data)
+	}
+	file, err := compose.AdoptFile(files, project.WorkingDir)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the variables of the project .env become the QA of the app, their
+	// values may be secrets and are only kept as the answers of the service
+	values := map[string]string{}
+	if data, err := exec.ReadFile(path.Join(project.WorkingDir, deploypipe.AppEnvFile)); err == nil {
+		values, err = dotenv.Parse(dotenv.FormatEnv, data)
+		if err != nil {
+			c.Error(fmt.Errorf("failed to parse the .env file: %w", err))
+			return
+		}
+	}
+	items := make([]*model.AppQAItem, 0, len(values))
+	for k := range values {
+		items = append(items, &model.AppQAItem{Name: k, Type: qa.TypeString})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	tpl := compose.EscapeTemplate(file)
+	description := fmt.Sprintf("adopted from compose project %s in %s", project.Name, project.WorkingDir)
+	appView := model.AppView{
+		Name:          req.Name,
+		Description:   &description,
+		DockerCompose: &tpl,
+		Version:       initialAppVersion,
+		QA:            items,
+	}
+	appModel := appView.ToModel()
+	if err := h.AppRepository().Create(appModel); err != nil {
+		c.Error(err)
+		return
+	}
+	// fail drops the rows created so far, the project can be adopted again
+	var serviceID int64
+	fail := func(err error) {
+		if serviceID != 0 {
+			h.ServiceRepository().Delete(serviceID)
+		}
+		h.AppVersionStore().DeleteApp(appModel.ID)
+		h.AppRepository().Delete(appModel.ID)
+		c.Error(err)
+	}
+	version, err := h.AppVersionStore().Publish(appModel)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	serviceView := model.ServiceView{
+		Name:         req.Name,
+		AppID:        appModel.ID,
+		NodeID:       req.NodeID,
+		Status:       projectStatus(project.DiscoveredProject),
+		QAValues:     values,
+		AppVersionID: &version.ID,
+	}
+	service := serviceView.ToModel()
+	service.ComposeProject = &project.Name
+	id, err := h.ServiceRepository().Create(service)
+	if err != nil {
+		fail(err)
+		return
+	}
+	service.ID = id
+	serviceID = id
+
+	// the service is running: write the files the compose commands of the
+	// panel use until the next deploy replaces them
+	servicePath, err := deploypipe.NewDownCtx(h.options, service, state, nil).GetServicePath()
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := exec.WriteFile(path.Join(servicePath, deploypipe.DockerComposeFile), []byte(file)); err != nil {
+		fail(err)
+		return
+	}
+	envFile, err := dotenv.Format(dotenv.FormatEnv, values)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := exec.WriteFileWithMode(path.Join(servicePath, deploypipe.DockerComposeEnvFile), envFile, 0o600); err != nil {
+		fail(err)
+		return
+	}
+	if err := h.updateServiceDeployInfo(service, map[string]string{
+		model.DeployInfoAppVersion: appModel.Version,
+	}); err != nil {
+		fail(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(adoptComposeProjectResponse{
+		AppID:     appModel.ID,
+		ServiceID: id,
+	}))
+}
+
+func projectStatus(project *compose.DiscoveredProject) string {
+	for _, c := range project.Containers {
+		if c.State != "running" {
+			return "stopped"
+		}
+	}
+	return "running"
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/scheduler"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// nodeResources reads the free capacity of a node, from its last metrics
+// when they are recent.
+func (h *BaseHandler) nodeResources(node *model.Node) (*scheduler.Resources, error) {
+	_, interval := h.metricsTiers()
+	m, err := h.NodeMetricRepository().Latest(node.ID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && time.Since(time.Unix(m.Timestamp, 0)) <= 3*interval {
+		return &scheduler.Resources{
+			CPUs:        m.CPUs,
+			Load:        m.Load1,
+			MemoryTotal: m.MemoryTotal,
+			MemoryFree:  m.MemoryTotal - m.MemoryUsed,
+			DiskTotal:   m.DiskTotal,
+			DiskFree:    m.DiskTotal - m.DiskUsed,
+		}, nil
+	}
+
+	state, err := h.NodeManager().AddOrGetNode(node)
+	if err != nil {
+		return nil, err
+	}
+	exec, err := state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+	path := ""
+	if node.DataPath != nil {
+		path = *node.DataPath
+	}
+	stdout, _, err := exec.ExecuteOutput(scheduler.ResourcesCommand(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	return scheduler.ParseResources(stdout)
+}
+
+// scheduleService picks the node of a service from the placement of its
+// app, serviceID is 0 for a new service.
+func (h *BaseHandler) scheduleService(app *model.App, serviceID int64) (*scheduler.Decision, error) {
+	placement, err := scheduler.ParsePlacement(app.ToView().Metadata)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := h.NodeRepository().List()
+	if err != nil {
+		return nil, err
+	}
+	services, err := h.ServiceRepository().List()
+	if err != nil {
+		return nil, err
+	}
+	byNode := map[int64][]*model.Service{}
+	for _, service := range services {
+		byNode[service.NodeID] = append(byNode[service.NodeID], service)
+	}
+
+	candidates := make([]*scheduler.Candidate, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		node := &nodes[i]
+		candidates[i] = &scheduler.Candidate{
+			Node:     node,
+			Labels:   node.GetLabels(),
+			Services: byNode[node.ID],
+		}
+		if node.Status != "online" {
+			continue
+		}
+		wg.Add(1)
+		go func(c *scheduler.Candidate) {
+			defer wg.Done()
+			resources, err := h.nodeResources(c.Node)
+			if err != nil {
+				log.Printf("read resources of node %s: %v", c.Node.Name, err)
+				return
+			}
+			c.Resources = resources
+		}(candidates[i])
+	}
+	wg.Wait()
+
+	return scheduler.Schedule(placement, serviceID, candidates)
+}
+
+// GetServicePlacementHandler previews the node the scheduler picks for a
+// service of an app.
+func (h *BaseHandler) GetServicePlacementHandler(ctx context.Context, c *app.RequestContext) {
+	type getServicePlacementRequest struct {
+		AppID        int64  `json:"app_id"`
+		AppVersionID *int64 `json:"app_version_id"`
+		// ServiceID is left out of the anti affinity of the app
+		ServiceID int64 `json:"service_id"`
+	}
+
+	var req getServicePlacementRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.AppID <= 0 {
+		c.Error(errors.New("app_id is required"))
+		return
+	}
+
+	app, err := h.AppRepository().GetByID(req.AppID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if app == nil {
+		c.Error(errors.New("app not found"))
+		return
+	}
+	version, err := h.resolveAppVersion(app, req.AppVersionID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	decision, err := h.scheduleService(version.ApplyTo(app), req.ServiceID)
+	if err != nil && !errors.Is(err, scheduler.ErrNoNode) {
+		c.Error(err)
+		return
+	}
+	// the decision explains why every node was left out
+	c.JSON(http.StatusOK, SuccessResponse(decision))
+}
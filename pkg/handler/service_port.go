@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func (h *BaseHandler) GetServicePortListHandler(ctx context.Context, c *app.RequestContext) {
+	type getServicePortListRequest struct {
+		ServiceID int64 `json:"service_id"`
+	}
+
+	var req getServicePortListRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ServiceID <= 0 {
+		c.Error(errors.New("service_id is required"))
+		return
+	}
+
+	allocations, err := h.PortAllocationRepository().ListByService(req.ServiceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(allocations))
+}
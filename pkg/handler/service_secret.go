@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/benlocal/lai-panel/pkg/secret"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func (h *BaseHandler) GetServiceSecretListHandler(ctx context.Context, c *app.RequestContext) {
+	type getServiceSecretListRequest struct {
+		ServiceID int64 `json:"service_id"`
+	}
+
+	var req getServiceSecretListRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ServiceID <= 0 {
+		c.Error(errors.New("service_id is required"))
+		return
+	}
+
+	secrets, err := secret.NewStore(h.KvRepository()).List(req.ServiceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(secrets))
+}
+
+func (h *BaseHandler) RotateServiceSecretHandler(ctx context.Context, c *app.RequestContext) {
+	type rotateServiceSecretRequest struct {
+		ServiceID int64  `json:"service_id"`
+		Name      string `json:"name"`
+	}
+
+	var req rotateServiceSecretRequest
+	if err := c.BindAndValidate(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ServiceID <= 0 || req.Name == "" {
+		c.Error(errors.New("service_id and name are required"))
+		return
+	}
+
+	s, err := secret.NewStore(h.KvRepository()).Rotate(req.ServiceID, req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, SuccessResponse(s))
+}
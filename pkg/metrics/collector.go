@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/docker/docker/client"
+)
+
+// Collector samples the resources of the node it runs on. CPU usage and
+// network rates are measured between two samples, the first sample reports
+// them as zero.
+type Collector struct {
+	procPath     string
+	diskPath     string
+	dockerClient *client.Client
+
+	mu       sync.Mutex
+	lastTime time.Time
+	lastCPU  cpuTimes
+	lastNet  netCounters
+}
+
+// NewCollector samples the filesystem of diskPath, dockerClient may be nil.
+func NewCollector(diskPath string, dockerClient *client.Client) *Collector {
+	if diskPath == "" {
+		diskPath = "/"
+	}
+	return &Collector{
+		procPath:     "/proc",
+		diskPath:     diskPath,
+		dockerClient: dockerClient,
+	}
+}
+
+func (c *Collector) readProc(name string) (string, error) {
+	data, err := os.ReadFile(c.procPath + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Collect takes a sample, the node id is left to the caller.
+func (c *Collector) Collect(ctx context.Context) (*model.NodeMetric, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	m := &model.NodeMetric{Timestamp: now.Unix()}
+
+	stat, err := c.readProc("stat")
+	if err != nil {
+		return nil, err
+	}
+	cpu, cpus, err := parseCPUTimes(stat)
+	if err != nil {
+		return nil, err
+	}
+	m.CPUs = cpus
+
+	meminfo, err := c.readProc("meminfo")
+	if err != nil {
+		return nil, err
+	}
+	if m.MemoryTotal, m.MemoryUsed, err = parseMeminfo(meminfo); err != nil {
+		return nil, err
+	}
+
+	loadavg, err := c.readProc("loadavg")
+	if err != nil {
+		return nil, err
+	}
+	load, err := parseLoadavg(loadavg)
+	if err != nil {
+		return nil, err
+	}
+	m.Load1, m.Load5, m.Load15 = load[0], load[1], load[2]
+
+	netdev, err := c.readProc("net/dev")
+	if err != nil {
+		return nil, err
+	}
+	net, err := parseNetDev(netdev)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.DiskTotal, m.DiskUsed, err = diskUsage(c.diskPath); err != nil {
+		return nil, err
+	}
+
+	if c.dockerClient != nil {
+		info, err := c.dockerClient.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.Containers = info.Containers
+		m.ContainersRunning = info.ContainersRunning
+	}
+
+	if !c.lastTime.IsZero() {
+		seconds := now.Sub(c.lastTime).Seconds()
+		m.CPUPercent = cpuPercent(c.lastCPU, cpu)
+		m.NetRxRate = rate(c.lastNet.rx, net.rx, seconds)
+		m.NetTxRate = rate(c.lastNet.tx, net.tx, seconds)
+	}
+	c.lastTime = now
+	c.lastCPU = cpu
+	c.lastNet = net
+	return m, nil
+}
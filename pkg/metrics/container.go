@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ContainerStats is the resource usage of a container computed the way
+// docker stats does.
+type ContainerStats struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Read          time.Time `json:"read"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	MemoryUsage   uint64    `json:"memory_usage"`
+	MemoryLimit   uint64    `json:"memory_limit"`
+	MemoryPercent float64   `json:"memory_percent"`
+	NetRx         uint64    `json:"net_rx"`
+	NetTx         uint64    `json:"net_tx"`
+	BlockRead     uint64    `json:"block_read"`
+	BlockWrite    uint64    `json:"block_write"`
+	PIDs          uint64    `json:"pids"`
+}
+
+// NewContainerStats computes the usage of a stats sample. The CPU usage is
+// measured against the previous sample the daemon sends along, it is zero
+// for the first sample of a stream.
+func NewContainerStats(s *container.StatsResponse) *ContainerStats {
+	stats := &ContainerStats{
+		ID:          s.ID,
+		Name:        strings.TrimPrefix(s.Name, "/"),
+		Read:        s.Read,
+		CPUPercent:  containerCPUPercent(s),
+		MemoryUsage: containerMemoryUsage(s.MemoryStats),
+		MemoryLimit: s.MemoryStats.Limit,
+		PIDs:        s.PidsStats.Current,
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+	for _, n := range s.Networks {
+		stats.NetRx += n.RxBytes
+		stats.NetTx += n.TxBytes
+	}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
+
+func containerCPUPercent(s *container.StatsResponse) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	// the first sample of a stream has no previous sample
+	if s.PreCPUStats.SystemUsage == 0 || cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// containerMemoryUsage leaves out the page cache the kernel can reclaim,
+// total_inactive_file on cgroup v1 and inactive_file on cgroup v2.
+func containerMemoryUsage(m container.MemoryStats) uint64 {
+	if v, ok := m.Stats["total_inactive_file"]; ok && v < m.Usage {
+		return m.Usage - v
+	}
+	if v := m.Stats["inactive_file"]; v < m.Usage {
+		return m.Usage - v
+	}
+	return m.Usage
+}
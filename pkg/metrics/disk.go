@@ -0,0 +1,9 @@
+//go:build !linux
+
+package metrics
+
+import "errors"
+
+func diskUsage(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk usage is only read on linux")
+}
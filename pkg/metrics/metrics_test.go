@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProc(t *testing.T) {
+	prev, cpus, err := parseCPUTimes(`cpu  100 0 100 700 100 0 0 0 0 0
+cpu0 50 0 50 350 50 0 0 0 0 0
+cpu1 50 0 50 350 50 0 0 0 0 0
+intr 12345
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cpus)
+	cur, _, err := parseCPUTimes("cpu  250 0 150 750 150 0 0 0 0 0\n")
+	assert.NoError(t, err)
+	// 300 jiffies passed, 100 of them idle
+	assert.InDelta(t, 66.67, cpuPercent(prev, cur), 0.01)
+	assert.Equal(t, 0.0, cpuPercent(cur, cur))
+
+	total, used, err := parseMeminfo(`MemTotal:        8000000 kB
+MemFree:          500000 kB
+MemAvailable:    2000000 kB
+Buffers:          100000 kB
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8000000*1024), total)
+	assert.Equal(t, uint64(6000000*1024), used)
+
+	load, err := parseLoadavg("0.52 0.58 0.59 1/467 12345\n")
+	assert.NoError(t, err)
+	assert.Equal(t, [3]float64{0.52, 0.58, 0.59}, load)
+
+	net, err := parseNetDev(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 9999       10    0    0    0     0          0         0     9999      10    0    0    0     0       0          0
+  eth0: 1000       10    0    0    0     0          0         0     2000      20    0    0    0     0       0          0
+docker0: 500        5    0    0    0     0          0         0      700       7    0    0    0     0       0          0
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, netCounters{rx: 1500, tx: 2700}, net)
+	assert.Equal(t, 100.0, rate(1000, 2000, 10))
+	assert.Equal(t, 0.0, rate(2000, 1000, 10))
+}
+
+func TestSelectTier(t *testing.T) {
+	tiers := Tiers(30 * 24 * time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+	interval := 15 * time.Second
+
+	tier := SelectTier(tiers, interval, now.Add(-time.Hour), now, now)
+	assert.Equal(t, time.Duration(0), tier.Resolution)
+
+	// a day of raw samples is more than MaxPoints
+	tier = SelectTier(tiers, interval, now.Add(-24*time.Hour), now, now)
+	assert.Equal(t, 5*time.Minute, tier.Resolution)
+
+	// raw samples are gone two days later
+	tier = SelectTier(tiers, interval, now.Add(-48*time.Hour), now.Add(-47*time.Hour), now)
+	assert.Equal(t, 5*time.Minute, tier.Resolution)
+
+	tier = SelectTier(tiers, interval, now.Add(-20*24*time.Hour), now, now)
+	assert.Equal(t, time.Hour, tier.Resolution)
+
+	// retention shorter than the finer tiers caps them
+	assert.Equal(t, 12*time.Hour, Tiers(12 * time.Hour)[0].Retention)
+}
+
+func TestNewContainerStats(t *testing.T) {
+	s := &container.StatsResponse{
+		ID:   "abc",
+		Name: "/web-1",
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 3_000_000},
+			SystemUsage: 20_000_000,
+			OnlineCPUs:  2,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 1_000_000},
+			SystemUsage: 10_000_000,
+		},
+		MemoryStats: container.MemoryStats{
+			Usage: 300,
+			Limit: 1000,
+			Stats: map[string]uint64{"inactive_file": 100},
+		},
+		Networks: map[string]container.NetworkStats{
+			"eth0": {RxBytes: 10, TxBytes: 20},
+			"eth1": {RxBytes: 1, TxBytes: 2},
+		},
+		BlkioStats: container.BlkioStats{
+			IoServiceBytesRecursive: []container.BlkioStatEntry{
+				{Op: "Read", Value: 5},
+				{Op: "write", Value: 7},
+				{Op: "Total", Value: 12},
+				{Op: "read", Value: 1},
+			},
+		},
+		PidsStats: container.PidsStats{Current: 4},
+	}
+	stats := NewContainerStats(s)
+	assert.Equal(t, "web-1", stats.Name)
+	// 2ms of 10ms on 2 cpus
+	assert.InDelta(t, 40.0, stats.CPUPercent, 0.001)
+	assert.Equal(t, uint64(200), stats.MemoryUsage)
+	assert.InDelta(t, 20.0, stats.MemoryPercent, 0.001)
+	assert.Equal(t, uint64(11), stats.NetRx)
+	assert.Equal(t, uint64(22), stats.NetTx)
+	assert.Equal(t, uint64(6), stats.BlockRead)
+	assert.Equal(t, uint64(7), stats.BlockWrite)
+	assert.Equal(t, uint64(4), stats.PIDs)
+
+	// cgroup v1
+	s.CPUStats.OnlineCPUs = 0
+	s.CPUStats.CPUUsage.PercpuUsage = []uint64{1, 2, 3, 4}
+	s.MemoryStats.Stats = map[string]uint64{"total_inactive_file": 50, "inactive_file": 100}
+	stats = NewContainerStats(s)
+	assert.InDelta(t, 80.0, stats.CPUPercent, 0.001)
+	assert.Equal(t, uint64(250), stats.MemoryUsage)
+
+	// the first sample of a stream
+	s.PreCPUStats = container.CPUStats{}
+	assert.Equal(t, 0.0, NewContainerStats(s).CPUPercent)
+}
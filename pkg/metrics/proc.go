@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cpuTimes are the jiffies of the cpu line of /proc/stat.
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// parseCPUTimes reads the aggregated cpu line of /proc/stat and the number of
+// cpus.
+func parseCPUTimes(stat string) (cpuTimes, int, error) {
+	var times cpuTimes
+	found := false
+	cpus := 0
+	scanner := bufio.NewScanner(strings.NewReader(stat))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cpus++
+			continue
+		}
+		if len(fields) < 5 {
+			return times, 0, fmt.Errorf("invalid cpu line: %q", scanner.Text())
+		}
+		// user nice system idle iowait irq softirq steal, guest is part of user
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return times, 0, fmt.Errorf("invalid cpu line: %w", err)
+			}
+			times.total += v
+			// idle and iowait
+			if i == 3 || i == 4 {
+				times.idle += v
+			}
+		}
+		found = true
+	}
+	if !found {
+		return times, 0, fmt.Errorf("no cpu line in /proc/stat")
+	}
+	return times, cpus, nil
+}
+
+// cpuPercent is the busy share of the cpus between two reads.
+func cpuPercent(prev, cur cpuTimes) float64 {
+	total := float64(cur.total) - float64(prev.total)
+	if total <= 0 {
+		return 0
+	}
+	idle := float64(cur.idle) - float64(prev.idle)
+	return (total - idle) / total * 100
+}
+
+// parseMeminfo returns the total and used memory in bytes, the available
+// memory is free for new processes.
+func parseMeminfo(meminfo string) (uint64, uint64, error) {
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = v * 1024
+	}
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	if available > total {
+		available = total
+	}
+	return total, total - available, nil
+}
+
+// parseLoadavg returns the load averages of /proc/loadavg.
+func parseLoadavg(loadavg string) ([3]float64, error) {
+	var load [3]float64
+	fields := strings.Fields(loadavg)
+	if len(fields) < 3 {
+		return load, fmt.Errorf("invalid loadavg: %q", loadavg)
+	}
+	for i := range load {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return load, fmt.Errorf("invalid loadavg: %w", err)
+		}
+		load[i] = v
+	}
+	return load, nil
+}
+
+// netCounters are the bytes received and sent of /proc/net/dev.
+type netCounters struct {
+	rx uint64
+	tx uint64
+}
+
+// parseNetDev sums the counters of the interfaces but the loopback.
+func parseNetDev(netdev string) (netCounters, error) {
+	var c netCounters
+	scanner := bufio.NewScanner(strings.NewReader(netdev))
+	for scanner.Scan() {
+		name, stats, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		if strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			return c, fmt.Errorf("invalid net/dev line: %q", scanner.Text())
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid net/dev line: %w", err)
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid net/dev line: %w", err)
+		}
+		c.rx += rx
+		c.tx += tx
+	}
+	return c, nil
+}
+
+// rate is the per second increase of a counter, counters reset when an
+// interface goes away.
+func rate(prev, cur uint64, seconds float64) float64 {
+	if seconds <= 0 || cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import "time"
+
+// MaxPoints bounds the samples of a range query.
+const MaxPoints = 720
+
+// Tier is a resolution metrics are kept at, raw samples have a resolution of
+// zero.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// Tiers returns the raw samples and the 5 minute and hourly averages, the
+// hourly averages are kept for retention.
+func Tiers(retention time.Duration) []Tier {
+	tiers := []Tier{
+		{Resolution: 0, Retention: 24 * time.Hour},
+		{Resolution: 5 * time.Minute, Retention: 7 * 24 * time.Hour},
+		{Resolution: time.Hour, Retention: retention},
+	}
+	for i := range tiers {
+		if tiers[i].Retention > retention {
+			tiers[i].Retention = retention
+		}
+	}
+	return tiers
+}
+
+// SelectTier returns the finest tier still holding from with at most
+// MaxPoints samples between from and to. interval is the period of the raw
+// samples.
+func SelectTier(tiers []Tier, interval time.Duration, from, to, now time.Time) Tier {
+	span := to.Sub(from)
+	for _, tier := range tiers {
+		step := tier.Resolution
+		if step == 0 {
+			step = interval
+		}
+		if step <= 0 {
+			continue
+		}
+		if now.Sub(from) <= tier.Retention && span/step <= MaxPoints {
+			return tier
+		}
+	}
+	return tiers[len(tiers)-1]
+}
@@ -20,6 +20,9 @@ type App struct {
 	Metadata      *string   `db:"metadata" json:"metadata"`
 	// installer file some like xxx.tar.gz
 	StaticPath *string `db:"static_path" json:"static_path"`
+	// set for apps synchronized from a catalog source
+	CatalogSourceID *int64  `db:"catalog_source_id" json:"catalog_source_id"`
+	CatalogCommit   *string `db:"catalog_commit" json:"catalog_commit"`
 }
 
 type AppQAItem struct {
@@ -29,6 +32,15 @@ type AppQAItem struct {
 	Options      []string `json:"options"`
 	Required     bool     `json:"required"`
 	Description  string   `json:"description"`
+	// Min and Max bound the value of int and port items and the length of
+	// string items
+	Min *int64 `json:"min,omitempty"`
+	Max *int64 `json:"max,omitempty"`
+	// Pattern is the regular expression of regex items
+	Pattern string `json:"pattern,omitempty"`
+	// ShowIf hides the item unless the condition holds, e.g. "db_type=mysql&&tls=true"
+	ShowIf string `json:"show_if,omitempty"`
+	Group  string `json:"group,omitempty"`
 }
 
 type AppView struct {
@@ -77,20 +89,24 @@ func (a *App) GetDefaultEnv() map[string]string {
 		json.Unmarshal([]byte(*a.Metadata), &metadata)
 	}
 	for _, metadata := range metadata {
-		key := fmt.Sprintf("metadata_%s", a.Name)
-		for _, property := range metadata.Properties {
-			env[fmt.Sprintf("%s_%s", key, property)] = property
+		key := fmt.Sprintf("metadata_%s", metadata.Name)
+		for property, value := range metadata.Properties {
+			env[fmt.Sprintf("%s_%s", key, property)] = value
 		}
 	}
 
+	for _, qa := range a.GetQA() {
+		env[qa.Name] = qa.DefaultValue
+	}
+	return env
+}
+
+func (a *App) GetQA() []*AppQAItem {
 	qa := []*AppQAItem{}
 	if a.QA != nil {
 		json.Unmarshal([]byte(*a.QA), &qa)
 	}
-	for _, qa := range qa {
-		env[a.Name] = qa.DefaultValue
-	}
-	return env
+	return qa
 }
 
 func (a *AppView) ToModel() *App {
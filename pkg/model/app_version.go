@@ -0,0 +1,95 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AppVersion is an immutable snapshot of the definition of an app, services
+// are pinned to one of them.
+type AppVersion struct {
+	ID            int64     `db:"id" json:"id"`
+	AppID         int64     `db:"app_id" json:"app_id"`
+	Version       string    `db:"version" json:"version"`
+	DockerCompose *string   `db:"docker_compose" json:"docker_compose"`
+	QA            *string   `db:"qa" json:"qa"`
+	Metadata      *string   `db:"metadata" json:"metadata"`
+	StaticPath    *string   `db:"static_path" json:"static_path"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+type AppVersionView struct {
+	ID            int64        `json:"id"`
+	AppID         int64        `json:"app_id"`
+	Version       string       `json:"version"`
+	DockerCompose *string      `json:"docker_compose"`
+	QA            []*AppQAItem `json:"qa"`
+	Metadata      []*Metadata  `json:"metadata"`
+	StaticPath    *string      `json:"static_path"`
+	CreatedAt     time.Time    `json:"created_at"`
+	// Services is the number of services pinned to the version
+	Services int `json:"services"`
+}
+
+func NewAppVersion(app *App) *AppVersion {
+	return &AppVersion{
+		AppID:         app.ID,
+		Version:       app.Version,
+		DockerCompose: app.DockerCompose,
+		QA:            app.QA,
+		Metadata:      app.Metadata,
+		StaticPath:    app.StaticPath,
+	}
+}
+
+func (v *AppVersion) GetQA() []*AppQAItem {
+	qa := []*AppQAItem{}
+	if v.QA != nil {
+		json.Unmarshal([]byte(*v.QA), &qa)
+	}
+	return qa
+}
+
+func (v *AppVersion) ToView() *AppVersionView {
+	metadata := []*Metadata{}
+	if v.Metadata != nil {
+		json.Unmarshal([]byte(*v.Metadata), &metadata)
+	}
+	return &AppVersionView{
+		ID:            v.ID,
+		AppID:         v.AppID,
+		Version:       v.Version,
+		DockerCompose: v.DockerCompose,
+		QA:            v.GetQA(),
+		Metadata:      metadata,
+		StaticPath:    v.StaticPath,
+		CreatedAt:     v.CreatedAt,
+	}
+}
+
+// Matches reports whether the app still has the definition of the version.
+func (v *AppVersion) Matches(app *App) bool {
+	return v.Version == app.Version &&
+		stringValue(v.DockerCompose) == stringValue(app.DockerCompose) &&
+		stringValue(v.QA) == stringValue(app.QA) &&
+		stringValue(v.Metadata) == stringValue(app.Metadata) &&
+		stringValue(v.StaticPath) == stringValue(app.StaticPath)
+}
+
+// ApplyTo returns a copy of the app with the definition of the version.
+func (v *AppVersion) ApplyTo(app *App) *App {
+	pinned := *app
+	pinned.Version = v.Version
+	pinned.DockerCompose = v.DockerCompose
+	pinned.QA = v.QA
+	pinned.Metadata = v.Metadata
+	pinned.StaticPath = v.StaticPath
+	return &pinned
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	CatalogSourceDir = "dir"
+	CatalogSourceGit = "git"
+)
+
+// CatalogSource is a directory or git repository holding app definitions,
+// one folder per app.
+type CatalogSource struct {
+	ID   int64  `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	Kind string `db:"kind" json:"kind"`
+	// URL is the directory path for dir sources, the clone url for git sources
+	URL    string `db:"url" json:"url"`
+	Branch string `db:"branch" json:"branch"`
+
+	LastCommit   string     `db:"last_commit" json:"last_commit"`
+	LastSyncedAt *time.Time `db:"last_synced_at" json:"last_synced_at"`
+	LastError    string     `db:"last_error" json:"last_error"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+}
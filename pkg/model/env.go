@@ -8,6 +8,7 @@ type Env struct {
 	Value       string    `db:"value" json:"value"`
 	Scope       string    `db:"scope" json:"scope"`
 	Description string    `db:"description" json:"description"`
+	IsSecret    bool      `db:"is_secret" json:"is_secret"`
 	Metadata    string    `db:"metadata" json:"metadata"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/crypto"
+)
+
+const (
+	DockerHubHost          = "docker.io"
+	DockerHubServerAddress = "https://index.docker.io/v1/"
+)
+
+// ImageRegistry holds the credential used to pull images from a private registry.
+type ImageRegistry struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Host      string    `db:"host" json:"host"`
+	Username  string    `db:"username" json:"username"`
+	Password  string    `db:"password" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	Metadata  *string   `db:"metadata" json:"metadata"`
+}
+
+type ImageRegistryView struct {
+	ID              int64   `json:"id"`
+	Name            string  `json:"name"`
+	Host            string  `json:"host"`
+	Username        string  `json:"username"`
+	RequestPassword *string `json:"password"`
+}
+
+func (r *ImageRegistry) ToView() *ImageRegistryView {
+	return &ImageRegistryView{
+		ID:              r.ID,
+		Name:            r.Name,
+		Host:            r.Host,
+		Username:        r.Username,
+		RequestPassword: nil,
+	}
+}
+
+func (r *ImageRegistry) GetDecryptedPassword() (string, error) {
+	return crypto.Decrypt(r.Password)
+}
+
+// ServerAddress returns the address docker expects in the auth config.
+func (r *ImageRegistry) ServerAddress() string {
+	if r.Host == DockerHubHost {
+		return DockerHubServerAddress
+	}
+	return r.Host
+}
+
+func (v *ImageRegistryView) ToModel() (*ImageRegistry, error) {
+	var encryptedPassword string
+	if v.RequestPassword != nil && *v.RequestPassword != "" {
+		encrypted, err := crypto.Encrypt(*v.RequestPassword)
+		if err != nil {
+			return nil, err
+		}
+		encryptedPassword = encrypted
+	}
+
+	return &ImageRegistry{
+		ID:       v.ID,
+		Name:     v.Name,
+		Host:     NormalizeRegistryHost(v.Host),
+		Username: v.Username,
+		Password: encryptedPassword,
+	}, nil
+}
+
+// NormalizeRegistryHost turns user input such as "https://index.docker.io/v1/"
+// into the registry domain used by image references.
+func NormalizeRegistryHost(host string) string {
+	host = strings.TrimSpace(strings.ToLower(host))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "", "index.docker.io", "registry-1.docker.io":
+		return DockerHubHost
+	}
+	return host
+}
@@ -1,9 +1,14 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/benlocal/lai-panel/pkg/crypto"
+	"github.com/benlocal/lai-panel/pkg/version"
 )
 
 type Node struct {
@@ -21,8 +26,30 @@ type Node struct {
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
 	Metadata    *string   `db:"metadata" json:"metadata"`
 	DataPath    *string   `db:"data_path" json:"data_path"`
+	// ComposeBackend is how compose projects are run on the node
+	ComposeBackend string `db:"compose_backend" json:"compose_backend"`
+	// AgentAutoReinstall lets the health check reinstall the agent of the
+	// node when it is offline
+	AgentAutoReinstall bool `db:"agent_auto_reinstall" json:"agent_auto_reinstall"`
+	// AgentVersion and AgentCapabilities are reported by the agent when it
+	// registers, the capabilities are comma separated
+	AgentVersion      string `db:"agent_version" json:"agent_version"`
+	AgentCapabilities string `db:"agent_capabilities" json:"agent_capabilities"`
+	// AgentTunnel is set when the agent dials the master, the master then
+	// reaches the agent through the tunnel instead of its address
+	AgentTunnel bool `db:"agent_tunnel" json:"agent_tunnel"`
+	// AgentToken is issued to the agent when it first registers, the master
+	// authenticates to the agent with it and the agent to the master
+	AgentToken string `db:"agent_token" json:"-"`
 }
 
+const (
+	// ComposeBackendCLI runs the docker compose command on the node
+	ComposeBackendCLI = "cli"
+	// ComposeBackendNative drives the node docker API directly
+	ComposeBackendNative = "native"
+)
+
 type NodeView struct {
 	ID                 int64   `json:"id"`
 	IsLocal            bool    `json:"is_local"`
@@ -34,10 +61,26 @@ type NodeView struct {
 	RequestSSHPassword *string `json:"ssh_password"`
 	SSHPort            int     `json:"ssh_port"`
 	AgentPort          int     `json:"agent_port"`
+	ComposeBackend     string  `json:"compose_backend"`
+	// Labels are matched by the placement constraints of apps, the labels
+	// are kept when nil
+	Labels map[string]string `json:"labels"`
+	// AgentAutoReinstall is kept when nil
+	AgentAutoReinstall *bool `json:"agent_auto_reinstall"`
+
+	// reported by the agent, read only
+	AgentVersion      string   `json:"agent_version"`
+	AgentCapabilities []string `json:"agent_capabilities"`
+	AgentTunnel       bool     `json:"agent_tunnel"`
+	// AgentIncompatible tells why the agent can't work with the master
+	AgentIncompatible string `json:"agent_incompatible,omitempty"`
+	// AgentOutdated is set when the agent runs another version than the
+	// master
+	AgentOutdated bool `json:"agent_outdated"`
 }
 
 func (n *Node) ToView() *NodeView {
-	return &NodeView{
+	view := &NodeView{
 		ID:                 n.ID,
 		IsLocal:            n.IsLocal,
 		Name:               n.Name,
@@ -47,8 +90,43 @@ func (n *Node) ToView() *NodeView {
 		SSHUser:            n.SSHUser,
 		SSHPort:            n.SSHPort,
 		AgentPort:          n.AgentPort,
+		ComposeBackend:     n.ComposeBackend,
 		RequestSSHPassword: nil,
+		Labels:             n.GetLabels(),
+		AgentAutoReinstall: &n.AgentAutoReinstall,
+		AgentVersion:       n.AgentVersion,
+		AgentCapabilities:  n.GetAgentCapabilities(),
+		AgentTunnel:        n.AgentTunnel,
 	}
+	if !n.IsLocal {
+		if err := version.CheckAgent(n.AgentVersion, view.AgentCapabilities); err != nil {
+			view.AgentIncompatible = err.Error()
+		}
+		view.AgentOutdated = version.Outdated(n.AgentVersion)
+	}
+	return view
+}
+
+func (n *Node) GetAgentCapabilities() []string {
+	if n.AgentCapabilities == "" {
+		return []string{}
+	}
+	return strings.Split(n.AgentCapabilities, ",")
+}
+
+// NodeLabelsMetadata is the metadata holding the labels of a node.
+const NodeLabelsMetadata = "labels"
+
+func (n *Node) GetLabels() map[string]string {
+	metadata := []*Metadata{}
+	if n.Metadata != nil {
+		json.Unmarshal([]byte(*n.Metadata), &metadata)
+	}
+	labels, ok := ToMetadataMap(metadata, NodeLabelsMetadata)
+	if !ok || labels == nil {
+		labels = map[string]string{}
+	}
+	return labels
 }
 
 func (n *Node) GetDecryptedSSHPassword() (string, error) {
@@ -60,6 +138,12 @@ func (n *Node) GetDecryptedSSHPassword() (string, error) {
 }
 
 func (v *NodeView) ToModel() (*Node, error) {
+	switch v.ComposeBackend {
+	case "", ComposeBackendCLI, ComposeBackendNative:
+	default:
+		return nil, fmt.Errorf("invalid compose backend: %s", v.ComposeBackend)
+	}
+
 	var encryptedPassword string
 	if v.RequestSSHPassword != nil && *v.RequestSSHPassword != "" {
 		encrypted, err := crypto.Encrypt(*v.RequestSSHPassword)
@@ -69,6 +153,30 @@ func (v *NodeView) ToModel() (*Node, error) {
 		encryptedPassword = encrypted
 	}
 
+	var metadataString *string
+	if v.Labels != nil {
+		for k := range v.Labels {
+			if k == "" {
+				return nil, errors.New("label keys must not be empty")
+			}
+		}
+		metadata, _ := json.Marshal([]*Metadata{
+			{
+				MetadataBase: MetadataBase{
+					Name:       NodeLabelsMetadata,
+					Properties: v.Labels,
+				},
+			},
+		})
+		s := string(metadata)
+		metadataString = &s
+	}
+
+	autoReinstall := false
+	if v.AgentAutoReinstall != nil {
+		autoReinstall = *v.AgentAutoReinstall
+	}
+
 	return &Node{
 		ID:          v.ID,
 		IsLocal:     v.IsLocal,
@@ -78,5 +186,9 @@ func (v *NodeView) ToModel() (*Node, error) {
 		SSHUser:     v.SSHUser,
 		SSHPassword: encryptedPassword,
 		SSHPort:     v.SSHPort,
+		Metadata:    metadataString,
+
+		ComposeBackend:     v.ComposeBackend,
+		AgentAutoReinstall: autoReinstall,
 	}, nil
 }
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// actions of a node job
+const (
+	NodeJobInstall   = "install"
+	NodeJobReinstall = "reinstall"
+	NodeJobUninstall = "uninstall"
+	NodeJobUpgrade   = "upgrade"
+)
+
+// statuses of a node job
+const (
+	NodeJobRunning   = "running"
+	NodeJobSucceeded = "succeeded"
+	NodeJobFailed    = "failed"
+)
+
+// what started a node job
+const (
+	NodeJobManual = "manual"
+	// NodeJobAuto is a reinstall of the health check of a node with the
+	// auto reinstall policy
+	NodeJobAuto = "auto"
+)
+
+// NodeJob is a run of an agent operation on a node.
+type NodeJob struct {
+	ID          int64      `db:"id" json:"id"`
+	NodeID      int64      `db:"node_id" json:"node_id"`
+	Action      string     `db:"action" json:"action"`
+	TriggeredBy string     `db:"triggered_by" json:"triggered_by"`
+	Status      string     `db:"status" json:"status"`
+	Output      string     `db:"output" json:"output,omitempty"`
+	Error       *string    `db:"error" json:"error"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	FinishedAt  *time.Time `db:"finished_at" json:"finished_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+// NodeMetric is a resource sample of a node. Downsampled metrics average the
+// raw samples over Resolution seconds.
+type NodeMetric struct {
+	ID         int64 `db:"id" json:"-"`
+	NodeID     int64 `db:"node_id" json:"node_id"`
+	Resolution int64 `db:"resolution" json:"resolution"`
+	// Timestamp is in unix seconds, the start of the period of downsampled
+	// metrics
+	Timestamp   int64   `db:"ts" json:"ts"`
+	CPUs        int     `db:"cpus" json:"cpus"`
+	CPUPercent  float64 `db:"cpu_percent" json:"cpu_percent"`
+	Load1       float64 `db:"load1" json:"load1"`
+	Load5       float64 `db:"load5" json:"load5"`
+	Load15      float64 `db:"load15" json:"load15"`
+	MemoryTotal uint64  `db:"memory_total" json:"memory_total"`
+	MemoryUsed  uint64  `db:"memory_used" json:"memory_used"`
+	DiskTotal   uint64  `db:"disk_total" json:"disk_total"`
+	DiskUsed    uint64  `db:"disk_used" json:"disk_used"`
+	// NetRxRate and NetTxRate are bytes per second over all interfaces but
+	// the loopback
+	NetRxRate         float64 `db:"net_rx_rate" json:"net_rx_rate"`
+	NetTxRate         float64 `db:"net_tx_rate" json:"net_tx_rate"`
+	Containers        int     `db:"containers" json:"containers"`
+	ContainersRunning int     `db:"containers_running" json:"containers_running"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PortAllocation is a host port reserved on a node for a service by the
+// alloc_port template function.
+type PortAllocation struct {
+	ID        int64     `db:"id" json:"id"`
+	NodeID    int64     `db:"node_id" json:"node_id"`
+	ServiceID int64     `db:"service_id" json:"service_id"`
+	Name      string    `db:"name" json:"name"`
+	Port      int       `db:"port" json:"port"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -7,9 +7,21 @@ type RegistryRequest struct {
 	IsLocal   bool    `json:"is_local"`
 	Status    string  `json:"status"`
 	DataPath  *string `json:"data_path,omitempty"`
+	// Version and Capabilities of the agent
+	Version      string   `json:"version,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Tunnel is set when the agent is reached through its tunnel
+	Tunnel bool `json:"tunnel,omitempty"`
+	// Token is the agent token issued by the master, empty on the first
+	// registration
+	Token string `json:"token,omitempty"`
 }
 
 type RegistryResponse struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
+	// Incompatible tells why the agent can't work with the master
+	Incompatible string `json:"incompatible,omitempty"`
+	// Token is the agent token the master authenticates with
+	Token string `json:"token,omitempty"`
 }
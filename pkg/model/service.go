@@ -5,6 +5,12 @@ import (
 	"time"
 )
 
+// keys of the deploy info saved on a service after a deploy
+const (
+	DeployInfoAppVersion = "app_version"
+	DeployInfoAppCommit  = "app_commit"
+)
+
 type Service struct {
 	ID         int64     `db:"id" json:"id"`
 	Name       string    `db:"name" json:"name"`
@@ -15,9 +21,15 @@ type Service struct {
 	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
 	Metadata   *string   `db:"metadata" json:"metadata"`
 	DeployInfo *string   `db:"deploy_info" json:"deploy_info"`
+	// the app version the service is pinned to
+	AppVersionID *int64 `db:"app_version_id" json:"app_version_id"`
+	// ComposeProject is the project name of an adopted compose project, the
+	// project is named after the service when empty
+	ComposeProject *string `db:"compose_project" json:"compose_project"`
 
-	AppName  string `db:"app_name" json:"app_name"`
-	NodeName string `db:"node_name" json:"node_name"`
+	AppName    string  `db:"app_name" json:"app_name"`
+	NodeName   string  `db:"node_name" json:"node_name"`
+	AppVersion *string `db:"app_version" json:"app_version"`
 }
 
 type ServiceView struct {
@@ -29,6 +41,14 @@ type ServiceView struct {
 	QAValues map[string]string `json:"qa_values"`
 	AppName  string            `json:"app_name"`
 	NodeName string            `json:"node_name"`
+	// AppVersionID pins the service to a version, the current version of
+	// the app when empty
+	AppVersionID   *int64 `json:"app_version_id"`
+	AppVersion     string `json:"app_version,omitempty"`
+	ComposeProject string `json:"compose_project,omitempty"`
+	// DependsOn lists the services deployed before the service, the
+	// dependencies are kept when nil
+	DependsOn []int64 `json:"depends_on"`
 }
 
 func (s *Service) ToView() *ServiceView {
@@ -50,6 +70,10 @@ func (s *Service) ToView() *ServiceView {
 		QAValues: qa,
 		AppName:  s.AppName,
 		NodeName: s.NodeName,
+
+		AppVersionID:   s.AppVersionID,
+		AppVersion:     stringValue(s.AppVersion),
+		ComposeProject: stringValue(s.ComposeProject),
 	}
 }
 
@@ -75,5 +99,7 @@ func (v *ServiceView) ToModel() *Service {
 		NodeID:   v.NodeID,
 		Status:   v.Status,
 		Metadata: metadataString,
+
+		AppVersionID: v.AppVersionID,
 	}
 }
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// ServiceDependency makes a service wait for another one, the dependency is
+// deployed and healthy before the service is deployed.
+type ServiceDependency struct {
+	ID          int64     `db:"id" json:"id"`
+	ServiceID   int64     `db:"service_id" json:"service_id"`
+	DependsOnID int64     `db:"depends_on_id" json:"depends_on_id"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,239 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// AgentNodeExec runs commands and transfers files through the agent of the
+// node, it is used for nodes without ssh access.
+type AgentNodeExec struct {
+	node    *model.Node
+	baseURL string
+	client  *http.Client
+	// transport is the tunnel of the agent, nil when the agent is dialed
+	transport http.RoundTripper
+}
+
+func NewAgentNodeExec(node *model.Node, transport http.RoundTripper) *AgentNodeExec {
+	return &AgentNodeExec{
+		node:      node,
+		transport: transport,
+	}
+}
+
+func (a *AgentNodeExec) Init() error {
+	if a.transport != nil {
+		a.baseURL = "http://" + a.node.Name
+		a.client = &http.Client{Transport: &agentTransport{base: a.transport, token: a.node.AgentToken}}
+		return nil
+	}
+	if a.node.AgentPort == 0 {
+		return errors.New("agent port is not set")
+	}
+	a.baseURL = fmt.Sprintf("http://%s:%d", a.node.Address, a.node.AgentPort)
+	a.client = &http.Client{Transport: &agentTransport{base: http.DefaultTransport, token: a.node.AgentToken}}
+	return nil
+}
+
+// agentTransport authenticates the requests to the agent with its token.
+type agentTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *agentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(r)
+}
+
+func (t *agentTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+func (a *AgentNodeExec) Close() error {
+	if a.client != nil {
+		a.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (a *AgentNodeExec) fileURL(path string) string {
+	return a.baseURL + "/node.exec/file?path=" + url.QueryEscape(path)
+}
+
+func (a *AgentNodeExec) WriteFile(path string, data []byte) error {
+	return a.WriteFileStream(path, bytes.NewReader(data))
+}
+
+func (a *AgentNodeExec) WriteFileWithMode(path string, data []byte, mode os.FileMode) error {
+	u := a.fileURL(path) + "&mode=" + strconv.FormatUint(uint64(mode.Perm()), 8)
+	resp, err := a.client.Post(u, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return agentResponseError(resp)
+}
+
+func (a *AgentNodeExec) WriteFileStream(path string, reader io.Reader) error {
+	resp, err := a.client.Post(a.fileURL(path), "application/octet-stream", reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return agentResponseError(resp)
+}
+
+func (a *AgentNodeExec) ReadFile(path string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := a.ReadFileStream(path, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *AgentNodeExec) ReadFileStream(path string, writer io.Writer) error {
+	resp, err := a.client.Get(a.fileURL(path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := agentResponseError(resp); err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, resp.Body)
+	return err
+}
+
+func (a *AgentNodeExec) ExecuteOutput(command string, opt *NodeExecuteCommandOptions) (string, string, error) {
+	stdout := ""
+	stderr := ""
+	err := a.ExecuteCommand(command, opt, func(line string) {
+		stdout += line + "\n"
+	}, func(line string) {
+		stderr += line + "\n"
+	})
+	return stdout, stderr, err
+}
+
+func (a *AgentNodeExec) ExecuteCommand(
+	command string,
+	opt *NodeExecuteCommandOptions,
+	onStdout func(string),
+	onStderr func(string),
+) error {
+	req := AgentCommandRequest{Command: command}
+	if opt != nil {
+		req.Env = opt.Env
+		req.WorkingDir = opt.WorkingDir
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	// the agent stops the command once the stream is closed
+	httpReq, err := http.NewRequestWithContext(commandContext(opt), http.MethodPost, a.baseURL+"/node.exec/command", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := agentResponseError(resp); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		kind, line, _ := strings.Cut(scanner.Text(), ":")
+		switch kind {
+		case "o":
+			if onStdout != nil {
+				onStdout(line)
+			}
+		case "e":
+			if onStderr != nil {
+				onStderr(line)
+			}
+		case "x":
+			if line != "" {
+				return errors.New(line)
+			}
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("agent closed the command stream before the command exited")
+}
+
+func agentResponseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("agent returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// AgentCommandRequest is the body of the agent command endpoint.
+type AgentCommandRequest struct {
+	Command    string            `json:"command"`
+	Env        map[string]string `json:"env"`
+	WorkingDir string            `json:"working_dir"`
+}
+
+// AgentCommandWriter writes the output of a command for AgentNodeExec, one
+// line per output line prefixed with o: or e:, terminated by x:<error>.
+type AgentCommandWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewAgentCommandWriter(w io.Writer) *AgentCommandWriter {
+	return &AgentCommandWriter{w: w}
+}
+
+func (w *AgentCommandWriter) write(kind string, line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line = strings.ReplaceAll(line, "\n", " ")
+	_, _ = io.WriteString(w.w, kind+":"+line+"\n")
+}
+
+func (w *AgentCommandWriter) Stdout(line string) {
+	w.write("o", line)
+}
+
+func (w *AgentCommandWriter) Stderr(line string) {
+	w.write("e", line)
+}
+
+func (w *AgentCommandWriter) Exit(err error) {
+	if err != nil {
+		w.write("x", err.Error())
+		return
+	}
+	w.write("x", "")
+}
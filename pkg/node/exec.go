@@ -1,11 +1,18 @@
 package node
 
-import "io"
+import (
+	"context"
+	"io"
+	"os"
+)
 
 type NodeExec interface {
 	Init() error
 	Close() error
 	WriteFile(path string, data []byte) error
+	// WriteFileWithMode writes a file which is never readable with other
+	// permissions than mode, not even while it is written
+	WriteFileWithMode(path string, data []byte, mode os.FileMode) error
 	WriteFileStream(path string, reader io.Reader) error
 	ReadFile(path string) ([]byte, error)
 	ReadFileStream(path string, writer io.Writer) error
@@ -19,8 +26,13 @@ type NodeExec interface {
 }
 
 type NodeExecuteCommandOptions struct {
+	// Env is exported on the command line of remote nodes, secrets belong in a
+	// file written with WriteFileWithMode
 	Env        map[string]string
 	WorkingDir string
+	// Context stops the command when it is done, commands such as a log
+	// follow run until then
+	Context context.Context
 }
 
 func NewNodeExecuteCommandOptions() *NodeExecuteCommandOptions {
@@ -37,3 +49,15 @@ func (o *NodeExecuteCommandOptions) SetEnv(env map[string]string) {
 func (o *NodeExecuteCommandOptions) SetWorkingDir(workingDir string) {
 	o.WorkingDir = workingDir
 }
+
+func (o *NodeExecuteCommandOptions) SetContext(ctx context.Context) {
+	o.Context = ctx
+}
+
+// commandContext is the context of the command, opt may be nil.
+func commandContext(opt *NodeExecuteCommandOptions) context.Context {
+	if opt == nil || opt.Context == nil {
+		return context.Background()
+	}
+	return opt.Context
+}
@@ -1,11 +1,48 @@
 package node
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ImageCompressionNone = ""
+	ImageCompressionGzip = "gzip"
+	ImageCompressionZstd = "zstd"
 )
 
+type ImageTransferOptions struct {
+	// Compression applied to the archive sent to the destination node,
+	// docker load detects it by itself.
+	Compression string
+	// BandwidthLimit limits the bytes per second sent to the destination node, 0 means unlimited.
+	BandwidthLimit int64
+	// Report receives human readable transfer information.
+	Report func(msg string)
+}
+
+func (o *ImageTransferOptions) report(format string, args ...interface{}) {
+	if o != nil && o.Report != nil {
+		o.Report(fmt.Sprintf(format, args...))
+	}
+}
+
 func CopyImageBetweenNodes(
 	ctx context.Context,
 	sourceState *NodeState,
@@ -13,6 +50,23 @@ func CopyImageBetweenNodes(
 	image string,
 	cb func(ctx context.Context, reader io.ReadCloser) error,
 ) error {
+	return CopyImageBetweenNodesWithOptions(ctx, sourceState, destState, image, nil, cb)
+}
+
+// CopyImageBetweenNodesWithOptions copies image from source to destination node,
+// only shipping the layers the destination node does not have yet.
+func CopyImageBetweenNodesWithOptions(
+	ctx context.Context,
+	sourceState *NodeState,
+	destState *NodeState,
+	image string,
+	opt *ImageTransferOptions,
+	cb func(ctx context.Context, reader io.ReadCloser) error,
+) error {
+	if opt == nil {
+		opt = &ImageTransferOptions{}
+	}
+
 	sdc, err := sourceState.GetDockerClient()
 	if err != nil {
 		return fmt.Errorf("failed to get source docker client: %w", err)
@@ -22,23 +76,337 @@ func CopyImageBetweenNodes(
 		return fmt.Errorf("failed to get destination docker client: %w", err)
 	}
 
+	skip, err := existingLayers(ctx, sdc, ddc, image)
+	if err != nil {
+		opt.report("failed to compare layers, sending the full image: %v", err)
+		skip = nil
+	}
+
+	err = transferImage(ctx, sdc, ddc, image, skip, opt, cb)
+	if err != nil && len(skip) > 0 {
+		// the destination may not be able to reuse its layers (e.g. containerd image store)
+		opt.report("reduced image load failed (%v), retrying with the full image", err)
+		err = transferImage(ctx, sdc, ddc, image, nil, opt, cb)
+	}
+	return err
+}
+
+func transferImage(
+	ctx context.Context,
+	sdc *dockerClient.Client,
+	ddc *dockerClient.Client,
+	image string,
+	skip map[string]bool,
+	opt *ImageTransferOptions,
+	cb func(ctx context.Context, reader io.ReadCloser) error,
+) error {
 	reader, err := sdc.ImageSave(ctx, []string{image})
 	if err != nil {
 		return fmt.Errorf("failed to export image from source node: %w", err)
 	}
 	defer reader.Close()
 
-	loadResp, err := ddc.ImageLoad(ctx, reader)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeImageArchive(ctx, reader, pw, skip, opt))
+	}()
+	defer pr.Close()
+
+	loadResp, err := ddc.ImageLoad(ctx, pr)
 	if err != nil {
 		return fmt.Errorf("failed to import image to destination node: %w", err)
 	}
+	defer loadResp.Body.Close()
 
+	body := newLoadErrorReader(loadResp.Body)
 	if cb != nil {
-		if err := cb(ctx, loadResp.Body); err != nil {
+		if err := cb(ctx, body); err != nil {
+			body.Err()
 			return fmt.Errorf("failed to copy image: %w", err)
 		}
 	}
-	defer loadResp.Body.Close()
+	// every message has to be decoded to find the errors
+	_, _ = io.Copy(io.Discard, body)
 
+	if err := body.Err(); err != nil {
+		return fmt.Errorf("destination node failed to load the image: %w", err)
+	}
 	return nil
 }
+
+// loadErrorReader passes the docker load output through and decodes its
+// JSON messages on the side, docker reports load failures in the stream
+// rather than the status code.
+type loadErrorReader struct {
+	io.ReadCloser
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func newLoadErrorReader(body io.ReadCloser) *loadErrorReader {
+	pr, pw := io.Pipe()
+	r := &loadErrorReader{ReadCloser: body, pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		r.err = DecodeImageProgress(pr, nil)
+		// keep reading so the writes of Read never block
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return r
+}
+
+func (r *loadErrorReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = r.pw.Write(p[:n])
+	}
+	if err != nil {
+		r.pw.Close()
+	}
+	return n, err
+}
+
+// Err waits for the decoder and returns the error reported in the output,
+// the output has to be read to its end before.
+func (r *loadErrorReader) Err() error {
+	r.pw.Close()
+	<-r.done
+	return r.err
+}
+
+// DecodeImageProgress decodes the docker json message stream of a pull,
+// push or load, reporting each status change of a layer and returning the
+// first error reported by the daemon.
+func DecodeImageProgress(reader io.Reader, report func(msg string)) error {
+	decoder := json.NewDecoder(reader)
+	lastStatus := map[string]string{}
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if report == nil || msg.Status == "" || lastStatus[msg.ID] == msg.Status {
+			continue
+		}
+		lastStatus[msg.ID] = msg.Status
+
+		line := msg.Status
+		if msg.ID != "" {
+			line = msg.ID + ": " + line
+		}
+		report(line)
+	}
+}
+
+// writeImageArchive writes the filtered, compressed and rate limited archive to w.
+func writeImageArchive(ctx context.Context, reader io.Reader, w io.Writer, skip map[string]bool, opt *ImageTransferOptions) error {
+	if opt.BandwidthLimit > 0 {
+		w = newRateLimitedWriter(ctx, w, opt.BandwidthLimit)
+	}
+
+	var compressor io.WriteCloser
+	switch opt.Compression {
+	case ImageCompressionNone:
+	case ImageCompressionGzip:
+		compressor = gzip.NewWriter(w)
+	case ImageCompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		compressor = zw
+	default:
+		return fmt.Errorf("unsupported compression: %s", opt.Compression)
+	}
+	if compressor != nil {
+		w = compressor
+	}
+
+	skipped, err := filterImageArchive(reader, w, skip)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		opt.report("skipped %d layers already present on the destination node", skipped)
+	}
+
+	if compressor != nil {
+		return compressor.Close()
+	}
+	return nil
+}
+
+// existingLayers returns the diff ids of image which are already available
+// on the destination node as the same layer chain.
+func existingLayers(ctx context.Context, sdc *dockerClient.Client, ddc *dockerClient.Client, ref string) (map[string]bool, error) {
+	src, err := sdc.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(src.RootFS.Layers) == 0 {
+		return nil, nil
+	}
+
+	images, err := ddc.ImageList(ctx, image.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	shared := 0
+	for _, summary := range images {
+		dst, err := ddc.ImageInspect(ctx, summary.ID)
+		if err != nil {
+			continue
+		}
+		if n := sharedLayerChain(src.RootFS.Layers, dst.RootFS.Layers); n > shared {
+			shared = n
+		}
+		if shared == len(src.RootFS.Layers) {
+			break
+		}
+	}
+
+	skip := make(map[string]bool, shared)
+	for _, layer := range src.RootFS.Layers[:shared] {
+		skip[layer] = true
+	}
+	return skip, nil
+}
+
+// sharedLayerChain returns the length of the common layer prefix of two images,
+// docker only reuses a layer when its whole parent chain matches.
+func sharedLayerChain(src []string, dst []string) int {
+	n := 0
+	for n < len(src) && n < len(dst) && src[n] == dst[n] {
+		n++
+	}
+	return n
+}
+
+// filterImageArchive copies a `docker save` archive from r to w, dropping the
+// layer files whose diff id is in skip. It returns the number of dropped layers.
+func filterImageArchive(r io.Reader, w io.Writer, skip map[string]bool) (int, error) {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	skipped := 0
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return skipped, err
+		}
+
+		if len(skip) > 0 && header.Typeflag == tar.TypeReg {
+			// oci layout, layer blobs are named by their diff id
+			if digest, ok := blobDigest(header.Name); ok {
+				if skip[digest] {
+					skipped++
+					continue
+				}
+			} else if path.Base(header.Name) == "layer.tar" {
+				// legacy layout, the diff id is only known after hashing the layer
+				drop, err := copyLegacyLayer(tr, tw, header, skip)
+				if err != nil {
+					return skipped, err
+				}
+				if drop {
+					skipped++
+				}
+				continue
+			}
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return skipped, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return skipped, err
+		}
+	}
+
+	return skipped, tw.Close()
+}
+
+func blobDigest(name string) (string, bool) {
+	dir, file := path.Split(name)
+	if strings.TrimSuffix(dir, "/") != "blobs/sha256" || len(file) != sha256.Size*2 {
+		return "", false
+	}
+	return "sha256:" + file, true
+}
+
+func copyLegacyLayer(tr *tar.Reader, tw *tar.Writer, header *tar.Header, skip map[string]bool) (bool, error) {
+	tmp, err := os.CreateTemp("", "lai-layer-*.tar")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), tr); err != nil {
+		return false, err
+	}
+	if skip["sha256:"+hex.EncodeToString(hash.Sum(nil))] {
+		return true, nil
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return false, err
+	}
+	_, err = io.Copy(tw, tmp)
+	return false, err
+}
+
+type rateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func newRateLimitedWriter(ctx context.Context, w io.Writer, bytesPerSecond int64) *rateLimitedWriter {
+	burst := int(bytesPerSecond)
+	if burst > 1024*1024 {
+		burst = 1024 * 1024
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedWriter{
+		ctx:     ctx,
+		w:       w,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+}
+
+func (r *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n := len(p) - written
+		if n > r.limiter.Burst() {
+			n = r.limiter.Burst()
+		}
+		if err := r.limiter.WaitN(r.ctx, n); err != nil {
+			return written, err
+		}
+		m, err := r.w.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
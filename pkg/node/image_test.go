@@ -0,0 +1,134 @@
+package node
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type archiveEntry struct {
+	name string
+	body string
+}
+
+func buildArchive(t *testing.T, entries []archiveEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Mode:     0o644,
+			Size:     int64(len(e.body)),
+			Typeflag: tar.TypeReg,
+		})
+		assert.NoError(t, err)
+		_, err = tw.Write([]byte(e.body))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func archiveNames(t *testing.T, data []byte) []string {
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	return names
+}
+
+func digestOf(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSharedLayerChain(t *testing.T) {
+	assert.Equal(t, 2, sharedLayerChain([]string{"a", "b", "c"}, []string{"a", "b", "d"}))
+	assert.Equal(t, 0, sharedLayerChain([]string{"a", "b"}, []string{"b", "a"}))
+	assert.Equal(t, 2, sharedLayerChain([]string{"a", "b"}, []string{"a", "b", "c"}))
+	assert.Equal(t, 0, sharedLayerChain(nil, []string{"a"}))
+}
+
+func TestFilterImageArchive_OCILayout(t *testing.T) {
+	base := digestOf("base layer")
+	app := digestOf("app layer")
+	data := buildArchive(t, []archiveEntry{
+		{name: "blobs/sha256/" + base, body: "base layer"},
+		{name: "blobs/sha256/" + app, body: "app layer"},
+		{name: "manifest.json", body: "[]"},
+	})
+
+	var out bytes.Buffer
+	skipped, err := filterImageArchive(bytes.NewReader(data), &out, map[string]bool{
+		"sha256:" + base: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, []string{"blobs/sha256/" + app, "manifest.json"}, archiveNames(t, out.Bytes()))
+}
+
+func TestFilterImageArchive_LegacyLayout(t *testing.T) {
+	data := buildArchive(t, []archiveEntry{
+		{name: "111/layer.tar", body: "base layer"},
+		{name: "222/layer.tar", body: "app layer"},
+		{name: "manifest.json", body: "[]"},
+	})
+
+	var out bytes.Buffer
+	skipped, err := filterImageArchive(bytes.NewReader(data), &out, map[string]bool{
+		"sha256:" + digestOf("base layer"): true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, []string{"222/layer.tar", "manifest.json"}, archiveNames(t, out.Bytes()))
+}
+
+func TestFilterImageArchive_NoSkipKeepsEverything(t *testing.T) {
+	data := buildArchive(t, []archiveEntry{
+		{name: "blobs/sha256/" + digestOf("layer"), body: "layer"},
+		{name: "index.json", body: "{}"},
+	})
+
+	var out bytes.Buffer
+	skipped, err := filterImageArchive(bytes.NewReader(data), &out, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, skipped)
+	assert.Len(t, archiveNames(t, out.Bytes()), 2)
+}
+
+func TestLoadErrorReader(t *testing.T) {
+	output := `{"stream":"Loaded image: nginx:latest\n"}` + "\n"
+	failure := `{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}` + "\n"
+
+	// the output is passed through
+	r := newLoadErrorReader(io.NopCloser(iotest.OneByteReader(strings.NewReader(output))))
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, output, string(b))
+	assert.NoError(t, r.Err())
+
+	// an error split across reads is found
+	r = newLoadErrorReader(io.NopCloser(iotest.OneByteReader(strings.NewReader(output + failure))))
+	_, err = io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.EqualError(t, r.Err(), "unexpected EOF")
+
+	// the text of a message does not count as an error
+	r = newLoadErrorReader(io.NopCloser(strings.NewReader(`{"stream":"\"errorDetail\""}` + "\n")))
+	_, err = io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Err())
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// listenCommand lists the listening tcp and udp sockets, netstat is used
+// where ss is missing.
+const listenCommand = "ss -ltunH 2>/dev/null || netstat -ltun 2>/dev/null"
+
+// Listener is a socket listening on a node.
+type Listener struct {
+	Protocol string
+	HostIP   string
+	Port     int
+}
+
+// ListListeners returns the sockets listening on the node of exec.
+func ListListeners(exec NodeExec) ([]Listener, error) {
+	stdout, stderr, err := exec.ExecuteOutput(listenCommand, NewNodeExecuteCommandOptions())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+	}
+	return ParseListeners(stdout), nil
+}
+
+// ParseListeners parses the output of `ss -ltunH` or `netstat -ltun`:
+//
+//	tcp LISTEN 0 4096 0.0.0.0:80 0.0.0.0:*
+//	udp UNCONN 0 0 [::]:53 [::]:*
+//	tcp6 0 0 :::8080 :::* LISTEN
+func ParseListeners(output string) []Listener {
+	listeners := []Listener{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		protocol := strings.TrimSuffix(fields[0], "6")
+		if protocol != "tcp" && protocol != "udp" {
+			continue
+		}
+		// ss has a state column before the queues, netstat has it last
+		local := fields[4]
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			local = fields[3]
+		}
+
+		i := strings.LastIndex(local, ":")
+		if i < 0 {
+			continue
+		}
+		// headers and unresolved addresses don't parse
+		port, err := strconv.Atoi(local[i+1:])
+		if err != nil {
+			continue
+		}
+		host := strings.Trim(local[:i], "[]")
+		// 127.0.0.53%lo
+		host, _, _ = strings.Cut(host, "%")
+
+		listeners = append(listeners, Listener{
+			Protocol: protocol,
+			HostIP:   host,
+			Port:     port,
+		})
+	}
+	return listeners
+}
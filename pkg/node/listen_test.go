@@ -0,0 +1,34 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListeners(t *testing.T) {
+	// ss
+	listeners := ParseListeners(`tcp   LISTEN 0      4096         0.0.0.0:80        0.0.0.0:*
+tcp   LISTEN 0      4096            [::]:443          [::]:*
+udp   UNCONN 0      0      127.0.0.53%lo:53        0.0.0.0:*
+garbage
+`)
+	assert.Equal(t, []Listener{
+		{Protocol: "tcp", HostIP: "0.0.0.0", Port: 80},
+		{Protocol: "tcp", HostIP: "::", Port: 443},
+		{Protocol: "udp", HostIP: "127.0.0.53", Port: 53},
+	}, listeners)
+
+	// netstat
+	listeners = ParseListeners(`Active Internet connections (only servers)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State
+tcp        0      0 127.0.0.1:5432          0.0.0.0:*               LISTEN
+tcp6       0      0 :::8080                 :::*                    LISTEN
+udp        0      0 0.0.0.0:68              0.0.0.0:*
+`)
+	assert.Equal(t, []Listener{
+		{Protocol: "tcp", HostIP: "127.0.0.1", Port: 5432},
+		{Protocol: "tcp", HostIP: "::", Port: 8080},
+		{Protocol: "udp", HostIP: "0.0.0.0", Port: 68},
+	}, listeners)
+}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"time"
 )
 
 type LocalNodeExec struct {
@@ -34,6 +35,28 @@ func (l *LocalNodeExec) WriteFile(path string, data []byte) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
+func (l *LocalNodeExec) WriteFileWithMode(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// an existing file keeps its permissions on open
+	if err := file.Chmod(mode); err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
+
 func (l *LocalNodeExec) WriteFileStream(path string, reader io.Reader) error {
 	dir := filepath.Dir(path)
 	if dir != "" && dir != "." {
@@ -98,7 +121,9 @@ func (l *LocalNodeExec) ExecuteCommand(
 		workingDir = opt.WorkingDir
 	}
 
-	cmd := exec.Command("bash", "-c", command)
+	cmd := exec.CommandContext(commandContext(opt), "bash", "-c", command)
+	// children of a killed command may hold the output open
+	cmd.WaitDelay = time.Second
 
 	// Set working directory if provided
 	if workingDir != "" {
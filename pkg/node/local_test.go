@@ -0,0 +1,29 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalExecuteCommandCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opt := NewNodeExecuteCommandOptions()
+	opt.SetContext(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewLocalNodeExec().ExecuteCommand("echo ready; sleep 60", opt, func(line string) {
+			cancel()
+		}, func(string) {})
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("command was not stopped")
+	}
+}
@@ -1,24 +1,51 @@
 package node
 
 import (
+	"crypto/subtle"
+	"fmt"
 	"sync"
 
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/benlocal/lai-panel/pkg/tunnel"
 )
 
 type NodeManager struct {
 	nodes          map[int64]*NodeState
 	nodeRepository *repository.NodeRepository
+	tunnels        *tunnel.Registry
 
 	mu sync.RWMutex
 }
 
 func NewNodeManager(nodeRepository *repository.NodeRepository) *NodeManager {
-	return &NodeManager{
+	m := &NodeManager{
 		nodeRepository: nodeRepository,
 		nodes:          make(map[int64]*NodeState),
 	}
+	m.tunnels = tunnel.NewRegistry(m.authenticateTunnel)
+	return m
+}
+
+// authenticateTunnel lets an agent open a tunnel when its node is reached
+// through the tunnel and the token is the one issued to the agent.
+func (m *NodeManager) authenticateTunnel(name string, token string) error {
+	node, err := m.nodeRepository.GetByNodeName(name)
+	if err != nil {
+		return err
+	}
+	if node == nil || node.IsLocal || !node.AgentTunnel {
+		return fmt.Errorf("node %s is not registered for a tunnel", name)
+	}
+	if node.AgentToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(node.AgentToken)) != 1 {
+		return fmt.Errorf("agent token of node %s does not match", name)
+	}
+	return nil
+}
+
+// Tunnels returns the tunnels of the agents connected to the master.
+func (m *NodeManager) Tunnels() *tunnel.Registry {
+	return m.tunnels
 }
 
 func (m *NodeManager) GetNodeState(nodeID int64) (*NodeState, error) {
@@ -56,7 +83,8 @@ func (m *NodeManager) addNode(node *model.Node) (*NodeState, error) {
 	}
 
 	state := NodeState{
-		info: *node,
+		info:    *node,
+		tunnels: m.tunnels,
 	}
 	m.nodes[node.ID] = &state
 	return &state, nil
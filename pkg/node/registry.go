@@ -0,0 +1,60 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// PushImageToRegistry pushes image of the node as mirrorRef, the temporary
+// tag is removed afterwards. Layers already in the registry are not sent again.
+// auth is the encoded credentials of the registry.
+func PushImageToRegistry(ctx context.Context, state *NodeState, imageRef string, mirrorRef string, auth string, report func(msg string)) error {
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return err
+	}
+
+	if err := dc.ImageTag(ctx, imageRef, mirrorRef); err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+	defer func() {
+		_, _ = dc.ImageRemove(context.Background(), mirrorRef, image.RemoveOptions{})
+	}()
+
+	reader, err := dc.ImagePush(ctx, mirrorRef, image.PushOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push image: %w", err)
+	}
+	defer reader.Close()
+
+	return DecodeImageProgress(reader, report)
+}
+
+// PullImageFromRegistry pulls mirrorRef on the node and tags it back as imageRef.
+// auth is the encoded credentials of the registry.
+func PullImageFromRegistry(ctx context.Context, state *NodeState, mirrorRef string, imageRef string, auth string, report func(msg string)) error {
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return err
+	}
+
+	reader, err := dc.ImagePull(ctx, mirrorRef, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	err = DecodeImageProgress(reader, report)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := dc.ImageTag(ctx, mirrorRef, imageRef); err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+	_, _ = dc.ImageRemove(ctx, mirrorRef, image.RemoveOptions{})
+	return nil
+}
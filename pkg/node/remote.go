@@ -2,8 +2,10 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -94,6 +96,36 @@ func (r *RemoteNodeExec) WriteFile(path string, data []byte) error {
 	return nil
 }
 
+func (r *RemoteNodeExec) WriteFileWithMode(path string, data []byte, mode os.FileMode) error {
+	if r.sftpClient == nil {
+		return fmt.Errorf("SFTP client not initialized")
+	}
+
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := r.sftpClient.MkdirAll(dir); err != nil {
+			return fmt.Errorf("failed to create remote directory: %w", err)
+		}
+	}
+
+	file, err := r.sftpClient.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	// restrict the permissions before the content is written
+	if err := file.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to change file mode: %w", err)
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
 func (r *RemoteNodeExec) WriteFileStream(path string, reader io.Reader) error {
 	if r.sftpClient == nil {
 		return fmt.Errorf("SFTP client not initialized")
@@ -205,6 +237,14 @@ func (r *RemoteNodeExec) ExecuteCommand(
 	if err := session.Start(fullCommand); err != nil {
 		return fmt.Errorf("failed to start remote command: %w", err)
 	}
+	// a cancelled command closes its session, the remote process is
+	// signalled and loses its output
+	ctx := commandContext(opt)
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+	})
+	defer stop()
 
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -226,6 +266,9 @@ func (r *RemoteNodeExec) ExecuteCommand(
 	wg.Wait()
 
 	if err := session.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return err
 	}
 
@@ -1,11 +1,14 @@
 package node
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"sync"
 
 	"github.com/benlocal/lai-panel/pkg/docker"
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/tunnel"
 	dockerClient "github.com/docker/docker/client"
 )
 
@@ -13,6 +16,7 @@ type NodeState struct {
 	info         model.Node
 	exec         NodeExec
 	dockerClient *dockerClient.Client
+	tunnels      *tunnel.Registry
 
 	execMu         sync.RWMutex
 	dockerClientMu sync.RWMutex
@@ -34,6 +38,14 @@ func (n *NodeState) GetDataPath() *string {
 	return n.info.DataPath
 }
 
+// GetComposeBackend returns how compose projects are run on the node.
+func (n *NodeState) GetComposeBackend() string {
+	if n.info.ComposeBackend == "" {
+		return model.ComposeBackendCLI
+	}
+	return n.info.ComposeBackend
+}
+
 func (n *NodeState) GetDockerClient() (*dockerClient.Client, error) {
 	n.dockerClientMu.RLock()
 	if n.dockerClient != nil {
@@ -46,8 +58,10 @@ func (n *NodeState) GetDockerClient() (*dockerClient.Client, error) {
 	var err error
 	if n.info.IsLocal {
 		dockerClient, err = docker.LocalDockerClient()
+	} else if n.info.AgentTunnel {
+		dockerClient, err = docker.TunnelDockerClient(n.info.Name, n.dialTunnel, n.info.AgentToken)
 	} else {
-		dockerClient, err = docker.AgentDockerClient(n.info.Address, n.info.AgentPort)
+		dockerClient, err = docker.AgentDockerClient(n.info.Address, n.info.AgentPort, n.info.AgentToken)
 	}
 	if err != nil {
 		return nil, err
@@ -59,6 +73,10 @@ func (n *NodeState) GetDockerClient() (*dockerClient.Client, error) {
 	return dockerClient, nil
 }
 
+func (n *NodeState) dialTunnel(ctx context.Context) (net.Conn, error) {
+	return n.tunnels.Dial(ctx, n.info.Name)
+}
+
 func (n *NodeState) GetExec() (NodeExec, error) {
 	n.execMu.RLock()
 	if n.exec != nil {
@@ -70,6 +88,12 @@ func (n *NodeState) GetExec() (NodeExec, error) {
 	var exec NodeExec
 	if n.info.IsLocal {
 		exec = NewLocalNodeExec()
+	} else if n.info.AgentTunnel {
+		// the master can't dial a node behind a nat, ssh included
+		exec = NewAgentNodeExec(&n.info, n.tunnels.Transport(n.info.Name))
+	} else if n.info.SSHUser == "" {
+		// agent only node
+		exec = NewAgentNodeExec(&n.info, nil)
 	} else {
 		exec = NewRemoteNodeExec(&n.info)
 	}
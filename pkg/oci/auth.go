@@ -0,0 +1,42 @@
+package oci
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// Realm is the basic auth realm of the embedded registry.
+const Realm = "lai-panel"
+
+// CheckBasicAuth tells whether the Authorization header carries the
+// credentials of the registry.
+func CheckBasicAuth(header string, username string, password string) bool {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+	return userOK && passOK
+}
+
+// EncodeAuth encodes the credentials of the registry at address for the
+// RegistryAuth of docker pushes and pulls.
+func EncodeAuth(address string, username string, password string) (string, error) {
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      username,
+		Password:      password,
+		ServerAddress: address,
+	})
+}
@@ -0,0 +1,20 @@
+package oci
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBasicAuth(t *testing.T) {
+	basic := func(s string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	assert.True(t, CheckBasicAuth(basic("panel:secret"), "panel", "secret"))
+	assert.False(t, CheckBasicAuth(basic("panel:guess"), "panel", "secret"))
+	assert.False(t, CheckBasicAuth(basic("other:secret"), "panel", "secret"))
+	assert.False(t, CheckBasicAuth(basic("panel"), "panel", "secret"))
+	assert.False(t, CheckBasicAuth("Bearer secret", "panel", "secret"))
+	assert.False(t, CheckBasicAuth("", "panel", ""))
+}
@@ -0,0 +1,137 @@
+package oci
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+type RouteKind int
+
+const (
+	RouteUnknown RouteKind = iota
+	// /v2/
+	RouteBase
+	// /v2/_catalog
+	RouteCatalog
+	// /v2/<name>/tags/list
+	RouteTags
+	// /v2/<name>/manifests/<reference>
+	RouteManifest
+	// /v2/<name>/blobs/<digest>
+	RouteBlob
+	// /v2/<name>/blobs/uploads/
+	RouteUploadStart
+	// /v2/<name>/blobs/uploads/<id>
+	RouteUpload
+)
+
+type Route struct {
+	Kind RouteKind
+	Name string
+	// tag or digest of a manifest, digest of a blob or id of an upload
+	Reference string
+}
+
+var (
+	nameComponentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagRegexp           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+func ValidName(name string) bool {
+	if name == "" || len(name) > 255 {
+		return false
+	}
+	for _, component := range strings.Split(name, "/") {
+		if !nameComponentRegexp.MatchString(component) {
+			return false
+		}
+	}
+	return true
+}
+
+func ValidTag(tag string) bool {
+	return tagRegexp.MatchString(tag)
+}
+
+func IsDigest(ref string) bool {
+	_, err := digest.Parse(ref)
+	return err == nil
+}
+
+// ParseRoute parses the path below /v2 of a distribution api request.
+func ParseRoute(p string) *Route {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return &Route{Kind: RouteBase}
+	}
+	if p == "_catalog" {
+		return &Route{Kind: RouteCatalog}
+	}
+
+	if name, ok := strings.CutSuffix(p, "/tags/list"); ok {
+		return validRoute(&Route{Kind: RouteTags, Name: name})
+	}
+	if name, ok := strings.CutSuffix(p, "/blobs/uploads/"); ok {
+		return validRoute(&Route{Kind: RouteUploadStart, Name: name})
+	}
+	if name, ok := strings.CutSuffix(p, "/blobs/uploads"); ok {
+		return validRoute(&Route{Kind: RouteUploadStart, Name: name})
+	}
+
+	for _, r := range []struct {
+		sep  string
+		kind RouteKind
+	}{
+		{"/blobs/uploads/", RouteUpload},
+		{"/manifests/", RouteManifest},
+		{"/blobs/", RouteBlob},
+	} {
+		i := strings.LastIndex(p, r.sep)
+		if i <= 0 {
+			continue
+		}
+		ref := p[i+len(r.sep):]
+		if ref == "" || strings.Contains(ref, "/") {
+			continue
+		}
+		return validRoute(&Route{Kind: r.kind, Name: p[:i], Reference: ref})
+	}
+
+	return &Route{Kind: RouteUnknown}
+}
+
+func validRoute(r *Route) *Route {
+	if !ValidName(r.Name) {
+		return &Route{Kind: RouteUnknown}
+	}
+	return r
+}
+
+// MirrorReference returns the reference of image inside the registry at
+// address, the source domain is kept in the repository path so images of
+// different registries do not collide:
+//
+//	nginx:1.25 -> 10.0.0.1:8080/docker.io/library/nginx:1.25
+//	ghcr.io:443/a/b:v1 -> 10.0.0.1:8080/ghcr.io_443/a/b:v1
+func MirrorReference(address string, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := named.(reference.Digested); ok {
+		// docker can not tag an image by digest, it must be loaded instead
+		return "", errors.New("digest references can not be mirrored")
+	}
+	named = reference.TagNameOnly(named)
+	tagged, ok := named.(reference.Tagged)
+	if !ok {
+		return "", errors.New("image has no tag")
+	}
+
+	domain := strings.ReplaceAll(strings.ToLower(reference.Domain(named)), ":", "_")
+	return address + "/" + domain + "/" + reference.Path(named) + ":" + tagged.Tag(), nil
+}
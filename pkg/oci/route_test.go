@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoute(t *testing.T) {
+	d := "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	cases := []struct {
+		path string
+		want Route
+	}{
+		{"/", Route{Kind: RouteBase}},
+		{"/_catalog", Route{Kind: RouteCatalog}},
+		{"/docker.io/library/nginx/tags/list", Route{Kind: RouteTags, Name: "docker.io/library/nginx"}},
+		{"/app/manifests/v1", Route{Kind: RouteManifest, Name: "app", Reference: "v1"}},
+		{"/a/b/manifests/" + d, Route{Kind: RouteManifest, Name: "a/b", Reference: d}},
+		{"/app/blobs/" + d, Route{Kind: RouteBlob, Name: "app", Reference: d}},
+		{"/app/blobs/uploads/", Route{Kind: RouteUploadStart, Name: "app"}},
+		{"/app/blobs/uploads/abc", Route{Kind: RouteUpload, Name: "app", Reference: "abc"}},
+		// a repository may be named like a route segment
+		{"/blobs/manifests/v1", Route{Kind: RouteManifest, Name: "blobs", Reference: "v1"}},
+		{"/../app/manifests/v1", Route{Kind: RouteUnknown}},
+		{"/App/manifests/v1", Route{Kind: RouteUnknown}},
+		{"/app/unknown", Route{Kind: RouteUnknown}},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, *ParseRoute(tc.path), tc.path)
+	}
+}
+
+func TestMirrorReference(t *testing.T) {
+	ref, err := MirrorReference("10.0.0.1:8080", "nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8080/docker.io/library/nginx:latest", ref)
+
+	ref, err = MirrorReference("10.0.0.1:8080", "ghcr.io:443/org/app:v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8080/ghcr.io_443/org/app:v1", ref)
+
+	_, err = MirrorReference("10.0.0.1:8080", "nginx@sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	assert.Error(t, err)
+}
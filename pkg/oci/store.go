@@ -0,0 +1,642 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+const staleUploadAge = time.Hour
+
+var (
+	ErrBlobUnknown     = errors.New("blob unknown")
+	ErrManifestUnknown = errors.New("manifest unknown")
+	ErrNameUnknown     = errors.New("repository name unknown")
+	ErrUploadUnknown   = errors.New("upload unknown")
+	ErrDigestInvalid   = errors.New("digest invalid")
+	ErrNameInvalid     = errors.New("invalid repository name")
+	ErrTagInvalid      = errors.New("manifest tag invalid")
+)
+
+// Store keeps the registry content on disk, blobs are shared by all
+// repositories so a layer is only stored once.
+//
+//	blobs/sha256/<hex>
+//	uploads/<id>
+//	repositories/<name>/_tags/<tag>             content is the manifest digest
+//	repositories/<name>/_revisions/sha256/<hex> manifests pushed to the repository
+type Store struct {
+	root string
+	// gc must not run while content is pushed
+	mu sync.RWMutex
+}
+
+func NewStore(root string) (*Store, error) {
+	for _, dir := range []string{"blobs", "uploads", "repositories"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{root: root}, nil
+}
+
+type Manifest struct {
+	Digest    digest.Digest
+	MediaType string
+	Content   []byte
+}
+
+type TagInfo struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+	// size of the manifest, config and layers
+	Size int64 `json:"size"`
+}
+
+type RepositoryInfo struct {
+	Name string     `json:"name"`
+	Tags []*TagInfo `json:"tags"`
+}
+
+type GCResult struct {
+	DeletedManifests int   `json:"deleted_manifests"`
+	DeletedBlobs     int   `json:"deleted_blobs"`
+	FreedBytes       int64 `json:"freed_bytes"`
+}
+
+func (s *Store) blobPath(d digest.Digest) string {
+	return filepath.Join(s.root, "blobs", d.Algorithm().String(), d.Encoded())
+}
+
+func (s *Store) uploadPath(id string) string {
+	return filepath.Join(s.root, "uploads", id)
+}
+
+func (s *Store) repositoryPath(name string) string {
+	return filepath.Join(s.root, "repositories", filepath.FromSlash(name))
+}
+
+func (s *Store) tagPath(name string, tag string) string {
+	return filepath.Join(s.repositoryPath(name), "_tags", tag)
+}
+
+func (s *Store) revisionPath(name string, d digest.Digest) string {
+	return filepath.Join(s.repositoryPath(name), "_revisions", d.Algorithm().String(), d.Encoded())
+}
+
+// StatBlob returns the size of the blob.
+func (s *Store) StatBlob(d digest.Digest) (int64, error) {
+	if err := d.Validate(); err != nil {
+		return 0, ErrDigestInvalid
+	}
+	info, err := os.Stat(s.blobPath(d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrBlobUnknown
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *Store) OpenBlob(d digest.Digest) (*os.File, int64, error) {
+	size, err := s.StatBlob(d)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(s.blobPath(d))
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, size, nil
+}
+
+// StartUpload creates an empty upload session and returns its id.
+func (s *Store) StartUpload() (string, error) {
+	id := uuid.NewString()
+	f, err := os.Create(s.uploadPath(id))
+	if err != nil {
+		return "", err
+	}
+	return id, f.Close()
+}
+
+// UploadSize returns the number of bytes received by the upload session.
+func (s *Store) UploadSize(id string) (int64, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return 0, ErrUploadUnknown
+	}
+	info, err := os.Stat(s.uploadPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrUploadUnknown
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// AppendUpload appends r to the upload session and returns the new size.
+func (s *Store) AppendUpload(id string, r io.Reader) (int64, error) {
+	if _, err := s.UploadSize(id); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.uploadPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// FinishUpload verifies the uploaded content against d and moves it to the blob store.
+func (s *Store) FinishUpload(id string, d digest.Digest) error {
+	if err := d.Validate(); err != nil {
+		return ErrDigestInvalid
+	}
+	if _, err := s.UploadSize(id); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, err := os.Open(s.uploadPath(id))
+	if err != nil {
+		return err
+	}
+	verifier := d.Verifier()
+	_, err = io.Copy(verifier, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		_ = os.Remove(s.uploadPath(id))
+		return ErrDigestInvalid
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.blobPath(d)), 0755); err != nil {
+		return err
+	}
+	return os.Rename(s.uploadPath(id), s.blobPath(d))
+}
+
+func (s *Store) CancelUpload(id string) error {
+	if _, err := s.UploadSize(id); err != nil {
+		return err
+	}
+	return os.Remove(s.uploadPath(id))
+}
+
+// PutManifest stores the manifest in the repository and tags it when
+// reference is a tag. It returns the digest of the manifest.
+func (s *Store) PutManifest(name string, reference string, mediaType string, content []byte) (digest.Digest, error) {
+	if err := validateReference(name, reference); err != nil {
+		return "", err
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "" {
+		var probe struct {
+			MediaType string `json:"mediaType"`
+		}
+		_ = json.Unmarshal(content, &probe)
+		mediaType = probe.MediaType
+	}
+
+	d := digest.FromBytes(content)
+	if IsDigest(reference) && digest.Digest(reference) != d {
+		return "", ErrDigestInvalid
+	}
+
+	refs, err := manifestReferences(mediaType, content)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ref := range refs {
+		if _, err := s.StatBlob(ref); err != nil {
+			// a child manifest of an index is stored as a blob as well
+			return "", fmt.Errorf("%w: %s", ErrBlobUnknown, ref)
+		}
+	}
+
+	if err := writeFile(s.blobPath(d), content); err != nil {
+		return "", err
+	}
+	if err := writeFile(s.revisionPath(name, d), []byte(mediaType)); err != nil {
+		return "", err
+	}
+	if !IsDigest(reference) {
+		if err := writeFile(s.tagPath(name, reference), []byte(d.String())); err != nil {
+			return "", err
+		}
+	}
+	return d, nil
+}
+
+// GetManifest resolves reference (a tag or a digest) in the repository.
+func (s *Store) GetManifest(name string, reference string) (*Manifest, error) {
+	if err := validateReference(name, reference); err != nil {
+		return nil, err
+	}
+	d, err := s.resolve(name, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, err := os.ReadFile(s.revisionPath(name, d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrManifestUnknown
+		}
+		return nil, err
+	}
+	content, err := os.ReadFile(s.blobPath(d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrManifestUnknown
+		}
+		return nil, err
+	}
+
+	return &Manifest{
+		Digest:    d,
+		MediaType: string(mediaType),
+		Content:   content,
+	}, nil
+}
+
+// DeleteManifest removes a tag, or a manifest with all tags pointing to it
+// when reference is a digest. Blobs are released by GC.
+func (s *Store) DeleteManifest(name string, reference string) error {
+	if err := validateReference(name, reference); err != nil {
+		return err
+	}
+	if !IsDigest(reference) {
+		err := os.Remove(s.tagPath(name, reference))
+		if os.IsNotExist(err) {
+			return ErrManifestUnknown
+		}
+		return err
+	}
+
+	d := digest.Digest(reference)
+	if err := os.Remove(s.revisionPath(name, d)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrManifestUnknown
+		}
+		return err
+	}
+
+	tags, err := s.Tags(name)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if current, err := s.resolve(name, tag); err == nil && current == d {
+			_ = os.Remove(s.tagPath(name, tag))
+		}
+	}
+	return nil
+}
+
+// Tags returns the sorted tags of the repository.
+func (s *Store) Tags(name string) ([]string, error) {
+	if !ValidName(name) {
+		return nil, ErrNameInvalid
+	}
+	entries, err := os.ReadDir(filepath.Join(s.repositoryPath(name), "_tags"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			if _, err := os.Stat(s.repositoryPath(name)); err == nil {
+				return []string{}, nil
+			}
+			return nil, ErrNameUnknown
+		}
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			tags = append(tags, entry.Name())
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// Repositories returns the sorted names of all repositories.
+func (s *Store) Repositories() ([]string, error) {
+	base := filepath.Join(s.root, "repositories")
+	names := []string{}
+	err := filepath.WalkDir(base, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || entry.Name() != "_revisions" {
+			return nil
+		}
+		rel, err := filepath.Rel(base, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RepositoryInfo lists the tags of a repository with the size of their content.
+func (s *Store) RepositoryInfo(name string) (*RepositoryInfo, error) {
+	tags, err := s.Tags(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &RepositoryInfo{Name: name, Tags: make([]*TagInfo, 0, len(tags))}
+	for _, tag := range tags {
+		d, err := s.resolve(name, tag)
+		if err != nil {
+			continue
+		}
+		size := int64(0)
+		_ = s.walkManifest(d, map[digest.Digest]bool{}, func(blob digest.Digest) {
+			if n, err := s.StatBlob(blob); err == nil {
+				size += n
+			}
+		})
+		info.Tags = append(info.Tags, &TagInfo{
+			Tag:    tag,
+			Digest: d.String(),
+			Size:   size,
+		})
+	}
+	return info, nil
+}
+
+// GC removes the blobs which are not referenced by any manifest of any
+// repository. Untagged manifests are deleted first when deleteUntagged is set.
+func (s *Store) GC(deleteUntagged bool) (*GCResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &GCResult{}
+	names, err := s.Repositories()
+	if err != nil {
+		return nil, err
+	}
+
+	marked := map[digest.Digest]bool{}
+	for _, name := range names {
+		revisions, err := s.revisions(name)
+		if err != nil {
+			return nil, err
+		}
+
+		roots := revisions
+		if deleteUntagged {
+			tagged := map[digest.Digest]bool{}
+			tags, err := s.Tags(name)
+			if err != nil {
+				return nil, err
+			}
+			for _, tag := range tags {
+				if d, err := s.resolve(name, tag); err == nil {
+					tagged[d] = true
+				}
+			}
+
+			// children of a tagged index are kept with it
+			reachable := map[digest.Digest]bool{}
+			for d := range tagged {
+				_ = s.walkManifest(d, reachable, func(digest.Digest) {})
+			}
+
+			roots = nil
+			for _, d := range revisions {
+				if tagged[d] || reachable[d] {
+					roots = append(roots, d)
+					continue
+				}
+				if err := os.Remove(s.revisionPath(name, d)); err != nil {
+					return nil, err
+				}
+				result.DeletedManifests++
+			}
+		}
+
+		for _, d := range roots {
+			if err := s.walkManifest(d, marked, func(digest.Digest) {}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(s.root, "blobs"), func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		d := digest.NewDigestFromEncoded(digest.Algorithm(filepath.Base(filepath.Dir(p))), entry.Name())
+		if marked[d] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		result.DeletedBlobs++
+		result.FreedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// interrupted uploads, recent ones may still be in progress
+	entries, err := os.ReadDir(filepath.Join(s.root, "uploads"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleUploadAge {
+			continue
+		}
+		if err := os.Remove(s.uploadPath(entry.Name())); err == nil {
+			result.FreedBytes += info.Size()
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Store) revisions(name string) ([]digest.Digest, error) {
+	base := filepath.Join(s.repositoryPath(name), "_revisions")
+	algorithms, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	revisions := []digest.Digest{}
+	for _, algorithm := range algorithms {
+		entries, err := os.ReadDir(filepath.Join(base, algorithm.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			revisions = append(revisions, digest.NewDigestFromEncoded(digest.Algorithm(algorithm.Name()), entry.Name()))
+		}
+	}
+	return revisions, nil
+}
+
+func (s *Store) resolve(name string, reference string) (digest.Digest, error) {
+	if IsDigest(reference) {
+		return digest.Digest(reference), nil
+	}
+	content, err := os.ReadFile(s.tagPath(name, reference))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrManifestUnknown
+		}
+		return "", err
+	}
+	return digest.Parse(strings.TrimSpace(string(content)))
+}
+
+// walkManifest calls fn for the manifest d and every blob it references.
+func (s *Store) walkManifest(d digest.Digest, seen map[digest.Digest]bool, fn func(digest.Digest)) error {
+	if seen[d] {
+		return nil
+	}
+	seen[d] = true
+	fn(d)
+
+	content, err := os.ReadFile(s.blobPath(d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var probe struct {
+		Config    *ocispec.Descriptor  `json:"config"`
+		Layers    []ocispec.Descriptor `json:"layers"`
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return nil
+	}
+
+	if probe.Config != nil {
+		seen[probe.Config.Digest] = true
+		fn(probe.Config.Digest)
+	}
+	for _, layer := range probe.Layers {
+		if !seen[layer.Digest] {
+			seen[layer.Digest] = true
+			fn(layer.Digest)
+		}
+	}
+	for _, m := range probe.Manifests {
+		if err := s.walkManifest(m.Digest, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestReferences returns the blobs a manifest depends on, foreign
+// layers are not stored by the registry.
+func manifestReferences(mediaType string, content []byte) ([]digest.Digest, error) {
+	switch mediaType {
+	case ocispec.MediaTypeImageIndex, MediaTypeDockerManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(content, &index); err != nil {
+			return nil, err
+		}
+		refs := make([]digest.Digest, 0, len(index.Manifests))
+		for _, m := range index.Manifests {
+			refs = append(refs, m.Digest)
+		}
+		return refs, nil
+	case ocispec.MediaTypeImageManifest, MediaTypeDockerManifest:
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, err
+		}
+		refs := []digest.Digest{manifest.Config.Digest}
+		for _, layer := range manifest.Layers {
+			if len(layer.URLs) > 0 {
+				continue
+			}
+			refs = append(refs, layer.Digest)
+		}
+		return refs, nil
+	default:
+		return nil, fmt.Errorf("unsupported manifest media type: %s", mediaType)
+	}
+}
+
+func validateReference(name string, reference string) error {
+	if !ValidName(name) {
+		return ErrNameInvalid
+	}
+	if !IsDigest(reference) && !ValidTag(reference) {
+		return ErrTagInvalid
+	}
+	return nil
+}
+
+func writeFile(p string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
@@ -0,0 +1,127 @@
+package oci
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func putBlob(t *testing.T, s *Store, content string) digest.Digest {
+	id, err := s.StartUpload()
+	assert.NoError(t, err)
+	_, err = s.AppendUpload(id, strings.NewReader(content))
+	assert.NoError(t, err)
+	d := digest.FromString(content)
+	assert.NoError(t, s.FinishUpload(id, d))
+	return d
+}
+
+func putImage(t *testing.T, s *Store, name string, tag string, layers ...string) digest.Digest {
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageConfig,
+			Digest:    putBlob(t, s, "config "+name+":"+tag),
+		},
+	}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayer,
+			Digest:    putBlob(t, s, layer),
+		})
+	}
+	content, err := json.Marshal(manifest)
+	assert.NoError(t, err)
+
+	d, err := s.PutManifest(name, tag, ocispec.MediaTypeImageManifest, content)
+	assert.NoError(t, err)
+	return d
+}
+
+func TestStore_UploadVerifiesDigest(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	id, err := s.StartUpload()
+	assert.NoError(t, err)
+	_, err = s.AppendUpload(id, strings.NewReader("layer"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, s.FinishUpload(id, digest.FromString("other")), ErrDigestInvalid)
+
+	_, err = s.StatBlob(digest.FromString("layer"))
+	assert.ErrorIs(t, err, ErrBlobUnknown)
+}
+
+func TestStore_ManifestRequiresBlobs(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	content, _ := json.Marshal(ocispec.Manifest{
+		Config: ocispec.Descriptor{Digest: digest.FromString("missing")},
+	})
+	_, err = s.PutManifest("library/app", "v1", ocispec.MediaTypeImageManifest, content)
+	assert.ErrorIs(t, err, ErrBlobUnknown)
+}
+
+func TestStore_TagsAndRepositories(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	d := putImage(t, s, "docker.io/library/app", "v1", "base", "app")
+	putImage(t, s, "docker.io/library/app", "v2", "base", "app v2")
+	putImage(t, s, "docker.io/library/app/sub", "latest", "base")
+
+	repositories, err := s.Repositories()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docker.io/library/app", "docker.io/library/app/sub"}, repositories)
+
+	tags, err := s.Tags("docker.io/library/app")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2"}, tags)
+
+	manifest, err := s.GetManifest("docker.io/library/app", "v1")
+	assert.NoError(t, err)
+	assert.Equal(t, d, manifest.Digest)
+	assert.Equal(t, ocispec.MediaTypeImageManifest, manifest.MediaType)
+
+	_, err = s.GetManifest("docker.io/library/app", "../../v1")
+	assert.ErrorIs(t, err, ErrTagInvalid)
+	_, err = s.Tags("../app")
+	assert.ErrorIs(t, err, ErrNameInvalid)
+}
+
+func TestStore_GCKeepsSharedLayers(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	putImage(t, s, "app", "v1", "base", "app v1")
+	putImage(t, s, "app", "v2", "base", "app v2")
+	orphan := putBlob(t, s, "orphan")
+
+	result, err := s.GC(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.DeletedBlobs)
+	_, err = s.StatBlob(orphan)
+	assert.ErrorIs(t, err, ErrBlobUnknown)
+
+	// untag v1, its manifest stays until untagged manifests are collected
+	assert.NoError(t, s.DeleteManifest("app", "v1"))
+	result, err = s.GC(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.DeletedBlobs)
+
+	result, err = s.GC(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.DeletedManifests)
+	// manifest, config and the app v1 layer, base is still used by v2
+	assert.Equal(t, 3, result.DeletedBlobs)
+
+	_, err = s.StatBlob(digest.FromString("base"))
+	assert.NoError(t, err)
+	_, err = s.GetManifest("app", "v2")
+	assert.NoError(t, err)
+}
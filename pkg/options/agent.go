@@ -4,6 +4,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -15,6 +16,14 @@ type AgentOptions struct {
 	Port       int
 	Address    string
 	dataPath   string
+	// interval of the resource samples pushed to the master, 0 disables them
+	MetricsInterval time.Duration
+	// Tunnel makes the agent dial the master and serve its API through that
+	// connection, for nodes the master can't reach
+	Tunnel bool
+	// masterTLS makes the agent reach the master over https and wss, for a
+	// master behind a TLS terminating proxy
+	masterTLS bool
 }
 
 func NewAgentOptions(opts ...func(o *AgentOptions)) *AgentOptions {
@@ -27,6 +36,8 @@ func NewAgentOptions(opts ...func(o *AgentOptions)) *AgentOptions {
 		masterPort: 8080,
 		Name:       uuid,
 		dataPath:   dataPath,
+
+		MetricsInterval: DefaultMetricsInterval,
 	}
 
 	for _, f := range opts {
@@ -54,12 +65,30 @@ func WithMasterPort(masterPort int) func(o *AgentOptions) {
 	}
 }
 
+func WithMasterTLS(masterTLS bool) func(o *AgentOptions) {
+	return func(o *AgentOptions) {
+		o.masterTLS = masterTLS
+	}
+}
+
 func WithAddress(address string) func(o *AgentOptions) {
 	return func(o *AgentOptions) {
 		o.Address = address
 	}
 }
 
+func WithMetricsInterval(interval time.Duration) func(o *AgentOptions) {
+	return func(o *AgentOptions) {
+		o.MetricsInterval = interval
+	}
+}
+
+func WithTunnel(tunnel bool) func(o *AgentOptions) {
+	return func(o *AgentOptions) {
+		o.Tunnel = tunnel
+	}
+}
+
 func WithName(name string) func(o *AgentOptions) {
 	return func(o *AgentOptions) {
 		if name == "" {
@@ -109,3 +138,7 @@ func (o *AgentOptions) MasterHost() string {
 func (o *AgentOptions) MasterPort() int {
 	return o.masterPort
 }
+
+func (o *AgentOptions) MasterTLS() bool {
+	return o.masterTLS
+}
@@ -3,14 +3,23 @@ package options
 import (
 	"os"
 	"path"
+	"time"
 )
 
+// DefaultMetricsInterval is the period of the node resource samples.
+const DefaultMetricsInterval = 15 * time.Second
+
 const (
 	LOG_BASE_PATH        = "log"
 	WORK_SPACE_BASE_PATH = "workspace"
 	SERVICE_BASE_PATH    = "service"
 	STATIC_BASE_PATH     = "static"
 	INSTALL_BASE_PATH    = "install"
+	REGISTRY_BASE_PATH   = "registry"
+	// clones of git catalog sources
+	CATALOG_BASE_PATH = "catalog"
+	// workspace copies of app versions
+	APP_VERSION_BASE_PATH = "versions"
 )
 
 func InitOptions(options IOptions) error {
@@ -38,6 +47,8 @@ type IOptions interface {
 
 	MasterPort() int
 
+	MasterTLS() bool
+
 	Agent() bool
 }
 
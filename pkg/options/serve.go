@@ -1,8 +1,14 @@
 package options
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
 	"os"
+	"path"
 	"strconv"
+	"time"
 )
 
 type ServeOptions struct {
@@ -16,6 +22,32 @@ type ServeOptions struct {
 	Port       int
 	DBPath     string
 	dataPath   string
+	// compression used when copying images between nodes: "", "gzip" or "zstd"
+	ImageCompression string
+	// bytes per second limit when copying images between nodes, 0 means unlimited
+	ImageBandwidthLimit int64
+	// number of images prepared at the same time when deploying, 0 means the default
+	ImageConcurrency int
+	// address of the embedded registry as seen by the nodes, defaults to master host and port
+	registryAddress string
+	// serve the embedded registry and pull images through it when deploying,
+	// off by default
+	RegistryEnabled bool
+	// credentials of the embedded registry, the password is generated when
+	// it is not set
+	RegistryUsername string
+	RegistryPassword string
+	// name of the environment, e.g. staging or prod, selects env:<name> scoped values
+	Environment string
+	// interval of the catalog sync job, 0 disables it
+	CatalogSyncInterval time.Duration
+	// interval of the resource samples of the local node, 0 disables them
+	MetricsInterval time.Duration
+	// how long hourly node metrics are kept
+	MetricsRetention time.Duration
+	// the master is served over TLS, e.g. by the reverse proxy, agents
+	// reach it over https and wss
+	masterTLS bool
 }
 
 func NewServeOptions() *ServeOptions {
@@ -43,12 +75,94 @@ func NewServeOptions() *ServeOptions {
 		masterPortInt = port
 	}
 
+	masterTLS := false
+	if v, ok := os.LookupEnv("PANEL_MASTER_TLS"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err == nil {
+			masterTLS = enabled
+		}
+	}
+
+	var imageBandwidthLimit int64
+	if v, ok := os.LookupEnv("PANEL_IMAGE_BANDWIDTH_LIMIT"); ok {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			imageBandwidthLimit = limit
+		}
+	}
+
+	imageConcurrency := 0
+	if v, ok := os.LookupEnv("PANEL_IMAGE_CONCURRENCY"); ok {
+		n, err := strconv.Atoi(v)
+		if err == nil {
+			imageConcurrency = n
+		}
+	}
+
+	registryAddress := os.Getenv("PANEL_REGISTRY_ADDRESS")
+	if registryAddress == "" {
+		registryAddress = fmt.Sprintf("%s:%d", masterHost, masterPortInt)
+	}
+
+	registryEnabled := false
+	if v, ok := os.LookupEnv("PANEL_REGISTRY_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err == nil {
+			registryEnabled = enabled
+		}
+	}
+	registryUsername := os.Getenv("PANEL_REGISTRY_USERNAME")
+	if registryUsername == "" {
+		registryUsername = "panel"
+	}
+	registryPassword := os.Getenv("PANEL_REGISTRY_PASSWORD")
+	if registryEnabled && registryPassword == "" {
+		registryPassword = randomPassword()
+		log.Println("embedded registry password generated, set PANEL_REGISTRY_PASSWORD to log in to the registry")
+	}
+
+	catalogSyncInterval := 10 * time.Minute
+	if v, ok := os.LookupEnv("PANEL_CATALOG_SYNC_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err == nil {
+			catalogSyncInterval = interval
+		}
+	}
+
+	metricsInterval := DefaultMetricsInterval
+	if v, ok := os.LookupEnv("PANEL_METRICS_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err == nil {
+			metricsInterval = interval
+		}
+	}
+
+	metricsRetention := 30 * 24 * time.Hour
+	if v, ok := os.LookupEnv("PANEL_METRICS_RETENTION"); ok {
+		retention, err := time.ParseDuration(v)
+		if err == nil && retention > 0 {
+			metricsRetention = retention
+		}
+	}
+
 	return &ServeOptions{
-		DBPath:     "lai-panel.db",
-		Port:       port,
-		dataPath:   dataPath,
-		masterHost: masterHost,
-		masterPort: masterPortInt,
+		DBPath:              "lai-panel.db",
+		Port:                port,
+		dataPath:            dataPath,
+		masterHost:          masterHost,
+		masterPort:          masterPortInt,
+		masterTLS:           masterTLS,
+		ImageCompression:    os.Getenv("PANEL_IMAGE_COMPRESSION"),
+		ImageBandwidthLimit: imageBandwidthLimit,
+		ImageConcurrency:    imageConcurrency,
+		registryAddress:     registryAddress,
+		RegistryEnabled:     registryEnabled,
+		RegistryUsername:    registryUsername,
+		RegistryPassword:    registryPassword,
+		Environment:         os.Getenv("PANEL_ENVIRONMENT"),
+		CatalogSyncInterval: catalogSyncInterval,
+		MetricsInterval:     metricsInterval,
+		MetricsRetention:    metricsRetention,
 	}
 }
 
@@ -64,6 +178,12 @@ func WithServePort(port int) func(o *ServeOptions) {
 	}
 }
 
+func WithDataPath(dataPath string) func(o *ServeOptions) {
+	return func(o *ServeOptions) {
+		o.dataPath = dataPath
+	}
+}
+
 func (o *ServeOptions) DataPath() string {
 	return o.dataPath
 }
@@ -79,3 +199,21 @@ func (o *ServeOptions) MasterHost() string {
 func (o *ServeOptions) MasterPort() int {
 	return o.masterPort
 }
+
+func (o *ServeOptions) MasterTLS() bool {
+	return o.masterTLS
+}
+
+func (o *ServeOptions) RegistryAddress() string {
+	return o.registryAddress
+}
+
+func (o *ServeOptions) RegistryDataPath() string {
+	return path.Join(o.dataPath, REGISTRY_BASE_PATH)
+}
+
+func randomPassword() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,71 @@
+package agentpipe
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderUnit(t *testing.T) {
+	unit, err := RenderUnit(UnitConfig{
+		MasterHost: "10.0.0.1",
+		MasterPort: 8080,
+		Name:       "edge 1",
+		Address:    "10.0.0.2",
+		Port:       9091,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, unit, `ExecStart=/opt/lai-panel/bin/agent run --master-host=10.0.0.1 --master-port=8080 "--name=edge 1" --address=10.0.0.2 --port=9091`+"\n")
+	assert.Contains(t, unit, "SyslogIdentifier=lai-agent\n")
+
+	unit, err = RenderUnit(UnitConfig{MasterHost: "panel", MasterPort: 8080, Name: "100%"})
+	assert.NoError(t, err)
+	assert.Contains(t, unit, "--name=100%%")
+	assert.NotContains(t, unit, "--port")
+	assert.NotContains(t, unit, "--tunnel")
+
+	unit, err = RenderUnit(UnitConfig{MasterHost: "panel", MasterPort: 8080, Name: "nat", Tunnel: true})
+	assert.NoError(t, err)
+	assert.Contains(t, unit, "--name=nat --tunnel\n")
+
+	unit, err = RenderUnit(UnitConfig{MasterHost: "panel", MasterPort: 443, Name: "nat", Tunnel: true, MasterTLS: true})
+	assert.NoError(t, err)
+	assert.Contains(t, unit, "--name=nat --tunnel --master-tls\n")
+
+	_, err = RenderUnit(UnitConfig{MasterPort: 8080, Name: "n"})
+	assert.Error(t, err)
+}
+
+func TestGoArch(t *testing.T) {
+	arch, err := goArch("x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, "amd64", arch)
+	arch, err = goArch("aarch64")
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", arch)
+	_, err = goArch("sparc64")
+	assert.Error(t, err)
+}
+
+func TestAgentBinary(t *testing.T) {
+	dir := t.TempDir()
+	_, err := agentBinary(dir, "riscv64")
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(path.Join(dir, "agent-linux-riscv64"), []byte("bin"), 0755))
+	binary, err := agentBinary(dir, "riscv64")
+	assert.NoError(t, err)
+	assert.Equal(t, path.Join(dir, "agent-linux-riscv64"), binary)
+}
+
+func TestParseVerify(t *testing.T) {
+	checksum, version, err := parseVerify("9f86d081884c7d65  /tmp/lai-agent-2\nv1.4.0\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "9f86d081884c7d65", checksum)
+	assert.Equal(t, "v1.4.0", version)
+
+	_, _, err = parseVerify("9f86d081884c7d65  /tmp/lai-agent-2\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package agentpipe
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
+)
+
+const (
+	ServiceName = "lai-agent"
+	BinaryPath  = "/opt/lai-panel/bin/agent"
+	UnitPath    = "/etc/systemd/system/" + ServiceName + ".service"
+
+	// DefaultRegisterTimeout bounds the wait for a started agent to register
+	DefaultRegisterTimeout = 2 * time.Minute
+)
+
+type AgentCtx struct {
+	options        options.IOptions
+	nodeRepository *repository.NodeRepository
+	Node           *model.Node
+	NodeState      *node.NodeState
+	// Action is one of the node job actions
+	Action string
+	writer *sse.Writer
+	sendMu sync.Mutex
+	output strings.Builder
+	// RegisterTimeout bounds the wait for the agent to register
+	RegisterTimeout time.Duration
+
+	// out
+	arch   string
+	root   bool
+	active bool
+	// files uploaded to the node before they are installed
+	binaryUpload string
+	unitUpload   string
+	tokenUpload  string
+	// sha256 of the uploaded binary
+	checksum string
+	// version of the uploaded binary, the agent registers with it
+	version string
+	// set once the installed binary is being replaced
+	swapped bool
+}
+
+// NewAgentCtx creates the context of an agent operation on a node, writer may
+// be nil when the output is not streamed.
+func NewAgentCtx(
+	options options.IOptions,
+	nodeRepository *repository.NodeRepository,
+	node *model.Node,
+	nodeState *node.NodeState,
+	action string,
+	writer *sse.Writer,
+) *AgentCtx {
+	return &AgentCtx{
+		options:         options,
+		nodeRepository:  nodeRepository,
+		Node:            node,
+		NodeState:       nodeState,
+		Action:          action,
+		writer:          writer,
+		RegisterTimeout: DefaultRegisterTimeout,
+	}
+}
+
+// Send streams an event and records it in the output of the operation.
+func (a *AgentCtx) Send(event string, data string) error {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	if event == "info" {
+		a.output.WriteString(data + "\n")
+	} else {
+		fmt.Fprintf(&a.output, "[%s] %s\n", event, data)
+	}
+	if a.writer == nil {
+		return nil
+	}
+	return a.writer.WriteEvent("", event, []byte(data))
+}
+
+// Output returns what the operation sent so far.
+func (a *AgentCtx) Output() string {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	return a.output.String()
+}
+
+// binarySource is the directory holding the agent binaries of the server.
+func (a *AgentCtx) binarySource() string {
+	return path.Join(a.options.DataPath(), options.STATIC_BASE_PATH, options.INSTALL_BASE_PATH)
+}
+
+// run runs a script as root on the node and streams its output.
+func (a *AgentCtx) run(script string) error {
+	exec, err := a.NodeState.GetExec()
+	if err != nil {
+		return err
+	}
+	command := "sh -c " + shellQuote(script)
+	if !a.root {
+		command = "sudo -n " + command
+	}
+	var lastErr string
+	err = exec.ExecuteCommand(command, nil, func(line string) {
+		a.Send("info", line)
+	}, func(line string) {
+		lastErr = line
+		a.Send("info", line)
+	})
+	if err != nil && lastErr != "" {
+		return fmt.Errorf("%w: %s", err, lastErr)
+	}
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
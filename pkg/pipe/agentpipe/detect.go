@@ -0,0 +1,87 @@
+package agentpipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+const detectCommand = `uname -m; id -u
+if command -v systemctl >/dev/null 2>&1; then echo systemd; else echo nosystemd; fi
+if sudo -n true >/dev/null 2>&1; then echo sudo; else echo nosudo; fi
+if systemctl is-active --quiet ` + ServiceName + ` 2>/dev/null; then echo active; else echo inactive; fi
+if [ -x ` + BinaryPath + ` ]; then echo installed; else echo missing; fi`
+
+// AgentDetectPipeline checks the agent can be managed over ssh on the node
+// and finds out its architecture.
+type AgentDetectPipeline struct {
+}
+
+func (p *AgentDetectPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	if a.Node.IsLocal {
+		return nil, errors.New("the local node runs without an agent")
+	}
+	if a.Node.SSHUser == "" {
+		return nil, errors.New("managing the agent needs ssh access to the node")
+	}
+	exec, err := a.NodeState.GetExec()
+	if err != nil {
+		return nil, err
+	}
+
+	a.Send("info", "checking node "+a.Node.Name)
+	out, errout, err := exec.ExecuteOutput(detectCommand, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(errout))
+	}
+	lines := strings.Fields(out)
+	if len(lines) != 6 {
+		return nil, errors.New("unexpected output of the node check")
+	}
+
+	a.arch, err = goArch(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	a.root = lines[1] == "0"
+	if lines[2] != "systemd" {
+		return nil, errors.New("the agent runs as a systemd service, systemd is not found on the node")
+	}
+	if !a.root && lines[3] != "sudo" {
+		return nil, fmt.Errorf("%s is neither root nor allowed to sudo without a password", a.Node.SSHUser)
+	}
+	a.active = lines[4] == "active"
+	a.Send("info", fmt.Sprintf("node architecture is %s, agent is %s", a.arch, lines[4]))
+	if a.active && a.Action == model.NodeJobInstall {
+		return nil, errors.New("the agent is already running, reinstall it instead")
+	}
+	if lines[5] != "installed" && a.Action == model.NodeJobUpgrade {
+		return nil, errors.New("the agent is not installed, install it instead")
+	}
+	return a, nil
+}
+
+func (p *AgentDetectPipeline) Cancel(a *AgentCtx, err error) {
+	// do nothing
+}
+
+// goArch maps the machine of uname -m to the go architecture of the agent
+// binary.
+func goArch(machine string) (string, error) {
+	switch machine {
+	case "x86_64", "amd64":
+		return "amd64", nil
+	case "aarch64", "arm64", "armv8l":
+		return "arm64", nil
+	case "armv7l", "armv6l":
+		return "arm", nil
+	case "i386", "i686":
+		return "386", nil
+	case "riscv64", "ppc64le", "s390x":
+		return machine, nil
+	}
+	return "", fmt.Errorf("architecture %s is not supported", machine)
+}
@@ -0,0 +1,196 @@
+package agentpipe
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	appCtx "github.com/benlocal/lai-panel/pkg/ctx"
+)
+
+// AgentInstallPipeline installs the uploaded agent binary and unit, then
+// (re)starts the agent.
+type AgentInstallPipeline struct {
+}
+
+func (p *AgentInstallPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	script := fmt.Sprintf(`set -e
+install -D -m 0755 %s %s
+install -D -m 0644 %s %s
+rm -f %s %s
+systemctl daemon-reload
+systemctl enable %s
+systemctl restart %s
+`,
+		shellQuote(a.binaryUpload), BinaryPath,
+		shellQuote(a.unitUpload), UnitPath,
+		shellQuote(a.binaryUpload), shellQuote(a.unitUpload),
+		ServiceName, ServiceName,
+	)
+
+	if a.tokenUpload != "" {
+		token := path.Join(*a.Node.DataPath, appCtx.AgentTokenFile)
+		script = fmt.Sprintf(`set -e
+install -D -m 0600 %s %s
+rm -f %s
+`, shellQuote(a.tokenUpload), shellQuote(token), shellQuote(a.tokenUpload)) + script
+	}
+
+	// the restarted agent flips the node back online when it registers
+	if err := a.nodeRepository.UpdateNodeStatus(a.Node.ID, "offline"); err != nil {
+		return nil, err
+	}
+	a.Send("info", "installing the agent service")
+	if err := a.run(script); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (p *AgentInstallPipeline) Cancel(a *AgentCtx, err error) {
+	// do nothing
+}
+
+// AgentWaitPipeline waits for the started agent to register with the
+// server.
+type AgentWaitPipeline struct {
+}
+
+func (p *AgentWaitPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	a.Send("info", "waiting for the agent to register")
+	ctx, cancel := context.WithTimeout(ctx, a.RegisterTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.journal()
+			return nil, fmt.Errorf("the agent did not register within %s", a.RegisterTimeout)
+		case <-ticker.C:
+			node, err := a.nodeRepository.GetByID(a.Node.ID)
+			if err != nil {
+				return nil, err
+			}
+			// an agent registering with the previous version is still
+			// starting, or was not replaced
+			if node.Status == "online" && (a.version == "" || node.AgentVersion == a.version) {
+				a.Send("info", "agent registered")
+				return a, nil
+			}
+		}
+	}
+}
+
+// Cancel rolls an upgrade back to the previous binary.
+func (p *AgentWaitPipeline) Cancel(a *AgentCtx, err error) {
+	if a.swapped {
+		a.rollback()
+	}
+}
+
+// journal sends the last log lines of the agent service.
+func (a *AgentCtx) journal() {
+	exec, err := a.NodeState.GetExec()
+	if err != nil {
+		return
+	}
+	command := "journalctl -u " + ServiceName + " -n 20 --no-pager"
+	if !a.root {
+		command = "sudo -n " + command
+	}
+	out, _, _ := exec.ExecuteOutput(command, nil)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			a.Send("info", line)
+		}
+	}
+}
+
+// AgentSwapPipeline replaces the installed agent binary with the uploaded
+// one and restarts the agent. The previous binary is kept next to it, the
+// rename makes the swap atomic.
+type AgentSwapPipeline struct {
+}
+
+func (p *AgentSwapPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	// a backup left by an earlier upgrade must not be restored
+	script := fmt.Sprintf(`set -e
+rm -f %s.bak
+install -m 0755 %s %s.new
+rm -f %s
+cp -p %s %s.bak
+mv -f %s.new %s
+systemctl restart %s
+`,
+		BinaryPath,
+		shellQuote(a.binaryUpload), BinaryPath,
+		shellQuote(a.binaryUpload),
+		BinaryPath, BinaryPath,
+		BinaryPath, BinaryPath,
+		ServiceName,
+	)
+
+	if err := a.nodeRepository.UpdateNodeStatus(a.Node.ID, "offline"); err != nil {
+		return nil, err
+	}
+	a.Send("info", "swapping the agent binary")
+	a.swapped = true
+	if err := a.run(script); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Cancel rolls back to the previous binary when the swap got halfway.
+func (p *AgentSwapPipeline) Cancel(a *AgentCtx, err error) {
+	if a.swapped {
+		a.rollback()
+	}
+}
+
+// rollback restores the binary saved by the swap and restarts the agent.
+func (a *AgentCtx) rollback() {
+	a.Send("warning", "rolling back to the previous agent")
+	script := fmt.Sprintf(`set -e
+if [ -f %s.bak ]; then mv -f %s.bak %s; fi
+rm -f %s.new
+systemctl restart %s
+`, BinaryPath, BinaryPath, BinaryPath, BinaryPath, ServiceName)
+	if err := a.run(script); err != nil {
+		a.Send("warning", "rollback failed: "+err.Error())
+		return
+	}
+	a.swapped = false
+}
+
+// AgentUninstallPipeline stops the agent and removes its binary and unit.
+type AgentUninstallPipeline struct {
+}
+
+func (p *AgentUninstallPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	script := fmt.Sprintf(`systemctl disable --now %s 2>/dev/null || true
+rm -f %s %s
+systemctl daemon-reload
+`, ServiceName, UnitPath, BinaryPath)
+
+	a.Send("info", "removing the agent service")
+	if err := a.run(script); err != nil {
+		return nil, err
+	}
+	if err := a.nodeRepository.UpdateNodeStatus(a.Node.ID, "offline"); err != nil {
+		return nil, err
+	}
+	// the health check would install it again
+	if err := a.nodeRepository.UpdateAgentAutoReinstall(a.Node.ID, false); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (p *AgentUninstallPipeline) Cancel(a *AgentCtx, err error) {
+	// do nothing
+}
@@ -0,0 +1,93 @@
+package agentpipe
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// UnitConfig is what the agent is started with.
+type UnitConfig struct {
+	MasterHost string
+	MasterPort int
+	Name       string
+	Address    string
+	// Port is the agent port, the default of the agent when 0
+	Port int
+	// Tunnel makes the agent dial the master
+	Tunnel bool
+	// MasterTLS makes the agent reach the master over https and wss
+	MasterTLS bool
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=LAI Panel Agent Service
+After=network-online.target docker.service
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=root
+WorkingDirectory=/opt/lai-panel
+ExecStart={{ .ExecStart }}
+Restart=always
+RestartSec=5
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=` + ServiceName + `
+Environment="PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// RenderUnit renders the systemd unit of the agent.
+func RenderUnit(c UnitConfig) (string, error) {
+	if c.MasterHost == "" || c.MasterPort <= 0 {
+		return "", errors.New("the master address of the agent is not configured")
+	}
+	if c.Name == "" {
+		return "", errors.New("node name is required")
+	}
+
+	args := []string{
+		BinaryPath, "run",
+		"--master-host=" + c.MasterHost,
+		"--master-port=" + strconv.Itoa(c.MasterPort),
+		"--name=" + c.Name,
+	}
+	if c.Address != "" {
+		args = append(args, "--address="+c.Address)
+	}
+	if c.Port > 0 {
+		args = append(args, "--port="+strconv.Itoa(c.Port))
+	}
+	if c.Tunnel {
+		args = append(args, "--tunnel")
+	}
+	if c.MasterTLS {
+		args = append(args, "--master-tls")
+	}
+	for i, arg := range args {
+		args[i] = systemdQuote(arg)
+	}
+
+	var buf bytes.Buffer
+	err := unitTemplate.Execute(&buf, map[string]string{
+		"ExecStart": strings.Join(args, " "),
+	})
+	return buf.String(), err
+}
+
+// systemdQuote quotes an argument of a systemd command line when needed,
+// % starts a specifier and is always escaped.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,101 @@
+package agentpipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// AgentUploadPipeline uploads the agent binary matching the architecture of
+// the node and its systemd unit to the node.
+type AgentUploadPipeline struct {
+}
+
+func (p *AgentUploadPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	source, err := agentBinary(a.binarySource(), a.arch)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := RenderUnit(UnitConfig{
+		MasterHost: a.options.MasterHost(),
+		MasterPort: a.options.MasterPort(),
+		Name:       a.Node.Name,
+		Address:    a.Node.Address,
+		Port:       a.Node.AgentPort,
+		Tunnel:     a.Node.AgentTunnel,
+		MasterTLS:  a.options.MasterTLS(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exec, err := a.NodeState.GetExec()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a.binaryUpload = fmt.Sprintf("/tmp/%s-%d", ServiceName, a.Node.ID)
+	a.Send("info", "uploading "+path.Base(source))
+	hash := sha256.New()
+	if err := exec.WriteFileStream(a.binaryUpload, io.TeeReader(f, hash)); err != nil {
+		return nil, err
+	}
+	a.checksum = hex.EncodeToString(hash.Sum(nil))
+
+	// an upgrade keeps the unit the agent was installed with
+	if a.Action == model.NodeJobUpgrade {
+		return a, nil
+	}
+	a.unitUpload = a.binaryUpload + ".service"
+	if err := exec.WriteFile(a.unitUpload, []byte(unit)); err != nil {
+		return nil, err
+	}
+	// a reinstalled agent which lost its data registers with the token of
+	// the node
+	if a.Node.AgentToken != "" && a.Node.DataPath != nil {
+		a.tokenUpload = a.binaryUpload + ".token"
+		if err := exec.WriteFileWithMode(a.tokenUpload, []byte(a.Node.AgentToken), 0600); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (p *AgentUploadPipeline) Cancel(a *AgentCtx, err error) {
+	if a.binaryUpload == "" {
+		return
+	}
+	exec, execErr := a.NodeState.GetExec()
+	if execErr != nil {
+		return
+	}
+	exec.ExecuteOutput("rm -f "+shellQuote(a.binaryUpload)+" "+shellQuote(a.binaryUpload+".service")+" "+shellQuote(a.binaryUpload+".token"), nil)
+}
+
+// agentBinary finds the agent binary for an architecture in dir, agent-linux-
+// <arch>, or the agent binary built for the server when it runs on linux with
+// the same architecture.
+func agentBinary(dir string, arch string) (string, error) {
+	candidates := []string{path.Join(dir, "agent-linux-"+arch)}
+	if runtime.GOOS == "linux" && runtime.GOARCH == arch {
+		candidates = append(candidates, path.Join(dir, "agent"))
+	}
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no agent binary for linux/%s, put it at %s", arch, candidates[0])
+}
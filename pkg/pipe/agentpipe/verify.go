@@ -0,0 +1,58 @@
+package agentpipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AgentVerifyPipeline compares the checksum of the uploaded binary with the
+// one sent and makes sure it runs on the node.
+type AgentVerifyPipeline struct {
+}
+
+func (p *AgentVerifyPipeline) Process(ctx context.Context, a *AgentCtx) (*AgentCtx, error) {
+	exec, err := a.NodeState.GetExec()
+	if err != nil {
+		return nil, err
+	}
+
+	binary := shellQuote(a.binaryUpload)
+	out, errout, err := exec.ExecuteOutput(fmt.Sprintf(`set -e
+sha256sum %s
+chmod 0755 %s
+%s version`, binary, binary, binary), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(errout))
+	}
+	checksum, version, err := parseVerify(out)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != a.checksum {
+		return nil, fmt.Errorf("checksum mismatch of the uploaded agent: %s, expected %s", checksum, a.checksum)
+	}
+	a.version = version
+	a.Send("info", fmt.Sprintf("uploaded agent %s, sha256 %s", version, checksum))
+	return a, nil
+}
+
+// Cancel removes the uploaded files.
+func (p *AgentVerifyPipeline) Cancel(a *AgentCtx, err error) {
+	(&AgentUploadPipeline{}).Cancel(a, err)
+}
+
+// parseVerify parses the sha256sum line and the version printed by the
+// uploaded binary.
+func parseVerify(out string) (checksum string, version string, err error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		return "", "", errors.New("unexpected output of the agent verification")
+	}
+	fields := strings.Fields(lines[0])
+	if len(fields) == 0 {
+		return "", "", errors.New("unexpected sha256sum output")
+	}
+	return fields[0], strings.TrimSpace(lines[1]), nil
+}
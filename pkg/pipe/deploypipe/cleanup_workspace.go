@@ -44,7 +44,6 @@ func (p *CleanupWorkspacePipeline) Cancel(c *DeployCtx, err error) {
 func (p *CleanupWorkspacePipeline) mkdir(exec node.NodeExec, path string, c *DeployCtx) error {
 	cmd := fmt.Sprintf("mkdir -p %s", path)
 	opt := node.NewNodeExecuteCommandOptions()
-	opt.SetEnv(c.env)
 	return exec.ExecuteCommand(cmd, opt, func(s string) {
 		c.Send("info", s)
 	}, func(s string) {
@@ -63,7 +62,6 @@ func (p *CleanupWorkspacePipeline) rm(exec node.NodeExec, path string, c *Deploy
 
 	cmd := fmt.Sprintf("rm -rf %s", path)
 	opt := node.NewNodeExecuteCommandOptions()
-	opt.SetEnv(c.env)
 	return exec.ExecuteCommand(cmd, opt, func(s string) {
 		c.Send("info", s)
 	}, func(s string) {
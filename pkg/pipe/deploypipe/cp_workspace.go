@@ -16,15 +16,17 @@ type CopyWorkspacePipeline struct {
 }
 
 func (p *CopyWorkspacePipeline) Process(ctx context.Context, c *DeployCtx) (*DeployCtx, error) {
-	workspace := path.Join(c.options.DataPath(), options.WORK_SPACE_BASE_PATH)
-	appws := path.Join(workspace, c.App.Name)
-	_, err := os.Stat(appws)
-	if err != nil {
-		return c, err
+	appws := c.Workspace
+	if appws == "" {
+		appws = path.Join(c.options.DataPath(), options.WORK_SPACE_BASE_PATH, c.App.Name)
 	}
+	_, err := os.Stat(appws)
 	if os.IsNotExist(err) {
 		return c, nil
 	}
+	if err != nil {
+		return c, err
+	}
 
 	installerPath, err := c.GetServicePath()
 	if err != nil {
@@ -60,7 +62,6 @@ func (p *CopyWorkspacePipeline) Process(ctx context.Context, c *DeployCtx) (*Dep
 		if info.IsDir() {
 			cmd := fmt.Sprintf("mkdir -p %s", targetPath)
 			opt := node.NewNodeExecuteCommandOptions()
-			opt.SetEnv(c.env)
 			return exec.ExecuteCommand(cmd, opt, func(s string) {
 				c.Send("info", s)
 			}, func(s string) {
@@ -85,7 +86,6 @@ func (p *CopyWorkspacePipeline) Process(ctx context.Context, c *DeployCtx) (*Dep
 		targetDir := filepath.Dir(targetPath)
 		cmd := fmt.Sprintf("mkdir -p %s", targetDir)
 		opt := node.NewNodeExecuteCommandOptions()
-		opt.SetEnv(c.env)
 		if err := exec.ExecuteCommand(cmd, opt, func(s string) {
 			c.Send("info", s)
 		}, func(s string) {
@@ -7,9 +7,11 @@ import (
 	"sync"
 
 	"github.com/benlocal/lai-panel/pkg/ctx"
+	"github.com/benlocal/lai-panel/pkg/envscope"
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/benlocal/lai-panel/pkg/node"
 	"github.com/benlocal/lai-panel/pkg/options"
+	"github.com/benlocal/lai-panel/pkg/secret"
 	"github.com/cloudwego/hertz/pkg/protocol/sse"
 )
 
@@ -23,10 +25,17 @@ type DeployCtx struct {
 	sendMu      sync.Mutex
 	env         map[string]string
 	tmplFuncMap map[string]interface{}
+	// Workspace is copied to the node, the workspace of the app when empty
+	Workspace string
 
 	// out
 	dockerComposeFile *string
 	deployInfo        map[string]string
+	// images pulled from their registry, no need to copy from other nodes
+	pulledImages map[string]bool
+	ports        portAllocator
+	// dependencies of the service by name, loaded on first use
+	dependencies map[string]*model.Service
 }
 
 func NewDeployCtx(
@@ -35,15 +44,41 @@ func NewDeployCtx(
 	env map[string]string,
 	appCtx *ctx.AppCtx,
 ) *DeployCtx {
-	tmplFuncMap := builtinFuncMap(appCtx)
-	return &DeployCtx{
-		options:     options,
-		appCtx:      appCtx,
-		writer:      writer,
-		env:         env,
-		sendMu:      sync.Mutex{},
-		deployInfo:  make(map[string]string),
-		tmplFuncMap: tmplFuncMap,
+	d := &DeployCtx{
+		options:      options,
+		appCtx:       appCtx,
+		writer:       writer,
+		env:          env,
+		sendMu:       sync.Mutex{},
+		deployInfo:   make(map[string]string),
+		pulledImages: make(map[string]bool),
+	}
+	d.tmplFuncMap = builtinFuncMap(d)
+	for k, v := range d.secretFuncMap() {
+		d.tmplFuncMap[k] = v
+	}
+	for k, v := range d.dependencyFuncMap() {
+		d.tmplFuncMap[k] = v
+	}
+	return d
+}
+
+// secretFuncMap returns the template functions generating values which are
+// stored per service and stay the same across deploys: secrets and host ports.
+func (d *DeployCtx) secretFuncMap() map[string]interface{} {
+	store := secret.NewStore(d.appCtx.KvRepository())
+	return map[string]interface{}{
+		"gen_secret": func(name string, length ...int) (string, error) {
+			l := secret.DefaultLength
+			if len(length) > 0 {
+				l = length[0]
+			}
+			return store.GetOrCreate(d.Service.ID, name, secret.TypeSecret, l)
+		},
+		"gen_uuid": func(name string) (string, error) {
+			return store.GetOrCreate(d.Service.ID, name, secret.TypeUUID, 0)
+		},
+		"alloc_port": d.allocPort,
 	}
 }
 
@@ -57,6 +92,14 @@ func (d *DeployCtx) GetDeployInfo() map[string]string {
 	return d.deployInfo
 }
 
+// recordApp remembers which version of the app the service runs.
+func (d *DeployCtx) recordApp() {
+	d.deployInfo[model.DeployInfoAppVersion] = d.App.Version
+	if d.App.CatalogCommit != nil {
+		d.deployInfo[model.DeployInfoAppCommit] = *d.App.CatalogCommit
+	}
+}
+
 func (d *DeployCtx) GetServicePath() (string, error) {
 	return getPath(d.NodeState, d.options, d.Service.Name)
 }
@@ -75,6 +118,7 @@ func NewDownCtx(
 	deployInfo map[string]string,
 ) *DownCtx {
 	return &DownCtx{
+		options:    options,
 		Service:    service,
 		NodeState:  nodeState,
 		deployInfo: deployInfo,
@@ -97,7 +141,26 @@ func getPath(nodeState *node.NodeState, opt options.IOptions, name string) (stri
 	return path.Join(*dataPath, options.SERVICE_BASE_PATH, name), nil
 }
 
-func builtinFuncMap(appCtx *ctx.AppCtx) map[string]interface{} {
+// envTarget is the scope panel_env values are resolved for.
+func (d *DeployCtx) envTarget() envscope.Target {
+	target := envscope.Target{}
+	if so, ok := d.options.(*options.ServeOptions); ok {
+		target.Environment = so.Environment
+	}
+	if d.NodeState != nil {
+		target.NodeID = d.NodeState.GetNodeID()
+	}
+	if d.App != nil {
+		target.AppID = d.App.ID
+	}
+	if d.Service != nil {
+		target.ServiceID = d.Service.ID
+	}
+	return target
+}
+
+func builtinFuncMap(d *DeployCtx) map[string]interface{} {
+	appCtx := d.appCtx
 	return map[string]interface{}{
 		"panel_env": func(key interface{}, defaultValue interface{}) string {
 			// Convert key to string
@@ -118,12 +181,13 @@ func builtinFuncMap(appCtx *ctx.AppCtx) map[string]interface{} {
 				defaultValueStr = fmt.Sprintf("%v", v)
 			}
 
-			e, err := appCtx.EnvRepository().GetByKey(keyStr)
+			envs, err := appCtx.EnvRepository().ListByKey(keyStr)
 			if err != nil {
 				return defaultValueStr
 			}
 
-			if e == nil {
+			e, ok := envscope.Resolve(envs, d.envTarget())[keyStr]
+			if !ok {
 				return defaultValueStr
 			}
 
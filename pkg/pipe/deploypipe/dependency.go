@@ -0,0 +1,116 @@
+package deploypipe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/secret"
+)
+
+// dependencyFuncMap returns the template functions reading the values of
+// the services the service depends on.
+func (d *DeployCtx) dependencyFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"service_output": d.serviceOutput,
+		"service_host":   d.serviceHost,
+	}
+}
+
+// dependency returns the dependency of the service with the given name,
+// only declared dependencies can be read.
+func (d *DeployCtx) dependency(name string) (*model.Service, error) {
+	if d.Service == nil {
+		return nil, fmt.Errorf("service %s is not a dependency", name)
+	}
+	if d.dependencies == nil {
+		deps, err := d.appCtx.ServiceDependencyRepository().ListByService(d.Service.ID)
+		if err != nil {
+			return nil, err
+		}
+		d.dependencies = make(map[string]*model.Service, len(deps))
+		for _, dep := range deps {
+			service, err := d.appCtx.ServiceRepository().GetByID(dep.DependsOnID)
+			if err != nil {
+				return nil, err
+			}
+			d.dependencies[service.Name] = service
+		}
+	}
+
+	service, ok := d.dependencies[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s is not a dependency of %s", name, d.Service.Name)
+	}
+	return service, nil
+}
+
+// serviceOutput resolves a value of a dependency: one of its QA values,
+// secrets or allocated ports, in that order.
+func (d *DeployCtx) serviceOutput(name string, key string) (string, error) {
+	service, err := d.dependency(name)
+	if err != nil {
+		return "", err
+	}
+
+	if v, ok := service.ToView().QAValues[key]; ok {
+		return v, nil
+	}
+	s, err := secret.NewStore(d.appCtx.KvRepository()).Get(service.ID, key)
+	if err != nil {
+		return "", err
+	}
+	if s != nil {
+		return s.Value, nil
+	}
+	port, err := d.appCtx.PortAllocationRepository().GetByServiceAndName(service.ID, key)
+	if err != nil {
+		return "", err
+	}
+	if port != nil {
+		return strconv.Itoa(port.Port), nil
+	}
+	return "", fmt.Errorf("service %s has no output %s", name, key)
+}
+
+// serviceHost returns the address of the node running a dependency.
+func (d *DeployCtx) serviceHost(name string) (string, error) {
+	service, err := d.dependency(name)
+	if err != nil {
+		return "", err
+	}
+	node, err := d.appCtx.NodeRepository().GetByID(service.NodeID)
+	if err != nil {
+		return "", err
+	}
+	return node.Address, nil
+}
+
+// NewDependencyCtx returns the context deploying a dependency of the service,
+// its progress goes to the same writer.
+func (d *DeployCtx) NewDependencyCtx(env map[string]string) *DeployCtx {
+	return NewDeployCtx(d.options, d.writer, env, d.appCtx)
+}
+
+// WaitServiceHealthy blocks until the containers of a deployed service run
+// and pass their healthchecks.
+func WaitServiceHealthy(ctx context.Context, c *DownCtx, timeout time.Duration) error {
+	dc, err := c.NodeState.GetDockerClient()
+	if err != nil {
+		return err
+	}
+	return compose.NewEngine(dc, nil).WaitHealthy(ctx, projectName(c.Service), timeout)
+}
+
+// ServiceRunning tells whether the containers of a service exist and run,
+// a service taken down keeps its deploy info.
+func ServiceRunning(ctx context.Context, c *DownCtx) (bool, error) {
+	dc, err := c.NodeState.GetDockerClient()
+	if err != nil {
+		return false, err
+	}
+	return compose.NewEngine(dc, nil).Running(ctx, projectName(c.Service))
+}
@@ -4,13 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"path"
+	"strconv"
 
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/dotenv"
+	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/benlocal/lai-panel/pkg/node"
 )
 
 const (
 	DockerComposeFile = "docker-compose.yml"
+	// DockerComposeEnvFile holds the deploy env merged over the .env of the
+	// app, it is readable by the owner only
+	DockerComposeEnvFile = ".panel.env"
+	// AppEnvFile is the .env an app may ship, docker compose reads it unless
+	// an env file is given
+	AppEnvFile = ".env"
 )
 
 type DockerComposeUpPipeline struct {
@@ -32,23 +43,40 @@ func (p *DockerComposeUpPipeline) Process(ctx context.Context, c *DeployCtx) (*D
 	if err != nil {
 		return c, err
 	}
-	composeCmd, err := findDockerComposeCommand(exec)
+	err = exec.WriteFile(pa, []byte(*c.dockerComposeFile))
 	if err != nil {
 		return c, err
 	}
-	err = exec.WriteFile(pa, []byte(*c.dockerComposeFile))
+
+	c.Send("info", "docker compose file written to disk, path: "+pa)
+
+	// the env is handed to docker compose in a file, never on the command line
+	env, err := composeEnv(exec, installerPath, c.env)
+	if err != nil {
+		return c, err
+	}
+	envFile, err := dotenv.Format(dotenv.FormatEnv, env)
+	if err != nil {
+		return c, err
+	}
+	err = exec.WriteFileWithMode(path.Join(installerPath, DockerComposeEnvFile), envFile, 0o600)
 	if err != nil {
 		return c, err
 	}
 
-	c.Send("info", "docker compose file written to disk, path: "+pa)
 	c.Send("info", "  --> deploying to node: "+c.NodeState.GetNodeInfo())
 
+	if c.NodeState.GetComposeBackend() == model.ComposeBackendNative {
+		return p.nativeUp(ctx, c, exec, installerPath, env)
+	}
+
 	// execute docker compose up
-	cmd := fmt.Sprintf("%s -f %s up -d --build", composeCmd, DockerComposeFile)
+	cmd, err := composeCommand(exec, installerPath, projectName(c.Service), "up -d --build")
+	if err != nil {
+		return c, err
+	}
 	c.Send("info", "executing command: "+cmd)
 	opt := node.NewNodeExecuteCommandOptions()
-	opt.SetEnv(c.env)
 	opt.SetWorkingDir(installerPath)
 	err = exec.ExecuteCommand(cmd, opt, func(s string) {
 		c.Send("info", s)
@@ -60,6 +88,36 @@ func (p *DockerComposeUpPipeline) Process(ctx context.Context, c *DeployCtx) (*D
 	}
 
 	c.Send("info", "docker compose up executed")
+	c.recordApp()
+	return c, nil
+}
+
+// nativeUp runs the compose file with the native engine through the node
+// docker client, the docker compose command is not needed on the node.
+func (p *DockerComposeUpPipeline) nativeUp(ctx context.Context, c *DeployCtx, exec node.NodeExec, installerPath string, env map[string]string) (*DeployCtx, error) {
+	project, err := compose.LoadProject(ctx, *c.dockerComposeFile, DockerComposeFile, installerPath, projectName(c.Service), env)
+	if err != nil {
+		return c, err
+	}
+	if err := compose.ResolveEnvironment(project, env, exec.ReadFile); err != nil {
+		return c, err
+	}
+
+	dc, err := c.NodeState.GetDockerClient()
+	if err != nil {
+		return c, err
+	}
+
+	c.Send("info", "running docker compose up with the native engine")
+	engine := compose.NewEngine(dc, func(s string) {
+		c.Send("info", s)
+	}).WithRegistryAuth(c.registryAuth)
+	if err := engine.Up(ctx, project); err != nil {
+		return c, err
+	}
+
+	c.Send("info", "docker compose up executed")
+	c.recordApp()
 	return c, nil
 }
 
@@ -67,27 +125,49 @@ func (p *DockerComposeUpPipeline) Cancel(c *DeployCtx, err error) {
 	// do nothing
 }
 
+// composeEnv merges the deploy env over the .env shipped with the app, an
+// env file given to docker compose replaces the .env it reads by default.
+func composeEnv(exec node.NodeExec, installerPath string, env map[string]string) (map[string]string, error) {
+	merged := map[string]string{}
+	if data, err := exec.ReadFile(path.Join(installerPath, AppEnvFile)); err == nil {
+		values, err := dotenv.Parse(dotenv.FormatEnv, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the %s of the app: %w", AppEnvFile, err)
+		}
+		maps.Copy(merged, values)
+	}
+	maps.Copy(merged, env)
+	return merged, nil
+}
+
 type DockerComposeDownPipeline struct {
 }
 
 func (p *DockerComposeDownPipeline) Process(ctx context.Context, c *DownCtx) (*DownCtx, error) {
+	if c.NodeState.GetComposeBackend() == model.ComposeBackendNative {
+		dc, err := c.NodeState.GetDockerClient()
+		if err != nil {
+			return c, err
+		}
+		err = compose.NewEngine(dc, nil).Down(ctx, projectName(c.Service), false)
+		return c, err
+	}
+
 	installerPath, err := c.GetServicePath()
 	if err != nil {
 		return c, err
 	}
-	env := map[string]string{}
 	exec, err := c.NodeState.GetExec()
 	if err != nil {
 		return c, err
 	}
-	composeCmd, err := findDockerComposeCommand(exec)
+	cmd, err := composeCommand(exec, installerPath, projectName(c.Service), "down")
 	if err != nil {
 		return c, err
 	}
 	opt := node.NewNodeExecuteCommandOptions()
-	opt.SetEnv(env)
 	opt.SetWorkingDir(installerPath)
-	err = exec.ExecuteCommand(fmt.Sprintf("%s -f %s down", composeCmd, DockerComposeFile), opt, func(s string) {
+	err = exec.ExecuteCommand(cmd, opt, func(s string) {
 		// do nothing
 	}, func(s string) {
 		// do nothing
@@ -102,6 +182,105 @@ func (p *DockerComposeDownPipeline) Cancel(c *DownCtx, err error) {
 	// do nothing
 }
 
+type DockerComposeRestartPipeline struct {
+}
+
+func (p *DockerComposeRestartPipeline) Process(ctx context.Context, c *DownCtx) (*DownCtx, error) {
+	if c.NodeState.GetComposeBackend() == model.ComposeBackendNative {
+		dc, err := c.NodeState.GetDockerClient()
+		if err != nil {
+			return c, err
+		}
+		err = compose.NewEngine(dc, nil).Restart(ctx, projectName(c.Service))
+		return c, err
+	}
+
+	installerPath, err := c.GetServicePath()
+	if err != nil {
+		return c, err
+	}
+	exec, err := c.NodeState.GetExec()
+	if err != nil {
+		return c, err
+	}
+	cmd, err := composeCommand(exec, installerPath, projectName(c.Service), "restart")
+	if err != nil {
+		return c, err
+	}
+	opt := node.NewNodeExecuteCommandOptions()
+	opt.SetWorkingDir(installerPath)
+	_, stderr, err := exec.ExecuteOutput(cmd, opt)
+	if err != nil {
+		return c, fmt.Errorf("%w: %s", err, stderr)
+	}
+	return c, nil
+}
+
+func (p *DockerComposeRestartPipeline) Cancel(c *DownCtx, err error) {
+	// do nothing
+}
+
+// DockerComposeLogs streams the logs of the containers of a service, the
+// last tail lines first ("all" for everything).
+func DockerComposeLogs(ctx context.Context, c *DownCtx, tail string, follow bool, onLine func(string)) error {
+	if c.NodeState.GetComposeBackend() == model.ComposeBackendNative {
+		dc, err := c.NodeState.GetDockerClient()
+		if err != nil {
+			return err
+		}
+		return compose.NewEngine(dc, nil).Logs(ctx, projectName(c.Service), tail, follow, onLine)
+	}
+
+	installerPath, err := c.GetServicePath()
+	if err != nil {
+		return err
+	}
+	exec, err := c.NodeState.GetExec()
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.Atoi(tail); err != nil && tail != "all" {
+		return fmt.Errorf("invalid tail %q", tail)
+	}
+	args := "logs --no-color --tail " + tail
+	if follow {
+		args += " --follow"
+	}
+	cmd, err := composeCommand(exec, installerPath, projectName(c.Service), args)
+	if err != nil {
+		return err
+	}
+	opt := node.NewNodeExecuteCommandOptions()
+	opt.SetWorkingDir(installerPath)
+	opt.SetContext(ctx)
+	return exec.ExecuteCommand(cmd, opt, onLine, onLine)
+}
+
+// projectName is the compose project of the service, adopted services keep
+// the project they were started with.
+func projectName(service *model.Service) string {
+	if service.ComposeProject != nil && *service.ComposeProject != "" {
+		return *service.ComposeProject
+	}
+	return compose.ProjectName(service.Name)
+}
+
+// composeCommand builds a docker compose command run in the service path. The
+// env file is passed when present, deploys made before it existed have none.
+func composeCommand(exec node.NodeExec, installerPath string, project string, args string) (string, error) {
+	composeCmd, err := findDockerComposeCommand(exec)
+	if err != nil {
+		return "", err
+	}
+
+	opt := node.NewNodeExecuteCommandOptions()
+	opt.SetWorkingDir(installerPath)
+	if _, _, err := exec.ExecuteOutput("test -f "+DockerComposeEnvFile, opt); err == nil {
+		composeCmd += " --env-file " + DockerComposeEnvFile
+	}
+	return fmt.Sprintf("%s -p %s -f %s %s", composeCmd, project, DockerComposeFile, args), nil
+}
+
 func findDockerComposeCommand(exec node.NodeExec) (string, error) {
 	if _, _, err := exec.ExecuteOutput("docker compose version", node.NewNodeExecuteCommandOptions()); err == nil {
 		return "docker compose", nil
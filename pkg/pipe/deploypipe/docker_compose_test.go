@@ -0,0 +1,25 @@
+package deploypipe
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeEnv(t *testing.T) {
+	exec := node.NewLocalNodeExec()
+	dir := t.TempDir()
+
+	env, err := composeEnv(exec, dir, map[string]string{"PORT": "8080"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PORT": "8080"}, env)
+
+	// the deploy env wins over the .env of the app
+	assert.NoError(t, os.WriteFile(path.Join(dir, AppEnvFile), []byte("PORT=80\nTZ=UTC\n"), 0o644))
+	env, err = composeEnv(exec, dir, map[string]string{"PORT": "8080"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PORT": "8080", "TZ": "UTC"}, env)
+}
@@ -0,0 +1,140 @@
+package deploypipe
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/opencontainers/go-digest"
+)
+
+// imageInventory caches the images of each node for the duration of a
+// deploy, every node is listed once instead of inspected for every image.
+type imageInventory struct {
+	mu    sync.Mutex
+	nodes map[int64]*nodeImages
+
+	sourcesOnce sync.Once
+	sources     []*node.NodeState
+}
+
+type nodeImages struct {
+	once sync.Once
+	mu   sync.RWMutex
+	refs map[string]bool
+	err  error
+}
+
+func newImageInventory() *imageInventory {
+	return &imageInventory{
+		nodes: make(map[int64]*nodeImages),
+	}
+}
+
+func (i *imageInventory) get(ctx context.Context, state *node.NodeState) (*nodeImages, error) {
+	i.mu.Lock()
+	n, ok := i.nodes[state.GetNodeID()]
+	if !ok {
+		n = &nodeImages{}
+		i.nodes[state.GetNodeID()] = n
+	}
+	i.mu.Unlock()
+
+	n.once.Do(func() {
+		n.refs, n.err = listNodeImages(ctx, state)
+	})
+	return n, n.err
+}
+
+// has reports whether the node has image.
+func (i *imageInventory) has(ctx context.Context, state *node.NodeState, image string) (bool, error) {
+	n, err := i.get(ctx, state)
+	if err != nil {
+		return false, err
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.refs[normalizeImageRef(image)], nil
+}
+
+// add records an image transferred to the node.
+func (i *imageInventory) add(ctx context.Context, state *node.NodeState, image string) {
+	n, err := i.get(ctx, state)
+	if err != nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.refs[normalizeImageRef(image)] = true
+}
+
+// sourceNodes returns the nodes images may be copied from: offline nodes are
+// skipped and the local node comes first, it is next to the registry.
+func (i *imageInventory) sourceNodes(c *DeployCtx) []*node.NodeState {
+	i.sourcesOnce.Do(func() {
+		nodes, err := c.appCtx.NodeRepository().List()
+		if err != nil {
+			return
+		}
+
+		sort.SliceStable(nodes, func(a, b int) bool {
+			return nodes[a].IsLocal && !nodes[b].IsLocal
+		})
+
+		for _, n := range nodes {
+			if n.ID == c.NodeState.GetNodeID() || n.Status == "offline" {
+				continue
+			}
+			state, err := c.appCtx.NodeManager().GetNodeState(n.ID)
+			if err != nil {
+				continue
+			}
+			i.sources = append(i.sources, state)
+		}
+	})
+	return i.sources
+}
+
+func listNodeImages(ctx context.Context, state *node.NodeState) (map[string]bool, error) {
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := dc.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return imageRefs(summaries), nil
+}
+
+// imageRefs indexes the images by ID, tag and digest.
+func imageRefs(summaries []image.Summary) map[string]bool {
+	refs := make(map[string]bool)
+	for _, summary := range summaries {
+		refs[summary.ID] = true
+		for _, tag := range summary.RepoTags {
+			refs[normalizeImageRef(tag)] = true
+		}
+		for _, d := range summary.RepoDigests {
+			refs[normalizeImageRef(d)] = true
+		}
+	}
+	return refs
+}
+
+// normalizeImageRef makes compose references comparable with the docker
+// image list, e.g. nginx and docker.io/library/nginx:latest are the same.
+// Image IDs are kept, they would parse as the tag of an image named sha256.
+func normalizeImageRef(ref string) string {
+	if _, err := digest.Parse(ref); err == nil {
+		return ref
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ref
+	}
+	return reference.TagNameOnly(named).String()
+}
@@ -0,0 +1,76 @@
+package deploypipe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/docker/docker/api/types/image"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeImageRef(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"no tag", "nginx", "docker.io/library/nginx:latest"},
+		{"tag", "nginx:1.27", "docker.io/library/nginx:1.27"},
+		{"library", "library/nginx:1.27", "docker.io/library/nginx:1.27"},
+		{"docker.io", "docker.io/library/nginx", "docker.io/library/nginx:latest"},
+		{"user repository", "grafana/grafana", "docker.io/grafana/grafana:latest"},
+		{"digest", "nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			"docker.io/library/nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000"},
+		{"registry with port", "registry.local:5000/team/app", "registry.local:5000/team/app:latest"},
+		{"registry with port and tag", "registry.local:5000/team/app:v2", "registry.local:5000/team/app:v2"},
+		{"image id", "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			"sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+		{"invalid", "Not A Ref", "Not A Ref"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeImageRef(tt.ref))
+		})
+	}
+}
+
+func TestImageInventory(t *testing.T) {
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	state := &node.NodeState{}
+	inventory := newImageInventory()
+	// the node is listed from the summaries instead of docker
+	images := &nodeImages{refs: imageRefs([]image.Summary{
+		{
+			ID:          "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			RepoTags:    []string{"nginx:latest", "registry.local:5000/team/app:v2"},
+			RepoDigests: []string{"redis@" + digest},
+		},
+	})}
+	images.once.Do(func() {})
+	inventory.nodes[state.GetNodeID()] = images
+
+	ctx := context.Background()
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"nginx", true},
+		{"docker.io/library/nginx:latest", true},
+		{"nginx:1.27", false},
+		{"registry.local:5000/team/app:v2", true},
+		{"registry.local:5000/team/app", false},
+		{"docker.io/library/redis@" + digest, true},
+		{"sha256:1111111111111111111111111111111111111111111111111111111111111111", true},
+	}
+	for _, tt := range tests {
+		has, err := inventory.has(ctx, state, tt.image)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, has, tt.image)
+	}
+
+	inventory.add(ctx, state, "nginx:1.27")
+	has, err := inventory.has(ctx, state, "docker.io/library/nginx:1.27")
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
@@ -6,10 +6,16 @@ import (
 	"io"
 
 	"github.com/benlocal/lai-panel/pkg/node"
-	"github.com/cloudwego/hertz/pkg/protocol/sse"
+	"github.com/benlocal/lai-panel/pkg/oci"
+	"github.com/benlocal/lai-panel/pkg/options"
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 )
 
+const defaultImageConcurrency = 3
+
+// LoadImagePipeline copies the compose images missing on the target node
+// from other nodes, several images are prepared at the same time.
 type LoadImagePipeline struct {
 }
 
@@ -18,7 +24,7 @@ func (p *LoadImagePipeline) Process(ctx context.Context, c *DeployCtx) (*DeployC
 		return c, nil
 	}
 
-	images, err := p.getImages(*c.dockerComposeFile)
+	images, err := getComposeImages(*c.dockerComposeFile)
 	if err != nil {
 		return c, nil
 	}
@@ -27,13 +33,22 @@ func (p *LoadImagePipeline) Process(ctx context.Context, c *DeployCtx) (*DeployC
 		return c, nil
 	}
 
+	inventory := newImageInventory()
+	g := errgroup.Group{}
+	g.SetLimit(imageConcurrency(c))
 	for _, image := range images {
-		err := p.loadImage(ctx, c, image)
-		if err != nil {
-			c.Send("warning", "load image "+image+" failed: "+err.Error())
+		if c.pulledImages[image] {
 			continue
 		}
+		g.Go(func() error {
+			err := p.loadImage(ctx, c, inventory, image)
+			if err != nil {
+				c.Send("warning", "load image "+image+" failed: "+err.Error())
+			}
+			return nil
+		})
 	}
+	_ = g.Wait()
 
 	return c, nil
 }
@@ -42,7 +57,15 @@ func (p *LoadImagePipeline) Cancel(c *DeployCtx, err error) {
 	// do nothing
 }
 
-func (p *LoadImagePipeline) getImages(dockerComposeFile string) ([]string, error) {
+// imageConcurrency is how many images a deploy prepares at the same time.
+func imageConcurrency(c *DeployCtx) int {
+	if so, ok := c.options.(*options.ServeOptions); ok && so.ImageConcurrency > 0 {
+		return so.ImageConcurrency
+	}
+	return defaultImageConcurrency
+}
+
+func getComposeImages(dockerComposeFile string) ([]string, error) {
 	var y yaml.Node
 	if err := yaml.Unmarshal([]byte(dockerComposeFile), &y); err != nil {
 		return nil, err
@@ -60,6 +83,7 @@ func (p *LoadImagePipeline) getImages(dockerComposeFile string) ([]string, error
 
 	// 遍历所有 services，找出不需要 build 的 image
 	images := []string{}
+	seen := map[string]bool{}
 	for i := 0; i < len(services.Content); i += 2 {
 		if i+1 >= len(services.Content) {
 			continue
@@ -72,7 +96,8 @@ func (p *LoadImagePipeline) getImages(dockerComposeFile string) ([]string, error
 		}
 
 		imageNode := lookup(svc, "image")
-		if imageNode != nil && imageNode.Kind == yaml.ScalarNode && imageNode.Value != "" {
+		if imageNode != nil && imageNode.Kind == yaml.ScalarNode && imageNode.Value != "" && !seen[imageNode.Value] {
+			seen[imageNode.Value] = true
 			images = append(images, imageNode.Value)
 		}
 	}
@@ -82,72 +107,108 @@ func (p *LoadImagePipeline) getImages(dockerComposeFile string) ([]string, error
 
 func (p *LoadImagePipeline) loadImage(ctx context.Context,
 	c *DeployCtx,
+	inventory *imageInventory,
 	image string) error {
-	// load image to local registry
 	currentState := c.NodeState
-	dc, err := currentState.GetDockerClient()
+	exists, err := inventory.has(ctx, currentState, image)
 	if err != nil {
 		return err
 	}
-
-	// check if image exists
-	_, err = dc.ImageInspect(ctx, image)
-	if err == nil {
-		// image already exists
-		c.Send("info", "image "+image+" already exists")
+	if exists {
+		c.Send("info", image+": already exists")
 		return nil
 	}
 
-	// load image to local registry
-	nodes, err := c.appCtx.NodeRepository().List()
+	ss := p.findImageSource(ctx, c, inventory, image)
+
+	if so, ok := c.options.(*options.ServeOptions); ok && so.RegistryEnabled {
+		err := p.pullThroughRegistry(ctx, c, so, ss, image)
+		if err == nil {
+			inventory.add(ctx, currentState, image)
+			return nil
+		}
+		c.Send("warning", image+": registry transfer failed, falling back to image copy: "+err.Error())
+	}
+
+	if ss == nil {
+		return errors.New("no node has the image")
+	}
+
+	opt := &node.ImageTransferOptions{
+		Report: func(msg string) {
+			c.Send("info", image+": "+msg)
+		},
+	}
+	if so, ok := c.options.(*options.ServeOptions); ok {
+		opt.Compression = so.ImageCompression
+		opt.BandwidthLimit = so.ImageBandwidthLimit
+	}
+
+	c.Send("info", image+": copy from "+ss.GetNodeInfo())
+	err = node.CopyImageBetweenNodesWithOptions(ctx, ss, currentState, image, opt, func(ctx context.Context, reader io.ReadCloser) error {
+		_, err := io.Copy(&imageProgressWriter{c: c, image: image}, reader)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	inventory.add(ctx, currentState, image)
+	c.Send("info", image+": load from "+ss.GetNodeInfo()+" success")
+	return nil
+}
 
-	var ss *node.NodeState
-	for _, node := range nodes {
-		if node.ID == currentState.GetNodeID() {
-			continue
-		}
-		ssState, err := c.appCtx.NodeManager().GetNodeState(node.ID)
-		if err != nil {
-			continue
-		}
-		dc, err := ssState.GetDockerClient()
-		if err != nil {
-			continue
-		}
-		_, err = dc.ImageInspect(ctx, image)
-		if err != nil {
-			continue
+// findImageSource returns the first source node which has the image, or nil.
+func (p *LoadImagePipeline) findImageSource(ctx context.Context, c *DeployCtx, inventory *imageInventory, image string) *node.NodeState {
+	for _, state := range inventory.sourceNodes(c) {
+		if ok, err := inventory.has(ctx, state, image); err == nil && ok {
+			return state
 		}
+	}
+	return nil
+}
 
-		ss = ssState
-		break
+// pullThroughRegistry moves the image node -> master registry -> node, both
+// directions only transfer the layers missing on the other side. Without a
+// source node the image is pulled when the registry already has it.
+func (p *LoadImagePipeline) pullThroughRegistry(ctx context.Context, c *DeployCtx, so *options.ServeOptions, source *node.NodeState, image string) error {
+	mirrorRef, err := oci.MirrorReference(so.RegistryAddress(), image)
+	if err != nil {
+		return err
 	}
 
-	if ss == nil {
-		return errors.New("no node has the image")
+	auth, err := oci.EncodeAuth(so.RegistryAddress(), so.RegistryUsername, so.RegistryPassword)
+	if err != nil {
+		return err
+	}
+
+	report := func(msg string) {
+		c.Send("info", image+": "+msg)
 	}
 
-	return node.CopyImageBetweenNodes(ctx, ss, currentState, image, func(ctx context.Context, reader io.ReadCloser) error {
-		_, err := io.Copy(&CopyWriter{c.writer}, reader)
-		if err != nil {
+	if source != nil {
+		report("push from " + source.GetNodeInfo() + " to registry " + so.RegistryAddress())
+		if err := node.PushImageToRegistry(ctx, source, image, mirrorRef, auth, report); err != nil {
 			return err
 		}
-		c.Send("info", "load image "+image+" form "+ss.GetNodeInfo()+" to local node success")
-		return nil
-	})
+	}
+
+	report("pull from registry " + so.RegistryAddress())
+	if err := node.PullImageFromRegistry(ctx, c.NodeState, mirrorRef, image, auth, report); err != nil {
+		return err
+	}
+	report("load from registry success")
+	return nil
 }
 
-type CopyWriter struct {
-	*sse.Writer
+// imageProgressWriter forwards the docker load output of one image.
+type imageProgressWriter struct {
+	c     *DeployCtx
+	image string
 }
 
-func (c *CopyWriter) Write(p []byte) (n int, err error) {
-	err = c.Writer.WriteEvent("", "info", p)
-	if err != nil {
+func (w *imageProgressWriter) Write(p []byte) (int, error) {
+	if err := w.c.Send("info", w.image+": "+string(p)); err != nil {
 		return 0, err
 	}
-	return len(p), err
+	return len(p), nil
 }
@@ -0,0 +1,158 @@
+package deploypipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/docker/docker/api/types/container"
+)
+
+// range of host ports handed out by alloc_port
+const (
+	allocPortStart = 20000
+	allocPortEnd   = 29999
+)
+
+// portAllocator reserves host ports on the target node of a deploy. The
+// ports in use on the node are collected once, on the first allocation.
+type portAllocator struct {
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+// allocPort returns the port reserved for name by the service, a free port
+// is picked and reserved the first time. Reservations on another node are
+// released, the service has moved.
+func (d *DeployCtx) allocPort(name string) (int, error) {
+	if name == "" {
+		return 0, errors.New("alloc_port requires a name")
+	}
+	if d.Service == nil || d.NodeState == nil {
+		return 0, errors.New("alloc_port requires a service and a node")
+	}
+
+	repo := d.appCtx.PortAllocationRepository()
+	port, allocated, err := d.ports.alloc(repo, d.NodeState.GetNodeID(), d.Service.ID, name, func() (map[int]bool, error) {
+		return usedNodePorts(context.Background(), d.NodeState, repo)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if allocated {
+		d.Send("info", fmt.Sprintf("allocated host port %d for %s", port, name))
+	}
+	return port, nil
+}
+
+// alloc returns the port reserved for name by the service on the node and
+// whether it was reserved now, listUsed lists the ports in use on the node.
+func (a *portAllocator) alloc(
+	repo *repository.PortAllocationRepository,
+	nodeID int64,
+	serviceID int64,
+	name string,
+	listUsed func() (map[int]bool, error),
+) (int, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	existing, err := repo.GetByServiceAndName(serviceID, name)
+	if err != nil {
+		return 0, false, err
+	}
+	if existing != nil {
+		if existing.NodeID == nodeID {
+			return existing.Port, false, nil
+		}
+		if err := repo.Delete(existing.ID); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if a.used == nil {
+		used, err := listUsed()
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to list used ports: %w", err)
+		}
+		a.used = used
+	}
+
+	for port := allocPortStart; port <= allocPortEnd; port++ {
+		if a.used[port] {
+			continue
+		}
+		a.used[port] = true
+
+		allocation := &model.PortAllocation{
+			NodeID:    nodeID,
+			ServiceID: serviceID,
+			Name:      name,
+			Port:      port,
+		}
+		err := repo.Create(allocation)
+		if err == nil {
+			return port, true, nil
+		}
+		if !database.IsUniqueViolation(err) {
+			return 0, false, err
+		}
+		// reserved concurrently by another deploy, either the port or the
+		// name of the service
+		existing, err := repo.GetByServiceAndName(serviceID, name)
+		if err != nil {
+			return 0, false, err
+		}
+		if existing != nil {
+			if existing.NodeID != nodeID {
+				return 0, false, fmt.Errorf("port %s of the service is reserved on another node", name)
+			}
+			return existing.Port, false, nil
+		}
+	}
+	return 0, false, fmt.Errorf("no free host port in range %d-%d", allocPortStart, allocPortEnd)
+}
+
+// usedNodePorts returns the ports which are reserved, published by
+// containers or listened on by other processes on the node.
+func usedNodePorts(ctx context.Context, state *node.NodeState, repo *repository.PortAllocationRepository) (map[int]bool, error) {
+	used := map[int]bool{}
+
+	allocations, err := repo.ListByNode(state.GetNodeID())
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range allocations {
+		used[a.Port] = true
+	}
+
+	dc, err := state.GetDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := dc.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, ctr := range containers {
+		for _, p := range ctr.Ports {
+			if p.PublicPort != 0 {
+				used[int(p.PublicPort)] = true
+			}
+		}
+	}
+
+	listeners, err := nodeListeners(state)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		used[l.port] = true
+	}
+	return used, nil
+}
@@ -0,0 +1,66 @@
+package deploypipe
+
+import (
+	"path"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortAllocatorAlloc(t *testing.T) {
+	assert.NoError(t, database.InitDB(path.Join(t.TempDir(), "db.sqlite")))
+	defer database.CloseDB()
+	repo := repository.NewPortAllocationRepository()
+
+	listed := 0
+	listUsed := func() (map[int]bool, error) {
+		listed++
+		return map[int]bool{allocPortStart: true, allocPortStart + 1: true}, nil
+	}
+
+	// used ports are skipped
+	a := &portAllocator{}
+	port, allocated, err := a.alloc(repo, 1, 10, "http", listUsed)
+	assert.NoError(t, err)
+	assert.True(t, allocated)
+	assert.Equal(t, allocPortStart+2, port)
+
+	// ports reserved in the database but missed by the list are skipped
+	assert.NoError(t, repo.Create(&model.PortAllocation{NodeID: 1, ServiceID: 20, Name: "http", Port: allocPortStart + 3}))
+	port, allocated, err = a.alloc(repo, 1, 10, "admin", listUsed)
+	assert.NoError(t, err)
+	assert.True(t, allocated)
+	assert.Equal(t, allocPortStart+4, port)
+	assert.Equal(t, 1, listed)
+
+	// an existing reservation is reused by the next deploy
+	port, allocated, err = (&portAllocator{}).alloc(repo, 1, 10, "http", listUsed)
+	assert.NoError(t, err)
+	assert.False(t, allocated)
+	assert.Equal(t, allocPortStart+2, port)
+	assert.Equal(t, 1, listed)
+
+	// a service moved to another node gets a port there
+	port, allocated, err = (&portAllocator{}).alloc(repo, 2, 10, "http", func() (map[int]bool, error) {
+		return map[int]bool{}, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, allocated)
+	assert.Equal(t, allocPortStart, port)
+	moved, err := repo.GetByServiceAndName(10, "http")
+	assert.NoError(t, err)
+	if assert.NotNil(t, moved) {
+		assert.Equal(t, int64(2), moved.NodeID)
+	}
+	onNode, err := repo.ListByNode(1)
+	assert.NoError(t, err)
+	assert.Len(t, onNode, 2)
+
+	// other database errors are returned
+	database.CloseDB()
+	_, _, err = (&portAllocator{used: map[int]bool{}}).alloc(repo, 1, 30, "http", listUsed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package deploypipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"golang.org/x/sync/errgroup"
+)
+
+// PullImagePipeline pulls the compose images on the target node from their
+// registry, using the stored credential of the image's registry host.
+// Several images are pulled at the same time.
+// Images which can not be pulled are left to LoadImagePipeline.
+type PullImagePipeline struct {
+}
+
+func (p *PullImagePipeline) Process(ctx context.Context, c *DeployCtx) (*DeployCtx, error) {
+	if c.dockerComposeFile == nil {
+		return c, nil
+	}
+
+	images, err := getComposeImages(*c.dockerComposeFile)
+	if err != nil {
+		return c, nil
+	}
+
+	var mu sync.Mutex
+	g := errgroup.Group{}
+	g.SetLimit(imageConcurrency(c))
+	for _, image := range images {
+		g.Go(func() error {
+			err := p.pullImage(ctx, c, image)
+			if err != nil {
+				c.Send("warning", "pull image "+image+" failed: "+err.Error())
+				return nil
+			}
+			mu.Lock()
+			c.pulledImages[image] = true
+			mu.Unlock()
+			c.Send("info", "pull image "+image+" success")
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return c, nil
+}
+
+func (p *PullImagePipeline) Cancel(c *DeployCtx, err error) {
+	// do nothing
+}
+
+func (p *PullImagePipeline) pullImage(ctx context.Context, c *DeployCtx, ref string) error {
+	dc, err := c.NodeState.GetDockerClient()
+	if err != nil {
+		return err
+	}
+
+	auth, err := c.registryAuth(ref)
+	if err != nil {
+		return err
+	}
+
+	c.Send("info", "pulling image "+ref+" on node: "+c.NodeState.GetNodeInfo())
+	reader, err := dc.ImagePull(ctx, ref, image.PullOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return node.DecodeImageProgress(reader, func(msg string) {
+		c.Send("info", ref+": "+msg)
+	})
+}
+
+// registryAuth returns the encoded auth config for the registry of ref,
+// or an empty string when no credential is stored for it.
+func (c *DeployCtx) registryAuth(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", err
+	}
+
+	host := model.NormalizeRegistryHost(reference.Domain(named))
+	cred, err := c.appCtx.ImageRegistryRepository().GetByHost(host)
+	if err != nil {
+		return "", err
+	}
+	if cred == nil {
+		return "", nil
+	}
+
+	password, err := cred.GetDecryptedPassword()
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt credential of %s: %w", host, err)
+	}
+
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      cred.Username,
+		Password:      password,
+		ServerAddress: cred.ServerAddress(),
+	})
+}
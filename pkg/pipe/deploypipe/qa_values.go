@@ -0,0 +1,33 @@
+package deploypipe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/benlocal/lai-panel/pkg/qa"
+)
+
+// QAValuesPipeline validates the QA values of the deploy against the app
+// definition and fills in the defaults, the result is the template env.
+type QAValuesPipeline struct {
+}
+
+func (p *QAValuesPipeline) Process(ctx context.Context, c *DeployCtx) (*DeployCtx, error) {
+	values, err := qa.Apply(c.App.GetQA(), c.env)
+	if err != nil {
+		var verr *qa.ValidationError
+		if errors.As(err, &verr) {
+			for _, fe := range verr.Errors {
+				c.Send("error", fe.Error())
+			}
+		}
+		return c, err
+	}
+
+	c.env = values
+	return c, nil
+}
+
+func (p *QAValuesPipeline) Cancel(c *DeployCtx, err error) {
+	// do nothing
+}
@@ -0,0 +1,298 @@
+package deploypipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/benlocal/lai-panel/pkg/constant"
+	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// DockerComposeValidatePipeline checks the rendered compose file before it is
+// deployed: the compose specification, host ports published by the services
+// and bind mount paths on the target node. All problems are reported at once.
+type DockerComposeValidatePipeline struct {
+}
+
+func (p *DockerComposeValidatePipeline) Process(ctx context.Context, c *DeployCtx) (*DeployCtx, error) {
+	if c.dockerComposeFile == nil {
+		return c, errors.New("docker compose file is not found")
+	}
+
+	servicePath, err := c.GetServicePath()
+	if err != nil {
+		return c, err
+	}
+
+	project, err := compose.LoadProject(ctx, *c.dockerComposeFile, DockerComposeFile, servicePath, projectName(c.Service), c.env)
+	if err != nil {
+		return c, fmt.Errorf("invalid docker compose file: %w", err)
+	}
+
+	problems := checkDuplicatePorts(project)
+	problems = append(problems, p.checkNodePorts(ctx, c, project)...)
+	problems = append(problems, p.checkBindMounts(c, project)...)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			c.Send("error", problem)
+		}
+		return c, fmt.Errorf("docker compose validation failed:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	c.Send("info", "docker compose file validated")
+	return c, nil
+}
+
+func (p *DockerComposeValidatePipeline) Cancel(c *DeployCtx, err error) {
+	// do nothing
+}
+
+type publishedPort struct {
+	service  string
+	hostIP   string
+	port     int
+	protocol string
+}
+
+func (p publishedPort) String() string {
+	return fmt.Sprintf("%d/%s", p.port, p.protocol)
+}
+
+// publishedPorts expands the host ports of all services, ranges included.
+func publishedPorts(project *types.Project) ([]publishedPort, error) {
+	ports := []publishedPort{}
+	for _, name := range project.ServiceNames() {
+		svc := project.Services[name]
+		for _, cfg := range svc.Ports {
+			if cfg.Published == "" {
+				continue
+			}
+			start, end, err := parsePortRange(cfg.Published)
+			if err != nil {
+				return nil, fmt.Errorf("service %q: invalid published port %q", name, cfg.Published)
+			}
+			protocol := cfg.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			for port := start; port <= end; port++ {
+				ports = append(ports, publishedPort{
+					service:  name,
+					hostIP:   cfg.HostIP,
+					port:     port,
+					protocol: protocol,
+				})
+			}
+		}
+	}
+	return ports, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := start
+	if isRange {
+		end, err = strconv.Atoi(last)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if start < 1 || end > 65535 || start > end {
+		return 0, 0, errors.New("port out of range")
+	}
+	return start, end, nil
+}
+
+func isWildcardIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::" || ip == "*"
+}
+
+func hostIPsOverlap(a string, b string) bool {
+	return isWildcardIP(a) || isWildcardIP(b) || a == b
+}
+
+func checkDuplicatePorts(project *types.Project) []string {
+	ports, err := publishedPorts(project)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	problems := []string{}
+	for i, a := range ports {
+		for _, b := range ports[:i] {
+			if a.port == b.port && a.protocol == b.protocol && hostIPsOverlap(a.hostIP, b.hostIP) {
+				problems = append(problems, fmt.Sprintf("service %q: host port %s is also published by service %q", a.service, a, b.service))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// checkNodePorts reports published ports which are already used on the node,
+// either by containers of other services or by other listeners.
+// Containers of the service being deployed are replaced and therefore ignored.
+func (p *DockerComposeValidatePipeline) checkNodePorts(ctx context.Context, c *DeployCtx, project *types.Project) []string {
+	ports, err := publishedPorts(project)
+	if err != nil || len(ports) == 0 {
+		return nil
+	}
+
+	dc, err := c.NodeState.GetDockerClient()
+	if err != nil {
+		return []string{"failed to check host ports: " + err.Error()}
+	}
+	containers, err := dc.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return []string{"failed to check host ports: " + err.Error()}
+	}
+
+	// ports held by the containers of this service, docker-proxy listens on them as well
+	own := map[string]bool{}
+	problems := []string{}
+	reported := map[string]bool{}
+	for _, ctr := range containers {
+		ownContainer := ctr.Labels[constant.ServiceLabel] == c.Service.Name ||
+			ctr.Labels[compose.ProjectLabel] == project.Name
+		for _, bound := range ctr.Ports {
+			if bound.PublicPort == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%d/%s", bound.PublicPort, bound.Type)
+			if ownContainer {
+				own[key] = true
+				continue
+			}
+			for _, port := range ports {
+				if port.String() != key || !hostIPsOverlap(port.hostIP, bound.IP) || reported[port.service+key] {
+					continue
+				}
+				reported[port.service+key] = true
+				problems = append(problems, fmt.Sprintf("service %q: host port %s is already published by container %s%s",
+					port.service, key, containerName(ctr.Names), ownerOf(ctr.Labels)))
+			}
+		}
+	}
+
+	listeners, err := nodeListeners(c.NodeState)
+	if err != nil {
+		c.Send("warning", "can not list listening sockets on the node, skip the check: "+err.Error())
+		return problems
+	}
+	for _, port := range ports {
+		key := port.String()
+		if own[key] || reported[port.service+key] {
+			continue
+		}
+		for _, l := range listeners {
+			if l.String() == key && hostIPsOverlap(port.hostIP, l.hostIP) {
+				reported[port.service+key] = true
+				problems = append(problems, fmt.Sprintf("service %q: host port %s is already in use on the node (listening on %s)",
+					port.service, key, l.hostIP))
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
+
+func ownerOf(labels map[string]string) string {
+	if service, ok := labels[constant.ServiceLabel]; ok {
+		return fmt.Sprintf(" (lai-panel service %q)", service)
+	}
+	return ""
+}
+
+func nodeListeners(state *node.NodeState) ([]publishedPort, error) {
+	exec, err := state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+	listeners, err := node.ListListeners(exec)
+	if err != nil {
+		return nil, err
+	}
+	ports := make([]publishedPort, 0, len(listeners))
+	for _, l := range listeners {
+		ports = append(ports, publishedPort{
+			hostIP:   l.HostIP,
+			port:     l.Port,
+			protocol: l.Protocol,
+		})
+	}
+	return ports, nil
+}
+
+// checkBindMounts reports bind mount sources missing on the node, sources
+// docker creates by itself (create_host_path) are skipped.
+func (p *DockerComposeValidatePipeline) checkBindMounts(c *DeployCtx, project *types.Project) []string {
+	sources := map[string][]string{}
+	for _, name := range project.ServiceNames() {
+		for _, v := range project.Services[name].Volumes {
+			if v.Type != types.VolumeTypeBind || v.Source == "" {
+				continue
+			}
+			if v.Bind != nil && v.Bind.CreateHostPath {
+				continue
+			}
+			sources[v.Source] = append(sources[v.Source], name)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(sources))
+	for source := range sources {
+		paths = append(paths, source)
+	}
+	sort.Strings(paths)
+
+	exec, err := c.NodeState.GetExec()
+	if err != nil {
+		return []string{"failed to check bind mounts: " + err.Error()}
+	}
+	quoted := make([]string, 0, len(paths))
+	for _, source := range paths {
+		quoted = append(quoted, shellQuote(source))
+	}
+	cmd := fmt.Sprintf(`for p in %s; do [ -e "$p" ] || echo "$p"; done`, strings.Join(quoted, " "))
+	stdout, stderr, err := exec.ExecuteOutput(cmd, node.NewNodeExecuteCommandOptions())
+	if err != nil {
+		return []string{fmt.Sprintf("failed to check bind mounts: %v: %s", err, strings.TrimSpace(stderr))}
+	}
+
+	problems := []string{}
+	for _, missing := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if missing == "" {
+			continue
+		}
+		for _, service := range sources[missing] {
+			problems = append(problems, fmt.Sprintf("service %q: bind mount source %s does not exist on the node", service, missing))
+		}
+	}
+	return problems
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,58 @@
+package deploypipe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/compose"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProject_InvalidSpec(t *testing.T) {
+	_, err := compose.LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+    restart: sometimes-maybe
+    portz:
+      - 80:80
+`, DockerComposeFile, "/srv/web", "web", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "portz")
+}
+
+func TestCheckDuplicatePorts(t *testing.T) {
+	project, err := compose.LoadProject(context.Background(), `services:
+  web:
+    image: nginx
+    ports:
+      - 80:80
+      - 127.0.0.1:8080:8080
+  api:
+    image: api
+    ports:
+      - 8000-8002:8000-8002
+      - 80:8080
+      - 127.0.0.2:8080:80
+  dns:
+    image: dns
+    ports:
+      - 8001:53/udp
+`, DockerComposeFile, "/srv/web", "web", nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`service "web": host port 80/tcp is also published by service "api"`,
+	}, checkDuplicatePorts(project))
+}
+
+func TestParsePortRange(t *testing.T) {
+	start, end, err := parsePortRange("8000-8010")
+	assert.NoError(t, err)
+	assert.Equal(t, 8000, start)
+	assert.Equal(t, 8010, end)
+
+	_, _, err = parsePortRange("70000")
+	assert.Error(t, err)
+	_, _, err = parsePortRange("90-80")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package nodepipe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NodeBootstrapPipeline installs docker engine and the compose plugin with
+// the package manager of the distribution when the node lacks them. It only
+// runs when the bootstrap was asked for.
+type NodeBootstrapPipeline struct {
+}
+
+func (p *NodeBootstrapPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
+	r := nodeCtx.Report
+	if !nodeCtx.Bootstrap || (r.DockerVersion != "" && r.ComposeVersion != "") {
+		return nodeCtx, nil
+	}
+	if !r.Sudo {
+		r.set("bootstrap", StatusFailed, "installing docker needs root or passwordless sudo")
+		return nodeCtx, nil
+	}
+
+	user := r.User
+	if r.Root {
+		user = ""
+	}
+	script, err := bootstrapScript(r.Distro, r.DistroLike, user)
+	if err != nil {
+		r.set("bootstrap", StatusFailed, "%s", err.Error())
+		return nodeCtx, nil
+	}
+
+	exec, err := nodeCtx.state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+	nodeCtx.Send("info", fmt.Sprintf("installing docker on %s %s", r.Distro, r.DistroVersion))
+	var lastErr string
+	err = exec.ExecuteCommand(nodeCtx.sudo(script), nil, func(line string) {
+		nodeCtx.Send("info", line)
+	}, func(line string) {
+		lastErr = line
+		nodeCtx.Send("info", line)
+	})
+	if err != nil {
+		r.set("bootstrap", StatusFailed, "installing docker failed: %s", strings.TrimSpace(lastErr+" "+err.Error()))
+		return nodeCtx, nil
+	}
+
+	r.Bootstrapped = true
+	r.set("bootstrap", StatusOK, "docker installed")
+	if err := detectDocker(nodeCtx); err != nil {
+		return nil, err
+	}
+	return nodeCtx, nil
+}
+
+func (p *NodeBootstrapPipeline) Cancel(nodeCtx *NodeCtx, err error) {
+	// do nothing
+}
+
+// bootstrapScript returns the script installing docker engine and the
+// compose plugin on a distribution, user is added to the docker group when
+// set.
+func bootstrapScript(distro string, like []string, user string) (string, error) {
+	var script string
+	for _, id := range append([]string{distro}, like...) {
+		script = installScript(id)
+		if script != "" {
+			break
+		}
+	}
+	if script == "" {
+		return "", fmt.Errorf("installing docker on %s is not supported", distro)
+	}
+	if user != "" {
+		script += "usermod -aG docker " + shellQuote(user) + "\n"
+	}
+	return "set -e\n" + script, nil
+}
+
+func installScript(id string) string {
+	switch id {
+	case "ubuntu", "debian":
+		return aptScript(id)
+	case "raspbian":
+		return aptScript("debian")
+	case "fedora", "rhel", "centos":
+		return dnfScript(id)
+	case "rocky", "almalinux", "ol":
+		return dnfScript("centos")
+	case "alpine":
+		return `apk add --no-cache docker docker-cli-compose
+rc-update add docker default
+service docker start
+`
+	case "opensuse", "opensuse-leap", "opensuse-tumbleweed", "sles", "suse":
+		return `zypper --non-interactive install docker docker-compose
+systemctl enable --now docker
+`
+	case "arch", "manjaro":
+		return `pacman -Sy --noconfirm docker docker-compose
+systemctl enable --now docker
+`
+	}
+	return ""
+}
+
+// aptScript installs docker from the apt repository of docker for repo,
+// ubuntu or debian.
+func aptScript(repo string) string {
+	url := "https://download.docker.com/linux/" + repo
+	return `export DEBIAN_FRONTEND=noninteractive
+apt-get update
+apt-get install -y ca-certificates curl
+install -m 0755 -d /etc/apt/keyrings
+curl -fsSL ` + url + `/gpg -o /etc/apt/keyrings/docker.asc
+chmod a+r /etc/apt/keyrings/docker.asc
+. /etc/os-release
+echo "deb [arch=$(dpkg --print-architecture) signed-by=/etc/apt/keyrings/docker.asc] ` + url + ` ${UBUNTU_CODENAME:-$VERSION_CODENAME} stable" > /etc/apt/sources.list.d/docker.list
+apt-get update
+apt-get install -y docker-ce docker-ce-cli containerd.io docker-buildx-plugin docker-compose-plugin
+systemctl enable --now docker
+`
+}
+
+// dnfScript installs docker from the rpm repository of docker for repo,
+// fedora, rhel or centos.
+func dnfScript(repo string) string {
+	return `pm=$(command -v dnf || command -v yum)
+curl -fsSL https://download.docker.com/linux/` + repo + `/docker-ce.repo -o /etc/yum.repos.d/docker-ce.repo
+$pm -y install docker-ce docker-ce-cli containerd.io docker-buildx-plugin docker-compose-plugin
+systemctl enable --now docker
+`
+}
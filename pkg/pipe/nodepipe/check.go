@@ -2,34 +2,32 @@ package nodepipe
 
 import (
 	"context"
-	"errors"
+	"strings"
 )
 
+// NodeCheckPipeline makes sure the docker daemon answers and concludes the
+// report, it fails when any check failed.
 type NodeCheckPipeline struct {
 }
 
 func (p *NodeCheckPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
-	if nodeCtx.Node.ID <= 0 {
-		return nil, errors.New("node is invalid")
-	}
-	exec, err := nodeCtx.state.GetExec()
-	if err != nil {
-		return nil, err
-	}
-
-	out, errout, err := exec.ExecuteOutput("docker ps", nil)
-	if err != nil {
-		return nil, err
+	r := nodeCtx.Report
+	if r.DockerVersion != "" {
+		exec, err := nodeCtx.state.GetExec()
+		if err != nil {
+			return nil, err
+		}
+		// docker may warn on stderr, only the exit status tells a failure
+		_, errout, err := exec.ExecuteOutput(nodeCtx.docker()+" ps -q", nil)
+		if err != nil {
+			r.set("docker", StatusFailed, "docker is not running: %s", strings.TrimSpace(errout+" "+err.Error()))
+		}
 	}
 
-	if errout != "" {
-		return nil, errors.New(errout)
+	r.OK = r.Err() == nil
+	if !r.OK {
+		return nil, r.Err()
 	}
-
-	if out == "" {
-		return nil, errors.New("docker is not running")
-	}
-
 	return nodeCtx, nil
 }
 
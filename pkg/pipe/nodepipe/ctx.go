@@ -1,18 +1,70 @@
 package nodepipe
 
 import (
+	"strings"
+	"sync"
+
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/benlocal/lai-panel/pkg/node"
+	"github.com/cloudwego/hertz/pkg/protocol/sse"
 )
 
 type NodeCtx struct {
 	Node  *model.Node
 	state *node.NodeState
+
+	// Bootstrap installs docker and the compose plugin when they are missing
+	Bootstrap bool
+	// Ports must be free on the node, the agent port is always checked
+	Ports []int
+	// DataPath is where the node keeps its data, the root is checked when
+	// it is empty
+	DataPath string
+
+	writer *sse.Writer
+	sendMu sync.Mutex
+
+	// out
+	Report *Report
 }
 
-func NewNodeCtx(node *model.Node, state *node.NodeState) *NodeCtx {
+// NewNodeCtx creates the context of the preflight of a node, writer may be
+// nil when the progress is not streamed.
+func NewNodeCtx(node *model.Node, state *node.NodeState, writer *sse.Writer) *NodeCtx {
 	return &NodeCtx{
-		Node:  node,
-		state: state,
+		Node:   node,
+		state:  state,
+		writer: writer,
+		Report: &Report{Checks: []*Check{}},
 	}
 }
+
+func (n *NodeCtx) Send(event string, data string) error {
+	if n.writer == nil {
+		return nil
+	}
+	n.sendMu.Lock()
+	defer n.sendMu.Unlock()
+	return n.writer.WriteEvent("", event, []byte(data))
+}
+
+// sudo wraps a shell script run as root.
+func (n *NodeCtx) sudo(script string) string {
+	if n.Report.Root {
+		return "sh -c " + shellQuote(script)
+	}
+	return "sudo -n sh -c " + shellQuote(script)
+}
+
+// docker is the docker command of the ssh user, through sudo when the user
+// is not in the docker group.
+func (n *NodeCtx) docker() string {
+	if n.Report.Root || n.Report.DockerGroup || !n.Report.Sudo {
+		return "docker"
+	}
+	return "sudo -n docker"
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
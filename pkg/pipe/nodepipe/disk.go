@@ -0,0 +1,95 @@
+package nodepipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// below minDiskFree the node can't take deployments
+	minDiskFree = 1 << 30
+	// below lowDiskFree the node is reported as running low
+	lowDiskFree = 10 << 30
+)
+
+// NodeDiskPipeline measures the free disk space of the data path, or of its
+// nearest existing parent when the path is not created yet.
+type NodeDiskPipeline struct {
+}
+
+func (p *NodeDiskPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
+	exec, err := nodeCtx.state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+
+	r := nodeCtx.Report
+	r.DataPath = nodeCtx.DataPath
+	dataPath := r.DataPath
+	if dataPath == "" {
+		dataPath = "/"
+	}
+
+	nodeCtx.Send("info", "checking disk space of "+dataPath)
+	command := "p=" + shellQuote(dataPath) + `
+while [ ! -e "$p" ]; do p=$(dirname "$p"); done
+df -Pk "$p" | tail -n 1`
+	out, errout, err := exec.ExecuteOutput(command, nil)
+	if err != nil {
+		r.set("disk", StatusWarning, "could not measure the disk space: %s", strings.TrimSpace(errout+" "+err.Error()))
+		return nodeCtx, nil
+	}
+	total, free, err := parseDf(out)
+	if err != nil {
+		r.set("disk", StatusWarning, "could not measure the disk space: %s", err.Error())
+		return nodeCtx, nil
+	}
+	r.DiskTotal, r.DiskFree = total, free
+
+	switch {
+	case free < minDiskFree:
+		r.set("disk", StatusFailed, "%s free of %s", formatBytes(free), formatBytes(total))
+	case free < lowDiskFree:
+		r.set("disk", StatusWarning, "%s free of %s", formatBytes(free), formatBytes(total))
+	default:
+		r.set("disk", StatusOK, "%s free of %s", formatBytes(free), formatBytes(total))
+	}
+	return nodeCtx, nil
+}
+
+func (p *NodeDiskPipeline) Cancel(nodeCtx *NodeCtx, err error) {
+	// do nothing
+}
+
+// parseDf parses the line of df -Pk, sizes are in 1024 byte blocks.
+func parseDf(out string) (total uint64, free uint64, err error) {
+	fields := strings.Fields(strings.TrimSpace(out))
+	if len(fields) < 4 {
+		return 0, 0, errors.New("unexpected df output")
+	}
+	total, err = strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	free, err = strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return total * 1024, free * 1024, nil
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,90 @@
+package nodepipe
+
+import (
+	"context"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// NodeDockerPipeline finds out the docker engine and compose versions of the
+// node.
+type NodeDockerPipeline struct {
+}
+
+func (p *NodeDockerPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
+	nodeCtx.Send("info", "checking docker")
+	if err := detectDocker(nodeCtx); err != nil {
+		return nil, err
+	}
+	return nodeCtx, nil
+}
+
+func (p *NodeDockerPipeline) Cancel(nodeCtx *NodeCtx, err error) {
+	// do nothing
+}
+
+// dockerCommand prints the docker client and server versions and the
+// compose version, an empty line stands for what is missing.
+func dockerCommand(docker string) string {
+	return `command -v docker >/dev/null 2>&1 && echo installed
+echo ` + sectionMarker + `
+` + docker + ` version --format '{{.Server.Version}}' 2>&1
+echo ` + sectionMarker + `
+` + docker + ` compose version --short 2>/dev/null || docker-compose version --short 2>/dev/null
+true`
+}
+
+func detectDocker(nodeCtx *NodeCtx) error {
+	exec, err := nodeCtx.state.GetExec()
+	if err != nil {
+		return err
+	}
+	out, _, err := exec.ExecuteOutput(dockerCommand(nodeCtx.docker()), nil)
+	if err != nil {
+		return err
+	}
+
+	r := nodeCtx.Report
+	installed, server, compose := parseDocker(out)
+	r.DockerVersion = ""
+	switch {
+	case !installed:
+		r.set("docker", StatusFailed, "docker is not installed")
+	case !isVersion(server):
+		r.set("docker", StatusFailed, "docker daemon is not reachable: %s", server)
+	default:
+		r.DockerVersion = server
+		r.set("docker", StatusOK, "docker %s", server)
+	}
+
+	r.ComposeVersion = compose
+	switch {
+	case compose != "":
+		r.set("compose", StatusOK, "compose %s", compose)
+	case nodeCtx.Node.ComposeBackend == model.ComposeBackendNative:
+		// the native backend drives the docker api without the compose cli
+		r.set("compose", StatusWarning, "compose is not installed")
+	default:
+		r.set("compose", StatusFailed, "compose is not installed")
+	}
+	return nil
+}
+
+func parseDocker(out string) (installed bool, server string, compose string) {
+	sections := splitSections(out)
+	for len(sections) < 3 {
+		sections = append(sections, nil)
+	}
+	installed = len(sections[0]) > 0 && sections[0][0] == "installed"
+	server = strings.Join(sections[1], " ")
+	if len(sections[2]) > 0 {
+		compose = strings.TrimPrefix(sections[2][0], "v")
+	}
+	return installed, server, compose
+}
+
+// isVersion tells a version from the error docker prints instead.
+func isVersion(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " :") && s[0] >= '0' && s[0] <= '9'
+}
@@ -0,0 +1,83 @@
+package nodepipe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSystem(t *testing.T) {
+	r := &Report{}
+	err := parseSystem(r, `Linux
+6.8.0-45-generic
+x86_64
+---
+PRETTY_NAME="Ubuntu 24.04.1 LTS"
+NAME="Ubuntu"
+VERSION_ID="24.04"
+ID=ubuntu
+ID_LIKE=debian
+---
+deploy
+1000
+deploy adm sudo
+nosudo
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Linux", r.OS)
+	assert.Equal(t, "x86_64", r.Arch)
+	assert.Equal(t, "ubuntu", r.Distro)
+	assert.Equal(t, "24.04", r.DistroVersion)
+	assert.Equal(t, []string{"debian"}, r.DistroLike)
+	assert.Equal(t, "deploy", r.User)
+	assert.False(t, r.Root)
+	assert.False(t, r.DockerGroup)
+	assert.False(t, r.Sudo)
+
+	r = &Report{}
+	assert.NoError(t, parseSystem(r, "Linux\n6.1\naarch64\n---\n---\nroot\n0\nroot\nnosudo\n"))
+	assert.True(t, r.Root)
+	assert.True(t, r.Sudo)
+
+	assert.Error(t, parseSystem(&Report{}, "Linux\n"))
+}
+
+func TestParseDocker(t *testing.T) {
+	installed, server, compose := parseDocker("installed\n---\n27.3.1\n---\nv2.29.7\n")
+	assert.True(t, installed)
+	assert.True(t, isVersion(server))
+	assert.Equal(t, "2.29.7", compose)
+
+	installed, server, _ = parseDocker("installed\n---\npermission denied while trying to connect to the Docker daemon socket\n---\n")
+	assert.True(t, installed)
+	assert.False(t, isVersion(server))
+
+	installed, _, compose = parseDocker("---\n\n---\n")
+	assert.False(t, installed)
+	assert.Equal(t, "", compose)
+}
+
+func TestBootstrapScript(t *testing.T) {
+	script, err := bootstrapScript("linuxmint", []string{"ubuntu", "debian"}, "deploy")
+	assert.NoError(t, err)
+	assert.Contains(t, script, "https://download.docker.com/linux/ubuntu")
+	assert.Contains(t, script, "docker-compose-plugin")
+	assert.True(t, strings.HasSuffix(script, "usermod -aG docker 'deploy'\n"))
+
+	script, err = bootstrapScript("rocky", []string{"rhel", "centos", "fedora"}, "")
+	assert.NoError(t, err)
+	assert.Contains(t, script, "linux/centos/docker-ce.repo")
+	assert.NotContains(t, script, "usermod")
+
+	_, err = bootstrapScript("plan9", nil, "")
+	assert.Error(t, err)
+}
+
+func TestParseDf(t *testing.T) {
+	total, free, err := parseDf("/dev/sda1  102400 51200 51200  50% /\n")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(102400*1024), total)
+	assert.Equal(t, uint64(51200*1024), free)
+	assert.Equal(t, "50.0 MiB", formatBytes(free))
+}
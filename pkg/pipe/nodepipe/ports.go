@@ -0,0 +1,71 @@
+package nodepipe
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/node"
+)
+
+// NodePortsPipeline checks the ports the node needs are free. The agent port
+// of a remote node is taken by the agent once it runs.
+type NodePortsPipeline struct {
+}
+
+func (p *NodePortsPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
+	ports := append([]int{}, nodeCtx.Ports...)
+	agentPort := 0
+	if !nodeCtx.Node.IsLocal && nodeCtx.Node.AgentPort > 0 {
+		agentPort = nodeCtx.Node.AgentPort
+		ports = append(ports, agentPort)
+	}
+	if len(ports) == 0 {
+		return nodeCtx, nil
+	}
+
+	exec, err := nodeCtx.state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+	nodeCtx.Send("info", "checking ports")
+	listeners, err := node.ListListeners(exec)
+	r := nodeCtx.Report
+	if err != nil || len(listeners) == 0 {
+		r.set("ports", StatusWarning, "could not list the listening ports")
+		return nodeCtx, nil
+	}
+
+	listening := map[int]bool{}
+	for _, l := range listeners {
+		if l.Protocol == "tcp" {
+			listening[l.Port] = true
+		}
+	}
+	sort.Ints(ports)
+	var used []string
+	seen := map[int]bool{}
+	for _, port := range ports {
+		if seen[port] {
+			continue
+		}
+		seen[port] = true
+		free := !listening[port]
+		r.Ports = append(r.Ports, PortCheck{Port: port, Free: free})
+		// an online agent listens on its own port
+		if !free && !(port == agentPort && nodeCtx.Node.Status == "online") {
+			used = append(used, strconv.Itoa(port))
+		}
+	}
+	if len(used) > 0 {
+		r.set("ports", StatusFailed, "ports in use: %s", strings.Join(used, ", "))
+	} else {
+		r.set("ports", StatusOK, "")
+	}
+	return nodeCtx, nil
+}
+
+func (p *NodePortsPipeline) Cancel(nodeCtx *NodeCtx, err error) {
+	// do nothing
+}
@@ -0,0 +1,94 @@
+package nodepipe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// status of a preflight check
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusFailed  = "failed"
+)
+
+// Check is the outcome of one preflight check.
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// PortCheck tells whether a port is free on the node.
+type PortCheck struct {
+	Port int  `json:"port"`
+	Free bool `json:"free"`
+}
+
+// Report is what the preflight found out about a node.
+type Report struct {
+	OS            string `json:"os"`
+	Kernel        string `json:"kernel"`
+	Arch          string `json:"arch"`
+	Distro        string `json:"distro"`
+	DistroVersion string `json:"distro_version"`
+	// DistroLike lists the distributions the distro derives from
+	DistroLike []string `json:"distro_like"`
+
+	DockerVersion  string `json:"docker_version"`
+	ComposeVersion string `json:"compose_version"`
+
+	User        string `json:"user"`
+	Root        bool   `json:"root"`
+	DockerGroup bool   `json:"docker_group"`
+	// Sudo is set when the user may run commands as root without a password
+	Sudo bool `json:"sudo"`
+
+	DataPath  string `json:"data_path"`
+	DiskTotal uint64 `json:"disk_total"`
+	DiskFree  uint64 `json:"disk_free"`
+
+	Ports []PortCheck `json:"ports"`
+	// Bootstrapped is set when docker was installed by the preflight
+	Bootstrapped bool `json:"bootstrapped"`
+
+	Checks []*Check `json:"checks"`
+	OK     bool     `json:"ok"`
+}
+
+// set records a check, replacing an earlier outcome of the same check.
+func (r *Report) set(name string, status string, format string, args ...interface{}) *Check {
+	c := &Check{Name: name, Status: status, Message: fmt.Sprintf(format, args...)}
+	for i, existing := range r.Checks {
+		if existing.Name == name {
+			r.Checks[i] = c
+			return c
+		}
+	}
+	r.Checks = append(r.Checks, c)
+	return c
+}
+
+// Failed returns the failed checks.
+func (r *Report) Failed() []*Check {
+	var failed []*Check
+	for _, c := range r.Checks {
+		if c.Status == StatusFailed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Err summarizes the failed checks, nil when there are none.
+func (r *Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	names := make([]string, len(failed))
+	for i, c := range failed {
+		names[i] = c.Name
+	}
+	return fmt.Errorf("preflight failed: %s", strings.Join(names, ", "))
+}
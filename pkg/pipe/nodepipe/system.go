@@ -0,0 +1,137 @@
+package nodepipe
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const sectionMarker = "---"
+
+// systemCommand prints the kernel, the distribution and the ssh user of the
+// node in sections split by sectionMarker.
+const systemCommand = `uname -s; uname -r; uname -m
+echo ` + sectionMarker + `
+cat /etc/os-release 2>/dev/null
+echo ` + sectionMarker + `
+id -un; id -u; id -nG
+if sudo -n true >/dev/null 2>&1; then echo sudo; else echo nosudo; fi`
+
+// NodeSystemPipeline finds out the os, the architecture and what the ssh
+// user is allowed to do on the node.
+type NodeSystemPipeline struct {
+}
+
+func (p *NodeSystemPipeline) Process(ctx context.Context, nodeCtx *NodeCtx) (*NodeCtx, error) {
+	if nodeCtx.Node.ID <= 0 {
+		return nil, errors.New("node is invalid")
+	}
+	exec, err := nodeCtx.state.GetExec()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeCtx.Send("info", "checking system")
+	out, errout, err := exec.ExecuteOutput(systemCommand, nil)
+	if err != nil {
+		return nil, errors.New(strings.TrimSpace(errout + " " + err.Error()))
+	}
+
+	r := nodeCtx.Report
+	if err := parseSystem(r, out); err != nil {
+		return nil, err
+	}
+
+	if r.OS != "Linux" {
+		r.set("os", StatusFailed, "%s is not supported", r.OS)
+	} else {
+		r.set("os", StatusOK, "%s %s %s (%s)", r.Distro, r.DistroVersion, r.Arch, r.Kernel)
+	}
+	switch {
+	case r.Root:
+		r.set("user", StatusOK, "%s is root", r.User)
+	case r.DockerGroup:
+		r.set("user", StatusOK, "%s is in the docker group", r.User)
+	case r.Sudo:
+		r.set("user", StatusWarning, "%s is not in the docker group, docker is run with sudo", r.User)
+	default:
+		r.set("user", StatusFailed, "%s is neither in the docker group nor allowed to sudo without a password", r.User)
+	}
+	return nodeCtx, nil
+}
+
+func (p *NodeSystemPipeline) Cancel(nodeCtx *NodeCtx, err error) {
+	// do nothing
+}
+
+func parseSystem(r *Report, out string) error {
+	sections := splitSections(out)
+	if len(sections) != 3 {
+		return errors.New("unexpected system command output")
+	}
+
+	uname := sections[0]
+	if len(uname) < 3 {
+		return errors.New("unexpected uname output")
+	}
+	r.OS, r.Kernel, r.Arch = uname[0], uname[1], uname[2]
+
+	release := parseOSRelease(sections[1])
+	r.Distro = release["ID"]
+	r.DistroVersion = release["VERSION_ID"]
+	r.DistroLike = strings.Fields(release["ID_LIKE"])
+
+	user := sections[2]
+	if len(user) < 4 {
+		return errors.New("unexpected id output")
+	}
+	r.User = user[0]
+	uid, err := strconv.Atoi(user[1])
+	if err != nil {
+		return err
+	}
+	r.Root = uid == 0
+	for _, group := range strings.Fields(user[2]) {
+		if group == "docker" {
+			r.DockerGroup = true
+		}
+	}
+	r.Sudo = r.Root || user[3] == "sudo"
+	return nil
+}
+
+// parseOSRelease parses the KEY=value lines of /etc/os-release.
+func parseOSRelease(lines []string) map[string]string {
+	values := map[string]string{}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values
+}
+
+// splitSections splits the trimmed lines of an output on sectionMarker.
+func splitSections(out string) [][]string {
+	sections := [][]string{{}}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == sectionMarker {
+			sections = append(sections, []string{})
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	return sections
+}
@@ -3,6 +3,7 @@ package pipe
 import (
 	"context"
 
+	"github.com/benlocal/lai-panel/pkg/pipe/agentpipe"
 	"github.com/benlocal/lai-panel/pkg/pipe/deploypipe"
 	"github.com/benlocal/lai-panel/pkg/pipe/nodepipe"
 	"github.com/deliveryhero/pipeline/v2"
@@ -13,7 +14,14 @@ type NodePipeline struct {
 }
 
 func NewNodePipeline() *NodePipeline {
-	p := pipeline.Sequence(&nodepipe.NodeCheckPipeline{})
+	p := pipeline.Sequence(
+		&nodepipe.NodeSystemPipeline{},
+		&nodepipe.NodeDockerPipeline{},
+		&nodepipe.NodeBootstrapPipeline{},
+		&nodepipe.NodeDiskPipeline{},
+		&nodepipe.NodePortsPipeline{},
+		&nodepipe.NodeCheckPipeline{},
+	)
 
 	return &NodePipeline{
 		Processor: p,
@@ -24,17 +32,71 @@ func (p *NodePipeline) Run(ctx context.Context, nodeCtx *nodepipe.NodeCtx) (*nod
 	return p.Processor.Process(ctx, nodeCtx)
 }
 
+type AgentPipeline struct {
+	installPipeline   pipeline.Processor[*agentpipe.AgentCtx, *agentpipe.AgentCtx]
+	uninstallPipeline pipeline.Processor[*agentpipe.AgentCtx, *agentpipe.AgentCtx]
+	upgradePipeline   pipeline.Processor[*agentpipe.AgentCtx, *agentpipe.AgentCtx]
+}
+
+func NewAgentPipeline() *AgentPipeline {
+	install := pipeline.Sequence(
+		&agentpipe.AgentDetectPipeline{},
+		&agentpipe.AgentUploadPipeline{},
+		&agentpipe.AgentVerifyPipeline{},
+		&agentpipe.AgentInstallPipeline{},
+		&agentpipe.AgentWaitPipeline{},
+	)
+
+	upgrade := pipeline.Sequence(
+		&agentpipe.AgentDetectPipeline{},
+		&agentpipe.AgentUploadPipeline{},
+		&agentpipe.AgentVerifyPipeline{},
+		&agentpipe.AgentSwapPipeline{},
+		&agentpipe.AgentWaitPipeline{},
+	)
+
+	uninstall := pipeline.Sequence(
+		&agentpipe.AgentDetectPipeline{},
+		&agentpipe.AgentUninstallPipeline{},
+	)
+
+	return &AgentPipeline{
+		installPipeline:   install,
+		uninstallPipeline: uninstall,
+		upgradePipeline:   upgrade,
+	}
+}
+
+// Install installs or reinstalls the agent, as the action of agentCtx says.
+func (p *AgentPipeline) Install(ctx context.Context, agentCtx *agentpipe.AgentCtx) (*agentpipe.AgentCtx, error) {
+	return p.installPipeline.Process(ctx, agentCtx)
+}
+
+// Upgrade swaps the agent binary, the previous one is restored when the
+// upgraded agent does not register in time.
+func (p *AgentPipeline) Upgrade(ctx context.Context, agentCtx *agentpipe.AgentCtx) (*agentpipe.AgentCtx, error) {
+	return p.upgradePipeline.Process(ctx, agentCtx)
+}
+
+func (p *AgentPipeline) Uninstall(ctx context.Context, agentCtx *agentpipe.AgentCtx) (*agentpipe.AgentCtx, error) {
+	return p.uninstallPipeline.Process(ctx, agentCtx)
+}
+
 type DeployPipeline struct {
-	upPipeline   pipeline.Processor[*deploypipe.DeployCtx, *deploypipe.DeployCtx]
-	downPipeline pipeline.Processor[*deploypipe.DownCtx, *deploypipe.DownCtx]
+	upPipeline      pipeline.Processor[*deploypipe.DeployCtx, *deploypipe.DeployCtx]
+	downPipeline    pipeline.Processor[*deploypipe.DownCtx, *deploypipe.DownCtx]
+	restartPipeline pipeline.Processor[*deploypipe.DownCtx, *deploypipe.DownCtx]
 }
 
 func NewDeployPipeline() *DeployPipeline {
 	up := pipeline.Sequence(
+		&deploypipe.QAValuesPipeline{},
 		&deploypipe.CleanupWorkspacePipeline{},
 		&deploypipe.CopyWorkspacePipeline{},
 		&deploypipe.DownloadInstallerPipeline{},
 		&deploypipe.DockerComposeFileParsePipeline{},
+		&deploypipe.DockerComposeValidatePipeline{},
+		&deploypipe.PullImagePipeline{},
 		&deploypipe.LoadImagePipeline{},
 		&deploypipe.DockerComposeUpPipeline{},
 	)
@@ -43,9 +105,14 @@ func NewDeployPipeline() *DeployPipeline {
 		&deploypipe.DockerComposeDownPipeline{},
 	)
 
+	restart := pipeline.Sequence(
+		&deploypipe.DockerComposeRestartPipeline{},
+	)
+
 	return &DeployPipeline{
-		upPipeline:   up,
-		downPipeline: down,
+		upPipeline:      up,
+		downPipeline:    down,
+		restartPipeline: restart,
 	}
 }
 
@@ -56,3 +123,7 @@ func (p *DeployPipeline) Up(ctx context.Context, deployCtx *deploypipe.DeployCtx
 func (p *DeployPipeline) Down(ctx context.Context, downCtx *deploypipe.DownCtx) (*deploypipe.DownCtx, error) {
 	return p.downPipeline.Process(ctx, downCtx)
 }
+
+func (p *DeployPipeline) Restart(ctx context.Context, downCtx *deploypipe.DownCtx) (*deploypipe.DownCtx, error) {
+	return p.restartPipeline.Process(ctx, downCtx)
+}
@@ -0,0 +1,276 @@
+package qa
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// QA item types
+const (
+	TypeString   = "string"
+	TypeText     = "text"
+	TypeInt      = "int"
+	TypeBool     = "bool"
+	TypeEnum     = "enum"
+	TypePassword = "password"
+	TypePort     = "port"
+	TypeRegex    = "regex"
+)
+
+// FieldError is a problem with the value or the definition of one item.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError collects the problems of all items.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fe.Error())
+	}
+	return "invalid parameters: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field string, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) errOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateSchema checks the QA definition of an app: names, types, bounds,
+// patterns, show_if conditions and default values.
+func ValidateSchema(items []*model.AppQAItem) error {
+	verr := &ValidationError{}
+	names := map[string]bool{}
+	for _, item := range items {
+		if item.Name == "" {
+			verr.add("", "item name is required")
+			continue
+		}
+		if names[item.Name] {
+			verr.add(item.Name, "duplicate item")
+		}
+		names[item.Name] = true
+	}
+
+	for _, item := range items {
+		if item.Name == "" {
+			continue
+		}
+		switch typeOf(item) {
+		case TypeString, TypeText, TypeInt, TypeBool, TypePassword, TypePort:
+		case TypeEnum:
+			if len(item.Options) == 0 {
+				verr.add(item.Name, "enum item requires options")
+			}
+		case TypeRegex:
+			if item.Pattern == "" {
+				verr.add(item.Name, "regex item requires a pattern")
+			} else if _, err := regexp.Compile(item.Pattern); err != nil {
+				verr.add(item.Name, "invalid pattern: %v", err)
+			}
+		default:
+			verr.add(item.Name, "unknown type %q", item.Type)
+			continue
+		}
+		if item.Min != nil && item.Max != nil && *item.Min > *item.Max {
+			verr.add(item.Name, "min is greater than max")
+		}
+
+		conditions, err := parseShowIf(item.ShowIf)
+		if err != nil {
+			verr.add(item.Name, "invalid show_if: %v", err)
+		}
+		for _, cond := range conditions {
+			if !names[cond.field] {
+				verr.add(item.Name, "show_if refers to unknown item %q", cond.field)
+			}
+		}
+
+		if item.DefaultValue != "" {
+			if _, err := normalize(item, item.DefaultValue); err != nil {
+				verr.add(item.Name, "invalid default value: %v", err)
+			}
+		}
+	}
+	return verr.errOrNil()
+}
+
+// Apply merges values with the defaults of the items and validates them.
+// Items hidden by show_if are neither required nor validated. The result
+// holds normalized values, e.g. bool values are "true" or "false", and
+// values without an item are kept as is.
+func Apply(items []*model.AppQAItem, values map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(items)+len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+	for _, item := range items {
+		if v, ok := result[item.Name]; !ok || v == "" {
+			result[item.Name] = item.DefaultValue
+		}
+	}
+
+	verr := &ValidationError{}
+	for _, item := range items {
+		visible, err := Visible(item, result)
+		if err != nil {
+			verr.add(item.Name, "invalid show_if: %v", err)
+			continue
+		}
+		if !visible {
+			continue
+		}
+
+		value := result[item.Name]
+		if value == "" {
+			if item.Required {
+				verr.add(item.Name, "value is required")
+			}
+			continue
+		}
+
+		normalized, err := normalize(item, value)
+		if err != nil {
+			verr.add(item.Name, "%v", err)
+			continue
+		}
+		result[item.Name] = normalized
+	}
+	if err := verr.errOrNil(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Defaults returns the default values of the items.
+func Defaults(items []*model.AppQAItem) map[string]string {
+	defaults := make(map[string]string, len(items))
+	for _, item := range items {
+		defaults[item.Name] = item.DefaultValue
+	}
+	return defaults
+}
+
+// Visible evaluates the show_if condition of an item against values.
+func Visible(item *model.AppQAItem, values map[string]string) (bool, error) {
+	conditions, err := parseShowIf(item.ShowIf)
+	if err != nil {
+		return false, err
+	}
+	for _, cond := range conditions {
+		if (values[cond.field] == cond.value) == cond.negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func typeOf(item *model.AppQAItem) string {
+	if item.Type == "" {
+		return TypeString
+	}
+	return strings.ToLower(item.Type)
+}
+
+// normalize validates a value against its item and returns the canonical form.
+func normalize(item *model.AppQAItem, value string) (string, error) {
+	switch typeOf(item) {
+	case TypeInt, TypePort:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+		if typeOf(item) == TypePort && (n < 1 || n > 65535) {
+			return "", fmt.Errorf("port %d is out of range 1-65535", n)
+		}
+		if item.Min != nil && n < *item.Min {
+			return "", fmt.Errorf("value must be at least %d", *item.Min)
+		}
+		if item.Max != nil && n > *item.Max {
+			return "", fmt.Errorf("value must be at most %d", *item.Max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case TypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		return strconv.FormatBool(b), nil
+	case TypeEnum:
+		for _, option := range item.Options {
+			if option == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", value, strings.Join(item.Options, ", "))
+	case TypeRegex:
+		re, err := regexp.Compile(item.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern: %v", err)
+		}
+		if !re.MatchString(value) {
+			return "", fmt.Errorf("%q does not match %s", value, item.Pattern)
+		}
+	}
+
+	length := int64(utf8.RuneCountInString(value))
+	if item.Min != nil && length < *item.Min {
+		return "", fmt.Errorf("value must be at least %d characters", *item.Min)
+	}
+	if item.Max != nil && length > *item.Max {
+		return "", fmt.Errorf("value must be at most %d characters", *item.Max)
+	}
+	return value, nil
+}
+
+type condition struct {
+	field  string
+	value  string
+	negate bool
+}
+
+// parseShowIf parses conditions like "a=1&&b!=2".
+func parseShowIf(showIf string) ([]condition, error) {
+	if strings.TrimSpace(showIf) == "" {
+		return nil, nil
+	}
+
+	conditions := []condition{}
+	for _, part := range strings.Split(showIf, "&&") {
+		part = strings.TrimSpace(part)
+		cond := condition{}
+		field, value, ok := strings.Cut(part, "!=")
+		if ok {
+			cond.negate = true
+		} else {
+			field, value, ok = strings.Cut(part, "=")
+		}
+		if !ok || strings.TrimSpace(field) == "" {
+			return nil, fmt.Errorf("condition %q must be name=value or name!=value", part)
+		}
+		cond.field = strings.TrimSpace(field)
+		cond.value = strings.TrimSpace(value)
+		conditions = append(conditions, cond)
+	}
+	return conditions, nil
+}
@@ -0,0 +1,169 @@
+package qa
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func testItems() []*model.AppQAItem {
+	return []*model.AppQAItem{
+		{Name: "HTTP_PORT", Type: TypePort, DefaultValue: "8080", Required: true},
+		{Name: "WORKERS", Type: TypeInt, DefaultValue: "2", Min: int64Ptr(1), Max: int64Ptr(16)},
+		{Name: "DB_TYPE", Type: TypeEnum, DefaultValue: "sqlite", Options: []string{"sqlite", "mysql"}},
+		{Name: "DB_PASSWORD", Type: TypePassword, Required: true, Min: int64Ptr(8), ShowIf: "DB_TYPE=mysql"},
+		{Name: "DEBUG", Type: TypeBool, DefaultValue: "0"},
+		{Name: "DOMAIN", Type: TypeRegex, Pattern: `^[a-z0-9.-]+$`},
+	}
+}
+
+func TestApply_Defaults(t *testing.T) {
+	values, err := Apply(testItems(), map[string]string{"EXTRA": "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HTTP_PORT":   "8080",
+		"WORKERS":     "2",
+		"DB_TYPE":     "sqlite",
+		"DB_PASSWORD": "",
+		"DEBUG":       "false",
+		"DOMAIN":      "",
+		"EXTRA":       "x",
+	}, values)
+}
+
+func TestApply_FieldErrors(t *testing.T) {
+	_, err := Apply(testItems(), map[string]string{
+		"HTTP_PORT":   "70000",
+		"WORKERS":     "32",
+		"DB_TYPE":     "mysql",
+		"DB_PASSWORD": "short",
+		"DEBUG":       "maybe",
+		"DOMAIN":      "Example.com",
+	})
+
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, []FieldError{
+		{Field: "HTTP_PORT", Message: "port 70000 is out of range 1-65535"},
+		{Field: "WORKERS", Message: "value must be at most 16"},
+		{Field: "DB_PASSWORD", Message: "value must be at least 8 characters"},
+		{Field: "DEBUG", Message: `"maybe" is not a boolean`},
+		{Field: "DOMAIN", Message: `"Example.com" does not match ^[a-z0-9.-]+$`},
+	}, verr.Errors)
+}
+
+func TestApply_ShowIf(t *testing.T) {
+	_, err := Apply(testItems(), map[string]string{"DB_TYPE": "mysql"})
+	assert.EqualError(t, err, "invalid parameters: DB_PASSWORD: value is required")
+
+	values, err := Apply(testItems(), map[string]string{"DB_TYPE": "mysql", "DB_PASSWORD": "secret-password"})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-password", values["DB_PASSWORD"])
+}
+
+func TestVisible(t *testing.T) {
+	item := &model.AppQAItem{Name: "TLS_CERT", ShowIf: "TLS=true && MODE != dev"}
+
+	visible, err := Visible(item, map[string]string{"TLS": "true", "MODE": "prod"})
+	assert.NoError(t, err)
+	assert.True(t, visible)
+
+	visible, err = Visible(item, map[string]string{"TLS": "true", "MODE": "dev"})
+	assert.NoError(t, err)
+	assert.False(t, visible)
+
+	_, err = Visible(&model.AppQAItem{ShowIf: "TLS"}, nil)
+	assert.Error(t, err)
+}
+
+func TestValidateSchema(t *testing.T) {
+	assert.NoError(t, ValidateSchema(testItems()))
+
+	err := ValidateSchema([]*model.AppQAItem{
+		{Name: "A", Type: "float"},
+		{Name: "B", Type: TypeEnum},
+		{Name: "C", Type: TypeRegex, Pattern: "("},
+		{Name: "D", Type: TypeInt, DefaultValue: "abc", Min: int64Ptr(5), Max: int64Ptr(1)},
+		{Name: "E", ShowIf: "MISSING=1"},
+		{Name: "E"},
+	})
+
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	fields := []string{}
+	for _, fe := range verr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	assert.Equal(t, []string{"E", "A", "B", "C", "D", "D", "E"}, fields)
+}
+
+func upgradedItems() []*model.AppQAItem {
+	return []*model.AppQAItem{
+		// default changed
+		{Name: "HTTP_PORT", Type: TypePort, DefaultValue: "80", Required: true},
+		// range narrowed
+		{Name: "WORKERS", Type: TypeInt, DefaultValue: "2", Min: int64Ptr(1), Max: int64Ptr(4)},
+		// option added
+		{Name: "DB_TYPE", Type: TypeEnum, DefaultValue: "sqlite", Options: []string{"sqlite", "mysql", "postgres"}},
+		{Name: "DB_PASSWORD", Type: TypePassword, Required: true, Min: int64Ptr(8), ShowIf: "DB_TYPE=mysql"},
+		{Name: "DEBUG", Type: TypeBool, DefaultValue: "0"},
+		// DOMAIN removed, TIMEZONE added
+		{Name: "TIMEZONE", Type: TypeString, DefaultValue: "UTC"},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changes := Diff(testItems(), upgradedItems())
+	summary := map[string]string{}
+	for _, change := range changes {
+		summary[change.Name] = change.Kind
+	}
+	assert.Equal(t, map[string]string{
+		"HTTP_PORT": ChangeChanged,
+		"WORKERS":   ChangeChanged,
+		"DB_TYPE":   ChangeChanged,
+		"DOMAIN":    ChangeRemoved,
+		"TIMEZONE":  ChangeAdded,
+	}, summary)
+	assert.Equal(t, "DB_TYPE", changes[0].Name)
+	assert.Equal(t, []string{"options"}, changes[0].Fields)
+	assert.Empty(t, Diff(testItems(), testItems()))
+}
+
+func TestMigrate(t *testing.T) {
+	values := map[string]string{
+		"HTTP_PORT":   "8080", // old default, follows the new default
+		"WORKERS":     "8",    // out of the new range, reset
+		"DB_TYPE":     "mysql",
+		"DB_PASSWORD": "secret-password",
+		"DEBUG":       "true",
+		"DOMAIN":      "example.com",
+	}
+	m, err := Migrate(testItems(), upgradedItems(), values, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HTTP_PORT":   "80",
+		"WORKERS":     "2",
+		"DB_TYPE":     "mysql",
+		"DB_PASSWORD": "secret-password",
+		"DEBUG":       "true",
+		"TIMEZONE":    "UTC",
+	}, m.Values)
+	assert.Equal(t, []string{"HTTP_PORT", "TIMEZONE", "WORKERS"}, m.Defaulted)
+	assert.Equal(t, []string{"DOMAIN"}, m.Dropped)
+
+	m, err = Migrate(testItems(), upgradedItems(), values, map[string]string{"WORKERS": "3", "HTTP_PORT": "8080"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", m.Values["WORKERS"])
+	assert.Equal(t, "8080", m.Values["HTTP_PORT"])
+
+	_, err = Migrate(testItems(), upgradedItems(), values, map[string]string{"WORKERS": "9"})
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+}
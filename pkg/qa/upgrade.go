@@ -0,0 +1,151 @@
+package qa
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// kinds of Change
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is the difference of one item between two versions of an app.
+type Change struct {
+	Name string           `json:"name"`
+	Kind string           `json:"kind"`
+	From *model.AppQAItem `json:"from,omitempty"`
+	To   *model.AppQAItem `json:"to,omitempty"`
+	// Fields lists the changed attributes of a changed item
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Diff returns the items added, removed and changed from one version to the
+// other, sorted by name.
+func Diff(from, to []*model.AppQAItem) []Change {
+	old := make(map[string]*model.AppQAItem, len(from))
+	for _, item := range from {
+		old[item.Name] = item
+	}
+
+	changes := []Change{}
+	seen := map[string]bool{}
+	for _, item := range to {
+		seen[item.Name] = true
+		prev, ok := old[item.Name]
+		if !ok {
+			changes = append(changes, Change{Name: item.Name, Kind: ChangeAdded, To: item})
+			continue
+		}
+		if fields := changedFields(prev, item); len(fields) > 0 {
+			changes = append(changes, Change{Name: item.Name, Kind: ChangeChanged, From: prev, To: item, Fields: fields})
+		}
+	}
+	for _, item := range from {
+		if !seen[item.Name] {
+			changes = append(changes, Change{Name: item.Name, Kind: ChangeRemoved, From: item})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func changedFields(a, b *model.AppQAItem) []string {
+	var fields []string
+	if typeOf(a) != typeOf(b) {
+		fields = append(fields, "type")
+	}
+	if a.DefaultValue != b.DefaultValue {
+		fields = append(fields, "default_value")
+	}
+	if !reflect.DeepEqual(a.Options, b.Options) && (len(a.Options) > 0 || len(b.Options) > 0) {
+		fields = append(fields, "options")
+	}
+	if a.Required != b.Required {
+		fields = append(fields, "required")
+	}
+	if !reflect.DeepEqual(a.Min, b.Min) {
+		fields = append(fields, "min")
+	}
+	if !reflect.DeepEqual(a.Max, b.Max) {
+		fields = append(fields, "max")
+	}
+	if a.Pattern != b.Pattern {
+		fields = append(fields, "pattern")
+	}
+	if a.ShowIf != b.ShowIf {
+		fields = append(fields, "show_if")
+	}
+	return fields
+}
+
+// Migration is the outcome of carrying the values of a service over to
+// another version of its app.
+type Migration struct {
+	Values map[string]string `json:"values"`
+	// Defaulted items took the default of the new version, because they
+	// are new, kept the old default or the old value is no longer valid
+	Defaulted []string `json:"defaulted"`
+	// Dropped items no longer exist in the new version
+	Dropped []string `json:"dropped"`
+}
+
+// Migrate carries values over from the items of one version to the items of
+// another. Values still valid for the new item are kept, values left at the
+// old default follow the new default, invalid values are reset to the new
+// default. overrides are applied last and the result is validated with Apply.
+func Migrate(from, to []*model.AppQAItem, values, overrides map[string]string) (*Migration, error) {
+	old := make(map[string]*model.AppQAItem, len(from))
+	for _, item := range from {
+		old[item.Name] = item
+	}
+
+	m := &Migration{Values: map[string]string{}, Defaulted: []string{}, Dropped: []string{}}
+	seen := map[string]bool{}
+	for _, item := range to {
+		seen[item.Name] = true
+		value, ok := values[item.Name]
+		prev, existed := old[item.Name]
+		switch {
+		case !ok || !existed:
+			m.Values[item.Name] = item.DefaultValue
+			m.Defaulted = append(m.Defaulted, item.Name)
+		case value == prev.DefaultValue && value != item.DefaultValue:
+			m.Values[item.Name] = item.DefaultValue
+			m.Defaulted = append(m.Defaulted, item.Name)
+		case value == "":
+			m.Values[item.Name] = value
+		default:
+			if _, err := normalize(item, value); err != nil {
+				m.Values[item.Name] = item.DefaultValue
+				m.Defaulted = append(m.Defaulted, item.Name)
+				continue
+			}
+			m.Values[item.Name] = value
+		}
+	}
+	for _, item := range from {
+		if !seen[item.Name] {
+			m.Dropped = append(m.Dropped, item.Name)
+		}
+	}
+	sort.Strings(m.Defaulted)
+	sort.Strings(m.Dropped)
+
+	for k, v := range overrides {
+		m.Values[k] = v
+	}
+	applied, err := Apply(to, m.Values)
+	if err != nil {
+		return m, err
+	}
+	m.Values = applied
+	return m, nil
+}
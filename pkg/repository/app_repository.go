@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"database/sql"
+
 	"github.com/benlocal/lai-panel/pkg/database"
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/jmoiron/sqlx"
@@ -96,3 +98,36 @@ func (r *AppRepository) Delete(id int64) error {
 	_, err := r.db.Exec(query, id)
 	return err
 }
+
+func (r *AppRepository) GetByName(name string) (*model.App, error) {
+	query := `SELECT * FROM apps WHERE name = ?`
+	var app model.App
+	err := r.db.Get(&app, query, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &app, nil
+}
+
+// UpdateCatalog records the catalog source and commit an app was synchronized from.
+func (r *AppRepository) UpdateCatalog(id int64, sourceID int64, commit string) error {
+	query := `UPDATE apps SET catalog_source_id = ?, catalog_commit = ? WHERE id = ?`
+	_, err := r.db.Exec(query, sourceID, commit, id)
+	return err
+}
+
+func (r *AppRepository) ListByCatalogSource(sourceID int64) ([]model.App, error) {
+	query := `SELECT * FROM apps WHERE catalog_source_id = ? ORDER BY name`
+	var apps []model.App
+	err := r.db.Select(&apps, query, sourceID)
+	return apps, err
+}
+
+func (r *AppRepository) Count() (int, error) {
+	var total int
+	err := r.db.Get(&total, "SELECT COUNT(*) FROM apps")
+	return total, err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// AppVersionRepository stores the versions of apps, a version is never
+// updated once created.
+type AppVersionRepository struct {
+	db *sqlx.DB
+}
+
+func NewAppVersionRepository() *AppVersionRepository {
+	return &AppVersionRepository{db: database.GetDB()}
+}
+
+func (r *AppVersionRepository) Create(version *model.AppVersion) error {
+	query := `INSERT INTO app_versions (app_id, version, docker_compose, qa, metadata, static_path)
+	VALUES (:app_id, :version, :docker_compose, :qa, :metadata, :static_path)`
+	result, err := r.db.NamedExec(query, version)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	version.ID = id
+	return nil
+}
+
+func (r *AppVersionRepository) GetByID(id int64) (*model.AppVersion, error) {
+	var version model.AppVersion
+	err := r.db.Get(&version, "SELECT * FROM app_versions WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *AppVersionRepository) GetByAppAndVersion(appID int64, version string) (*model.AppVersion, error) {
+	var v model.AppVersion
+	err := r.db.Get(&v, "SELECT * FROM app_versions WHERE app_id = ? AND version = ?", appID, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *AppVersionRepository) ListByApp(appID int64) ([]model.AppVersion, error) {
+	var versions []model.AppVersion
+	err := r.db.Select(&versions, "SELECT * FROM app_versions WHERE app_id = ? ORDER BY created_at DESC, id DESC", appID)
+	return versions, err
+}
+
+func (r *AppVersionRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM app_versions WHERE id = ?", id)
+	return err
+}
+
+func (r *AppVersionRepository) DeleteByApp(appID int64) error {
+	_, err := r.db.Exec("DELETE FROM app_versions WHERE app_id = ?", appID)
+	return err
+}
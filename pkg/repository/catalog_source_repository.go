@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type CatalogSourceRepository struct {
+	db *sqlx.DB
+}
+
+func NewCatalogSourceRepository() *CatalogSourceRepository {
+	return &CatalogSourceRepository{db: database.GetDB()}
+}
+
+func (r *CatalogSourceRepository) Create(source *model.CatalogSource) error {
+	query := `INSERT INTO catalog_sources (name, kind, url, branch)
+	VALUES (:name, :kind, :url, :branch)`
+	result, err := r.db.NamedExec(query, source)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	source.ID = id
+	return nil
+}
+
+func (r *CatalogSourceRepository) Update(source *model.CatalogSource) error {
+	query := `UPDATE catalog_sources SET name = :name,
+		kind = :kind,
+		url = :url,
+		branch = :branch,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = :id`
+	_, err := r.db.NamedExec(query, source)
+	return err
+}
+
+// UpdateSyncState records the outcome of a sync.
+func (r *CatalogSourceRepository) UpdateSyncState(id int64, commit string, syncErr error) error {
+	lastError := ""
+	if syncErr != nil {
+		lastError = syncErr.Error()
+	}
+	query := `UPDATE catalog_sources SET last_commit = ?,
+		last_synced_at = ?,
+		last_error = ?
+	WHERE id = ?`
+	_, err := r.db.Exec(query, commit, time.Now(), lastError, id)
+	return err
+}
+
+func (r *CatalogSourceRepository) GetByID(id int64) (*model.CatalogSource, error) {
+	var source model.CatalogSource
+	err := r.db.Get(&source, "SELECT * FROM catalog_sources WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &source, nil
+}
+
+func (r *CatalogSourceRepository) List() ([]model.CatalogSource, error) {
+	var sources []model.CatalogSource
+	err := r.db.Select(&sources, "SELECT * FROM catalog_sources ORDER BY name")
+	return sources, err
+}
+
+func (r *CatalogSourceRepository) Delete(id int64) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// apps stay, they are no longer tracked by the catalog
+	if _, err := tx.Exec("UPDATE apps SET catalog_source_id = NULL, catalog_commit = NULL WHERE catalog_source_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM catalog_sources WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"database/sql"
+	"fmt"
+	"sort"
+
 	"github.com/benlocal/lai-panel/pkg/database"
 	"github.com/benlocal/lai-panel/pkg/model"
 	"github.com/jmoiron/sqlx"
@@ -15,7 +19,8 @@ func NewEnvRepository() *EnvRepository {
 }
 
 func (r *EnvRepository) Create(env *model.Env) error {
-	query := `INSERT INTO env (key, value, scope, description, metadata) VALUES (:key, :value, :scope, :description, :metadata)`
+	query := `INSERT INTO env (key, value, scope, description, metadata, is_secret)
+	 VALUES (:key, :value, :scope, :description, :metadata, :is_secret)`
 	result, err := r.db.NamedExec(query, env)
 	if err != nil {
 		return err
@@ -29,19 +34,25 @@ func (r *EnvRepository) Create(env *model.Env) error {
 }
 
 func (r *EnvRepository) Update(env *model.Env) error {
-	query := `UPDATE env SET key = :key, value = :value, scope = :scope, description = :description WHERE id = :id`
+	query := `UPDATE env SET key = :key, value = :value, scope = :scope, description = :description,
+	 is_secret = :is_secret, updated_at = CURRENT_TIMESTAMP WHERE id = :id`
 	_, err := r.db.NamedExec(query, env)
 	return err
 }
 
-func (r *EnvRepository) GetByKey(key string) (*model.Env, error) {
+// ListByKey returns the values of a key in all scopes.
+func (r *EnvRepository) ListByKey(key string) ([]model.Env, error) {
 	query := `SELECT * FROM env WHERE key = ?`
-	var env model.Env
-	err := r.db.Get(&env, query, key)
-	if err != nil {
-		return nil, err
-	}
-	return &env, nil
+	var envs []model.Env
+	err := r.db.Select(&envs, query, key)
+	return envs, err
+}
+
+func (r *EnvRepository) List() ([]model.Env, error) {
+	query := `SELECT * FROM env ORDER BY key, scope`
+	var envs []model.Env
+	err := r.db.Select(&envs, query)
+	return envs, err
 }
 
 func (r *EnvRepository) GetScopes() ([]string, error) {
@@ -99,3 +110,78 @@ func (r *EnvRepository) GetPage(scope string, page int, pageSize int) (int, []mo
 	err = r.db.Select(&lst, sql, p...)
 	return total, lst, err
 }
+
+func (r *EnvRepository) ListByScope(scope string) ([]model.Env, error) {
+	query := `SELECT * FROM env WHERE scope = ? ORDER BY key`
+	var envs []model.Env
+	err := r.db.Select(&envs, query, scope)
+	return envs, err
+}
+
+// conflict strategies of Import
+const (
+	EnvImportSkip      = "skip"
+	EnvImportOverwrite = "overwrite"
+	EnvImportFail      = "fail"
+)
+
+type EnvImportResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// Import writes values into a scope in a single transaction, strategy decides
+// what happens to keys which already exist in the scope. New keys are marked
+// as secret when secret is set, existing keys keep their flag.
+func (r *EnvRepository) Import(scope string, values map[string]string, strategy string, secret bool) (*EnvImportResult, error) {
+	switch strategy {
+	case EnvImportSkip, EnvImportOverwrite, EnvImportFail:
+	default:
+		return nil, fmt.Errorf("invalid conflict strategy %q", strategy)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &EnvImportResult{Created: []string{}, Updated: []string{}, Skipped: []string{}}
+	for _, key := range keys {
+		var id int64
+		err := tx.Get(&id, `SELECT id FROM env WHERE key = ? AND scope = ?`, key, scope)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.Exec(`INSERT INTO env (key, value, scope, description, metadata, is_secret)
+			 VALUES (?, ?, ?, '', '', ?)`, key, values[key], scope, secret)
+			if err != nil {
+				return nil, err
+			}
+			result.Created = append(result.Created, key)
+		case err != nil:
+			return nil, err
+		case strategy == EnvImportFail:
+			return nil, fmt.Errorf("variable %s already exists in scope %q", key, scope)
+		case strategy == EnvImportSkip:
+			result.Skipped = append(result.Skipped, key)
+		default:
+			_, err = tx.Exec(`UPDATE env SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, values[key], id)
+			if err != nil {
+				return nil, err
+			}
+			result.Updated = append(result.Updated, key)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
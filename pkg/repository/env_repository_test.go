@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"path"
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvImport(t *testing.T) {
+	assert.NoError(t, database.InitDB(path.Join(t.TempDir(), "db.sqlite")))
+	defer database.CloseDB()
+	r := NewEnvRepository()
+
+	assert.NoError(t, r.Create(&model.Env{Key: "HOST", Value: "old", Scope: "prod"}))
+	result, err := r.Import("prod", map[string]string{"HOST": "new", "PORT": "80"}, EnvImportOverwrite, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PORT"}, result.Created)
+	assert.Equal(t, []string{"HOST"}, result.Updated)
+
+	envs, err := r.List()
+	assert.NoError(t, err)
+	if assert.Len(t, envs, 2) {
+		assert.Equal(t, "HOST", envs[0].Key)
+		assert.Equal(t, "new", envs[0].Value)
+		assert.False(t, envs[0].IsSecret)
+		assert.Equal(t, "PORT", envs[1].Key)
+		assert.True(t, envs[1].IsSecret)
+		assert.Empty(t, envs[1].Description)
+	}
+
+	envs, err = r.ListByKey("PORT")
+	assert.NoError(t, err)
+	assert.Len(t, envs, 1)
+
+	_, err = r.Import("prod", map[string]string{"PORT": "81"}, EnvImportFail, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type ImageRegistryRepository struct {
+	db *sqlx.DB
+}
+
+func NewImageRegistryRepository() *ImageRegistryRepository {
+	return &ImageRegistryRepository{db: database.GetDB()}
+}
+
+func (r *ImageRegistryRepository) Create(registry *model.ImageRegistry) error {
+	query := `INSERT INTO image_registries (name, host, username, password, metadata)
+	VALUES (:name, :host, :username, :password, :metadata)`
+	result, err := r.db.NamedExec(query, registry)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	registry.ID = id
+	return nil
+}
+
+func (r *ImageRegistryRepository) Update(registry *model.ImageRegistry) error {
+	query := `UPDATE image_registries SET name = :name,
+	 host = :host,
+	 username = :username,
+	 updated_at = CURRENT_TIMESTAMP`
+
+	if registry.Password != "" {
+		query += `, password = :password`
+	}
+
+	query += ` WHERE id = :id`
+	_, err := r.db.NamedExec(query, registry)
+	return err
+}
+
+func (r *ImageRegistryRepository) GetByID(id int64) (*model.ImageRegistry, error) {
+	var registry model.ImageRegistry
+	err := r.db.Get(&registry, "SELECT * FROM image_registries WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+func (r *ImageRegistryRepository) GetByHost(host string) (*model.ImageRegistry, error) {
+	var registry model.ImageRegistry
+	err := r.db.Get(&registry, "SELECT * FROM image_registries WHERE host = ?", host)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &registry, nil
+}
+
+func (r *ImageRegistryRepository) List() ([]model.ImageRegistry, error) {
+	var registries []model.ImageRegistry
+	err := r.db.Select(&registries, "SELECT * FROM image_registries ORDER BY created_at DESC")
+	return registries, err
+}
+
+func (r *ImageRegistryRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM image_registries WHERE id = ?", id)
+	return err
+}
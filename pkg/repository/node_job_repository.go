@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type NodeJobRepository struct {
+	db *sqlx.DB
+}
+
+func NewNodeJobRepository() *NodeJobRepository {
+	return &NodeJobRepository{db: database.GetDB()}
+}
+
+func (r *NodeJobRepository) Create(job *model.NodeJob) error {
+	query := `INSERT INTO node_jobs (node_id, action, triggered_by, status)
+	 VALUES (:node_id, :action, :triggered_by, :status)`
+	result, err := r.db.NamedExec(query, job)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	job.ID = id
+	return nil
+}
+
+// Finish records the outcome of a job, errMsg is empty when it succeeded.
+func (r *NodeJobRepository) Finish(id int64, output string, errMsg string) error {
+	status := model.NodeJobSucceeded
+	var jobErr *string
+	if errMsg != "" {
+		status = model.NodeJobFailed
+		jobErr = &errMsg
+	}
+	_, err := r.db.Exec(`UPDATE node_jobs SET status = ?, output = ?, error = ?,
+	 finished_at = CURRENT_TIMESTAMP WHERE id = ?`, status, output, jobErr, id)
+	return err
+}
+
+// FailRunning fails the jobs left running by a previous run of the server.
+func (r *NodeJobRepository) FailRunning(errMsg string) error {
+	_, err := r.db.Exec(`UPDATE node_jobs SET status = ?, error = ?,
+	 finished_at = CURRENT_TIMESTAMP WHERE status = ?`, model.NodeJobFailed, errMsg, model.NodeJobRunning)
+	return err
+}
+
+func (r *NodeJobRepository) GetByID(id int64) (*model.NodeJob, error) {
+	var job model.NodeJob
+	err := r.db.Get(&job, "SELECT * FROM node_jobs WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Latest returns the last job of a node.
+func (r *NodeJobRepository) Latest(nodeID int64) (*model.NodeJob, error) {
+	var job model.NodeJob
+	err := r.db.Get(&job, "SELECT * FROM node_jobs WHERE node_id = ? ORDER BY id DESC LIMIT 1", nodeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListByNode returns the last jobs of a node without their output, newest
+// first.
+func (r *NodeJobRepository) ListByNode(nodeID int64, limit int) ([]model.NodeJob, error) {
+	jobs := []model.NodeJob{}
+	err := r.db.Select(&jobs, `SELECT id, node_id, action, triggered_by, status, '' AS output,
+	 error, created_at, finished_at
+	 FROM node_jobs WHERE node_id = ? ORDER BY id DESC LIMIT ?`, nodeID, limit)
+	return jobs, err
+}
+
+func (r *NodeJobRepository) DeleteByNode(nodeID int64) error {
+	_, err := r.db.Exec("DELETE FROM node_jobs WHERE node_id = ?", nodeID)
+	return err
+}
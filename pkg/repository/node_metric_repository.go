@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type NodeMetricRepository struct {
+	db *sqlx.DB
+}
+
+func NewNodeMetricRepository() *NodeMetricRepository {
+	return &NodeMetricRepository{db: database.GetDB()}
+}
+
+// Create stores a sample, a sample of the same node and time is replaced.
+func (r *NodeMetricRepository) Create(m *model.NodeMetric) error {
+	query := `INSERT OR REPLACE INTO node_metrics (node_id, resolution, ts, cpus, cpu_percent,
+	 load1, load5, load15, memory_total, memory_used, disk_total, disk_used,
+	 net_rx_rate, net_tx_rate, containers, containers_running)
+	 VALUES (:node_id, :resolution, :ts, :cpus, :cpu_percent,
+	 :load1, :load5, :load15, :memory_total, :memory_used, :disk_total, :disk_used,
+	 :net_rx_rate, :net_tx_rate, :containers, :containers_running)`
+	_, err := r.db.NamedExec(query, m)
+	return err
+}
+
+// Range returns the samples of a node at a resolution between from and to,
+// in unix seconds.
+func (r *NodeMetricRepository) Range(nodeID int64, resolution int64, from int64, to int64) ([]model.NodeMetric, error) {
+	metrics := []model.NodeMetric{}
+	err := r.db.Select(&metrics, `SELECT * FROM node_metrics
+	 WHERE node_id = ? AND resolution = ? AND ts >= ? AND ts <= ?
+	 ORDER BY ts`, nodeID, resolution, from, to)
+	return metrics, err
+}
+
+// Latest returns the last raw sample of a node.
+func (r *NodeMetricRepository) Latest(nodeID int64) (*model.NodeMetric, error) {
+	var m model.NodeMetric
+	err := r.db.Get(&m, `SELECT * FROM node_metrics
+	 WHERE node_id = ? AND resolution = 0 ORDER BY ts DESC LIMIT 1`, nodeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Downsample averages the samples of the source resolution between from and
+// to into periods of resolution seconds. Periods already downsampled are
+// replaced, so a period may be downsampled again while it fills up.
+func (r *NodeMetricRepository) Downsample(source int64, resolution int64, from int64, to int64) error {
+	query := `INSERT OR REPLACE INTO node_metrics (node_id, resolution, ts, cpus, cpu_percent,
+	 load1, load5, load15, memory_total, memory_used, disk_total, disk_used,
+	 net_rx_rate, net_tx_rate, containers, containers_running)
+	 SELECT node_id, ?, (ts / ?) * ?, MAX(cpus), AVG(cpu_percent),
+	 AVG(load1), AVG(load5), AVG(load15),
+	 CAST(AVG(memory_total) AS INTEGER), CAST(AVG(memory_used) AS INTEGER),
+	 CAST(AVG(disk_total) AS INTEGER), CAST(AVG(disk_used) AS INTEGER),
+	 AVG(net_rx_rate), AVG(net_tx_rate),
+	 CAST(ROUND(AVG(containers)) AS INTEGER), CAST(ROUND(AVG(containers_running)) AS INTEGER)
+	 FROM node_metrics
+	 WHERE resolution = ? AND ts >= ? AND ts < ?
+	 GROUP BY node_id, ts / ?`
+	_, err := r.db.Exec(query, resolution, resolution, resolution, source, from, to, resolution)
+	return err
+}
+
+// DeleteBefore removes the samples of a resolution older than before.
+func (r *NodeMetricRepository) DeleteBefore(resolution int64, before int64) error {
+	_, err := r.db.Exec("DELETE FROM node_metrics WHERE resolution = ? AND ts < ?", resolution, before)
+	return err
+}
+
+func (r *NodeMetricRepository) DeleteByNode(nodeID int64) error {
+	_, err := r.db.Exec("DELETE FROM node_metrics WHERE node_id = ?", nodeID)
+	return err
+}
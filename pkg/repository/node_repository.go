@@ -17,10 +17,15 @@ func NewNodeRepository() *NodeRepository {
 }
 
 func (r *NodeRepository) Create(node *model.Node) error {
+	if node.ComposeBackend == "" {
+		node.ComposeBackend = model.ComposeBackendCLI
+	}
 	query := `INSERT INTO nodes (name, address, ssh_port,
-	 ssh_user, ssh_password, agent_port, status, is_local, data_path) 
+	 ssh_user, ssh_password, agent_port, status, is_local, data_path, compose_backend, metadata, agent_auto_reinstall,
+	 agent_version, agent_capabilities, agent_tunnel, agent_token) 
 	          VALUES (:name, :address, :ssh_port, :ssh_user, 
-			  :ssh_password, :agent_port, :status, :is_local, :data_path) RETURNING id`
+			  :ssh_password, :agent_port, :status, :is_local, :data_path, :compose_backend, :metadata, :agent_auto_reinstall,
+			  :agent_version, :agent_capabilities, :agent_tunnel, :agent_token) RETURNING id`
 
 	result, err := r.db.NamedExec(query, node)
 	if err != nil {
@@ -68,6 +73,12 @@ func (r *NodeRepository) Update(node *model.Node) error {
 	if node.SSHPassword != "" {
 		query += `, ssh_password = :ssh_password`
 	}
+	if node.ComposeBackend != "" {
+		query += `, compose_backend = :compose_backend`
+	}
+	if node.Metadata != nil {
+		query += `, metadata = :metadata`
+	}
 
 	query += ` WHERE id = :id`
 	_, err := r.db.NamedExec(query, node)
@@ -79,12 +90,22 @@ func (r *NodeRepository) UpdateRegistry(node *model.Node) error {
 	 address = :address,
 	 agent_port = :agent_port,
 	 data_path = :data_path,
+	 agent_version = :agent_version,
+	 agent_capabilities = :agent_capabilities,
+	 agent_tunnel = :agent_tunnel,
+	 agent_token = :agent_token,
 	 updated_at = CURRENT_TIMESTAMP
 	 WHERE id = :id`
 	_, err := r.db.NamedExec(query, node)
 	return err
 }
 
+func (r *NodeRepository) UpdateAgentAutoReinstall(id int64, enabled bool) error {
+	_, err := r.db.Exec(`UPDATE nodes SET agent_auto_reinstall = ?,
+	 updated_at = CURRENT_TIMESTAMP WHERE id = ?`, enabled, id)
+	return err
+}
+
 func (r *NodeRepository) UpdateNodeStatus(id int64, status string) error {
 	query := `UPDATE nodes SET status = :status,
 	 updated_at = CURRENT_TIMESTAMP 
@@ -124,3 +145,15 @@ func (r *NodeRepository) Page(page int, pageSize int) (int, []model.Node, error)
 	err = r.db.Select(&nodes, "SELECT * FROM nodes ORDER BY created_at DESC LIMIT ? OFFSET ?", limit, offset)
 	return total, nodes, err
 }
+
+func (r *NodeRepository) Count() (int, error) {
+	var total int
+	err := r.db.Get(&total, "SELECT COUNT(*) FROM nodes")
+	return total, err
+}
+
+func (r *NodeRepository) CountByStatus(status string) (int, error) {
+	var total int
+	err := r.db.Get(&total, "SELECT COUNT(*) FROM nodes WHERE status = ?", status)
+	return total, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type PortAllocationRepository struct {
+	db *sqlx.DB
+}
+
+func NewPortAllocationRepository() *PortAllocationRepository {
+	return &PortAllocationRepository{db: database.GetDB()}
+}
+
+func (r *PortAllocationRepository) Create(allocation *model.PortAllocation) error {
+	query := `INSERT INTO port_allocations (node_id, service_id, name, port)
+	VALUES (:node_id, :service_id, :name, :port)`
+	result, err := r.db.NamedExec(query, allocation)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	allocation.ID = id
+	return nil
+}
+
+func (r *PortAllocationRepository) GetByServiceAndName(serviceID int64, name string) (*model.PortAllocation, error) {
+	var allocation model.PortAllocation
+	err := r.db.Get(&allocation, "SELECT * FROM port_allocations WHERE service_id = ? AND name = ?", serviceID, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &allocation, nil
+}
+
+func (r *PortAllocationRepository) ListByService(serviceID int64) ([]model.PortAllocation, error) {
+	var allocations []model.PortAllocation
+	err := r.db.Select(&allocations, "SELECT * FROM port_allocations WHERE service_id = ? ORDER BY name", serviceID)
+	return allocations, err
+}
+
+func (r *PortAllocationRepository) ListByNode(nodeID int64) ([]model.PortAllocation, error) {
+	var allocations []model.PortAllocation
+	err := r.db.Select(&allocations, "SELECT * FROM port_allocations WHERE node_id = ? ORDER BY port", nodeID)
+	return allocations, err
+}
+
+func (r *PortAllocationRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM port_allocations WHERE id = ?", id)
+	return err
+}
+
+func (r *PortAllocationRepository) DeleteByService(serviceID int64) error {
+	_, err := r.db.Exec("DELETE FROM port_allocations WHERE service_id = ?", serviceID)
+	return err
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"github.com/benlocal/lai-panel/pkg/database"
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type ServiceDependencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewServiceDependencyRepository() *ServiceDependencyRepository {
+	return &ServiceDependencyRepository{db: database.GetDB()}
+}
+
+func (r *ServiceDependencyRepository) List() ([]model.ServiceDependency, error) {
+	var deps []model.ServiceDependency
+	err := r.db.Select(&deps, "SELECT * FROM service_dependencies ORDER BY service_id, depends_on_id")
+	return deps, err
+}
+
+// ListByService returns the dependencies of a service.
+func (r *ServiceDependencyRepository) ListByService(serviceID int64) ([]model.ServiceDependency, error) {
+	var deps []model.ServiceDependency
+	err := r.db.Select(&deps, "SELECT * FROM service_dependencies WHERE service_id = ? ORDER BY depends_on_id", serviceID)
+	return deps, err
+}
+
+// ListDependents returns the dependencies on a service.
+func (r *ServiceDependencyRepository) ListDependents(serviceID int64) ([]model.ServiceDependency, error) {
+	var deps []model.ServiceDependency
+	err := r.db.Select(&deps, "SELECT * FROM service_dependencies WHERE depends_on_id = ? ORDER BY service_id", serviceID)
+	return deps, err
+}
+
+// Replace sets the dependencies of a service.
+func (r *ServiceDependencyRepository) Replace(serviceID int64, dependsOn []int64) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM service_dependencies WHERE service_id = ?", serviceID); err != nil {
+		return err
+	}
+	for _, id := range dependsOn {
+		if _, err := tx.Exec("INSERT INTO service_dependencies (service_id, depends_on_id) VALUES (?, ?)", serviceID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteByService removes the dependencies of and on a service.
+func (r *ServiceDependencyRepository) DeleteByService(serviceID int64) error {
+	_, err := r.db.Exec("DELETE FROM service_dependencies WHERE service_id = ? OR depends_on_id = ?", serviceID, serviceID)
+	return err
+}
@@ -15,8 +15,8 @@ func NewServiceRepository() *ServiceRepository {
 }
 
 func (r *ServiceRepository) Create(service *model.Service) (int64, error) {
-	query := `INSERT INTO services (name, app_id, node_id, status, metadata) 
-	VALUES (:name, :app_id, :node_id, :status, :metadata)`
+	query := `INSERT INTO services (name, app_id, node_id, status, metadata, app_version_id, compose_project) 
+	VALUES (:name, :app_id, :node_id, :status, :metadata, :app_version_id, :compose_project)`
 	result, err := r.db.NamedExec(query, service)
 	if err != nil {
 		return 0, err
@@ -36,6 +36,7 @@ func (r *ServiceRepository) Update(service *model.Service) error {
 	query := `UPDATE services SET name = :name, app_id = :app_id, 
 	node_id = :node_id,
 	metadata = :metadata,
+	app_version_id = :app_version_id,
 	updated_at = CURRENT_TIMESTAMP
 	WHERE id = :id`
 	_, err := r.db.NamedExec(query, service)
@@ -78,9 +79,11 @@ func (r *ServiceRepository) GetPage(page, pageSize int) (int, []*model.Service,
 
 	query := `SELECT services.*,
 		apps.name as app_name,
-		nodes.name as node_name FROM services
+		nodes.name as node_name,
+		app_versions.version as app_version FROM services
 		LEFT JOIN apps ON services.app_id = apps.id
 		LEFT JOIN nodes ON services.node_id = nodes.id
+		LEFT JOIN app_versions ON services.app_version_id = app_versions.id
 	ORDER BY services.created_at DESC LIMIT ? OFFSET ?`
 	var services []*model.Service
 	limit := pageSize
@@ -90,8 +93,23 @@ func (r *ServiceRepository) GetPage(page, pageSize int) (int, []*model.Service,
 }
 
 func (r *ServiceRepository) List() ([]*model.Service, error) {
-	query := `SELECT * FROM services`
+	query := `SELECT services.*,
+		COALESCE(apps.name, '') as app_name FROM services
+		LEFT JOIN apps ON services.app_id = apps.id`
 	var services []*model.Service
 	err := r.db.Select(&services, query)
 	return services, err
 }
+
+// CountByAppVersion returns the number of services pinned to the version.
+func (r *ServiceRepository) CountByAppVersion(versionID int64) (int, error) {
+	var count int
+	err := r.db.Get(&count, "SELECT COUNT(*) FROM services WHERE app_version_id = ?", versionID)
+	return count, err
+}
+
+func (r *ServiceRepository) Count() (int, error) {
+	var total int
+	err := r.db.Get(&total, "SELECT COUNT(*) FROM services")
+	return total, err
+}
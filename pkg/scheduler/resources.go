@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResourcesCommand returns the shell command printing the capacity of a node,
+// the disk is the one of path or of / when path does not exist.
+func ResourcesCommand(path string) string {
+	if path == "" {
+		path = "/"
+	}
+	return "nproc; cat /proc/loadavg; grep -E '^(MemTotal|MemAvailable):' /proc/meminfo; " +
+		"(df -Pk " + shellQuote(path) + " 2>/dev/null || df -Pk /) | tail -n 1"
+}
+
+// ParseResources reads the output of ResourcesCommand.
+func ParseResources(output string) (*Resources, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 5 {
+		return nil, fmt.Errorf("unexpected resources output: %q", output)
+	}
+
+	r := &Resources{}
+	cpus, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu count: %w", err)
+	}
+	r.CPUs = cpus
+
+	load := strings.Fields(lines[1])
+	if len(load) == 0 {
+		return nil, fmt.Errorf("invalid load average: %q", lines[1])
+	}
+	if r.Load, err = strconv.ParseFloat(load[0], 64); err != nil {
+		return nil, fmt.Errorf("invalid load average: %w", err)
+	}
+
+	for _, line := range lines[2:4] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid meminfo line: %q", line)
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meminfo line: %w", err)
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			r.MemoryTotal = kb * 1024
+		case "MemAvailable:":
+			r.MemoryFree = kb * 1024
+		}
+	}
+
+	// Filesystem 1024-blocks Used Available Capacity Mounted
+	df := strings.Fields(lines[len(lines)-1])
+	if len(df) < 4 {
+		return nil, fmt.Errorf("invalid df line: %q", lines[len(lines)-1])
+	}
+	total, err := strconv.ParseUint(df[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid df line: %w", err)
+	}
+	free, err := strconv.ParseUint(df[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid df line: %w", err)
+	}
+	r.DiskTotal = total * 1024
+	r.DiskFree = free * 1024
+	return r, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
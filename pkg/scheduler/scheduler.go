@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// PlacementMetadata is the app metadata holding its placement, e.g.
+// {"constraints": "disk=ssd,region!=us", "anti_affinity": "postgres"}
+const PlacementMetadata = "placement"
+
+// ErrNoNode is returned when no node satisfies the placement of an app.
+var ErrNoNode = errors.New("no eligible node")
+
+// Constraint requires a label of the node to have a value, or not to have it
+// when Not is set.
+type Constraint struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Not   bool   `json:"not,omitempty"`
+}
+
+func (c Constraint) String() string {
+	if c.Not {
+		return c.Key + "!=" + c.Value
+	}
+	return c.Key + "=" + c.Value
+}
+
+// Match reports whether the labels satisfy the constraint.
+func (c Constraint) Match(labels map[string]string) bool {
+	v, ok := labels[c.Key]
+	if c.Not {
+		return !ok || v != c.Value
+	}
+	return ok && v == c.Value
+}
+
+// Placement is where the services of an app may run.
+type Placement struct {
+	Constraints []Constraint `json:"constraints"`
+	// AntiAffinity lists services or apps the app must not share a node with
+	AntiAffinity []string `json:"anti_affinity"`
+}
+
+// ParsePlacement reads the placement of an app from its metadata.
+func ParsePlacement(metadata []*model.Metadata) (*Placement, error) {
+	p := &Placement{}
+	props, ok := model.ToMetadataMap(metadata, PlacementMetadata)
+	if !ok {
+		return p, nil
+	}
+	for _, field := range splitList(props["constraints"]) {
+		c, err := ParseConstraint(field)
+		if err != nil {
+			return nil, err
+		}
+		p.Constraints = append(p.Constraints, c)
+	}
+	p.AntiAffinity = splitList(props["anti_affinity"])
+	return p, nil
+}
+
+// ParseConstraint reads a key=value or key!=value constraint.
+func ParseConstraint(s string) (Constraint, error) {
+	if k, v, ok := strings.Cut(s, "!="); ok {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			return Constraint{}, fmt.Errorf("invalid constraint %q", s)
+		}
+		return Constraint{Key: k, Value: strings.TrimSpace(v), Not: true}, nil
+	}
+	k, v, ok := strings.Cut(s, "=")
+	k = strings.TrimSpace(k)
+	if !ok || k == "" {
+		return Constraint{}, fmt.Errorf("invalid constraint %q", s)
+	}
+	return Constraint{Key: k, Value: strings.TrimSpace(v)}, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// Resources is the capacity of a node, nil when it could not be read.
+type Resources struct {
+	CPUs        int     `json:"cpus"`
+	Load        float64 `json:"load"`
+	MemoryTotal uint64  `json:"memory_total"`
+	MemoryFree  uint64  `json:"memory_free"`
+	DiskTotal   uint64  `json:"disk_total"`
+	DiskFree    uint64  `json:"disk_free"`
+}
+
+// Score rates the free capacity of the node between 0 and 1, free CPU,
+// memory and disk weigh the same.
+func (r *Resources) Score() float64 {
+	var score float64
+	if r.CPUs > 0 {
+		free := 1 - r.Load/float64(r.CPUs)
+		if free > 0 {
+			score += free
+		}
+	}
+	if r.MemoryTotal > 0 {
+		score += float64(r.MemoryFree) / float64(r.MemoryTotal)
+	}
+	if r.DiskTotal > 0 {
+		score += float64(r.DiskFree) / float64(r.DiskTotal)
+	}
+	return score / 3
+}
+
+// Candidate is a node the scheduler may place a service on.
+type Candidate struct {
+	Node      *model.Node
+	Labels    map[string]string
+	Resources *Resources
+	// Services are the services running on the node
+	Services []*model.Service
+}
+
+// NodeScore is the outcome of the scheduler for one node.
+type NodeScore struct {
+	NodeID    int64      `json:"node_id"`
+	NodeName  string     `json:"node_name"`
+	Eligible  bool       `json:"eligible"`
+	Reason    string     `json:"reason,omitempty"`
+	Score     float64    `json:"score"`
+	Resources *Resources `json:"resources,omitempty"`
+}
+
+// Decision is the node picked for a service and how every node was rated.
+type Decision struct {
+	NodeID    int64        `json:"node_id"`
+	NodeName  string       `json:"node_name"`
+	Placement *Placement   `json:"placement"`
+	Nodes     []*NodeScore `json:"nodes"`
+}
+
+// Schedule picks the online node satisfying the placement with the most
+// free capacity. serviceID is left out of the anti affinity, it is 0 for a
+// new service.
+func Schedule(p *Placement, serviceID int64, candidates []*Candidate) (*Decision, error) {
+	d := &Decision{Placement: p, Nodes: []*NodeScore{}}
+	var best *NodeScore
+	for _, c := range candidates {
+		s := &NodeScore{
+			NodeID:    c.Node.ID,
+			NodeName:  c.Node.Name,
+			Resources: c.Resources,
+		}
+		d.Nodes = append(d.Nodes, s)
+		if reason := ineligible(p, serviceID, c); reason != "" {
+			s.Reason = reason
+			continue
+		}
+		s.Eligible = true
+		if c.Resources == nil {
+			s.Reason = "resources unknown"
+		} else {
+			s.Score = c.Resources.Score()
+		}
+		if best == nil || s.Score > best.Score {
+			best = s
+		}
+	}
+	sort.SliceStable(d.Nodes, func(i, j int) bool {
+		if d.Nodes[i].Eligible != d.Nodes[j].Eligible {
+			return d.Nodes[i].Eligible
+		}
+		return d.Nodes[i].Score > d.Nodes[j].Score
+	})
+	if best == nil {
+		return d, ErrNoNode
+	}
+	d.NodeID = best.NodeID
+	d.NodeName = best.NodeName
+	return d, nil
+}
+
+func ineligible(p *Placement, serviceID int64, c *Candidate) string {
+	if c.Node.Status != "online" {
+		return "node is " + c.Node.Status
+	}
+	for _, constraint := range p.Constraints {
+		if !constraint.Match(c.Labels) {
+			return "constraint " + constraint.String() + " not met"
+		}
+	}
+	for _, service := range c.Services {
+		if service.ID == serviceID {
+			continue
+		}
+		for _, name := range p.AntiAffinity {
+			if service.Name == name || service.AppName == name {
+				return "runs " + service.Name
+			}
+		}
+	}
+	return ""
+}
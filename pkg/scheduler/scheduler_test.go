@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlacement(t *testing.T) {
+	p, err := ParsePlacement([]*model.Metadata{{MetadataBase: model.MetadataBase{
+		Name: PlacementMetadata,
+		Properties: map[string]string{
+			"constraints":   "disk=ssd, region!=us",
+			"anti_affinity": "postgres,",
+		},
+	}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Constraint{{Key: "disk", Value: "ssd"}, {Key: "region", Value: "us", Not: true}}, p.Constraints)
+	assert.Equal(t, []string{"postgres"}, p.AntiAffinity)
+
+	_, err = ParsePlacement([]*model.Metadata{{MetadataBase: model.MetadataBase{
+		Name:       PlacementMetadata,
+		Properties: map[string]string{"constraints": "ssd"},
+	}}})
+	assert.Error(t, err)
+}
+
+func TestSchedule(t *testing.T) {
+	p := &Placement{
+		Constraints:  []Constraint{{Key: "disk", Value: "ssd"}},
+		AntiAffinity: []string{"postgres"},
+	}
+	candidates := []*Candidate{
+		{
+			Node:      &model.Node{ID: 1, Name: "busy", Status: "online"},
+			Labels:    map[string]string{"disk": "ssd"},
+			Resources: &Resources{CPUs: 4, Load: 3, MemoryTotal: 100, MemoryFree: 10, DiskTotal: 100, DiskFree: 10},
+		},
+		{
+			Node:      &model.Node{ID: 2, Name: "idle", Status: "online"},
+			Labels:    map[string]string{"disk": "ssd"},
+			Resources: &Resources{CPUs: 4, Load: 0, MemoryTotal: 100, MemoryFree: 90, DiskTotal: 100, DiskFree: 90},
+		},
+		{
+			Node:      &model.Node{ID: 3, Name: "hdd", Status: "online"},
+			Labels:    map[string]string{"disk": "hdd"},
+			Resources: &Resources{CPUs: 8, MemoryTotal: 100, MemoryFree: 100, DiskTotal: 100, DiskFree: 100},
+		},
+		{
+			Node:   &model.Node{ID: 4, Name: "down", Status: "offline"},
+			Labels: map[string]string{"disk": "ssd"},
+		},
+	}
+
+	d, err := Schedule(p, 0, candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), d.NodeID)
+	assert.Equal(t, "constraint disk=ssd not met", d.Nodes[2].Reason)
+
+	candidates[1].Services = []*model.Service{{ID: 7, Name: "db", AppName: "postgres"}}
+	d, err = Schedule(p, 0, candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), d.NodeID)
+
+	// a service is not kept away from itself
+	d, err = Schedule(p, 7, candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), d.NodeID)
+
+	candidates[0].Node.Status = "offline"
+	_, err = Schedule(p, 0, candidates)
+	assert.ErrorIs(t, err, ErrNoNode)
+}
+
+func TestParseResources(t *testing.T) {
+	r, err := ParseResources(`4
+1.50 1.20 0.90 2/345 6789
+MemTotal:        8000000 kB
+MemAvailable:    2000000 kB
+/dev/sda1         100000000  60000000  40000000      60% /
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, &Resources{
+		CPUs:        4,
+		Load:        1.5,
+		MemoryTotal: 8000000 * 1024,
+		MemoryFree:  2000000 * 1024,
+		DiskTotal:   100000000 * 1024,
+		DiskFree:    40000000 * 1024,
+	}, r)
+
+	_, err = ParseResources("4\n")
+	assert.Error(t, err)
+}
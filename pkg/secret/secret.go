@@ -0,0 +1,213 @@
+package secret
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/benlocal/lai-panel/pkg/crypto"
+	"github.com/benlocal/lai-panel/pkg/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	TypeSecret = "secret"
+	TypeUUID   = "uuid"
+
+	DefaultLength = 32
+	MaxLength     = 256
+
+	alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Secret is a value generated for a service by the gen_secret or gen_uuid
+// template functions.
+type Secret struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Length int    `json:"length,omitempty"`
+	Value  string `json:"value"`
+}
+
+// Store keeps the generated secrets of services encrypted in the kv table,
+// one entry per secret grouped by service.
+type Store struct {
+	kv *repository.KvRepository
+}
+
+func NewStore(kv *repository.KvRepository) *Store {
+	return &Store{kv: kv}
+}
+
+func serviceKey(serviceID int64) string {
+	return fmt.Sprintf("service_secret:%d", serviceID)
+}
+
+func secretKey(serviceID int64, name string) string {
+	return fmt.Sprintf("%s:%s", serviceKey(serviceID), name)
+}
+
+// GetOrCreate returns the secret of a service, generating and storing it the
+// first time it is requested.
+func (s *Store) GetOrCreate(serviceID int64, name string, typ string, length int) (string, error) {
+	if name == "" {
+		return "", errors.New("secret name is required")
+	}
+
+	existing, err := s.get(serviceID, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return existing.Value, nil
+	}
+
+	secret := &Secret{Name: name, Type: typ, Length: length}
+	if err := secret.generate(); err != nil {
+		return "", err
+	}
+	data, err := encode(secret)
+	if err != nil {
+		return "", err
+	}
+	subKey := serviceKey(serviceID)
+	if err := s.kv.Create(secretKey(serviceID, name), data, &subKey); err != nil {
+		// created by a concurrent render
+		existing, getErr := s.get(serviceID, name)
+		if getErr != nil || existing == nil {
+			return "", err
+		}
+		return existing.Value, nil
+	}
+	return secret.Value, nil
+}
+
+// Get returns a secret of a service, nil when it was never generated.
+func (s *Store) Get(serviceID int64, name string) (*Secret, error) {
+	return s.get(serviceID, name)
+}
+
+// List returns the secrets of a service sorted by name.
+func (s *Store) List(serviceID int64) ([]*Secret, error) {
+	values, err := s.kv.GetWithSubKey(serviceKey(serviceID))
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*Secret, 0, len(values))
+	for _, value := range values {
+		secret, err := decode(value)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+// Rotate replaces a secret with a new value of the same type and length, the
+// service has to be redeployed to pick it up.
+func (s *Store) Rotate(serviceID int64, name string) (*Secret, error) {
+	secret, err := s.get(serviceID, name)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	if err := secret.generate(); err != nil {
+		return nil, err
+	}
+	data, err := encode(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.kv.Update(secretKey(serviceID, name), data); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// DeleteAll removes the secrets of a service.
+func (s *Store) DeleteAll(serviceID int64) error {
+	return s.kv.DeleteWithSubKey(serviceKey(serviceID))
+}
+
+func (s *Store) get(serviceID int64, name string) (*Secret, error) {
+	value, err := s.kv.Get(secretKey(serviceID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decode(value)
+}
+
+func encode(secret *Secret) (string, error) {
+	data, err := json.Marshal(secret)
+	if err != nil {
+		return "", err
+	}
+	return crypto.Encrypt(string(data))
+}
+
+func decode(value string) (*Secret, error) {
+	data, err := crypto.Decrypt(value)
+	if err != nil {
+		return nil, err
+	}
+	var secret Secret
+	if err := json.Unmarshal([]byte(data), &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (s *Secret) generate() error {
+	switch s.Type {
+	case TypeUUID:
+		s.Length = 0
+		s.Value = uuid.NewString()
+		return nil
+	case TypeSecret:
+		if s.Length <= 0 {
+			s.Length = DefaultLength
+		}
+		value, err := Generate(s.Length)
+		if err != nil {
+			return err
+		}
+		s.Value = value
+		return nil
+	}
+	return fmt.Errorf("unknown secret type %q", s.Type)
+}
+
+// Generate returns a random alphanumeric string, safe to use unquoted in
+// compose files and env files.
+func Generate(length int) (string, error) {
+	if length <= 0 || length > MaxLength {
+		return "", fmt.Errorf("secret length must be between 1 and %d", MaxLength)
+	}
+
+	var b strings.Builder
+	b.Grow(length)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(alphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,38 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := Generate(32)
+	assert.NoError(t, err)
+	assert.Len(t, a, 32)
+	assert.Empty(t, strings.Trim(a, alphabet))
+
+	b, err := Generate(32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	_, err = Generate(0)
+	assert.Error(t, err)
+	_, err = Generate(MaxLength + 1)
+	assert.Error(t, err)
+}
+
+func TestSecretGenerate(t *testing.T) {
+	s := &Secret{Name: "jwt", Type: TypeSecret}
+	assert.NoError(t, s.generate())
+	assert.Equal(t, DefaultLength, s.Length)
+	assert.Len(t, s.Value, DefaultLength)
+
+	u := &Secret{Name: "id", Type: TypeUUID, Length: 10}
+	assert.NoError(t, u.generate())
+	assert.Equal(t, 0, u.Length)
+	assert.Len(t, u.Value, 36)
+
+	assert.Error(t, (&Secret{Type: "other"}).generate())
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/handler"
+)
+
+// CatalogSyncService syncs the catalog sources periodically.
+type CatalogSyncService struct {
+	context     context.Context
+	cancel      context.CancelFunc
+	baseHandler *handler.BaseHandler
+	interval    time.Duration
+}
+
+func NewCatalogSyncService(baseHandler *handler.BaseHandler, interval time.Duration) *CatalogSyncService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CatalogSyncService{
+		context:     ctx,
+		cancel:      cancel,
+		baseHandler: baseHandler,
+		interval:    interval,
+	}
+}
+
+func (s *CatalogSyncService) Name() string {
+	return "catalog-sync"
+}
+
+func (s *CatalogSyncService) Start(ctx context.Context) error {
+	if s.interval <= 0 {
+		return nil
+	}
+	s.sync()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.context.Done():
+			return nil
+		case <-ticker.C:
+			s.sync()
+		}
+	}
+}
+
+func (s *CatalogSyncService) sync() {
+	if err := s.baseHandler.CatalogSyncer().SyncAll(s.context); err != nil {
+		log.Println("catalog sync failed", err)
+	}
+}
+
+func (s *CatalogSyncService) Shutdown() error {
+	s.cancel()
+	return nil
+}
@@ -2,19 +2,18 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
-	"path"
 	"time"
 
 	"github.com/benlocal/lai-panel/pkg/client"
 	"github.com/benlocal/lai-panel/pkg/handler"
 	"github.com/benlocal/lai-panel/pkg/model"
-	nodePkg "github.com/benlocal/lai-panel/pkg/node"
-	"github.com/benlocal/lai-panel/pkg/options"
 )
 
+// autoReinstallBackoff is the least time between two automatic reinstalls of
+// the agent of a node.
+const autoReinstallBackoff = 10 * time.Minute
+
 type HealthCheckService struct {
 	context     context.Context
 	cancel      context.CancelFunc
@@ -66,11 +65,11 @@ func (s *HealthCheckService) healthCheck() error {
 	}
 	for _, node := range nodes {
 		if !node.IsLocal {
-			if err := s.baseClient.HealthCheck(node.Address, node.AgentPort); err != nil {
+			if err := s.checkAgent(&node); err != nil {
 				log.Println("health check failed", node.Address, node.AgentPort, err)
 				// update node status to offline
 				s.baseHandler.NodeRepository().UpdateNodeStatus(node.ID, "offline")
-				s.tryStartupAgentService(&node)
+				s.tryReinstallAgent(&node)
 			} else {
 				// update node status to online
 				s.baseHandler.NodeRepository().UpdateNodeStatus(node.ID, "online")
@@ -80,87 +79,34 @@ func (s *HealthCheckService) healthCheck() error {
 	return nil
 }
 
-func (s *HealthCheckService) tryStartupAgentService(node *model.Node) {
-	state, err := s.baseHandler.NodeManager().GetNodeState(node.ID)
-	if err != nil {
-		return
-	}
-	exec, err := state.GetExec()
-	if err != nil {
-		return
+// checkAgent calls the health check of the agent, through its tunnel when
+// the agent dialed the master.
+func (s *HealthCheckService) checkAgent(node *model.Node) error {
+	if node.AgentTunnel {
+		return s.baseHandler.NodeManager().Tunnels().HealthCheck(s.context, node.Name)
 	}
+	return s.baseClient.HealthCheck(node.Address, node.AgentPort)
+}
 
-	currentBase := path.Join(s.baseHandler.StaticDataPath(), options.INSTALL_BASE_PATH)
-	distBase := path.Join(*state.GetDataPath(), options.STATIC_BASE_PATH, options.INSTALL_BASE_PATH)
-
-	// copy agent binary to data path
-	agentPath := path.Join(currentBase, "agent")
-	fi, err := os.Open(agentPath)
-	if err != nil {
+// tryReinstallAgent reinstalls the agent of an offline node with the auto
+// reinstall policy, at most once per autoReinstallBackoff.
+func (s *HealthCheckService) tryReinstallAgent(node *model.Node) {
+	if !node.AgentAutoReinstall || node.SSHUser == "" || node.AgentTunnel {
 		return
 	}
-	defer fi.Close()
-	disAgentPath := path.Join(distBase, "agent")
-	err = exec.WriteFileStream(disAgentPath, fi)
+	last, err := s.baseHandler.NodeJobRepository().Latest(node.ID)
 	if err != nil {
+		log.Println("failed to get the last job of node", node.Name, err)
 		return
 	}
-
-	// copy install script to data path
-	installScriptPath := path.Join(currentBase, "install.sh")
-	fi, err = os.Open(installScriptPath)
-	if err != nil {
-		return
-	}
-	defer fi.Close()
-	disPath := path.Join(distBase, "install.sh")
-	err = exec.WriteFileStream(disPath, fi)
-	if err != nil {
+	if last != nil && (last.Status == model.NodeJobRunning || time.Since(last.CreatedAt) < autoReinstallBackoff) {
 		return
 	}
 
-	// Make script executable
-	opt := nodePkg.NewNodeExecuteCommandOptions()
-	opt.WorkingDir = distBase
-	if _, _, err := exec.ExecuteOutput("chmod +x install.sh", opt); err != nil {
-		return
-	}
-
-	// Get master host and port
-	masterHost := s.baseHandler.Options().MasterHost()
-	masterPort := s.baseHandler.Options().MasterPort()
-
-	// Build install command with parameters
-	installCmd := fmt.Sprintf(
-		"./install.sh --master-host %s --master-port %d --binary-path %s",
-		masterHost,
-		masterPort,
-		disAgentPath,
-	)
-
-	// Add agent name if provided
-	if node.Name != "" {
-		installCmd += fmt.Sprintf(" --name %s", node.Name)
-	}
-
-	// Add agent address if provided
-	if node.Address != "" {
-		installCmd += fmt.Sprintf(" --address %s", node.Address)
-	}
-
-	// Add agent port if provided
-	if node.AgentPort > 0 {
-		installCmd += fmt.Sprintf(" --port %d", node.AgentPort)
-	}
-
-	// Execute install script
-	err = exec.ExecuteCommand(installCmd, opt, func(s string) {
-		log.Println("install script stdout", s)
-	}, func(s string) {
-		log.Println("install script stderr", s)
-	})
-	if err != nil {
-		log.Println("failed to execute install script", err)
-		return
-	}
+	log.Println("reinstall agent of offline node", node.Name)
+	go func() {
+		if err := s.baseHandler.RunAgentJob(s.context, node, model.NodeJobReinstall, model.NodeJobAuto, nil); err != nil {
+			log.Println("failed to reinstall agent of node", node.Name, err)
+		}
+	}()
 }
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/benlocal/lai-panel/pkg/client"
+	"github.com/benlocal/lai-panel/pkg/handler"
+	"github.com/benlocal/lai-panel/pkg/metrics"
+
+	appCtx "github.com/benlocal/lai-panel/pkg/ctx"
+)
+
+// MetricsService samples the resources of the node it runs on. The master
+// stores the samples of the local node, agents push them to the master.
+type MetricsService struct {
+	context context.Context
+	cancel  context.CancelFunc
+
+	collector   *metrics.Collector
+	interval    time.Duration
+	baseHandler *handler.BaseHandler
+	baseClient  *client.BaseClient
+}
+
+func NewLocalMetricsService(baseHandler *handler.BaseHandler, collector *metrics.Collector, interval time.Duration) *MetricsService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MetricsService{
+		context:     ctx,
+		cancel:      cancel,
+		collector:   collector,
+		interval:    interval,
+		baseHandler: baseHandler,
+	}
+}
+
+func NewRemoteMetricsService(baseClient *client.BaseClient, collector *metrics.Collector, interval time.Duration) *MetricsService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MetricsService{
+		context:    ctx,
+		cancel:     cancel,
+		collector:  collector,
+		interval:   interval,
+		baseClient: baseClient,
+	}
+}
+
+func (s *MetricsService) Name() string {
+	return "metrics-service"
+}
+
+func (s *MetricsService) Start(ctx context.Context) error {
+	if s.interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.context.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.sample(); err != nil {
+				log.Println("sample node metrics failed", err)
+			}
+		}
+	}
+}
+
+func (s *MetricsService) sample() error {
+	m, err := s.collector.Collect(s.context)
+	if err != nil {
+		return err
+	}
+	// the node registers itself first
+	id := appCtx.GlobalServerStore.GetID()
+	if id <= 0 {
+		return nil
+	}
+	m.NodeID = id
+
+	if s.baseHandler != nil {
+		return s.baseHandler.NodeMetricRepository().Create(m)
+	}
+	return s.baseClient.NodeMetrics(
+		appCtx.GlobalServerStore.GetMasterHost(),
+		appCtx.GlobalServerStore.GetMasterPort(),
+		m,
+	)
+}
+
+func (s *MetricsService) Shutdown() error {
+	s.cancel()
+	return nil
+}
+
+// MetricsDownsampleService averages the node metrics into the coarser tiers
+// and drops the metrics past their retention.
+type MetricsDownsampleService struct {
+	context context.Context
+	cancel  context.CancelFunc
+
+	baseHandler *handler.BaseHandler
+	tiers       []metrics.Tier
+}
+
+func NewMetricsDownsampleService(baseHandler *handler.BaseHandler, tiers []metrics.Tier) *MetricsDownsampleService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MetricsDownsampleService{
+		context:     ctx,
+		cancel:      cancel,
+		baseHandler: baseHandler,
+		tiers:       tiers,
+	}
+}
+
+func (s *MetricsDownsampleService) Name() string {
+	return "metrics-downsample-service"
+}
+
+func (s *MetricsDownsampleService) Start(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.context.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.downsample(time.Now()); err != nil {
+				log.Println("downsample node metrics failed", err)
+			}
+		}
+	}
+}
+
+func (s *MetricsDownsampleService) downsample(now time.Time) error {
+	repo := s.baseHandler.NodeMetricRepository()
+	for i, tier := range s.tiers {
+		resolution := int64(tier.Resolution.Seconds())
+		if i > 0 {
+			source := int64(s.tiers[i-1].Resolution.Seconds())
+			// the current period is averaged again until it is complete
+			from := (now.Unix()/resolution - 1) * resolution
+			if err := repo.Downsample(source, resolution, from, now.Unix()); err != nil {
+				return err
+			}
+		}
+		if err := repo.DeleteBefore(resolution, now.Add(-tier.Retention).Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MetricsDownsampleService) Shutdown() error {
+	s.cancel()
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/benlocal/lai-panel/pkg/client"
 	"github.com/benlocal/lai-panel/pkg/handler"
 	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/benlocal/lai-panel/pkg/version"
 
 	appCtx "github.com/benlocal/lai-panel/pkg/ctx"
 )
@@ -54,6 +55,11 @@ func (s *RegistryService) Start(ctx context.Context) error {
 		if err := s.tryAddLocalRegistry(); err != nil {
 			log.Println("try add local registry failed", err)
 		}
+	} else {
+		// register right away, an agent install waits for it
+		if err := s.updateRegistry(); err != nil {
+			log.Println("update registry failed", err)
+		}
 	}
 
 	ticker := time.NewTicker(30 * time.Second)
@@ -117,6 +123,11 @@ func (s *RegistryService) updateRegistry() error {
 		Status:    "online",
 		Address:   appCtx.GlobalServerStore.GetAddress(),
 		DataPath:  appCtx.GlobalServerStore.GetDataPath(),
+
+		Version:      version.Version,
+		Capabilities: version.Capabilities,
+		Tunnel:       appCtx.GlobalServerStore.IsTunnel(),
+		Token:        appCtx.GlobalServerStore.GetAgentToken(),
 	}
 	resp, err := s.baseClient.Registry(masterHost, masterPort, &reqBody)
 	if err != nil {
@@ -125,6 +136,14 @@ func (s *RegistryService) updateRegistry() error {
 	if resp.ID <= 0 {
 		return errors.New("registry failed")
 	}
+	if resp.Incompatible != "" {
+		log.Println("master reports the agent as incompatible:", resp.Incompatible)
+	}
+	if resp.Token != "" {
+		if err := appCtx.GlobalServerStore.SetAgentToken(resp.Token); err != nil {
+			return err
+		}
+	}
 
 	// set service id
 	appCtx.GlobalServerStore.SetID(resp.ID)
@@ -2,14 +2,12 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"time"
 
-	"github.com/benlocal/lai-panel/pkg/constant"
+	"github.com/benlocal/lai-panel/pkg/compose"
 	"github.com/benlocal/lai-panel/pkg/handler"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/filters"
 )
 
 type ServicesStateUpdater struct {
@@ -69,14 +67,13 @@ func (s *ServicesStateUpdater) updateServicesState() error {
 		if err != nil {
 			continue
 		}
-		filters := filters.NewArgs()
-		filters.Add("label", fmt.Sprintf("%s=%s", constant.OwnerLabel, constant.ProjectId))
-		filters.Add("label", fmt.Sprintf("%s=%s", constant.ManagedByLabel, constant.ProjectId))
-		filters.Add("label", fmt.Sprintf("%s=%s", constant.ServiceLabel, service.Name))
-
+		project := ""
+		if service.ComposeProject != nil {
+			project = *service.ComposeProject
+		}
 		containers, err := dc.ContainerList(context.Background(), container.ListOptions{
 			All:     true,
-			Filters: filters,
+			Filters: compose.ServiceFilters(service.Name, project),
 		})
 		if err != nil {
 			continue
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benlocal/lai-panel/pkg/tunnel"
+
+	appCtx "github.com/benlocal/lai-panel/pkg/ctx"
+)
+
+// TunnelService keeps the tunnel of an agent the master can't dial, the
+// requests of the master come through it to the agent API.
+type TunnelService struct {
+	context context.Context
+	cancel  context.CancelFunc
+}
+
+func NewTunnelService() *TunnelService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TunnelService{
+		context: ctx,
+		cancel:  cancel,
+	}
+}
+
+func (s *TunnelService) Name() string {
+	return "tunnel-service"
+}
+
+func (s *TunnelService) Start(ctx context.Context) error {
+	handler := tunnel.AgentHandler(fmt.Sprintf("127.0.0.1:%d", appCtx.GlobalServerStore.GetAgentPort()))
+	client := tunnel.NewClient(tunnel.URL(
+		appCtx.GlobalServerStore.GetMasterHost(),
+		appCtx.GlobalServerStore.GetMasterPort(),
+		appCtx.GlobalServerStore.GetName(),
+		appCtx.GlobalServerStore.IsMasterTLS(),
+	), appCtx.GlobalServerStore.GetAgentToken, handler)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.context.Done():
+			cancel()
+		}
+	}()
+	return client.Run(ctx)
+}
+
+func (s *TunnelService) Shutdown() error {
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,72 @@
+package servicedep
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+)
+
+// ErrCycle is returned when services depend on each other.
+var ErrCycle = errors.New("service dependencies form a cycle")
+
+// Graph maps a service to the services it depends on.
+type Graph map[int64][]int64
+
+func NewGraph(deps []model.ServiceDependency) Graph {
+	g := Graph{}
+	for _, d := range deps {
+		g[d.ServiceID] = append(g[d.ServiceID], d.DependsOnID)
+	}
+	return g
+}
+
+// With returns a copy of the graph with the dependencies of a service
+// replaced.
+func (g Graph) With(serviceID int64, dependsOn []int64) Graph {
+	c := make(Graph, len(g)+1)
+	for k, v := range g {
+		c[k] = v
+	}
+	c[serviceID] = dependsOn
+	return c
+}
+
+// Order returns the direct and indirect dependencies of a service, every
+// service comes after the services it depends on.
+func (g Graph) Order(serviceID int64) ([]int64, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[int64]int{}
+	order := []int64{}
+
+	var visit func(id int64) error
+	visit = func(id int64) error {
+		switch state[id] {
+		case visiting:
+			return ErrCycle
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		deps := append([]int64(nil), g[id]...)
+		sort.Slice(deps, func(i, j int) bool { return deps[i] < deps[j] })
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[id] = done
+		if id != serviceID {
+			order = append(order, id)
+		}
+		return nil
+	}
+
+	if err := visit(serviceID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
@@ -0,0 +1,35 @@
+package servicedep
+
+import (
+	"testing"
+
+	"github.com/benlocal/lai-panel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrder(t *testing.T) {
+	// wiki -> postgres, cache; cache -> postgres; postgres -> volume
+	g := NewGraph([]model.ServiceDependency{
+		{ServiceID: 1, DependsOnID: 3},
+		{ServiceID: 1, DependsOnID: 2},
+		{ServiceID: 3, DependsOnID: 2},
+		{ServiceID: 2, DependsOnID: 4},
+	})
+
+	order, err := g.Order(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 2, 3}, order)
+
+	order, err = g.Order(4)
+	assert.NoError(t, err)
+	assert.Empty(t, order)
+
+	_, err = g.With(4, []int64{1}).Order(1)
+	assert.ErrorIs(t, err, ErrCycle)
+	_, err = g.With(5, []int64{5}).Order(5)
+	assert.ErrorIs(t, err, ErrCycle)
+
+	// the original graph is unchanged
+	_, err = g.Order(1)
+	assert.NoError(t, err)
+}
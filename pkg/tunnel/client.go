@@ -0,0 +1,118 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/coder/websocket"
+	"golang.org/x/net/http2"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// a tunnel up for stableAfter resets the backoff
+	stableAfter = time.Minute
+)
+
+var (
+	errConnectionLost = errors.New("connection lost")
+	errNotRegistered  = errors.New("agent is not registered with the master yet")
+)
+
+// Client is the agent end of the tunnel, it dials the master and serves the
+// requests of the master with handler, and dials again when the tunnel
+// breaks.
+type Client struct {
+	url     string
+	token   func() string
+	handler http.Handler
+}
+
+// NewClient serves the requests coming through the tunnel at url with
+// handler, the tunnel is opened with the agent token returned by token.
+func NewClient(url string, token func() string, handler http.Handler) *Client {
+	return &Client{
+		url:     url,
+		token:   token,
+		handler: handler,
+	}
+}
+
+// AgentHandler serves the requests of the master with the agent API at
+// addr, requests are forwarded with the responses flushed right away for
+// the streamed ones, and streams are dialed to the API.
+func AgentHandler(addr string) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+	proxy.FlushInterval = -1
+	dial := dialHandler(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == DialPath {
+			dial.ServeHTTP(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// Run keeps the tunnel up until ctx is done, the reconnects back off
+// exponentially up to maxBackoff.
+func (c *Client) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := c.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(start) > stableAfter {
+			backoff = minBackoff
+		}
+		log.Printf("agent tunnel closed: %v, reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// serve dials the master and serves the tunnel until it breaks.
+func (c *Client) serve(ctx context.Context) error {
+	token := c.token()
+	if token == "" {
+		return errNotRegistered
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ws, _, err := websocket.Dial(dialCtx, c.url, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": []string{"Bearer " + token}},
+	})
+	cancel()
+	if err != nil {
+		return err
+	}
+	ws.SetReadLimit(-1)
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	conn := websocket.NetConn(connCtx, ws, websocket.MessageBinary)
+	defer conn.Close()
+
+	log.Println("agent tunnel connected to", c.url)
+	server := &http2.Server{
+		ReadIdleTimeout: pingInterval * 2,
+		PingTimeout:     pingTimeout,
+	}
+	server.ServeConn(conn, &http2.ServeConnOpts{
+		Context: connCtx,
+		Handler: c.handler,
+	})
+	return errConnectionLost
+}
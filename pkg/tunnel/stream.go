@@ -0,0 +1,123 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DialPath opens a raw stream to the agent API through the tunnel, the
+// request body carries what the master writes and the response body what
+// the agent answers. Hijacked docker connections (attach, exec) need them.
+const DialPath = "/tunnel.dial"
+
+// Dial opens a stream to the API of the agent through its tunnel, ctx only
+// bounds the dial.
+func (r *Registry) Dial(ctx context.Context, name string) (net.Conn, error) {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	body, writer := io.Pipe()
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodPost, "http://"+name+DialPath, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, cancel)
+	resp, err := r.Transport(name).RoundTrip(req)
+	if !stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		writer.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		writer.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		cancel()
+		writer.Close()
+		return nil, fmt.Errorf("agent %s refused the stream: %s", name, msg)
+	}
+	return &streamConn{name: name, reader: resp.Body, writer: writer, cancel: cancel}, nil
+}
+
+// streamConn is a stream through the tunnel, deadlines are not supported,
+// closing the stream unblocks its reads and writes.
+type streamConn struct {
+	name   string
+	reader io.ReadCloser
+	writer *io.PipeWriter
+	cancel context.CancelFunc
+}
+
+func (c *streamConn) Read(b []byte) (int, error)  { return c.reader.Read(b) }
+func (c *streamConn) Write(b []byte) (int, error) { return c.writer.Write(b) }
+
+func (c *streamConn) Close() error {
+	c.writer.Close()
+	err := c.reader.Close()
+	c.cancel()
+	return err
+}
+
+func (c *streamConn) LocalAddr() net.Addr                { return streamAddr("master") }
+func (c *streamConn) RemoteAddr() net.Addr               { return streamAddr(c.name) }
+func (c *streamConn) SetDeadline(t time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type streamAddr string
+
+func (a streamAddr) Network() string { return "tunnel" }
+func (a streamAddr) String() string  { return string(a) }
+
+// dialHandler serves the streams of the master by dialing addr and copying
+// both ways until either end closes.
+func dialHandler(addr string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer conn.Close()
+
+		rc := http.NewResponseController(w)
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			io.Copy(conn, r.Body)
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.CloseWrite()
+			}
+		}()
+
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil || rc.Flush() != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		conn.Close()
+		<-done
+	})
+}
@@ -0,0 +1,211 @@
+// Package tunnel lets the master reach agents it can't dial, an agent behind
+// a NAT opens a websocket to the master and serves its API over it. The
+// websocket carries a HTTP/2 connection, the master is its client, so the
+// docker proxy, node exec and health check requests are multiplexed over the
+// single connection the agent dialed.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// Path is where the master accepts the tunnels of agents.
+	Path = "/agent/tunnel"
+	// HealthCheckPath is the health check of the agent API.
+	HealthCheckPath = "/healthz"
+
+	// pingInterval is how often both ends make sure the tunnel is alive
+	pingInterval = 15 * time.Second
+	pingTimeout  = 10 * time.Second
+)
+
+// ErrNotConnected is returned for requests to an agent without tunnel.
+var ErrNotConnected = errors.New("agent tunnel is not connected")
+
+// URL is the tunnel endpoint of the master for the agent name. The agent
+// token is sent when the tunnel opens, masterTLS must be set unless the
+// master is only reached over a trusted network.
+func URL(masterHost string, masterPort int, name string, masterTLS bool) string {
+	scheme := "ws"
+	if masterTLS {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s:%d%s?name=%s", scheme, masterHost, masterPort, Path, url.QueryEscape(name))
+}
+
+// Authenticate tells whether the agent name may open a tunnel with token.
+type Authenticate func(name string, token string) error
+
+// Registry holds the tunnels connected to the master by agent name.
+type Registry struct {
+	transport    *http2.Transport
+	conns        map[string]*http2.ClientConn
+	authenticate Authenticate
+
+	mu sync.RWMutex
+}
+
+func NewRegistry(authenticate Authenticate) *Registry {
+	return &Registry{
+		transport:    &http2.Transport{AllowHTTP: true},
+		conns:        make(map[string]*http2.ClientConn),
+		authenticate: authenticate,
+	}
+}
+
+// ServeHTTP accepts the tunnel of an agent and holds it until it breaks, a
+// new tunnel of the same agent replaces the previous one. The agent proves
+// its name with the token the master issued to it.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	token, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if err := r.authenticate(name, token); err != nil {
+		log.Println("rejected the tunnel of agent", name, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	ws, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		log.Println("failed to accept the tunnel of agent", name, err)
+		return
+	}
+	ws.SetReadLimit(-1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := websocket.NetConn(ctx, ws, websocket.MessageBinary)
+	cc, err := r.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		log.Println("failed to open the tunnel of agent", name, err)
+		return
+	}
+
+	r.add(name, cc)
+	log.Println("agent tunnel connected", name)
+	defer func() {
+		r.remove(name, cc)
+		cc.Close()
+		log.Println("agent tunnel disconnected", name)
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if cc.State().Closed {
+			return
+		}
+		pingCtx, pingCancel := context.WithTimeout(ctx, pingTimeout)
+		err := cc.Ping(pingCtx)
+		pingCancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *Registry) add(name string, cc *http2.ClientConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.conns[name]; ok {
+		prev.Close()
+	}
+	r.conns[name] = cc
+}
+
+// remove removes the tunnel of the agent unless it was replaced.
+func (r *Registry) remove(name string, cc *http2.ClientConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conns[name] == cc {
+		delete(r.conns, name)
+	}
+}
+
+func (r *Registry) get(name string) (*http2.ClientConn, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cc, ok := r.conns[name]
+	return cc, ok
+}
+
+// Disconnect closes the tunnel of the agent.
+func (r *Registry) Disconnect(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cc, ok := r.conns[name]; ok {
+		cc.Close()
+		delete(r.conns, name)
+	}
+}
+
+// Connected tells whether the agent has a tunnel.
+func (r *Registry) Connected(name string) bool {
+	_, ok := r.get(name)
+	return ok
+}
+
+// Transport sends the requests to the agent through its tunnel, the tunnel
+// is looked up per request so the transport survives reconnects.
+func (r *Registry) Transport(name string) http.RoundTripper {
+	return &transport{registry: r, name: name}
+}
+
+// HealthCheck calls the health check of the agent through its tunnel.
+func (r *Registry) HealthCheck(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+name+HealthCheckPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.Transport(name).RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed, status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type transport struct {
+	registry *Registry
+	name     string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cc, ok := t.registry.get(t.name)
+	if !ok || !cc.CanTakeNewRequest() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotConnected, t.name)
+	}
+	// the http2 client conn only speaks http, the agent end forwards the
+	// request to its own API
+	if req.URL.Scheme != "http" {
+		r := req.Clone(req.Context())
+		r.URL.Scheme = "http"
+		req = r
+	}
+	return cc.RoundTrip(req)
+}
@@ -0,0 +1,143 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistry() *Registry {
+	return NewRegistry(func(name string, token string) error {
+		if name != "edge" || token != "secret" {
+			return errors.New("invalid agent token")
+		}
+		return nil
+	})
+}
+
+func token(t string) func() string {
+	return func() string { return t }
+}
+
+func TestTunnelRoundTrip(t *testing.T) {
+	registry := newTestRegistry()
+	master := httptest.NewServer(registry)
+	defer master.Close()
+
+	agent := http.NewServeMux()
+	agent.HandleFunc(HealthCheckPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	agent.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+
+	err := registry.HealthCheck(context.Background(), "edge")
+	assert.True(t, errors.Is(err, ErrNotConnected))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient("ws"+strings.TrimPrefix(master.URL, "http")+Path+"?name=edge", token("secret"), agent)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return registry.Connected("edge")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, registry.HealthCheck(context.Background(), "edge"))
+
+	c := &http.Client{Transport: registry.Transport("edge")}
+	resp, err := c.Post("http://edge/echo", "text/plain", strings.NewReader("hello"))
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "hello", string(body))
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tunnel client did not stop")
+	}
+}
+
+func TestTunnelDial(t *testing.T) {
+	registry := newTestRegistry()
+	master := httptest.NewServer(registry)
+	defer master.Close()
+
+	// the agent API, an echo server
+	api, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer api.Close()
+	go func() {
+		for {
+			conn, err := api.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient("ws"+strings.TrimPrefix(master.URL, "http")+Path+"?name=edge", token("secret"), AgentHandler(api.Addr().String()))
+	go client.Run(ctx)
+	assert.Eventually(t, func() bool {
+		return registry.Connected("edge")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	conn, err := registry.Dial(context.Background(), "edge")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	for _, msg := range []string{"ping", "pong"} {
+		_, err = conn.Write([]byte(msg))
+		assert.NoError(t, err)
+		buf := make([]byte, len(msg))
+		_, err = io.ReadFull(conn, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, msg, string(buf))
+	}
+
+	_, err = registry.Dial(context.Background(), "other")
+	assert.ErrorIs(t, err, ErrNotConnected)
+}
+
+func TestTunnelRejectsInvalidToken(t *testing.T) {
+	registry := newTestRegistry()
+	master := httptest.NewServer(registry)
+	defer master.Close()
+
+	client := NewClient("ws"+strings.TrimPrefix(master.URL, "http")+Path+"?name=edge", token("guess"), http.NotFoundHandler())
+	err := client.serve(context.Background())
+	assert.Error(t, err)
+	assert.False(t, registry.Connected("edge"))
+
+	client = NewClient("ws"+strings.TrimPrefix(master.URL, "http")+Path+"?name=edge", token(""), http.NotFoundHandler())
+	assert.ErrorIs(t, client.serve(context.Background()), errNotRegistered)
+}
+
+func TestURL(t *testing.T) {
+	assert.Equal(t, "ws://panel:8080/agent/tunnel?name=edge+1", URL("panel", 8080, "edge 1", false))
+	assert.Equal(t, "wss://panel:443/agent/tunnel?name=edge", URL("panel", 443, "edge", true))
+}
@@ -0,0 +1,73 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// capabilities an agent reports when it registers
+const (
+	CapabilityDockerProxy = "docker-proxy"
+	CapabilityNodeExec    = "node-exec"
+	CapabilityMetrics     = "metrics"
+)
+
+// Capabilities are the capabilities of this build of the agent.
+var Capabilities = []string{CapabilityDockerProxy, CapabilityNodeExec, CapabilityMetrics}
+
+// RequiredCapabilities are the capabilities the master can't do without.
+var RequiredCapabilities = []string{CapabilityDockerProxy, CapabilityNodeExec}
+
+// CheckAgent tells why an agent can't work with this master, nil when it
+// can. Agents of another major version are incompatible, development builds
+// are compatible with any version.
+func CheckAgent(agentVersion string, capabilities []string) error {
+	if agentVersion == "" {
+		return errors.New("agent does not report its version, upgrade it")
+	}
+
+	var missing []string
+	for _, required := range RequiredCapabilities {
+		found := false
+		for _, c := range capabilities {
+			if c == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("agent %s lacks %s", agentVersion, strings.Join(missing, ", "))
+	}
+
+	agentMajor, ok := major(agentVersion)
+	if !ok {
+		return nil
+	}
+	masterMajor, ok := major(Version)
+	if ok && agentMajor != masterMajor {
+		return fmt.Errorf("agent %s is not compatible with master %s", agentVersion, Version)
+	}
+	return nil
+}
+
+// Outdated tells whether an agent runs another version than the master.
+func Outdated(agentVersion string) bool {
+	return agentVersion != Version
+}
+
+// major parses the major of a version like v1.2.3 or 1.2.3-4-gabcdef.
+func major(v string) (int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	m, _, _ := strings.Cut(v, ".")
+	n, err := strconv.Atoi(m)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
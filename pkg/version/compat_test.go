@@ -0,0 +1,24 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAgent(t *testing.T) {
+	old := Version
+	defer func() { Version = old }()
+	Version = "v1.4.0"
+
+	assert.Error(t, CheckAgent("", nil))
+	assert.NoError(t, CheckAgent("v1.2.0-3-gabcdef", Capabilities))
+	assert.NoError(t, CheckAgent("dev", Capabilities))
+	assert.ErrorContains(t, CheckAgent("v1.2.0", []string{CapabilityMetrics}), "lacks docker-proxy, node-exec")
+	assert.ErrorContains(t, CheckAgent("v2.0.0", Capabilities), "not compatible")
+
+	Version = "dev"
+	assert.NoError(t, CheckAgent("v2.0.0", Capabilities))
+	assert.True(t, Outdated("v2.0.0"))
+	assert.False(t, Outdated("dev"))
+}